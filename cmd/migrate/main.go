@@ -0,0 +1,38 @@
+// The migrate command copies the items of the restaurants table of an older version of the
+// service to the current table, in the current format. It can be run again when interrupted:
+//
+//	go run ./cmd/migrate -from <stack name> -to <stack name>-restaurants
+package main
+
+import (
+	"flag"
+	"github.com/lfroomin/restaurant-serverless/internal/awsConfig"
+	"github.com/lfroomin/restaurant-serverless/internal/dynamo"
+	"log"
+	"os"
+)
+
+func main() {
+	from := flag.String("from", "", "DynamoDB table the items are copied from")
+	to := flag.String("to", os.Getenv("RestaurantsTable"), "DynamoDB table the items are copied to")
+	flag.Parse()
+
+	if *from == "" || *to == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *from == *to {
+		log.Fatal("the items must be copied to another table")
+	}
+
+	cfg, err := awsConfig.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	copied, skipped, err := dynamo.New(cfg, *to).Migrate(dynamo.New(cfg, *from))
+	log.Printf("Copied %d items  skipped %d items already in %s\n", copied, skipped, *to)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,112 @@
+// The server command runs the restaurant API as a local HTTP server, without SAM or Docker.
+// With -storage memory and -geocoder stub it runs fully offline:
+//
+//	go run ./cmd/server -storage memory -geocoder stub
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/lfroomin/restaurant-serverless/controllers"
+	"github.com/lfroomin/restaurant-serverless/internal/awsConfig"
+	"github.com/lfroomin/restaurant-serverless/internal/dynamo"
+	"github.com/lfroomin/restaurant-serverless/internal/event"
+	"github.com/lfroomin/restaurant-serverless/internal/geocode"
+	"github.com/lfroomin/restaurant-serverless/internal/memory"
+	"github.com/lfroomin/restaurant-serverless/internal/middleware"
+	"github.com/lfroomin/restaurant-serverless/internal/outbox"
+	"github.com/lfroomin/restaurant-serverless/internal/policy"
+	"github.com/lfroomin/restaurant-serverless/internal/webhook"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	storage := flag.String("storage", "memory", "restaurant storage: memory or dynamo")
+	geocoder := flag.String("geocoder", "stub", "geocoder: stub or location (AWS Location service)")
+	restaurantsTable := flag.String("table", os.Getenv("RestaurantsTable"), "DynamoDB table, when -storage is dynamo")
+	placeIndex := flag.String("place-index", os.Getenv("LocationPlaceIndex"), "AWS Location place index, when -geocoder is location")
+	minRelevance := flag.Float64("min-relevance", 0, "addresses geocoded with a lower relevance are rejected")
+	flag.Parse()
+
+	a, err := newAPI(*storage, *geocoder, *restaurantsTable, *placeIndex)
+	if err != nil {
+		log.Fatal(err)
+	}
+	a.restaurant.MinRelevance = *minRelevance
+	// Like the middleware, which only authenticates the requests when JwksUrl is set
+	if os.Getenv("JwksUrl") != "" {
+		a.restaurant.Policy, a.menu.Policy, a.review.Policy = policy.Default, policy.Default, policy.Default
+		a.webhook.Policy, a.apiKey.Policy = policy.Default, policy.Default
+	}
+	if a.relay != nil {
+		go a.relay.Run(context.Background(), time.Second)
+	}
+	if a.worker != nil {
+		go a.worker.Run(context.Background(), time.Second)
+	}
+
+	log.Printf("Listening on %s  storage: %s  geocoder: %s\n", *addr, *storage, *geocoder)
+	log.Fatal(http.ListenAndServe(*addr, newRouter(a)))
+}
+
+// api holds the controllers of the API, which share the storage, the storage of the API keys
+// and the rate limits of the middleware, and the relay of the events recorded by the in-memory
+// storage and the worker that delivers them to the webhooks.
+type api struct {
+	restaurant controllers.Restaurant
+	menu       controllers.Menu
+	review     controllers.Review
+	webhook    controllers.Webhook
+	apiKey     controllers.ApiKey
+	store      middleware.Store
+	relay      *outbox.Relay
+	worker     *webhook.Worker
+}
+
+func newAPI(storage, geocoder, restaurantsTable, placeIndex string) (api, error) {
+	a := api{}
+
+	switch storage {
+	case "memory":
+		s := memory.New()
+		a.restaurant.Restaurant, a.menu, a.review = s, controllers.Menu{Restaurant: s, Menu: s}, controllers.Review{Restaurant: s, Review: s}
+		a.webhook = controllers.Webhook{Webhook: s}
+		a.apiKey, a.store = controllers.ApiKey{ApiKey: s}, s
+		// The events are relayed in process and kept in memory, as there is no event bus locally.
+		// The outbox and the webhook deliveries of a DynamoDB table are left to the Lambdas.
+		relay := outbox.New(s, outbox.Publishers{event.NewMemoryPublisher(), webhook.Dispatcher{Store: s}})
+		worker := webhook.NewWorker(s)
+		a.relay, a.worker = &relay, &worker
+	case "dynamo":
+		cfg, err := awsConfig.New()
+		if err != nil {
+			return a, err
+		}
+		s := dynamo.New(cfg, restaurantsTable)
+		a.restaurant.Restaurant, a.menu, a.review = s, controllers.Menu{Restaurant: s, Menu: s}, controllers.Review{Restaurant: s, Review: s}
+		a.webhook = controllers.Webhook{Webhook: s}
+		a.apiKey, a.store = controllers.ApiKey{ApiKey: s}, s
+	default:
+		return a, fmt.Errorf("unknown storage %q", storage)
+	}
+
+	switch geocoder {
+	case "stub":
+		a.restaurant.Location = geocode.Stub{}
+	case "location":
+		cfg, err := awsConfig.New()
+		if err != nil {
+			return a, err
+		}
+		a.restaurant.Location = geocode.CachingGeocoder{Geocoder: geocode.New(cfg, placeIndex), Cache: geocode.NewLRUCache(1000)}
+	default:
+		return a, fmt.Errorf("unknown geocoder %q", geocoder)
+	}
+
+	return a, nil
+}
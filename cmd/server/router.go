@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/base64"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/middleware"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type route struct {
+	method   string
+	resource string
+	handler  middleware.Handler
+}
+
+// router serves the API Gateway resources of template.yaml by adapting
+// net/http requests into API Gateway proxy requests.
+type router struct {
+	routes []route
+}
+
+func newRouter(a api) router {
+	c, m, rv, wh, ak := a.restaurant, a.menu, a.review, a.webhook, a.apiKey
+	routes := []route{
+		{http.MethodPost, "/", c.Create},
+		{http.MethodGet, "/", c.List},
+		{http.MethodGet, "/nearby", c.Nearby},
+		{http.MethodPost, "/geocode/preview", c.GeocodePreview},
+		{http.MethodGet, "/{restaurantId}", c.Read},
+		{http.MethodPost, "/{restaurantId}", c.Update},
+		{http.MethodPatch, "/{restaurantId}", c.Patch},
+		{http.MethodDelete, "/{restaurantId}", c.Delete},
+		{http.MethodGet, "/{restaurantId}/status", c.Status},
+		{http.MethodPost, "/{restaurantId}/menus", m.Create},
+		{http.MethodGet, "/{restaurantId}/menus", m.List},
+		{http.MethodGet, "/{restaurantId}/menus/{menuId}", m.Read},
+		{http.MethodPost, "/{restaurantId}/menus/{menuId}", m.Update},
+		{http.MethodDelete, "/{restaurantId}/menus/{menuId}", m.Delete},
+		{http.MethodPost, "/{restaurantId}/reviews", rv.Create},
+		{http.MethodGet, "/{restaurantId}/reviews", rv.List},
+		{http.MethodGet, "/{restaurantId}/reviews/{reviewId}", rv.Read},
+		{http.MethodDelete, "/{restaurantId}/reviews/{reviewId}", rv.Delete},
+		{http.MethodPost, "/webhooks", wh.Create},
+		{http.MethodGet, "/webhooks", wh.List},
+		{http.MethodGet, "/webhooks/{webhookId}", wh.Read},
+		{http.MethodDelete, "/webhooks/{webhookId}", wh.Delete},
+		{http.MethodGet, "/webhooks/{webhookId}/deliveries", wh.Deliveries},
+		{http.MethodPost, "/apikeys", ak.Create},
+		{http.MethodGet, "/apikeys", ak.List},
+		{http.MethodDelete, "/apikeys/{apiKeyId}", ak.Revoke},
+		{http.MethodPost, "/apikeys/{apiKeyId}/rotate", ak.Rotate},
+	}
+
+	// Like the Lambdas, the handlers are wrapped in the shared middleware, with the API keys and the rate limits of the storage
+	for i := range routes {
+		routes[i].handler = middleware.WrapWithStore(a.store, routes[i].handler)
+	}
+	return router{routes: routes}
+}
+
+func (rt router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log.Printf("%s %s\n", r.Method, r.URL.RequestURI())
+
+	// Like API Gateway, resources without path parameters take precedence
+	var matched []route
+	var pathParameters map[string]string
+	for _, literal := range []bool{true, false} {
+		for _, rte := range rt.routes {
+			if params, ok := matchResource(rte.resource, r.URL.Path); ok && literal == (len(params) == 0) {
+				matched = append(matched, rte)
+				pathParameters = params
+			}
+		}
+		if len(matched) > 0 {
+			break
+		}
+	}
+
+	if len(matched) == 0 {
+		writeResponse(w, httpResponse.NewNotFound("no resource matches the path"))
+		return
+	}
+
+	methods := []string{http.MethodOptions}
+	for _, rte := range matched {
+		if rte.method == r.Method {
+			rt.serve(w, r, rte, pathParameters)
+			return
+		}
+		methods = append(methods, rte.method)
+	}
+	sort.Strings(methods)
+
+	// CORS preflight, which API Gateway answers without calling a function
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ","))
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type,Accept,Authorization,If-Match,If-None-Match,X-Tenant-Id,X-Api-Key")
+		writeResponse(w, httpResponse.New(http.StatusNoContent, nil))
+		return
+	}
+
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeResponse(w, httpResponse.NewProblem(http.StatusMethodNotAllowed, httpResponse.CodeMethodNotAllowed, ""))
+}
+
+func (rt router) serve(w http.ResponseWriter, r *http.Request, rte route, pathParameters map[string]string) {
+	request, err := proxyRequest(r, rte.resource, pathParameters)
+	if err != nil {
+		writeResponse(w, httpResponse.NewBadRequest(err.Error()))
+		return
+	}
+
+	response, err := rte.handler(request)
+	if err != nil || response == nil {
+		// API Gateway responds with 502 and this body when the function fails
+		log.Printf("error from handler: %v\n", err)
+		writeResponse(w, httpResponse.New(http.StatusBadGateway, map[string]string{"message": "Internal server error"}))
+		return
+	}
+
+	writeResponse(w, response)
+}
+
+// matchResource matches the path against an API Gateway resource such as
+// /{restaurantId} and returns the values of its path parameters.
+func matchResource(resource, path string) (map[string]string, bool) {
+	resourceParts := strings.Split(strings.Trim(resource, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(resourceParts) != len(pathParts) {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	for i, part := range resourceParts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			if pathParts[i] == "" {
+				return nil, false
+			}
+			params[strings.Trim(part, "{}")] = pathParts[i]
+		} else if part != pathParts[i] {
+			return nil, false
+		}
+	}
+
+	if len(params) == 0 {
+		return nil, true
+	}
+	return params, true
+}
+
+// proxyRequest converts the HTTP request into the request API Gateway sends to a Lambda proxy integration.
+func proxyRequest(r *http.Request, resource string, pathParameters map[string]string) (events.APIGatewayProxyRequest, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return events.APIGatewayProxyRequest{}, err
+	}
+
+	// Like API Gateway, the source IP has no port, so that it identifies the client
+	sourceIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		sourceIP = r.RemoteAddr
+	}
+
+	request := events.APIGatewayProxyRequest{
+		Resource:                        resource,
+		Path:                            r.URL.Path,
+		HTTPMethod:                      r.Method,
+		Headers:                         map[string]string{},
+		MultiValueHeaders:               map[string][]string(r.Header.Clone()),
+		QueryStringParameters:           map[string]string{},
+		MultiValueQueryStringParameters: map[string][]string(r.URL.Query()),
+		PathParameters:                  pathParameters,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			RequestID:    uuid.NewString(),
+			ResourcePath: resource,
+			HTTPMethod:   r.Method,
+			Path:         r.URL.Path,
+			Identity:     events.APIGatewayRequestIdentity{SourceIP: sourceIP},
+		},
+		Body: string(body),
+	}
+
+	// API Gateway sets the single value maps to the last value
+	for k, v := range request.MultiValueHeaders {
+		request.Headers[k] = v[len(v)-1]
+	}
+	for k, v := range request.MultiValueQueryStringParameters {
+		request.QueryStringParameters[k] = v[len(v)-1]
+	}
+	if r.Host != "" {
+		request.Headers["Host"] = r.Host
+	}
+
+	return request, nil
+}
+
+func writeResponse(w http.ResponseWriter, response *events.APIGatewayProxyResponse) {
+	for k, v := range response.Headers {
+		w.Header().Set(k, v)
+	}
+	for k, vs := range response.MultiValueHeaders {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	if w.Header().Get("Content-Type") == "" && response.Body != "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+
+	body := []byte(response.Body)
+	if response.IsBase64Encoded {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(response.Body); err != nil {
+			log.Printf("error decoding base64 response body: %s\n", err.Error())
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+	}
+
+	w.WriteHeader(response.StatusCode)
+	if _, err := w.Write(body); err != nil {
+		log.Printf("error writing response: %s\n", err.Error())
+	}
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lfroomin/restaurant-serverless/internal/apikey"
+	"github.com/lfroomin/restaurant-serverless/internal/event"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/middleware"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/outbox"
+	"github.com/lfroomin/restaurant-serverless/internal/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_MatchResource(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		resource string
+		path     string
+		params   map[string]string
+		match    bool
+	}{
+		{
+			name:     "root",
+			resource: "/",
+			path:     "/",
+			match:    true,
+		},
+		{
+			name:     "literal",
+			resource: "/nearby",
+			path:     "/nearby",
+			match:    true,
+		},
+		{
+			name:     "path parameter",
+			resource: "/{restaurantId}",
+			path:     "/restId",
+			params:   map[string]string{"restaurantId": "restId"},
+			match:    true,
+		},
+		{
+			name:     "trailing slash",
+			resource: "/{restaurantId}",
+			path:     "/restId/",
+			params:   map[string]string{"restaurantId": "restId"},
+			match:    true,
+		},
+		{
+			name:     "empty path parameter",
+			resource: "/{restaurantId}",
+			path:     "/",
+		},
+		{
+			name:     "too many segments",
+			resource: "/{restaurantId}",
+			path:     "/restId/menus",
+		},
+		{
+			name:     "different literal",
+			resource: "/nearby",
+			path:     "/faraway",
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			params, match := matchResource(tc.resource, tc.path)
+
+			assert.Equal(t, tc.match, match)
+			assert.Equal(t, tc.params, params)
+		})
+	}
+}
+
+func Test_ProxyRequest(t *testing.T) {
+	t.Parallel()
+
+	r := httptest.NewRequest(http.MethodPatch, "/restId?a=1&a=2&b=3", strings.NewReader(`{"name":"name"}`))
+	r.Header.Set("If-Match", `"1"`)
+
+	request, err := proxyRequest(r, "/{restaurantId}", map[string]string{"restaurantId": "restId"})
+	require.NoError(t, err)
+
+	assert.Equal(t, http.MethodPatch, request.HTTPMethod)
+	assert.Equal(t, "/{restaurantId}", request.Resource)
+	assert.Equal(t, "/restId", request.Path)
+	assert.Equal(t, map[string]string{"restaurantId": "restId"}, request.PathParameters)
+	assert.Equal(t, map[string]string{"a": "2", "b": "3"}, request.QueryStringParameters)
+	assert.Equal(t, []string{"1", "2"}, request.MultiValueQueryStringParameters["a"])
+	assert.Equal(t, `"1"`, request.Headers["If-Match"])
+	assert.Equal(t, `{"name":"name"}`, request.Body)
+	assert.NotEmpty(t, request.RequestContext.RequestID)
+	// httptest.NewRequest is from 192.0.2.1:1234
+	assert.Equal(t, "192.0.2.1", request.RequestContext.Identity.SourceIP)
+}
+
+func Test_Router(t *testing.T) {
+	t.Parallel()
+
+	var got events.APIGatewayProxyRequest
+	stub := func(name string) middleware.Handler {
+		return func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+			got = request
+			return &events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: name}, nil
+		}
+	}
+	rt := router{routes: []route{
+		{http.MethodPost, "/", stub("create")},
+		{http.MethodGet, "/nearby", stub("nearby")},
+		{http.MethodGet, "/{restaurantId}", stub("read")},
+		{http.MethodDelete, "/{restaurantId}", stub("delete")},
+	}}
+
+	testCases := []struct {
+		name       string
+		method     string
+		path       string
+		statusCode int
+		body       string
+		allow      string
+	}{
+		{
+			name:       "root",
+			method:     http.MethodPost,
+			path:       "/",
+			statusCode: http.StatusOK,
+			body:       "create",
+		},
+		{
+			name:       "literal before path parameter",
+			method:     http.MethodGet,
+			path:       "/nearby",
+			statusCode: http.StatusOK,
+			body:       "nearby",
+		},
+		{
+			name:       "path parameter",
+			method:     http.MethodDelete,
+			path:       "/restId",
+			statusCode: http.StatusOK,
+			body:       "delete",
+		},
+		{
+			name:       "not found",
+			method:     http.MethodGet,
+			path:       "/restId/photos",
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "method not allowed",
+			method:     http.MethodPut,
+			path:       "/restId",
+			statusCode: http.StatusMethodNotAllowed,
+			allow:      "DELETE, GET, OPTIONS",
+		},
+		{
+			name:       "preflight",
+			method:     http.MethodOptions,
+			path:       "/restId",
+			statusCode: http.StatusNoContent,
+		},
+	}
+
+	for _, tc := range testCases {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+
+		assert.Equal(t, tc.statusCode, w.Code, tc.name)
+		assert.Equal(t, tc.allow, w.Header().Get("Allow"), tc.name)
+		if tc.body != "" {
+			assert.Equal(t, tc.body, w.Body.String(), tc.name)
+			assert.Equal(t, tc.path, got.Path, tc.name)
+		}
+	}
+}
+
+func Test_RouterHandlerError(t *testing.T) {
+	t.Parallel()
+
+	rt := router{routes: []route{
+		{http.MethodGet, "/", func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+			return nil, assert.AnError
+		}},
+	}}
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+}
+
+// Test_Server runs the API offline with the in-memory storage and the stub geocoder.
+func Test_Server(t *testing.T) {
+	t.Parallel()
+
+	a, err := newAPI("memory", "stub", "", "")
+	require.NoError(t, err)
+	server := httptest.NewServer(newRouter(a))
+	defer server.Close()
+
+	do := func(method, path, body string, headers map[string]string) (*http.Response, string) {
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := server.Client().Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, string(b)
+	}
+
+	// The requests are validated against the OpenAPI spec
+	resp, body := do(http.MethodPost, "/", `{"name":"","cuisine":"Mexican"}`, nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, httpResponse.ProblemContentType, resp.Header.Get("Content-Type"))
+	problem := model.Problem{}
+	require.NoError(t, json.Unmarshal([]byte(body), &problem))
+	assert.Equal(t, []model.Violation{{Field: "cuisine", Message: "is not a known field"}, {Field: "name", Message: "minimum string length is 1"}}, *problem.Violations)
+	// The instance is the request ID
+	require.NotNil(t, problem.Instance)
+	assert.NotEmpty(t, *problem.Instance)
+
+	resp, body = do(http.MethodPost, "/", `{"name":"Taqueria","address":{"line1":"123 Pike St","city":"Seattle"}}`, nil)
+	require.Equal(t, http.StatusCreated, resp.StatusCode, body)
+	assert.Equal(t, `"1"`, resp.Header.Get("ETag"))
+	created := model.Restaurant{}
+	require.NoError(t, json.Unmarshal([]byte(body), &created))
+	require.NotNil(t, created.Id)
+	require.NotNil(t, created.Address.Location.Geocode)
+
+	resp, body = do(http.MethodGet, "/"+*created.Id, "", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+	resp, _ = do(http.MethodGet, "/"+*created.Id, "", map[string]string{"If-None-Match": `"1"`})
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	resp, body = do(http.MethodPatch, "/"+*created.Id, `{"name":"Tacos"}`, map[string]string{"Content-Type": "application/merge-patch+json", "If-Match": `"1"`})
+	assert.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Equal(t, `"2"`, resp.Header.Get("ETag"))
+
+	resp, body = do(http.MethodPatch, "/"+*created.Id, `{"openingHours":{"weekly":[{"day":"friday","open":"18:00","close":"02:00"}]}}`, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+	resp, body = do(http.MethodGet, "/"+*created.Id+"/status?at=2024-03-09T09:00:00Z", "", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Contains(t, body, `"open":true`)
+	assert.Contains(t, body, `"nextClose":"2024-03-09T02:00:00-08:00"`)
+
+	resp, body = do(http.MethodGet, "/nearby?lat=47.6062&lon=-122.3321&radiusKm=10", "", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Contains(t, body, *created.Id)
+
+	resp, body = do(http.MethodGet, "/?limit=10", "", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Contains(t, body, "Tacos")
+
+	resp, body = do(http.MethodPost, "/"+*created.Id+"/menus", `{"name":"Lunch","sections":[{"name":"Tacos","items":[{"name":"Al pastor","price":{"amount":"3.50","currency":"USD"}}]}]}`, nil)
+	require.Equal(t, http.StatusCreated, resp.StatusCode, body)
+	menu := model.Menu{}
+	require.NoError(t, json.Unmarshal([]byte(body), &menu))
+	require.NotNil(t, menu.Id)
+
+	resp, body = do(http.MethodGet, "/"+*created.Id+"/menus", "", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Contains(t, body, "Al pastor")
+
+	for _, review := range []string{`{"rating":5,"author":"Ana"}`, `{"rating":4,"author":"Bo"}`} {
+		resp, body = do(http.MethodPost, "/"+*created.Id+"/reviews", review, nil)
+		require.Equal(t, http.StatusCreated, resp.StatusCode, body)
+	}
+
+	resp, body = do(http.MethodGet, "/"+*created.Id+"/reviews?sort=highest&limit=1", "", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Contains(t, body, `"author":"Ana"`)
+	assert.Contains(t, body, `"nextToken"`)
+
+	resp, body = do(http.MethodGet, "/"+*created.Id, "", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Contains(t, body, `"rating":{"average":4.5,"count":2,"histogram":{"1":0,"2":0,"3":0,"4":1,"5":1}}`)
+
+	resp, body = do(http.MethodDelete, "/"+*created.Id, "", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, body)
+
+	resp, _ = do(http.MethodGet, "/"+*created.Id+"/menus/"+*menu.Id, "", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, body = do(http.MethodGet, "/"+*created.Id, "", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Contains(t, body, `"code":"NOT_FOUND"`)
+}
+
+func Test_NewAPI(t *testing.T) {
+	t.Parallel()
+
+	_, err := newAPI("files", "stub", "", "")
+	assert.EqualError(t, err, `unknown storage "files"`)
+
+	_, err = newAPI("memory", "google", "", "")
+	assert.EqualError(t, err, `unknown geocoder "google"`)
+
+	a, err := newAPI("memory", "stub", "", "")
+	require.NoError(t, err)
+	require.NotNil(t, a.relay)
+	require.IsType(t, outbox.Publishers{}, a.relay.Publisher)
+	assert.IsType(t, &event.MemoryPublisher{}, a.relay.Publisher.(outbox.Publishers)[0])
+	assert.IsType(t, webhook.Dispatcher{}, a.relay.Publisher.(outbox.Publishers)[1])
+	assert.NotNil(t, a.worker)
+}
+
+func Test_ServerWebhooks(t *testing.T) {
+	t.Parallel()
+
+	a, err := newAPI("memory", "stub", "", "")
+	require.NoError(t, err)
+	server := httptest.NewServer(newRouter(a))
+	defer server.Close()
+
+	received := make(chan *http.Request, 10)
+	bodies := make(chan []byte, 10)
+	receiver := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- b
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+	// The worker only connects to public addresses, and the receiver has a test certificate
+	a.worker.Client = receiver.Client()
+
+	resp, err := server.Client().Post(server.URL+"/webhooks", "application/json",
+		strings.NewReader(`{"url":"`+receiver.URL+`","eventTypes":["RestaurantCreated"],"secret":"0123456789abcdef"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	hook := model.Webhook{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&hook))
+
+	resp, err = server.Client().Post(server.URL+"/", "application/json", strings.NewReader(`{"name":"Taqueria"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	// The relay and the worker run in the background in main
+	_, err = a.relay.Drain()
+	require.NoError(t, err)
+	delivered, err := a.worker.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+
+	r, body := <-received, <-bodies
+	assert.Equal(t, "RestaurantCreated", r.Header.Get(webhook.EventHeader))
+	assert.NoError(t, webhook.Verify("0123456789abcdef", r.Header.Get(webhook.TimestampHeader), r.Header.Get(webhook.SignatureHeader), body, time.Now(), webhook.DefaultTolerance))
+	assert.Contains(t, string(body), `"name":"Taqueria"`)
+
+	resp, err = server.Client().Get(server.URL + "/webhooks/" + *hook.Id + "/deliveries")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	deliveries := model.WebhookDeliveryList{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&deliveries))
+	require.Len(t, deliveries.Items, 1)
+	assert.Equal(t, model.Delivered, deliveries.Items[0].Status)
+	assert.Equal(t, r.Header.Get(webhook.IdHeader), deliveries.Items[0].Id)
+}
+
+func Test_ServerApiKeys(t *testing.T) {
+	t.Parallel()
+
+	a, err := newAPI("memory", "stub", "", "")
+	require.NoError(t, err)
+	server := httptest.NewServer(newRouter(a))
+	defer server.Close()
+
+	do := func(method, path, key, body string) *http.Response {
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if key != "" {
+			req.Header.Set(apikey.Header, key)
+		}
+		resp, err := server.Client().Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	resp := do(http.MethodPost, "/apikeys", "", `{"name":"partner","scope":"read","quota":{"limit":2,"period":"day"}}`)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	issued := model.ApiKey{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&issued))
+	require.NotNil(t, issued.Key)
+
+	resp = do(http.MethodGet, "/", *issued.Key, "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "2", resp.Header.Get("X-Quota-Limit"))
+	assert.Equal(t, "1", resp.Header.Get("X-Quota-Remaining"))
+
+	// A read key cannot write, which is not counted
+	resp = do(http.MethodPost, "/", *issued.Key, `{"name":"Taqueria"}`)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp = do(http.MethodGet, "/", *issued.Key, "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "0", resp.Header.Get("X-Quota-Remaining"))
+
+	resp = do(http.MethodGet, "/", *issued.Key, "")
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+
+	// The old key is rejected once the key is rotated
+	resp = do(http.MethodPost, "/apikeys/"+*issued.Id+"/rotate", "", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	rotated := model.ApiKey{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&rotated))
+	require.NotNil(t, rotated.Key)
+	assert.NotEqual(t, *issued.Key, *rotated.Key)
+
+	resp = do(http.MethodGet, "/", *issued.Key, "")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = do(http.MethodDelete, "/apikeys/"+*issued.Id, "", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = do(http.MethodGet, "/", *rotated.Key, "")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
@@ -0,0 +1,235 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/google/uuid"
+	"github.com/lfroomin/restaurant-serverless/internal/apikey"
+	"github.com/lfroomin/restaurant-serverless/internal/dynamo"
+	"github.com/lfroomin/restaurant-serverless/internal/httpRequest"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/policy"
+	"github.com/lfroomin/restaurant-serverless/internal/print"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const maxApiKeyNameLength = 100
+
+type ApiKeyStorer interface {
+	SaveApiKey(k apikey.Key) error
+	GetApiKey(apiKeyId string) (apikey.Key, bool, error)
+	ListApiKeys(tenantId string) ([]apikey.Key, error)
+	RotateApiKey(apiKeyId, hash string, rotated time.Time) (apikey.Key, error)
+	RevokeApiKey(apiKeyId string, revoked time.Time) (apikey.Key, error)
+}
+
+// ApiKey serves the API keys of the tenant, under /apikeys. Only the admins of the tenant,
+// with a bearer token, manage them.
+type ApiKey struct {
+	ApiKey ApiKeyStorer
+	// Policy authorizes each action. When it is nil every action is allowed.
+	Policy Authorizer
+}
+
+// New creates the controller. The API keys are stored in the restaurants table.
+func (a ApiKey) New(cfg aws.Config, restaurantsTable string) ApiKey {
+	return ApiKey{ApiKey: dynamo.New(cfg, restaurantsTable), Policy: policy.Default}
+}
+
+// Create issues an API key. Its key is only returned by Create and Rotate.
+func (a ApiKey) Create(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	print.Request(request)
+
+	tenantId, resp := a.authorize(request)
+	if resp != nil {
+		return resp, nil
+	}
+
+	k := model.ApiKey{}
+	if resp := httpRequest.DecodeJSON(request, &k); resp != nil {
+		return resp, nil
+	}
+
+	if err := validateApiKey(k); err != nil {
+		return httpResponse.NewBadRequest(err.Error()), nil
+	}
+
+	id := uuid.NewString()
+	created := time.Now().UTC().Truncate(time.Millisecond)
+	k.Id, k.TenantId, k.Created = &id, &tenantId, &created
+	k.Key, k.Rotated, k.Revoked = nil, nil, nil
+	log.Printf("create API key tenantId: %s  apiKeyId: %s  scope: %s\n", tenantId, id, k.Scope)
+
+	key, err := apikey.NewKey(id)
+	if err != nil {
+		return httpResponse.NewServerError(err), nil
+	}
+
+	if err := a.ApiKey.SaveApiKey(apikey.Key{ApiKey: k, Hash: apikey.Hash(key)}); err != nil {
+		return storageError(err, "API key"), nil
+	}
+
+	k.Key = &key
+	return httpResponse.New(http.StatusCreated, k), nil
+}
+
+// List returns the API keys of the tenant, the revoked ones included, without their keys.
+func (a ApiKey) List(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	print.Request(request)
+
+	tenantId, resp := a.authorize(request)
+	if resp != nil {
+		return resp, nil
+	}
+
+	log.Printf("list API keys tenantId: %s\n", tenantId)
+
+	keys, err := a.ApiKey.ListApiKeys(tenantId)
+	if err != nil {
+		return httpResponse.NewServerError(err), nil
+	}
+
+	items := make([]model.ApiKey, 0, len(keys))
+	for _, k := range keys {
+		items = append(items, k.ApiKey)
+	}
+
+	return httpResponse.New(http.StatusOK, model.ApiKeyList{Items: items}), nil
+}
+
+// Rotate replaces the key of the API key with a new one, which it returns. The old key is
+// rejected from then on. A revoked API key cannot be rotated.
+func (a ApiKey) Rotate(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	print.Request(request)
+
+	tenantId, resp := a.authorize(request)
+	if resp != nil {
+		return resp, nil
+	}
+
+	apiKeyId := request.PathParameters["apiKeyId"]
+
+	// Validate input
+	if apiKeyId == "" {
+		return httpResponse.NewBadRequest("apiKeyId is empty"), nil
+	}
+
+	log.Printf("rotate API key tenantId: %s  apiKeyId: %s\n", tenantId, apiKeyId)
+
+	if resp := a.exists(tenantId, apiKeyId); resp != nil {
+		return resp, nil
+	}
+
+	key, err := apikey.NewKey(apiKeyId)
+	if err != nil {
+		return httpResponse.NewServerError(err), nil
+	}
+
+	k, err := a.ApiKey.RotateApiKey(apiKeyId, apikey.Hash(key), time.Now().UTC().Truncate(time.Millisecond))
+	if errors.Is(err, storage.ErrConflict) {
+		return httpResponse.NewProblem(http.StatusConflict, httpResponse.CodeConflict, "the API key is revoked"), nil
+	}
+	if err != nil {
+		return storageError(err, "API key"), nil
+	}
+
+	k.Key = &key
+	return httpResponse.New(http.StatusOK, k.ApiKey), nil
+}
+
+// Revoke rejects the key of the API key from then on. The revoked API key is kept, so that
+// it is still listed.
+func (a ApiKey) Revoke(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	print.Request(request)
+
+	tenantId, resp := a.authorize(request)
+	if resp != nil {
+		return resp, nil
+	}
+
+	apiKeyId := request.PathParameters["apiKeyId"]
+
+	// Validate input
+	if apiKeyId == "" {
+		return httpResponse.NewBadRequest("apiKeyId is empty"), nil
+	}
+
+	log.Printf("revoke API key tenantId: %s  apiKeyId: %s\n", tenantId, apiKeyId)
+
+	stored, exists, err := a.ApiKey.GetApiKey(apiKeyId)
+	if err != nil {
+		return httpResponse.NewServerError(err), nil
+	}
+	if !exists || !ofTenant(stored, tenantId) {
+		return httpResponse.NewNotFound("the API key was not found"), nil
+	}
+	// Revoking again keeps the time it was first revoked
+	if stored.Revoked != nil {
+		return httpResponse.New(http.StatusOK, stored.ApiKey), nil
+	}
+
+	k, err := a.ApiKey.RevokeApiKey(apiKeyId, time.Now().UTC().Truncate(time.Millisecond))
+	if err != nil {
+		return storageError(err, "API key"), nil
+	}
+
+	return httpResponse.New(http.StatusOK, k.ApiKey), nil
+}
+
+// authorize returns the tenant of the request, or the error response when the caller may
+// not manage its API keys.
+func (a ApiKey) authorize(request events.APIGatewayProxyRequest) (string, *events.APIGatewayProxyResponse) {
+	tenantId, resp := tenantOf(request)
+	if resp != nil {
+		return "", resp
+	}
+	if resp := authorize(a.Policy, request, policy.ActionManageApiKeys, nil, nil); resp != nil {
+		return "", resp
+	}
+	return tenantId, nil
+}
+
+// exists returns the 404 response when the API key does not exist, or belongs to another
+// tenant, which must not learn that it exists.
+func (a ApiKey) exists(tenantId, apiKeyId string) *events.APIGatewayProxyResponse {
+	k, exists, err := a.ApiKey.GetApiKey(apiKeyId)
+	if err != nil {
+		return httpResponse.NewServerError(err)
+	}
+	if !exists || !ofTenant(k, tenantId) {
+		return httpResponse.NewNotFound("the API key was not found")
+	}
+	return nil
+}
+
+func ofTenant(k apikey.Key, tenantId string) bool {
+	return k.TenantId != nil && *k.TenantId == tenantId
+}
+
+func validateApiKey(k model.ApiKey) error {
+	if strings.TrimSpace(k.Name) == "" {
+		return errors.New("name is empty")
+	}
+	if len(k.Name) > maxApiKeyNameLength {
+		return fmt.Errorf("name must have at most %d characters", maxApiKeyNameLength)
+	}
+
+	if k.Scope != model.Read && k.Scope != model.Write {
+		return fmt.Errorf("scope %q is not %s or %s", k.Scope, model.Read, model.Write)
+	}
+
+	if k.Quota.Limit < 1 {
+		return errors.New("quota limit must be at least 1")
+	}
+	if k.Quota.Period != model.Day && k.Quota.Period != model.Month {
+		return fmt.Errorf("quota period %q is not %s or %s", k.Quota.Period, model.Day, model.Month)
+	}
+	return nil
+}
@@ -0,0 +1,472 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lfroomin/restaurant-serverless/internal/apikey"
+	"github.com/lfroomin/restaurant-serverless/internal/auth"
+	"github.com/lfroomin/restaurant-serverless/internal/awsConfig"
+	"github.com/lfroomin/restaurant-serverless/internal/dynamo"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/policy"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ApiKeyNew(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := awsConfig.New()
+	require.NoError(t, err)
+
+	a := ApiKey{}.New(cfg, "RestaurantsTable")
+
+	assert.IsType(t, dynamo.RestaurantStorage{}, a.ApiKey)
+	assert.Equal(t, "RestaurantsTable", a.ApiKey.(dynamo.RestaurantStorage).Table)
+	assert.Equal(t, policy.Default, a.Policy)
+}
+
+func Test_ApiKeyCreate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		body         string
+		stub         apiKeyStorerStub
+		responseCode int
+		responseBody string
+	}{
+		{
+			name:         "happy path",
+			body:         `{"name":"partner","scope":"read","quota":{"limit":1000,"period":"day"}}`,
+			responseCode: http.StatusCreated,
+		},
+		{
+			name:         "id, tenant, key and created are set by the service",
+			body:         `{"id":"myId","tenantId":"tenant2","key":"myKey","created":"2020-01-01T00:00:00Z","revoked":"2020-01-01T00:00:00Z","name":"partner","scope":"read","quota":{"limit":1000,"period":"day"}}`,
+			responseCode: http.StatusCreated,
+		},
+		{
+			name:         "no name",
+			body:         `{"name":" ","scope":"read","quota":{"limit":1000,"period":"day"}}`,
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "name is empty"),
+		},
+		{
+			name:         "name too long",
+			body:         `{"name":"` + strings.Repeat("a", 101) + `","scope":"read","quota":{"limit":1000,"period":"day"}}`,
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "name must have at most 100 characters"),
+		},
+		{
+			name:         "unknown scope",
+			body:         `{"name":"partner","scope":"admin","quota":{"limit":1000,"period":"day"}}`,
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "scope \"admin\" is not read or write"),
+		},
+		{
+			name:         "no quota",
+			body:         `{"name":"partner","scope":"write"}`,
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "quota limit must be at least 1"),
+		},
+		{
+			name:         "unknown quota period",
+			body:         `{"name":"partner","scope":"write","quota":{"limit":1000,"period":"week"}}`,
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "quota period \"week\" is not day or month"),
+		},
+		{
+			name:         "storage error",
+			body:         `{"name":"partner","scope":"read","quota":{"limit":1000,"period":"day"}}`,
+			stub:         apiKeyStorerStub{error: "an error occurred"},
+			responseCode: http.StatusInternalServerError,
+			responseBody: internalError,
+		},
+		{
+			name:         "empty request body",
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeMalformedBody, "the request body is empty"),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			stub := tc.stub
+			stub.saved = &apikey.Key{}
+			a := ApiKey{ApiKey: &stub}
+
+			start := time.Now().Add(-time.Second)
+			resp, _ := a.Create(events.APIGatewayProxyRequest{Body: tc.body, Headers: map[string]string{"X-Tenant-Id": "tenant1"}})
+
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			if tc.responseCode != http.StatusCreated {
+				assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
+				return
+			}
+
+			created := model.ApiKey{}
+			require.NoError(t, json.Unmarshal([]byte(resp.Body), &created))
+			require.NotNil(t, created.Id)
+			assert.NotEqual(t, "myId", *created.Id)
+			assert.Equal(t, "tenant1", *created.TenantId)
+			require.NotNil(t, created.Created)
+			assert.True(t, created.Created.After(start))
+			assert.Nil(t, created.Revoked)
+			assert.Equal(t, model.ApiKeyQuota{Limit: 1000, Period: model.Day}, created.Quota)
+
+			// The key is only returned, its hash is stored
+			require.NotNil(t, created.Key)
+			assert.True(t, strings.HasPrefix(*created.Key, *created.Id+"."))
+			assert.Nil(t, stub.saved.Key)
+			assert.Equal(t, apikey.Hash(*created.Key), stub.saved.Hash)
+			assert.Equal(t, *created.Id, *stub.saved.Id)
+		})
+	}
+}
+
+func Test_ApiKeyList(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		tenantId     string
+		stub         apiKeyStorerStub
+		responseCode int
+		responseBody string
+	}{
+		{
+			name:         "happy path",
+			tenantId:     "tenant1",
+			stub:         apiKeyStorerStub{key: anApiKey("key1", "tenant1")},
+			responseCode: http.StatusOK,
+			responseBody: apiKeyListJson(anApiKey("key1", "tenant1").ApiKey),
+		},
+		{
+			name:         "other tenant",
+			tenantId:     "tenant2",
+			stub:         apiKeyStorerStub{key: anApiKey("key1", "tenant1")},
+			responseCode: http.StatusOK,
+			responseBody: apiKeyListJson(),
+		},
+		{
+			name:         "storage error",
+			tenantId:     "tenant1",
+			stub:         apiKeyStorerStub{error: "an error occurred"},
+			responseCode: http.StatusInternalServerError,
+			responseBody: internalError,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			a := ApiKey{ApiKey: &tc.stub}
+
+			resp, _ := a.List(events.APIGatewayProxyRequest{Headers: map[string]string{"X-Tenant-Id": tc.tenantId}})
+
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
+			assert.NotContains(t, resp.Body, "hash")
+		})
+	}
+}
+
+func Test_ApiKeyRotate(t *testing.T) {
+	t.Parallel()
+
+	revoked := anApiKey("key1", "tenant1")
+	revokedAt := time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)
+	revoked.Revoked, revoked.Hash = &revokedAt, ""
+
+	testCases := []struct {
+		name         string
+		apiKeyId     string
+		stub         apiKeyStorerStub
+		responseCode int
+		responseBody string
+	}{
+		{
+			name:         "happy path",
+			apiKeyId:     "key1",
+			stub:         apiKeyStorerStub{key: anApiKey("key1", "tenant1")},
+			responseCode: http.StatusOK,
+		},
+		{
+			name:         "API key not found",
+			apiKeyId:     "key2",
+			stub:         apiKeyStorerStub{key: anApiKey("key1", "tenant1")},
+			responseCode: http.StatusNotFound,
+			responseBody: problem(http.StatusNotFound, httpResponse.CodeNotFound, "the API key was not found"),
+		},
+		{
+			name:         "API key of another tenant",
+			apiKeyId:     "key1",
+			stub:         apiKeyStorerStub{key: anApiKey("key1", "tenant2")},
+			responseCode: http.StatusNotFound,
+			responseBody: problem(http.StatusNotFound, httpResponse.CodeNotFound, "the API key was not found"),
+		},
+		{
+			name:         "revoked API key",
+			apiKeyId:     "key1",
+			stub:         apiKeyStorerStub{key: revoked},
+			responseCode: http.StatusConflict,
+			responseBody: problem(http.StatusConflict, httpResponse.CodeConflict, "the API key is revoked"),
+		},
+		{
+			name:         "storage error",
+			apiKeyId:     "key1",
+			stub:         apiKeyStorerStub{error: "an error occurred"},
+			responseCode: http.StatusInternalServerError,
+			responseBody: internalError,
+		},
+		{
+			name:         "apiKeyId empty",
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "apiKeyId is empty"),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			a := ApiKey{ApiKey: &tc.stub}
+
+			resp, _ := a.Rotate(events.APIGatewayProxyRequest{
+				PathParameters: map[string]string{"apiKeyId": tc.apiKeyId},
+				Headers:        map[string]string{"X-Tenant-Id": "tenant1"},
+			})
+
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			if tc.responseCode != http.StatusOK {
+				assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
+				return
+			}
+
+			rotated := model.ApiKey{}
+			require.NoError(t, json.Unmarshal([]byte(resp.Body), &rotated))
+			require.NotNil(t, rotated.Key)
+			require.NotNil(t, rotated.Rotated)
+			assert.Equal(t, apikey.Hash(*rotated.Key), tc.stub.key.Hash)
+			assert.Equal(t, "partner key1", rotated.Name)
+		})
+	}
+}
+
+func Test_ApiKeyRevoke(t *testing.T) {
+	t.Parallel()
+
+	revoked := anApiKey("key1", "tenant1")
+	revokedAt := time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)
+	revoked.Revoked, revoked.Hash = &revokedAt, ""
+
+	testCases := []struct {
+		name         string
+		apiKeyId     string
+		stub         apiKeyStorerStub
+		responseCode int
+		responseBody string
+		revoked      *time.Time
+	}{
+		{
+			name:         "happy path",
+			apiKeyId:     "key1",
+			stub:         apiKeyStorerStub{key: anApiKey("key1", "tenant1")},
+			responseCode: http.StatusOK,
+		},
+		{
+			name:         "revoked API key",
+			apiKeyId:     "key1",
+			stub:         apiKeyStorerStub{key: revoked},
+			responseCode: http.StatusOK,
+			revoked:      &revokedAt,
+		},
+		{
+			name:         "API key of another tenant",
+			apiKeyId:     "key1",
+			stub:         apiKeyStorerStub{key: anApiKey("key1", "tenant2")},
+			responseCode: http.StatusNotFound,
+			responseBody: problem(http.StatusNotFound, httpResponse.CodeNotFound, "the API key was not found"),
+		},
+		{
+			name:         "storage error",
+			apiKeyId:     "key1",
+			stub:         apiKeyStorerStub{error: "an error occurred"},
+			responseCode: http.StatusInternalServerError,
+			responseBody: internalError,
+		},
+		{
+			name:         "apiKeyId empty",
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "apiKeyId is empty"),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			a := ApiKey{ApiKey: &tc.stub}
+
+			resp, _ := a.Revoke(events.APIGatewayProxyRequest{
+				PathParameters: map[string]string{"apiKeyId": tc.apiKeyId},
+				Headers:        map[string]string{"X-Tenant-Id": "tenant1"},
+			})
+
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			if tc.responseCode != http.StatusOK {
+				assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
+				return
+			}
+
+			k := model.ApiKey{}
+			require.NoError(t, json.Unmarshal([]byte(resp.Body), &k))
+			require.NotNil(t, k.Revoked)
+			if tc.revoked != nil {
+				assert.Equal(t, *tc.revoked, *k.Revoked)
+			}
+			assert.Empty(t, tc.stub.key.Hash)
+		})
+	}
+}
+
+func Test_ApiKeyAuthorize(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		claims       *auth.Claims
+		responseCode int
+		reason       policy.Reason
+	}{
+		{
+			name:         "admin",
+			claims:       &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "user1"}, Roles: []string{policy.RoleAdmin}},
+			responseCode: http.StatusOK,
+		},
+		{
+			name:         "not an admin",
+			claims:       &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "user1"}},
+			responseCode: http.StatusForbidden,
+			reason:       policy.ReasonAdminRequired,
+		},
+		{
+			name:         "anonymous",
+			responseCode: http.StatusForbidden,
+			reason:       policy.ReasonAuthenticationRequired,
+		},
+		{
+			name:         "write API key",
+			claims:       &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "apikey:key1"}, ApiKeyId: "key1"},
+			responseCode: http.StatusForbidden,
+			reason:       policy.ReasonBearerTokenRequired,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			a := ApiKey{ApiKey: &apiKeyStorerStub{}, Policy: policy.Default}
+
+			request := events.APIGatewayProxyRequest{}
+			if tc.claims != nil {
+				request = auth.WithClaims(request, *tc.claims)
+			}
+
+			resp, err := a.List(request)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.responseCode, resp.StatusCode, resp.Body)
+			if tc.reason != "" {
+				problem := model.Problem{}
+				require.NoError(t, json.Unmarshal([]byte(resp.Body), &problem))
+				require.NotNil(t, problem.Reason)
+				assert.Equal(t, string(tc.reason), *problem.Reason)
+			}
+		})
+	}
+}
+
+func anApiKey(id, tenantId string) apikey.Key {
+	created := time.Date(2024, 3, 8, 18, 0, 0, 0, time.UTC)
+	return apikey.Key{
+		ApiKey: model.ApiKey{
+			Id:       &id,
+			Name:     "partner " + id,
+			Scope:    model.Read,
+			Quota:    model.ApiKeyQuota{Limit: 100, Period: model.Day},
+			TenantId: &tenantId,
+			Created:  &created,
+		},
+		Hash: "hash of " + id,
+	}
+}
+
+func apiKeyListJson(keys ...model.ApiKey) string {
+	if keys == nil {
+		keys = []model.ApiKey{}
+	}
+	b, _ := json.Marshal(model.ApiKeyList{Items: keys})
+	return string(b)
+}
+
+// apiKeyStorerStub stores one API key, and the API key saved by SaveApiKey in saved.
+type apiKeyStorerStub struct {
+	key   apikey.Key
+	saved *apikey.Key
+	error string
+}
+
+func (s *apiKeyStorerStub) SaveApiKey(k apikey.Key) error {
+	if s.error != "" {
+		return errors.New(s.error)
+	}
+	*s.saved = k
+	return nil
+}
+
+func (s *apiKeyStorerStub) GetApiKey(apiKeyId string) (apikey.Key, bool, error) {
+	if s.error != "" {
+		return apikey.Key{}, false, errors.New(s.error)
+	}
+	if s.key.Id == nil || *s.key.Id != apiKeyId {
+		return apikey.Key{}, false, nil
+	}
+	return s.key, true, nil
+}
+
+func (s *apiKeyStorerStub) ListApiKeys(tenantId string) ([]apikey.Key, error) {
+	if s.error != "" {
+		return nil, errors.New(s.error)
+	}
+	if s.key.Id == nil || *s.key.TenantId != tenantId {
+		return []apikey.Key{}, nil
+	}
+	return []apikey.Key{s.key}, nil
+}
+
+func (s *apiKeyStorerStub) RotateApiKey(apiKeyId, hash string, rotated time.Time) (apikey.Key, error) {
+	if s.key.Hash == "" {
+		return apikey.Key{}, storage.ErrConflict
+	}
+	s.key.Hash, s.key.Rotated = hash, &rotated
+	return s.key, nil
+}
+
+func (s *apiKeyStorerStub) RevokeApiKey(apiKeyId string, revoked time.Time) (apikey.Key, error) {
+	s.key.Hash, s.key.Revoked = "", &revoked
+	return s.key, nil
+}
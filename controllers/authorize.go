@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lfroomin/restaurant-serverless/internal/auth"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/policy"
+	"log"
+)
+
+// Authorizer decides whether the caller of a request may perform an action on a restaurant,
+// or manage the API keys.
+type Authorizer interface {
+	Evaluate(r policy.Request) policy.Decision
+}
+
+var forbiddenDetails = map[policy.Reason]string{
+	policy.ReasonAuthenticationRequired: "anonymous callers can only read restaurants",
+	policy.ReasonAdminRequired:          "only admins can do this",
+	policy.ReasonNotOwner:               "only the owners of the restaurant can update it",
+	policy.ReasonOwnersChangeForbidden:  "only admins can change the owners of a restaurant",
+	policy.ReasonReadOnlyApiKey:         "the API key is read-only",
+	policy.ReasonBearerTokenRequired:    "the API keys are managed with a bearer token, not an API key",
+}
+
+// principal returns the caller of the request, from the claims of its bearer token or API key.
+func principal(request events.APIGatewayProxyRequest) policy.Principal {
+	claims, ok := auth.FromRequest(request)
+	if !ok {
+		return policy.Principal{}
+	}
+	return policy.Principal{Subject: claims.Subject, Roles: claims.Roles, ApiKey: claims.ApiKeyId != ""}
+}
+
+// authorize returns the 403 response when the policy denies the action on the stored
+// restaurant, which changed is the update of. Without a policy every action is allowed.
+func (r Restaurant) authorize(request events.APIGatewayProxyRequest, action policy.Action, stored, changed *model.Restaurant) *events.APIGatewayProxyResponse {
+	return authorize(r.Policy, request, action, stored, changed)
+}
+
+func authorize(authorizer Authorizer, request events.APIGatewayProxyRequest, action policy.Action, stored, changed *model.Restaurant) *events.APIGatewayProxyResponse {
+	if authorizer == nil {
+		return nil
+	}
+
+	p := principal(request)
+	decision := authorizer.Evaluate(policy.Request{Principal: p, Action: action, Restaurant: stored, Changed: changed})
+	if decision.Allow {
+		return nil
+	}
+
+	log.Printf("forbidden subject: %s  action: %s  policy: %s  reason: %s\n", p.Subject, action, decision.Policy, decision.Reason)
+	detail, ok := forbiddenDetails[decision.Reason]
+	if !ok {
+		detail = "the request is not allowed"
+	}
+	return httpResponse.NewForbidden(string(decision.Reason), detail)
+}
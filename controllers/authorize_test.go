@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lfroomin/restaurant-serverless/internal/auth"
+	"github.com/lfroomin/restaurant-serverless/internal/auth/authtest"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/middleware"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func Test_Authorize(t *testing.T) {
+	t.Parallel()
+
+	restId, restName := "Rest1", "Rest 1"
+	ownerIds := []string{"owner1", "owner2"}
+	admin := &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "admin1"}, Roles: []string{policy.RoleAdmin}}
+	owner := &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "owner2"}}
+	other := &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "user1"}}
+
+	testCases := []struct {
+		name         string
+		action       func(Restaurant, events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error)
+		claims       *auth.Claims
+		body         string
+		contentType  string
+		responseCode int
+		reason       policy.Reason
+		ownerIds     []string
+	}{
+		{
+			name:         "anonymous read",
+			action:       Restaurant.Read,
+			responseCode: http.StatusOK,
+			ownerIds:     ownerIds,
+		},
+		{
+			name:         "anonymous list",
+			action:       Restaurant.List,
+			responseCode: http.StatusOK,
+		},
+		{
+			name:         "anonymous create",
+			action:       Restaurant.Create,
+			body:         `{"name":"Rest 1"}`,
+			responseCode: http.StatusForbidden,
+			reason:       policy.ReasonAuthenticationRequired,
+		},
+		{
+			name:         "user create",
+			action:       Restaurant.Create,
+			claims:       other,
+			body:         `{"name":"Rest 1"}`,
+			responseCode: http.StatusForbidden,
+			reason:       policy.ReasonAdminRequired,
+		},
+		{
+			name:         "admin create",
+			action:       Restaurant.Create,
+			claims:       admin,
+			body:         `{"name":"Rest 1","ownerIds":["owner1"]}`,
+			responseCode: http.StatusCreated,
+			ownerIds:     []string{"owner1"},
+		},
+		{
+			name:         "owner update keeps the owners",
+			action:       Restaurant.Update,
+			claims:       owner,
+			body:         `{"id":"Rest1","name":"Rest 2"}`,
+			responseCode: http.StatusOK,
+			ownerIds:     ownerIds,
+		},
+		{
+			name:         "owner update with the owners in another order",
+			action:       Restaurant.Update,
+			claims:       owner,
+			body:         `{"id":"Rest1","name":"Rest 2","ownerIds":["owner2","owner1"]}`,
+			responseCode: http.StatusOK,
+			ownerIds:     []string{"owner2", "owner1"},
+		},
+		{
+			name:         "owner update of the owners",
+			action:       Restaurant.Update,
+			claims:       owner,
+			body:         `{"id":"Rest1","name":"Rest 2","ownerIds":["owner2"]}`,
+			responseCode: http.StatusForbidden,
+			reason:       policy.ReasonOwnersChangeForbidden,
+		},
+		{
+			name:         "admin update of the owners",
+			action:       Restaurant.Update,
+			claims:       admin,
+			body:         `{"id":"Rest1","name":"Rest 2","ownerIds":["owner3"]}`,
+			responseCode: http.StatusOK,
+			ownerIds:     []string{"owner3"},
+		},
+		{
+			name:         "update by another user",
+			action:       Restaurant.Update,
+			claims:       other,
+			body:         `{"id":"Rest1","name":"Rest 2"}`,
+			responseCode: http.StatusForbidden,
+			reason:       policy.ReasonNotOwner,
+		},
+		{
+			name:         "owner patch",
+			action:       Restaurant.Patch,
+			claims:       owner,
+			body:         `{"name":"Rest 2"}`,
+			responseCode: http.StatusOK,
+			ownerIds:     ownerIds,
+		},
+		{
+			name:         "owner patch of the owners",
+			action:       Restaurant.Patch,
+			claims:       owner,
+			body:         `[{"op":"add","path":"/ownerIds/-","value":"user1"}]`,
+			contentType:  "application/json-patch+json",
+			responseCode: http.StatusForbidden,
+			reason:       policy.ReasonOwnersChangeForbidden,
+		},
+		{
+			name:         "patch by another user",
+			action:       Restaurant.Patch,
+			claims:       other,
+			body:         `{"name":"Rest 2"}`,
+			responseCode: http.StatusForbidden,
+			reason:       policy.ReasonNotOwner,
+		},
+		{
+			name:         "anonymous patch",
+			action:       Restaurant.Patch,
+			body:         `{"name":"Rest 2"}`,
+			responseCode: http.StatusForbidden,
+			reason:       policy.ReasonAuthenticationRequired,
+		},
+		{
+			name:         "owner delete",
+			action:       Restaurant.Delete,
+			claims:       owner,
+			responseCode: http.StatusForbidden,
+			reason:       policy.ReasonAdminRequired,
+		},
+		{
+			name:         "admin delete",
+			action:       Restaurant.Delete,
+			claims:       admin,
+			responseCode: http.StatusOK,
+		},
+		{
+			name:         "user geocode preview",
+			action:       Restaurant.GeocodePreview,
+			claims:       other,
+			body:         `{"city":"Seattle"}`,
+			responseCode: http.StatusOK,
+		},
+		{
+			name:         "anonymous geocode preview",
+			action:       Restaurant.GeocodePreview,
+			body:         `{"city":"Seattle"}`,
+			responseCode: http.StatusForbidden,
+			reason:       policy.ReasonAuthenticationRequired,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			stored := model.Restaurant{Id: &restId, Name: restName, OwnerIds: &ownerIds}
+			rc := Restaurant{
+				Restaurant: restaurantStorerStub{restaurant: &stored, version: 1},
+				Location:   locationServiceStub{},
+				Policy:     policy.Default,
+			}
+
+			request := events.APIGatewayProxyRequest{
+				PathParameters: map[string]string{"restaurantId": restId},
+				Headers:        map[string]string{},
+				Body:           tc.body,
+			}
+			if tc.contentType != "" {
+				request.Headers["Content-Type"] = tc.contentType
+			}
+			if tc.claims != nil {
+				request = auth.WithClaims(request, *tc.claims)
+			}
+
+			resp, err := tc.action(rc, request)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.responseCode, resp.StatusCode, resp.Body)
+			assertForbidden(t, resp, tc.reason)
+			if tc.ownerIds != nil {
+				restaurant := model.Restaurant{}
+				require.NoError(t, json.Unmarshal([]byte(resp.Body), &restaurant))
+				require.NotNil(t, restaurant.OwnerIds)
+				assert.Equal(t, tc.ownerIds, *restaurant.OwnerIds)
+			}
+		})
+	}
+}
+
+// authenticated wraps the handler in the authentication middleware, with the keys of the
+// issuer, so that the requests are authenticated with bearer tokens like in the API.
+func authenticated(t *testing.T, issuer authtest.Issuer, h middleware.Handler) middleware.Handler {
+	verifier := auth.NewVerifier(auth.NewKeySet(issuer.WriteJWKS(t)), authtest.IssuerURL, authtest.Audience)
+	return middleware.Authenticate(verifier, h)
+}
+
+// bearer returns the Authorization header of a token of the subject with the roles.
+func bearer(t *testing.T, issuer authtest.Issuer, subject string, roles ...string) string {
+	claims := authtest.Claims(subject)
+	if len(roles) > 0 {
+		claims["roles"] = roles
+	}
+	return "Bearer " + issuer.RS256(t, claims)
+}
+
+// assertForbidden asserts that the response is a 403 problem with the reason, when the reason is set.
+func assertForbidden(t *testing.T, resp *events.APIGatewayProxyResponse, reason policy.Reason) {
+	if reason == "" {
+		return
+	}
+	problem := model.Problem{}
+	require.NoError(t, json.Unmarshal([]byte(resp.Body), &problem))
+	assert.Equal(t, string(httpResponse.CodeForbidden), problem.Code)
+	require.NotNil(t, problem.Reason)
+	assert.Equal(t, string(reason), *problem.Reason)
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lfroomin/restaurant-serverless/internal/httpRequest"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// etag returns the strong entity tag of a version of a restaurant with its rating summary,
+// "<version>" or "<version>.<hash of the summary>". The reviews change the summary without
+// changing the version, so that they do not fail the updates of the owners: If-Match only
+// compares the version, and If-None-Match the whole tag, so that a cached restaurant is not
+// served with a stale rating summary.
+func etag(version int64, rating *model.RatingSummary) string {
+	tag := strconv.FormatInt(version, 10)
+	if rating != nil {
+		b, _ := json.Marshal(rating)
+		h := fnv.New32a()
+		_, _ = h.Write(b)
+		tag += fmt.Sprintf(".%08x", h.Sum32())
+	}
+	return strconv.Quote(tag)
+}
+
+// ifMatchVersion returns the version required by the If-Match header. The version
+// is nil when there is no header or it is "*". ok is false when the header does not
+// contain exactly one strong ETag (or "*") created by etag.
+func ifMatchVersion(request events.APIGatewayProxyRequest) (version *int64, ok bool) {
+	value := strings.TrimSpace(httpRequest.Header(request, "If-Match"))
+	if value == "" || value == "*" {
+		return nil, true
+	}
+
+	v, err := parseETag(value)
+	if err != nil {
+		return nil, false
+	}
+	return &v, true
+}
+
+// ifNoneMatch reports whether the If-None-Match header matches the entity tag,
+// using the weak comparison required by RFC 9110.
+func ifNoneMatch(request events.APIGatewayProxyRequest, etag string) bool {
+	value := strings.TrimSpace(httpRequest.Header(request, "If-None-Match"))
+	if value == "*" {
+		return true
+	}
+
+	for _, tag := range strings.Split(value, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseETag returns the version of an entity tag created by etag.
+func parseETag(tag string) (int64, error) {
+	unquoted, err := strconv.Unquote(tag)
+	if err != nil {
+		return 0, err
+	}
+	version, _, _ := strings.Cut(unquoted, ".")
+	return strconv.ParseInt(version, 10, 64)
+}
@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lfroomin/restaurant-serverless/internal/httpRequest"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/policy"
+	"github.com/lfroomin/restaurant-serverless/internal/print"
+	"net/http"
+)
+
+// GeocodePreview returns all the candidate locations of an address, without saving
+// anything, so the correct one can be chosen before the restaurant is saved.
+func (r Restaurant) GeocodePreview(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	print.Request(request)
+
+	if resp := r.authorize(request, policy.ActionGeocode, nil, nil); resp != nil {
+		return resp, nil
+	}
+
+	address := model.Address{}
+	if resp := httpRequest.DecodeJSON(request, &address); resp != nil {
+		return resp, nil
+	}
+
+	candidates, err := r.Location.Candidates(address)
+	if err != nil {
+		return httpResponse.NewServerError(err), nil
+	}
+
+	if candidates == nil {
+		candidates = []model.GeocodeCandidate{}
+	}
+	return httpResponse.New(http.StatusOK, model.GeocodeCandidateList{Items: candidates}), nil
+}
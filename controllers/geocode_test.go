@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func Test_GeocodePreview(t *testing.T) {
+	t.Parallel()
+	timezone := "America/Los_Angeles"
+	best := model.NewLocation(47.6062, -122.3321)
+	best.Relevance = aFloat(0.9)
+	other := model.NewLocation(47.2529, -122.4443)
+	other.Relevance = aFloat(0.4)
+
+	testCases := []struct {
+		name         string
+		body         string
+		candidates   []model.GeocodeCandidate
+		stubError    string
+		responseCode int
+		responseBody string
+	}{
+		{
+			name: "happy path",
+			body: `{"line1":"123 Pike St","city":"Seattle"}`,
+			candidates: []model.GeocodeCandidate{
+				{Location: best, TimezoneName: &timezone},
+				{Location: other, TimezoneName: &timezone},
+			},
+			responseCode: http.StatusOK,
+			responseBody: `{"items":[` +
+				`{"location":{"geocode":"47.606200,-122.332100","latitude":47.6062,"longitude":-122.3321,"point":{"coordinates":[-122.3321,47.6062],"type":"Point"},"relevance":0.9},"timezoneName":"America/Los_Angeles"},` +
+				`{"location":{"geocode":"47.252900,-122.444300","latitude":47.2529,"longitude":-122.4443,"point":{"coordinates":[-122.4443,47.2529],"type":"Point"},"relevance":0.4},"timezoneName":"America/Los_Angeles"}]}`,
+		},
+		{
+			name:         "not found",
+			body:         `{"city":"Nowhere"}`,
+			responseCode: http.StatusOK,
+			responseBody: `{"items":[]}`,
+		},
+		{
+			name:         "location error",
+			body:         `{"city":"Seattle"}`,
+			stubError:    "an error occurred",
+			responseCode: http.StatusInternalServerError,
+			responseBody: internalError,
+		},
+		{
+			name:         "empty request body",
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeMalformedBody, "the request body is empty"),
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			rc := Restaurant{Location: locationServiceStub{candidates: tc.candidates, error: tc.stubError}}
+
+			resp, _ := rc.GeocodePreview(events.APIGatewayProxyRequest{Body: tc.body})
+
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
+		})
+	}
+}
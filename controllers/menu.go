@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/google/uuid"
+	"github.com/lfroomin/restaurant-serverless/internal/dynamo"
+	"github.com/lfroomin/restaurant-serverless/internal/httpRequest"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/policy"
+	"github.com/lfroomin/restaurant-serverless/internal/print"
+	"log"
+	"net/http"
+)
+
+// MenuStorer stores the menus of the restaurants of each tenant apart, like RestaurantStorer.
+type MenuStorer interface {
+	SaveMenu(tenantId, restaurantId string, menu model.Menu) error
+	GetMenu(tenantId, restaurantId, menuId string) (model.Menu, bool, error)
+	UpdateMenu(tenantId, restaurantId string, menu model.Menu) error
+	DeleteMenu(tenantId, restaurantId, menuId string) (model.Menu, error)
+	ListMenus(tenantId, restaurantId string) ([]model.Menu, error)
+}
+
+// Menu serves the menus of a restaurant, under /{restaurantId}/menus. Anyone can read the
+// menus, and the owners of the restaurant and the admins can change them.
+type Menu struct {
+	Restaurant RestaurantStorer
+	Menu       MenuStorer
+	// Policy authorizes each action. When it is nil every action is allowed.
+	Policy Authorizer
+}
+
+// New creates the controller. The menus are stored in the restaurants table.
+func (m Menu) New(cfg aws.Config, restaurantsTable string) Menu {
+	storer := dynamo.New(cfg, restaurantsTable)
+	return Menu{Restaurant: storer, Menu: storer, Policy: policy.Default}
+}
+
+func (m Menu) Create(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	print.Request(request)
+
+	restaurantId := request.PathParameters["restaurantId"]
+
+	// Validate input
+	if restaurantId == "" {
+		return httpResponse.NewBadRequest("restaurantId is empty"), nil
+	}
+
+	menu := model.Menu{}
+	if resp := httpRequest.DecodeJSON(request, &menu); resp != nil {
+		return resp, nil
+	}
+
+	if err := menu.Validate(); err != nil {
+		return httpResponse.NewBadRequest(err.Error()), nil
+	}
+
+	tenantId, resp := tenantOf(request)
+	if resp != nil {
+		return resp, nil
+	}
+
+	id := uuid.NewString()
+	menu.Id = &id
+	log.Printf("create menuName: %s  tenantId: %s  restaurantId: %s  menuId: %s\n", menu.Name, tenantId, restaurantId, *menu.Id)
+
+	if resp := m.authorizeChange(request, tenantId, restaurantId); resp != nil {
+		return resp, nil
+	}
+
+	if err := m.Menu.SaveMenu(tenantId, restaurantId, menu); err != nil {
+		return storageError(err, "menu"), nil
+	}
+
+	return httpResponse.New(http.StatusCreated, menu), nil
+}
+
+func (m Menu) Read(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	print.Request(request)
+
+	restaurantId := request.PathParameters["restaurantId"]
+	menuId := request.PathParameters["menuId"]
+
+	// Validate input
+	if restaurantId == "" || menuId == "" {
+		return httpResponse.NewBadRequest("restaurantId or menuId is empty"), nil
+	}
+
+	tenantId, resp := tenantOf(request)
+	if resp != nil {
+		return resp, nil
+	}
+
+	if resp := authorize(m.Policy, request, policy.ActionRead, nil, nil); resp != nil {
+		return resp, nil
+	}
+
+	log.Printf("read tenantId: %s  restaurantId: %s  menuId: %s\n", tenantId, restaurantId, menuId)
+
+	menu, exists, err := m.Menu.GetMenu(tenantId, restaurantId, menuId)
+	if err != nil {
+		return httpResponse.NewServerError(err), nil
+	}
+
+	if !exists {
+		return httpResponse.NewNotFound("the menu was not found"), nil
+	}
+
+	return httpResponse.New(http.StatusOK, menu), nil
+}
+
+func (m Menu) Update(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	print.Request(request)
+
+	restaurantId := request.PathParameters["restaurantId"]
+	menuId := request.PathParameters["menuId"]
+
+	menu := model.Menu{}
+	if resp := httpRequest.DecodeJSON(request, &menu); resp != nil {
+		return resp, nil
+	}
+
+	// Validate input
+	if restaurantId == "" {
+		return httpResponse.NewBadRequest("restaurantId is empty"), nil
+	}
+	if menu.Id == nil || menuId != *menu.Id {
+		return httpResponse.NewBadRequest("menuId in URL path parameters and menu in body do not match"), nil
+	}
+	if err := menu.Validate(); err != nil {
+		return httpResponse.NewBadRequest(err.Error()), nil
+	}
+
+	tenantId, resp := tenantOf(request)
+	if resp != nil {
+		return resp, nil
+	}
+
+	log.Printf("update menuName: %s  tenantId: %s  restaurantId: %s  menuId: %s\n", menu.Name, tenantId, restaurantId, *menu.Id)
+
+	if resp := m.authorizeChange(request, tenantId, restaurantId); resp != nil {
+		return resp, nil
+	}
+
+	if err := m.Menu.UpdateMenu(tenantId, restaurantId, menu); err != nil {
+		return storageError(err, "menu"), nil
+	}
+
+	return httpResponse.New(http.StatusOK, menu), nil
+}
+
+func (m Menu) Delete(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	print.Request(request)
+
+	restaurantId := request.PathParameters["restaurantId"]
+	menuId := request.PathParameters["menuId"]
+
+	// Validate input
+	if restaurantId == "" || menuId == "" {
+		return httpResponse.NewBadRequest("restaurantId or menuId is empty"), nil
+	}
+
+	tenantId, resp := tenantOf(request)
+	if resp != nil {
+		return resp, nil
+	}
+
+	log.Printf("delete tenantId: %s  restaurantId: %s  menuId: %s\n", tenantId, restaurantId, menuId)
+
+	if resp := m.authorizeChange(request, tenantId, restaurantId); resp != nil {
+		return resp, nil
+	}
+
+	menu, err := m.Menu.DeleteMenu(tenantId, restaurantId, menuId)
+	if err != nil {
+		return storageError(err, "menu"), nil
+	}
+
+	return httpResponse.New(http.StatusOK, menu), nil
+}
+
+// List returns all the menus of the restaurant. A restaurant has few menus, so they are not paginated.
+func (m Menu) List(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	print.Request(request)
+
+	restaurantId := request.PathParameters["restaurantId"]
+
+	// Validate input
+	if restaurantId == "" {
+		return httpResponse.NewBadRequest("restaurantId is empty"), nil
+	}
+
+	tenantId, resp := tenantOf(request)
+	if resp != nil {
+		return resp, nil
+	}
+
+	if resp := authorize(m.Policy, request, policy.ActionRead, nil, nil); resp != nil {
+		return resp, nil
+	}
+
+	log.Printf("list menus tenantId: %s  restaurantId: %s\n", tenantId, restaurantId)
+
+	if _, resp := m.restaurant(tenantId, restaurantId); resp != nil {
+		return resp, nil
+	}
+
+	menus, err := m.Menu.ListMenus(tenantId, restaurantId)
+	if err != nil {
+		return httpResponse.NewServerError(err), nil
+	}
+
+	return httpResponse.New(http.StatusOK, model.MenuList{Items: menus}), nil
+}
+
+// authorizeChange returns the error response when the restaurant of the tenant does not
+// exist, or when the policy denies the caller to change its menus.
+func (m Menu) authorizeChange(request events.APIGatewayProxyRequest, tenantId, restaurantId string) *events.APIGatewayProxyResponse {
+	stored, resp := m.restaurant(tenantId, restaurantId)
+	if resp != nil {
+		return resp
+	}
+	return authorize(m.Policy, request, policy.ActionManageMenus, &stored, nil)
+}
+
+// restaurant returns the restaurant of the tenant, or the error response when it does not exist.
+func (m Menu) restaurant(tenantId, restaurantId string) (model.Restaurant, *events.APIGatewayProxyResponse) {
+	stored, _, exists, err := m.Restaurant.Get(tenantId, restaurantId)
+	if err != nil {
+		return model.Restaurant{}, httpResponse.NewServerError(err)
+	}
+	if !exists {
+		return model.Restaurant{}, httpResponse.NewNotFound("the restaurant was not found")
+	}
+	return stored, nil
+}
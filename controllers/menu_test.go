@@ -0,0 +1,572 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lfroomin/restaurant-serverless/internal/auth/authtest"
+	"github.com/lfroomin/restaurant-serverless/internal/awsConfig"
+	"github.com/lfroomin/restaurant-serverless/internal/dynamo"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/policy"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func Test_MenuNew(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := awsConfig.New()
+	require.NoError(t, err)
+
+	m := Menu{}.New(cfg, "RestaurantsTable")
+
+	assert.IsType(t, dynamo.RestaurantStorage{}, m.Restaurant)
+	assert.IsType(t, dynamo.RestaurantStorage{}, m.Menu)
+	assert.Equal(t, "RestaurantsTable", m.Menu.(dynamo.RestaurantStorage).Table)
+	assert.Equal(t, policy.Default, m.Policy)
+}
+
+func Test_MenuCreate(t *testing.T) {
+	t.Parallel()
+	lunch := aMenu("", "Lunch")
+
+	testCases := []struct {
+		name             string
+		restaurantId     string
+		body             string
+		restaurantExists bool
+		stubError        stubError
+		responseCode     int
+		responseBody     string
+	}{
+		{
+			name:             "happy path",
+			restaurantId:     "restId",
+			body:             menuJson(lunch),
+			restaurantExists: true,
+			responseCode:     http.StatusCreated,
+		},
+		{
+			name:             "invalid menu",
+			restaurantId:     "restId",
+			body:             `{"name":"Lunch","sections":[{"name":"Starters","items":[{"name":"Soup","price":{"amount":"6.50","currency":"usd"}}]}]}`,
+			restaurantExists: true,
+			responseCode:     http.StatusBadRequest,
+			responseBody:     problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "sections[0].items[0].price.currency \"usd\" is not an ISO 4217 currency code, such as USD"),
+		},
+		{
+			name:         "restaurant not found",
+			restaurantId: "restId",
+			body:         menuJson(lunch),
+			responseCode: http.StatusNotFound,
+			responseBody: problem(http.StatusNotFound, httpResponse.CodeNotFound, "the restaurant was not found"),
+		},
+		{
+			name:             "storage error",
+			restaurantId:     "restId",
+			body:             menuJson(lunch),
+			restaurantExists: true,
+			stubError:        stubError{restaurant: "an error occurred"},
+			responseCode:     http.StatusInternalServerError,
+			responseBody:     internalError,
+		},
+		{
+			name:             "empty request body",
+			restaurantId:     "restId",
+			restaurantExists: true,
+			responseCode:     http.StatusBadRequest,
+			responseBody:     problem(http.StatusBadRequest, httpResponse.CodeMalformedBody, "the request body is empty"),
+		},
+		{
+			name:         "restaurantId empty",
+			body:         menuJson(lunch),
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "restaurantId is empty"),
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			mc := Menu{
+				Restaurant: restaurantStorerStub{notExist: !tc.restaurantExists},
+				Menu:       menuStorerStub{error: tc.stubError.restaurant},
+			}
+
+			resp, _ := mc.Create(events.APIGatewayProxyRequest{
+				PathParameters: map[string]string{"restaurantId": tc.restaurantId},
+				Body:           tc.body,
+			})
+
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			if tc.responseCode != http.StatusCreated {
+				assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
+				return
+			}
+
+			created := model.Menu{}
+			require.NoError(t, json.Unmarshal([]byte(resp.Body), &created))
+			require.NotNil(t, created.Id)
+			assert.NotEmpty(t, *created.Id)
+			created.Id = nil
+			assert.Equal(t, lunch, created)
+		})
+	}
+}
+
+func Test_MenuRead(t *testing.T) {
+	t.Parallel()
+	lunch := aMenu("menuId", "Lunch")
+
+	testCases := []struct {
+		name         string
+		restaurantId string
+		menuId       string
+		stub         menuStorerStub
+		responseCode int
+		responseBody string
+	}{
+		{
+			name:         "happy path",
+			restaurantId: "restId",
+			menuId:       "menuId",
+			stub:         menuStorerStub{menu: &lunch},
+			responseCode: http.StatusOK,
+			responseBody: menuJson(lunch),
+		},
+		{
+			name:         "menu not found",
+			restaurantId: "restId",
+			menuId:       "menuId",
+			responseCode: http.StatusNotFound,
+			responseBody: problem(http.StatusNotFound, httpResponse.CodeNotFound, "the menu was not found"),
+		},
+		{
+			name:         "storage error",
+			restaurantId: "restId",
+			menuId:       "menuId",
+			stub:         menuStorerStub{error: "an error occurred"},
+			responseCode: http.StatusInternalServerError,
+			responseBody: internalError,
+		},
+		{
+			name:         "menuId empty",
+			restaurantId: "restId",
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "restaurantId or menuId is empty"),
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			mc := Menu{Restaurant: restaurantStorerStub{}, Menu: tc.stub}
+
+			resp, _ := mc.Read(events.APIGatewayProxyRequest{
+				PathParameters: map[string]string{"restaurantId": tc.restaurantId, "menuId": tc.menuId},
+			})
+
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
+		})
+	}
+}
+
+func Test_MenuUpdate(t *testing.T) {
+	t.Parallel()
+	lunch := aMenu("menuId", "Lunch")
+
+	testCases := []struct {
+		name         string
+		menuId       string
+		body         string
+		stub         menuStorerStub
+		responseCode int
+		responseBody string
+	}{
+		{
+			name:         "happy path",
+			menuId:       "menuId",
+			body:         menuJson(lunch),
+			responseCode: http.StatusOK,
+			responseBody: menuJson(lunch),
+		},
+		{
+			name:         "menuId does not match",
+			menuId:       "otherMenuId",
+			body:         menuJson(lunch),
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "menuId in URL path parameters and menu in body do not match"),
+		},
+		{
+			name:         "invalid menu",
+			menuId:       "menuId",
+			body:         `{"id":"menuId","name":""}`,
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "name is empty"),
+		},
+		{
+			name:         "menu not found",
+			menuId:       "menuId",
+			body:         menuJson(lunch),
+			stub:         menuStorerStub{err: storage.ErrNotFound},
+			responseCode: http.StatusNotFound,
+			responseBody: problem(http.StatusNotFound, httpResponse.CodeNotFound, "the menu was not found"),
+		},
+		{
+			name:         "storage error",
+			menuId:       "menuId",
+			body:         menuJson(lunch),
+			stub:         menuStorerStub{error: "an error occurred"},
+			responseCode: http.StatusInternalServerError,
+			responseBody: internalError,
+		},
+		{
+			name:         "empty request body",
+			menuId:       "menuId",
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeMalformedBody, "the request body is empty"),
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			mc := Menu{Restaurant: restaurantStorerStub{}, Menu: tc.stub}
+
+			resp, _ := mc.Update(events.APIGatewayProxyRequest{
+				PathParameters: map[string]string{"restaurantId": "restId", "menuId": tc.menuId},
+				Body:           tc.body,
+			})
+
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
+		})
+	}
+}
+
+func Test_MenuDelete(t *testing.T) {
+	t.Parallel()
+	lunch := aMenu("menuId", "Lunch")
+
+	testCases := []struct {
+		name         string
+		menuId       string
+		stub         menuStorerStub
+		responseCode int
+		responseBody string
+	}{
+		{
+			name:         "happy path",
+			menuId:       "menuId",
+			stub:         menuStorerStub{menu: &lunch},
+			responseCode: http.StatusOK,
+			responseBody: menuJson(lunch),
+		},
+		{
+			name:         "menu not found",
+			menuId:       "menuId",
+			stub:         menuStorerStub{err: storage.ErrNotFound},
+			responseCode: http.StatusNotFound,
+			responseBody: problem(http.StatusNotFound, httpResponse.CodeNotFound, "the menu was not found"),
+		},
+		{
+			name:         "storage error",
+			menuId:       "menuId",
+			stub:         menuStorerStub{error: "an error occurred"},
+			responseCode: http.StatusInternalServerError,
+			responseBody: internalError,
+		},
+		{
+			name:         "menuId empty",
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "restaurantId or menuId is empty"),
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			mc := Menu{Restaurant: restaurantStorerStub{}, Menu: tc.stub}
+
+			resp, _ := mc.Delete(events.APIGatewayProxyRequest{
+				PathParameters: map[string]string{"restaurantId": "restId", "menuId": tc.menuId},
+			})
+
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
+		})
+	}
+}
+
+func Test_MenuList(t *testing.T) {
+	t.Parallel()
+	lunch := aMenu("menuId", "Lunch")
+
+	testCases := []struct {
+		name             string
+		stub             menuStorerStub
+		restaurantExists bool
+		responseCode     int
+		responseBody     string
+	}{
+		{
+			name:             "happy path",
+			stub:             menuStorerStub{menu: &lunch},
+			restaurantExists: true,
+			responseCode:     http.StatusOK,
+			responseBody:     `{"items":[` + menuJson(lunch) + `]}`,
+		},
+		{
+			name:             "no menus",
+			restaurantExists: true,
+			responseCode:     http.StatusOK,
+			responseBody:     `{"items":[]}`,
+		},
+		{
+			name:         "restaurant not found",
+			responseCode: http.StatusNotFound,
+			responseBody: problem(http.StatusNotFound, httpResponse.CodeNotFound, "the restaurant was not found"),
+		},
+		{
+			name:             "storage error",
+			stub:             menuStorerStub{error: "an error occurred"},
+			restaurantExists: true,
+			responseCode:     http.StatusInternalServerError,
+			responseBody:     internalError,
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			mc := Menu{Restaurant: restaurantStorerStub{notExist: !tc.restaurantExists}, Menu: tc.stub}
+
+			resp, _ := mc.List(events.APIGatewayProxyRequest{
+				PathParameters: map[string]string{"restaurantId": "restId"},
+			})
+
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
+		})
+	}
+}
+
+func aMenu(id, name string) model.Menu {
+	items := []model.MenuItem{{Name: "Soup", Price: model.Price{Amount: "6.50", Currency: "USD"}}}
+	menu := model.Menu{Name: name, Sections: &[]model.MenuSection{{Name: "Starters", Items: &items}}}
+	if id != "" {
+		menu.Id = &id
+	}
+	return menu
+}
+
+func menuJson(menu model.Menu) string {
+	b, _ := json.Marshal(menu)
+	return string(b)
+}
+
+type menuStorerStub struct {
+	menu  *model.Menu
+	error string
+	err   error
+}
+
+func (s menuStorerStub) SaveMenu(_, _ string, _ model.Menu) error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.error != "" {
+		return errors.New(s.error)
+	}
+	return nil
+}
+
+func (s menuStorerStub) GetMenu(_, _, _ string) (model.Menu, bool, error) {
+	if s.error != "" {
+		return model.Menu{}, false, errors.New(s.error)
+	}
+	if s.menu == nil {
+		return model.Menu{}, false, nil
+	}
+	return *s.menu, true, nil
+}
+
+func (s menuStorerStub) UpdateMenu(_, _ string, _ model.Menu) error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.error != "" {
+		return errors.New(s.error)
+	}
+	return nil
+}
+
+func (s menuStorerStub) DeleteMenu(_, _, _ string) (model.Menu, error) {
+	if s.err != nil {
+		return model.Menu{}, s.err
+	}
+	if s.error != "" {
+		return model.Menu{}, errors.New(s.error)
+	}
+	if s.menu == nil {
+		return model.Menu{}, nil
+	}
+	return *s.menu, nil
+}
+
+func (s menuStorerStub) ListMenus(_, _ string) ([]model.Menu, error) {
+	if s.error != "" {
+		return nil, errors.New(s.error)
+	}
+	if s.menu == nil {
+		return []model.Menu{}, nil
+	}
+	return []model.Menu{*s.menu}, nil
+}
+
+func Test_MenuAuthorize(t *testing.T) {
+	t.Parallel()
+
+	issuer := authtest.NewIssuer(t)
+	admin := bearer(t, issuer, "admin1", policy.RoleAdmin)
+	owner := bearer(t, issuer, "owner2")
+	user := bearer(t, issuer, "user1")
+	lunch := aMenu("menuId", "Lunch")
+
+	testCases := []struct {
+		name          string
+		action        func(Menu, events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error)
+		method        string
+		authorization string
+		body          string
+		responseCode  int
+		reason        policy.Reason
+	}{
+		{
+			name:         "anonymous read",
+			action:       Menu.Read,
+			method:       http.MethodGet,
+			responseCode: http.StatusOK,
+		},
+		{
+			name:         "anonymous list",
+			action:       Menu.List,
+			method:       http.MethodGet,
+			responseCode: http.StatusOK,
+		},
+		{
+			name:         "anonymous create",
+			action:       Menu.Create,
+			method:       http.MethodPost,
+			body:         menuJson(aMenu("", "Lunch")),
+			responseCode: http.StatusUnauthorized,
+		},
+		{
+			name:          "invalid token",
+			action:        Menu.Delete,
+			method:        http.MethodDelete,
+			authorization: "Bearer not-a-token",
+			responseCode:  http.StatusUnauthorized,
+		},
+		{
+			name:          "owner create",
+			action:        Menu.Create,
+			method:        http.MethodPost,
+			authorization: owner,
+			body:          menuJson(aMenu("", "Lunch")),
+			responseCode:  http.StatusCreated,
+		},
+		{
+			name:          "owner update",
+			action:        Menu.Update,
+			method:        http.MethodPut,
+			authorization: owner,
+			body:          menuJson(lunch),
+			responseCode:  http.StatusOK,
+		},
+		{
+			name:          "owner delete",
+			action:        Menu.Delete,
+			method:        http.MethodDelete,
+			authorization: owner,
+			responseCode:  http.StatusOK,
+		},
+		{
+			name:          "create by another user",
+			action:        Menu.Create,
+			method:        http.MethodPost,
+			authorization: user,
+			body:          menuJson(aMenu("", "Lunch")),
+			responseCode:  http.StatusForbidden,
+			reason:        policy.ReasonNotOwner,
+		},
+		{
+			name:          "update by another user",
+			action:        Menu.Update,
+			method:        http.MethodPut,
+			authorization: user,
+			body:          menuJson(lunch),
+			responseCode:  http.StatusForbidden,
+			reason:        policy.ReasonNotOwner,
+		},
+		{
+			name:          "delete by another user",
+			action:        Menu.Delete,
+			method:        http.MethodDelete,
+			authorization: user,
+			responseCode:  http.StatusForbidden,
+			reason:        policy.ReasonNotOwner,
+		},
+		{
+			name:          "admin update",
+			action:        Menu.Update,
+			method:        http.MethodPut,
+			authorization: admin,
+			body:          menuJson(lunch),
+			responseCode:  http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			restId, ownerIds := "restId", []string{"owner1", "owner2"}
+			mc := Menu{
+				Restaurant: restaurantStorerStub{restaurant: &model.Restaurant{Id: &restId, Name: "name", OwnerIds: &ownerIds}},
+				Menu:       menuStorerStub{menu: &lunch},
+				Policy:     policy.Default,
+			}
+			h := authenticated(t, issuer, func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+				return tc.action(mc, request)
+			})
+
+			resp, err := h(events.APIGatewayProxyRequest{
+				HTTPMethod:     tc.method,
+				PathParameters: map[string]string{"restaurantId": restId, "menuId": "menuId"},
+				Headers:        map[string]string{"Authorization": tc.authorization},
+				Body:           tc.body,
+			})
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.responseCode, resp.StatusCode, resp.Body)
+			assertForbidden(t, resp, tc.reason)
+		})
+	}
+}
@@ -2,78 +2,123 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	jsonpatch "github.com/evanphx/json-patch/v5"
 	"github.com/google/uuid"
 	"github.com/lfroomin/restaurant-serverless/internal/dynamo"
 	"github.com/lfroomin/restaurant-serverless/internal/geocode"
+	"github.com/lfroomin/restaurant-serverless/internal/httpRequest"
 	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
 	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/openapi"
+	"github.com/lfroomin/restaurant-serverless/internal/policy"
 	"github.com/lfroomin/restaurant-serverless/internal/print"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"github.com/lfroomin/restaurant-serverless/internal/timezone"
 	"log"
 	"net/http"
+	"reflect"
+	"strconv"
+	"time"
 )
 
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+
+	defaultListLimit  = 20
+	maxListLimit      = 100
+	maxNearbyRadiusKm = 50
+
+	geocodeCacheSize = 1000
+	geocodeCacheTTL  = 30 * 24 * time.Hour
+)
+
+// RestaurantStorer stores the restaurants of each tenant apart: a restaurant is only
+// found with the tenant id it was saved with.
 type RestaurantStorer interface {
-	Save(restaurant model.Restaurant) error
-	Get(restaurantId string) (model.Restaurant, bool, error)
-	Update(restaurant model.Restaurant) error
-	Delete(restaurantId string) error
+	Save(tenantId string, restaurant model.Restaurant) error
+	Get(tenantId, restaurantId string) (model.Restaurant, int64, bool, error)
+	Update(tenantId string, restaurant model.Restaurant, ifVersion *int64) (int64, error)
+	Patch(tenantId string, original, patched model.Restaurant, ifVersion *int64) (int64, error)
+	Delete(tenantId, restaurantId string, ifVersion *int64) (model.Restaurant, error)
+	List(tenantId string, limit int32, nextToken string) ([]model.Restaurant, string, error)
+	Nearby(tenantId string, lat, lon, radiusKm float64) ([]model.NearbyRestaurant, error)
 }
 
 type Geocoder interface {
 	Geocode(address model.Address) (model.Location, string, error)
+	Candidates(address model.Address) ([]model.GeocodeCandidate, error)
 }
 
 type Restaurant struct {
 	Restaurant RestaurantStorer
 	Location   Geocoder
+	// Policy authorizes each action. When it is nil every action is allowed.
+	Policy Authorizer
+	// MinRelevance is the minimum relevance of a geocoded address. When it is
+	// greater than 0, addresses that are not found are rejected too.
+	MinRelevance float64
 }
 
-func (r Restaurant) New(cfg aws.Config, restaurantsTable, placeIndex string) Restaurant {
+// New creates the controller. Geocoding results are cached in memory and, if
+// geocodeCacheTable is not empty, in that DynamoDB table.
+func (r Restaurant) New(cfg aws.Config, restaurantsTable, placeIndex, geocodeCacheTable string) Restaurant {
+	var geocoder Geocoder = geocode.New(cfg, placeIndex)
+	if geocodeCacheTable != "" {
+		geocoder = geocode.CachingGeocoder{Geocoder: geocoder, Cache: dynamo.NewGeocodeCache(cfg, geocodeCacheTable, geocodeCacheTTL)}
+	}
+
 	return Restaurant{
 		Restaurant: dynamo.New(cfg, restaurantsTable),
-		Location:   geocode.New(cfg, placeIndex),
+		Location:   geocode.CachingGeocoder{Geocoder: geocoder, Cache: geocode.NewLRUCache(geocodeCacheSize)},
+		Policy:     policy.Default,
 	}
 }
 
 func (r Restaurant) Create(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
-	print.Json("Request", request)
+	print.Request(request)
+
+	tenantId, resp := tenantOf(request)
+	if resp != nil {
+		return resp, nil
+	}
+	if resp := r.authorize(request, policy.ActionCreate, nil, nil); resp != nil {
+		return resp, nil
+	}
 
 	restaurant := model.Restaurant{}
-	if len(request.Body) > 0 {
-		if err := json.Unmarshal([]byte(request.Body), &restaurant); err != nil {
-			return httpResponse.NewServerError(fmt.Sprintf("error unmarshalling request body: %s", err.Error())), nil
-		}
-	} else {
-		return httpResponse.NewBadRequest("error request body is empty"), nil
+	if resp := httpRequest.DecodeJSON(request, &restaurant); resp != nil {
+		return resp, nil
+	}
+
+	if resp := validate(restaurant); resp != nil {
+		return resp, nil
 	}
 
 	id := uuid.NewString()
 	restaurant.Id = &id
-	log.Printf("create restaurantName: %s  restaurantId: %s\n", restaurant.Name, *restaurant.Id)
+	log.Printf("create restaurantName: %s  tenantId: %s  restaurantId: %s\n", restaurant.Name, tenantId, *restaurant.Id)
 
 	// Get the geocode of the restaurant address
 	if restaurant.Address != nil {
-		location, timezoneName, err := r.Location.Geocode(*restaurant.Address)
-		if err != nil {
-			return httpResponse.NewServerError(err.Error()), nil
+		if resp := r.locate(restaurant.Address, nil); resp != nil {
+			return resp, nil
 		}
-
-		restaurant.Address.Location = &location
-		restaurant.Address.TimezoneName = &timezoneName
 	}
 
-	if err := r.Restaurant.Save(restaurant); err != nil {
-		return httpResponse.NewServerError(err.Error()), nil
+	if err := r.Restaurant.Save(tenantId, restaurant); err != nil {
+		return storageError(err, "restaurant"), nil
 	}
 
-	return httpResponse.New(http.StatusCreated, restaurant), nil
+	return httpResponse.NewWithHeaders(http.StatusCreated, restaurant, map[string]string{"ETag": etag(1, nil)}), nil
 }
 
 func (r Restaurant) Read(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
-	print.Json("Request", request)
+	print.Request(request)
 
 	restaurantId := request.PathParameters["restaurantId"]
 
@@ -82,61 +127,208 @@ func (r Restaurant) Read(request events.APIGatewayProxyRequest) (*events.APIGate
 		return httpResponse.NewBadRequest("restaurantId is empty"), nil
 	}
 
-	log.Printf("read restaurantId: %s\n", restaurantId)
+	tenantId, resp := tenantOf(request)
+	if resp != nil {
+		return resp, nil
+	}
+	if resp := r.authorize(request, policy.ActionRead, nil, nil); resp != nil {
+		return resp, nil
+	}
+
+	log.Printf("read tenantId: %s  restaurantId: %s\n", tenantId, restaurantId)
 
-	restaurant, exists, err := r.Restaurant.Get(restaurantId)
+	restaurant, version, exists, err := r.Restaurant.Get(tenantId, restaurantId)
 	if err != nil {
-		return httpResponse.NewServerError(err.Error()), nil
+		return httpResponse.NewServerError(err), nil
 	}
 
 	if !exists {
-		return httpResponse.New(http.StatusNotFound, nil), nil
+		return httpResponse.NewNotFound("the restaurant was not found"), nil
 	}
 
-	return httpResponse.New(http.StatusOK, restaurant), nil
+	headers := map[string]string{"ETag": etag(version, restaurant.Rating)}
+	if ifNoneMatch(request, headers["ETag"]) {
+		return httpResponse.NewWithHeaders(http.StatusNotModified, nil, headers), nil
+	}
+
+	return httpResponse.NewWithHeaders(http.StatusOK, restaurant, headers), nil
 }
 
 func (r Restaurant) Update(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
-	print.Json("Request", request)
+	print.Request(request)
 
 	restaurantId := request.PathParameters["restaurantId"]
 
 	restaurant := model.Restaurant{}
-	if len(request.Body) > 0 {
-		if err := json.Unmarshal([]byte(request.Body), &restaurant); err != nil {
-			return httpResponse.NewServerError(fmt.Sprintf("error unmarshalling request body: %s", err.Error())), nil
-		}
-	} else {
-		return httpResponse.NewBadRequest("error request body is empty"), nil
+	if resp := httpRequest.DecodeJSON(request, &restaurant); resp != nil {
+		return resp, nil
 	}
 
 	// Validate input
 	if restaurant.Id == nil || restaurantId != *restaurant.Id {
 		return httpResponse.NewBadRequest("restaurantId in URL path parameters and restaurant in body do not match"), nil
 	}
+	if resp := validate(restaurant); resp != nil {
+		return resp, nil
+	}
+	ifVersion, ok := ifMatchVersion(request)
+	if !ok {
+		return preconditionFailed(), nil
+	}
+	tenantId, resp := tenantOf(request)
+	if resp != nil {
+		return resp, nil
+	}
 
-	log.Printf("update restaurantName: %s  restaurantId: %s\n", restaurant.Name, *restaurant.Id)
+	log.Printf("update restaurantName: %s  tenantId: %s  restaurantId: %s\n", restaurant.Name, tenantId, *restaurant.Id)
 
-	// Get the geocode of the restaurant address
+	stored, storedVersion, exists, err := r.Restaurant.Get(tenantId, restaurantId)
+	if err != nil {
+		return httpResponse.NewServerError(err), nil
+	}
+	if !exists {
+		return httpResponse.NewNotFound("the restaurant was not found"), nil
+	}
+	if ifVersion != nil && *ifVersion != storedVersion {
+		return preconditionFailed(), nil
+	}
+
+	// The rating summary is kept by the update, and the owners unless they are replaced
+	restaurant.Rating = stored.Rating
+	if restaurant.OwnerIds == nil {
+		restaurant.OwnerIds = stored.OwnerIds
+	}
+	if resp := r.authorize(request, policy.ActionUpdate, &stored, &restaurant); resp != nil {
+		return resp, nil
+	}
+
+	// Get the geocode of the restaurant address, unless it is the stored address
 	if restaurant.Address != nil {
-		location, timezoneName, err := r.Location.Geocode(*restaurant.Address)
-		if err != nil {
-			return httpResponse.NewServerError(err.Error()), nil
+		if resp := r.locate(restaurant.Address, stored.Address); resp != nil {
+			return resp, nil
 		}
+	}
 
-		restaurant.Address.Location = &location
-		restaurant.Address.TimezoneName = &timezoneName
+	// The update is only written to the version that was authorized, whose owners were checked
+	version, err := r.Restaurant.Update(tenantId, restaurant, &storedVersion)
+	if err != nil {
+		return writeError(err, ifVersion), nil
 	}
 
-	if err := r.Restaurant.Update(restaurant); err != nil {
-		return httpResponse.NewServerError(err.Error()), nil
+	return httpResponse.NewWithHeaders(http.StatusOK, restaurant, map[string]string{"ETag": etag(version, restaurant.Rating)}), nil
+}
+
+// Patch applies an RFC 7396 JSON Merge Patch or an RFC 6902 JSON Patch
+// (selected by the Content-Type header) to the stored restaurant.
+func (r Restaurant) Patch(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	print.Request(request)
+
+	restaurantId := request.PathParameters["restaurantId"]
+
+	// Validate input
+	if restaurantId == "" {
+		return httpResponse.NewBadRequest("restaurantId is empty"), nil
+	}
+	body, resp := httpRequest.Body(request)
+	if resp != nil {
+		return resp, nil
+	}
+	ifVersion, ok := ifMatchVersion(request)
+	if !ok {
+		return preconditionFailed(), nil
 	}
 
-	return httpResponse.New(http.StatusOK, restaurant), nil
+	contentType := httpRequest.MediaType(request)
+	if contentType != "" && contentType != httpRequest.JSONContentType && contentType != mergePatchContentType && contentType != jsonPatchContentType {
+		return httpResponse.NewProblem(http.StatusUnsupportedMediaType, httpResponse.CodeUnsupportedMediaType,
+			fmt.Sprintf("Content-Type must be %s or %s", mergePatchContentType, jsonPatchContentType)), nil
+	}
+
+	tenantId, resp := tenantOf(request)
+	if resp != nil {
+		return resp, nil
+	}
+
+	log.Printf("patch tenantId: %s  restaurantId: %s  contentType: %s\n", tenantId, restaurantId, contentType)
+
+	original, version, exists, err := r.Restaurant.Get(tenantId, restaurantId)
+	if err != nil {
+		return httpResponse.NewServerError(err), nil
+	}
+	if !exists {
+		return httpResponse.NewNotFound("the restaurant was not found"), nil
+	}
+	if ifVersion != nil && *ifVersion != version {
+		return preconditionFailed(), nil
+	}
+	if resp := r.authorize(request, policy.ActionUpdate, &original, nil); resp != nil {
+		return resp, nil
+	}
+
+	doc, err := json.Marshal(original)
+	if err != nil {
+		return httpResponse.NewServerError(fmt.Errorf("error marshalling restaurant: %w", err)), nil
+	}
+
+	if contentType == jsonPatchContentType {
+		patch := jsonpatch.Patch{}
+		if resp := httpRequest.Unmarshal(body, &patch); resp != nil {
+			return resp, nil
+		}
+		if doc, err = patch.Apply(doc); err != nil {
+			return httpResponse.NewProblem(http.StatusUnprocessableEntity, httpResponse.CodePatchFailed, fmt.Sprintf("error applying JSON Patch: %s", err.Error())), nil
+		}
+	} else {
+		// A merge patch must be an object, which jsonpatch.MergePatch does not check
+		if resp := httpRequest.Unmarshal(body, &map[string]json.RawMessage{}); resp != nil {
+			return resp, nil
+		}
+		if doc, err = jsonpatch.MergePatch(doc, body); err != nil {
+			return httpResponse.NewBadRequest(fmt.Sprintf("error applying JSON Merge Patch: %s", err.Error())), nil
+		}
+	}
+
+	// The patched restaurant must conform to the spec like the body of an Update, which the patch is not
+	if resp := openapi.ValidateSchema("Restaurant", doc); resp != nil {
+		return resp, nil
+	}
+
+	patched := model.Restaurant{}
+	if err = json.Unmarshal(doc, &patched); err != nil {
+		return httpResponse.NewBadRequest(fmt.Sprintf("error unmarshalling patched restaurant: %s", err.Error())), nil
+	}
+
+	if patched.Id == nil || *patched.Id != restaurantId {
+		return httpResponse.NewBadRequest("the restaurant id cannot be changed"), nil
+	}
+	// Which fields are patched is only known now, such as the owners
+	if resp := r.authorize(request, policy.ActionUpdate, &original, &patched); resp != nil {
+		return resp, nil
+	}
+	if resp := validate(patched); resp != nil {
+		return resp, nil
+	}
+
+	// The location and timezone are derived from the address, so they are
+	// only looked up again when the address itself changed
+	if patched.Address != nil {
+		if resp := r.locate(patched.Address, original.Address); resp != nil {
+			return resp, nil
+		}
+	}
+
+	if !reflect.DeepEqual(original, patched) {
+		// The patch is only written to the version that was authorized, whose owners were checked
+		if version, err = r.Restaurant.Patch(tenantId, original, patched, &version); err != nil {
+			return writeError(err, ifVersion), nil
+		}
+	}
+
+	return httpResponse.NewWithHeaders(http.StatusOK, patched, map[string]string{"ETag": etag(version, patched.Rating)}), nil
 }
 
 func (r Restaurant) Delete(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
-	print.Json("Request", request)
+	print.Request(request)
 
 	restaurantId := request.PathParameters["restaurantId"]
 
@@ -145,12 +337,217 @@ func (r Restaurant) Delete(request events.APIGatewayProxyRequest) (*events.APIGa
 		return httpResponse.NewBadRequest("restaurantId is empty"), nil
 	}
 
-	log.Printf("delete restaurantId: %s\n", restaurantId)
+	ifVersion, ok := ifMatchVersion(request)
+	if !ok {
+		return preconditionFailed(), nil
+	}
+
+	tenantId, resp := tenantOf(request)
+	if resp != nil {
+		return resp, nil
+	}
+	if resp := r.authorize(request, policy.ActionDelete, nil, nil); resp != nil {
+		return resp, nil
+	}
+
+	log.Printf("delete tenantId: %s  restaurantId: %s\n", tenantId, restaurantId)
+
+	restaurant, err := r.Restaurant.Delete(tenantId, restaurantId, ifVersion)
+	if err != nil {
+		return storageError(err, "restaurant"), nil
+	}
+
+	return httpResponse.New(http.StatusOK, restaurant), nil
+}
+
+func (r Restaurant) List(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	print.Request(request)
+
+	limit, ok := listLimit(request)
+	if !ok {
+		return httpResponse.NewBadRequest(fmt.Sprintf("limit must be an integer between 1 and %d", maxListLimit)), nil
+	}
+	nextToken := request.QueryStringParameters["nextToken"]
+
+	tenantId, resp := tenantOf(request)
+	if resp != nil {
+		return resp, nil
+	}
+	if resp := r.authorize(request, policy.ActionRead, nil, nil); resp != nil {
+		return resp, nil
+	}
+
+	log.Printf("list tenantId: %s  limit: %d  nextToken: %s\n", tenantId, limit, nextToken)
+
+	restaurants, token, err := r.Restaurant.List(tenantId, limit, nextToken)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidNextToken) {
+			return httpResponse.NewBadRequest("nextToken is invalid"), nil
+		}
+		return httpResponse.NewServerError(err), nil
+	}
+
+	list := model.RestaurantList{Items: restaurants}
+	if token != "" {
+		list.NextToken = &token
+	}
+
+	return httpResponse.New(http.StatusOK, list), nil
+}
+
+func (r Restaurant) Nearby(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	print.Request(request)
+
+	// Validate input
+	lat, err := strconv.ParseFloat(request.QueryStringParameters["lat"], 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return httpResponse.NewBadRequest("lat must be a number between -90 and 90"), nil
+	}
+	lon, err := strconv.ParseFloat(request.QueryStringParameters["lon"], 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return httpResponse.NewBadRequest("lon must be a number between -180 and 180"), nil
+	}
+	radiusKm, err := strconv.ParseFloat(request.QueryStringParameters["radiusKm"], 64)
+	if err != nil || radiusKm <= 0 || radiusKm > maxNearbyRadiusKm {
+		return httpResponse.NewBadRequest(fmt.Sprintf("radiusKm must be a number greater than 0 and at most %d", maxNearbyRadiusKm)), nil
+	}
+
+	tenantId, resp := tenantOf(request)
+	if resp != nil {
+		return resp, nil
+	}
+	if resp := r.authorize(request, policy.ActionRead, nil, nil); resp != nil {
+		return resp, nil
+	}
+
+	log.Printf("nearby tenantId: %s  lat: %f  lon: %f  radiusKm: %f\n", tenantId, lat, lon, radiusKm)
 
-	err := r.Restaurant.Delete(restaurantId)
+	restaurants, err := r.Restaurant.Nearby(tenantId, lat, lon, radiusKm)
 	if err != nil {
-		return httpResponse.NewServerError(err.Error()), nil
+		if errors.Is(err, storage.ErrRadiusTooLarge) {
+			return httpResponse.NewBadRequest("radiusKm is too large for this latitude"), nil
+		}
+		return httpResponse.NewServerError(err), nil
+	}
+
+	return httpResponse.New(http.StatusOK, model.NearbyRestaurantList{Items: restaurants}), nil
+}
+
+// listLimit returns the limit query parameter, defaultListLimit when it is absent.
+// It returns false when the limit is not an integer between 1 and maxListLimit.
+func listLimit(request events.APIGatewayProxyRequest) (int32, bool) {
+	l, ok := request.QueryStringParameters["limit"]
+	if !ok {
+		return defaultListLimit, true
+	}
+	n, err := strconv.ParseInt(l, 10, 32)
+	if err != nil || n < 1 || n > maxListLimit {
+		return 0, false
+	}
+	return int32(n), true
+}
+
+// validate checks the fields of the restaurant that the generated model does not.
+func validate(restaurant model.Restaurant) *events.APIGatewayProxyResponse {
+	if restaurant.OpeningHours != nil {
+		if err := restaurant.OpeningHours.Validate(); err != nil {
+			return httpResponse.NewBadRequest(err.Error())
+		}
+	}
+	return nil
+}
+
+// locate sets the location and timezone of the address. A location chosen from
+// POST /geocode/preview (one with coordinates that differs from the stored location)
+// is kept, the stored location is kept when the address did not change, and
+// otherwise the address is geocoded. It returns the error response when the
+// address cannot be geocoded with enough relevance.
+func (r Restaurant) locate(address *model.Address, stored *model.Address) *events.APIGatewayProxyResponse {
+	if loc := address.Location; loc != nil && loc.Latitude != nil && loc.Longitude != nil &&
+		(stored == nil || !reflect.DeepEqual(loc, stored.Location)) {
+		if name := address.TimezoneName; name != nil && *name != "" && !timezone.Valid(*name) {
+			return httpResponse.NewBadRequest(fmt.Sprintf("timezoneName %q is not an IANA time zone", *name))
+		}
+
+		// Keep the other formats of the coordinates in step with them
+		chosen := *loc
+		coordinates := model.NewLocation(*loc.Latitude, *loc.Longitude)
+		chosen.Geocode, chosen.Point = coordinates.Geocode, coordinates.Point
+		address.Location = &chosen
+		address.TimezoneName = timezoneOf(address.TimezoneName, address.Location)
+		return nil
 	}
 
-	return httpResponse.New(http.StatusOK, nil), nil
+	if stored != nil && stored.Location != nil && !addressChanged(*stored, *address) {
+		address.Location = stored.Location
+		address.TimezoneName = timezoneOf(stored.TimezoneName, stored.Location)
+		return nil
+	}
+
+	location, timezoneName, err := r.Location.Geocode(*address)
+	if err != nil {
+		return httpResponse.NewServerError(err)
+	}
+
+	if r.MinRelevance > 0 {
+		if location.Geocode == nil {
+			return httpResponse.NewProblem(http.StatusUnprocessableEntity, httpResponse.CodeAddressNotFound,
+				"the address was not found, use POST /geocode/preview to choose its location")
+		}
+		if location.Relevance != nil && *location.Relevance < r.MinRelevance {
+			return httpResponse.NewProblem(http.StatusUnprocessableEntity, httpResponse.CodeAddressNotRelevant,
+				fmt.Sprintf("the address matched with relevance %.2f, below the minimum of %.2f, use POST /geocode/preview to choose its location",
+					*location.Relevance, r.MinRelevance))
+		}
+	}
+
+	address.Location = &location
+	address.TimezoneName = timezoneOf(&timezoneName, address.Location)
+	return nil
+}
+
+// timezoneOf returns the time zone name when it is valid, otherwise the time zone
+// resolved from the coordinates of the location.
+func timezoneOf(name *string, location *model.Location) *string {
+	if location == nil || location.Latitude == nil || location.Longitude == nil {
+		return name
+	}
+	resolved := timezone.Resolve(name, *location.Latitude, *location.Longitude)
+	return &resolved
+}
+
+// addressChanged reports whether any of the fields used for geocoding differ.
+func addressChanged(a, b model.Address) bool {
+	return !reflect.DeepEqual(
+		[]*string{a.Line1, a.Line2, a.City, a.State, a.ZipCode, a.Country},
+		[]*string{b.Line1, b.Line2, b.City, b.State, b.ZipCode, b.Country},
+	)
+}
+
+func preconditionFailed() *events.APIGatewayProxyResponse {
+	return httpResponse.NewProblem(http.StatusPreconditionFailed, httpResponse.CodePreconditionFailed, "If-Match does not match the current ETag of the restaurant")
+}
+
+// storageError maps the errors returned by a storer of the resource, such as restaurant, to a response.
+// writeError is the response to the error of a write of the restaurant conditioned on the version
+// that was read and authorized. When the restaurant changed in between, the request fails with
+// 412 if it had an If-Match, and otherwise with 409, since its authorization may no longer hold.
+func writeError(err error, ifVersion *int64) *events.APIGatewayProxyResponse {
+	if ifVersion == nil && errors.Is(err, storage.ErrPreconditionFailed) {
+		return httpResponse.NewProblem(http.StatusConflict, httpResponse.CodeConflict, "the restaurant changed while it was written")
+	}
+	return storageError(err, "restaurant")
+}
+
+func storageError(err error, resource string) *events.APIGatewayProxyResponse {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return httpResponse.NewNotFound(fmt.Sprintf("the %s was not found", resource))
+	case errors.Is(err, storage.ErrConflict):
+		return httpResponse.NewProblem(http.StatusConflict, httpResponse.CodeConflict, fmt.Sprintf("a %s with this id already exists", resource))
+	case errors.Is(err, storage.ErrPreconditionFailed):
+		return preconditionFailed()
+	default:
+		return httpResponse.NewServerError(err)
+	}
 }
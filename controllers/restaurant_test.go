@@ -9,10 +9,13 @@ import (
 	"github.com/lfroomin/restaurant-serverless/internal/awsConfig"
 	"github.com/lfroomin/restaurant-serverless/internal/dynamo"
 	"github.com/lfroomin/restaurant-serverless/internal/geocode"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
 	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"net/http"
+	"regexp"
 	"testing"
 )
 
@@ -25,12 +28,19 @@ func Test_New(t *testing.T) {
 	t.Parallel()
 
 	testCases := []struct {
-		name             string
-		restaurantsTable string
-		placeIndex       string
+		name              string
+		restaurantsTable  string
+		placeIndex        string
+		geocodeCacheTable string
 	}{
 		{
-			name:             "happy path",
+			name:              "happy path",
+			restaurantsTable:  "RestaurantsTable",
+			placeIndex:        "LocationPlaceIndex",
+			geocodeCacheTable: "GeocodeCacheTable",
+		},
+		{
+			name:             "no geocode cache table",
 			restaurantsTable: "RestaurantsTable",
 			placeIndex:       "LocationPlaceIndex",
 		},
@@ -45,12 +55,21 @@ func Test_New(t *testing.T) {
 			cfg, err := awsConfig.New()
 			require.NoError(t, err)
 
-			r := Restaurant{}.New(cfg, tc.restaurantsTable, tc.placeIndex)
+			r := Restaurant{}.New(cfg, tc.restaurantsTable, tc.placeIndex, tc.geocodeCacheTable)
 
 			assert.IsType(t, dynamo.RestaurantStorage{}, r.Restaurant)
 			assert.Equal(t, tc.restaurantsTable, r.Restaurant.(dynamo.RestaurantStorage).Table)
-			assert.IsType(t, geocode.LocationService{}, r.Location)
-			assert.Equal(t, tc.placeIndex, r.Location.(geocode.LocationService).PlaceIndex)
+
+			// The in-memory cache is checked first, then the DynamoDB cache
+			require.IsType(t, geocode.CachingGeocoder{}, r.Location)
+			cg := r.Location.(geocode.CachingGeocoder)
+			assert.IsType(t, &geocode.LRUCache{}, cg.Cache)
+			if tc.geocodeCacheTable != "" {
+				require.IsType(t, geocode.CachingGeocoder{}, cg.Geocoder)
+				cg = cg.Geocoder.(geocode.CachingGeocoder)
+				assert.Equal(t, tc.geocodeCacheTable, cg.Cache.(dynamo.GeocodeCache).Table)
+			}
+			assert.Equal(t, tc.placeIndex, cg.Geocoder.(geocode.LocationService).PlaceIndex)
 		})
 	}
 }
@@ -76,6 +95,7 @@ func Test_Create(t *testing.T) {
 		responseCode int
 		responseBody string
 		stubError    stubError
+		stubErr      error
 	}{
 		{
 			name: "happy path",
@@ -98,9 +118,27 @@ func Test_Create(t *testing.T) {
 			name:         "storage error",
 			restaurant:   model.Restaurant{},
 			responseCode: http.StatusInternalServerError,
-			responseBody: `{"Message":"an error occurred"}`,
+			responseBody: internalError,
 			stubError:    stubError{restaurant: "an error occurred"},
 		},
+		{
+			name: "invalid opening hours",
+			restaurant: model.Restaurant{
+				Name:         restName,
+				OpeningHours: &model.OpeningHours{SpecialDates: &[]model.SpecialDate{{Date: "25/12/2024"}}},
+			},
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "openingHours.specialDates[0].date \"25/12/2024\" is not a date (YYYY-MM-DD)"),
+		},
+		{
+			name: "restaurant already exists",
+			restaurant: model.Restaurant{
+				Name: restName,
+			},
+			responseCode: http.StatusConflict,
+			responseBody: problem(http.StatusConflict, httpResponse.CodeConflict, "a restaurant with this id already exists"),
+			stubErr:      storage.ErrConflict,
+		},
 		{
 			name: "location error",
 			restaurant: model.Restaurant{
@@ -108,14 +146,14 @@ func Test_Create(t *testing.T) {
 				Address: &model.Address{},
 			},
 			responseCode: http.StatusInternalServerError,
-			responseBody: `{"Message":"an error occurred"}`,
+			responseBody: internalError,
 			stubError:    stubError{location: "an error occurred"},
 		},
 		{
 			name:         "empty request body",
 			emptyReqBody: true,
 			responseCode: http.StatusBadRequest,
-			responseBody: `{"Message":"error request body is empty"}`,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeMalformedBody, "the request body is empty"),
 		},
 	}
 
@@ -125,7 +163,7 @@ func Test_Create(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 			rc := Restaurant{
-				Restaurant: restaurantStorerStub{error: tc.stubError.restaurant},
+				Restaurant: restaurantStorerStub{error: tc.stubError.restaurant, err: tc.stubErr},
 				Location:   locationServiceStub{error: tc.stubError.location},
 			}
 
@@ -138,9 +176,12 @@ func Test_Create(t *testing.T) {
 			resp, _ := rc.Create(request)
 
 			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			if tc.responseCode == http.StatusCreated {
+				assert.Equal(t, `"1"`, resp.Headers["ETag"])
+			}
 
 			if tc.responseCode != http.StatusCreated {
-				assert.Equal(t, tc.responseBody, resp.Body)
+				assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
 			} else {
 				// Convert to type Restaurant so comparison can be done
 				// without the "Id" field
@@ -166,9 +207,11 @@ func Test_Read(t *testing.T) {
 	testCases := []struct {
 		name         string
 		restaurantId string
+		ifNoneMatch  string
 		notExist     bool
 		responseCode int
 		responseBody string
+		etag         string
 		stubError    string
 	}{
 		{
@@ -176,17 +219,40 @@ func Test_Read(t *testing.T) {
 			restaurantId: "restId",
 			responseCode: http.StatusOK,
 			responseBody: `{"name":""}`,
+			etag:         `"3"`,
+		},
+		{
+			name:         "if-none-match matches",
+			restaurantId: "restId",
+			ifNoneMatch:  `"2", W/"3"`,
+			responseCode: http.StatusNotModified,
+			etag:         `"3"`,
+		},
+		{
+			name:         "if-none-match any",
+			restaurantId: "restId",
+			ifNoneMatch:  "*",
+			responseCode: http.StatusNotModified,
+			etag:         `"3"`,
+		},
+		{
+			name:         "if-none-match does not match",
+			restaurantId: "restId",
+			ifNoneMatch:  `"2"`,
+			responseCode: http.StatusOK,
+			responseBody: `{"name":""}`,
+			etag:         `"3"`,
 		},
 		{
 			name:         "empty restaurantId",
 			responseCode: http.StatusBadRequest,
-			responseBody: `{"Message":"restaurantId is empty"}`,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "restaurantId is empty"),
 		},
 		{
 			name:         "storage error",
 			restaurantId: "restId",
 			responseCode: http.StatusInternalServerError,
-			responseBody: `{"Message":"an error occurred"}`,
+			responseBody: internalError,
 			stubError:    "an error occurred",
 		},
 		{
@@ -194,6 +260,7 @@ func Test_Read(t *testing.T) {
 			restaurantId: "restId",
 			notExist:     true,
 			responseCode: http.StatusNotFound,
+			responseBody: problem(http.StatusNotFound, httpResponse.CodeNotFound, "the restaurant was not found"),
 		},
 	}
 
@@ -202,18 +269,42 @@ func Test_Read(t *testing.T) {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
-			rc := Restaurant{Restaurant: restaurantStorerStub{notExist: tc.notExist, error: tc.stubError}}
+			rc := Restaurant{Restaurant: restaurantStorerStub{version: 3, notExist: tc.notExist, error: tc.stubError}}
 
 			resp, _ := rc.Read(events.APIGatewayProxyRequest{
 				PathParameters: map[string]string{"restaurantId": tc.restaurantId},
+				Headers:        map[string]string{"If-None-Match": tc.ifNoneMatch},
 			})
 
 			assert.Equal(t, tc.responseCode, resp.StatusCode)
-			assert.Equal(t, tc.responseBody, resp.Body)
+			assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
+			assert.Equal(t, tc.etag, resp.Headers["ETag"])
 		})
 	}
 }
 
+// Test_ETagRating checks that a new rating summary changes the ETag the reads are revalidated
+// with, but not the version If-Match checks.
+func Test_ETagRating(t *testing.T) {
+	t.Parallel()
+
+	rated := etag(3, &model.RatingSummary{Count: 1, Average: 4, Histogram: map[string]int{"4": 1}})
+	reviewed := etag(3, &model.RatingSummary{Count: 2, Average: 4.5, Histogram: map[string]int{"4": 1, "5": 1}})
+
+	assert.Equal(t, `"3"`, etag(3, nil))
+	assert.Regexp(t, `^"3\.[0-9a-f]{8}"$`, rated)
+	assert.NotEqual(t, rated, reviewed)
+
+	version, ok := ifMatchVersion(events.APIGatewayProxyRequest{Headers: map[string]string{"If-Match": rated}})
+	require.True(t, ok)
+	require.NotNil(t, version)
+	assert.Equal(t, int64(3), *version)
+
+	request := events.APIGatewayProxyRequest{Headers: map[string]string{"If-None-Match": rated}}
+	assert.True(t, ifNoneMatch(request, rated))
+	assert.False(t, ifNoneMatch(request, reviewed))
+}
+
 func Test_Update(t *testing.T) {
 	t.Parallel()
 	restId, restName := "Rest1", "Rest 1"
@@ -229,15 +320,30 @@ func Test_Update(t *testing.T) {
 		Id:   &restId,
 		Name: restName,
 	})
+	city, geocode, timezone := "Seattle", "47.606200,-122.332100", "America/Los_Angeles"
+	stored := model.Restaurant{
+		Id:   &restId,
+		Name: restName,
+		Address: &model.Address{
+			City:         &city,
+			Location:     &model.Location{Geocode: &geocode},
+			TimezoneName: &timezone,
+		},
+	}
+	restaurantStoredAddressExp, _ := json.Marshal(stored)
 
 	testCases := []struct {
 		name         string
 		restaurantId string
 		restaurant   model.Restaurant
+		ifMatch      string
 		emptyReqBody bool
 		responseCode int
 		responseBody string
+		stored       *model.Restaurant
+		notExist     bool
 		stubError    stubError
+		stubErr      error
 	}{
 		{
 			name:         "happy path",
@@ -250,6 +356,62 @@ func Test_Update(t *testing.T) {
 			responseCode: http.StatusOK,
 			responseBody: string(restaurantExp),
 		},
+		{
+			name:         "if-match",
+			restaurantId: restId,
+			restaurant: model.Restaurant{
+				Id:   &restId,
+				Name: restName,
+			},
+			ifMatch:      `"3"`,
+			responseCode: http.StatusOK,
+			responseBody: string(restaurantNoAddressExp),
+		},
+		{
+			name:         "if-match does not match",
+			restaurantId: restId,
+			restaurant: model.Restaurant{
+				Id:   &restId,
+				Name: restName,
+			},
+			ifMatch:      `"2"`,
+			responseCode: http.StatusPreconditionFailed,
+			responseBody: problem(http.StatusPreconditionFailed, httpResponse.CodePreconditionFailed, "If-Match does not match the current ETag of the restaurant"),
+			stubErr:      storage.ErrPreconditionFailed,
+		},
+		{
+			name:         "restaurant changed after it was authorized",
+			restaurantId: restId,
+			restaurant: model.Restaurant{
+				Id:   &restId,
+				Name: restName,
+			},
+			responseCode: http.StatusConflict,
+			responseBody: problem(http.StatusConflict, httpResponse.CodeConflict, "the restaurant changed while it was written"),
+			stubErr:      storage.ErrPreconditionFailed,
+		},
+		{
+			name:         "restaurant does not exist",
+			restaurantId: restId,
+			restaurant: model.Restaurant{
+				Id:   &restId,
+				Name: restName,
+			},
+			responseCode: http.StatusNotFound,
+			responseBody: problem(http.StatusNotFound, httpResponse.CodeNotFound, "the restaurant was not found"),
+			stubErr:      storage.ErrNotFound,
+		},
+		{
+			name:         "weak if-match",
+			restaurantId: restId,
+			restaurant: model.Restaurant{
+				Id:   &restId,
+				Name: restName,
+			},
+			ifMatch:      `W/"3"`,
+			responseCode: http.StatusPreconditionFailed,
+			responseBody: problem(http.StatusPreconditionFailed, httpResponse.CodePreconditionFailed, "If-Match does not match the current ETag of the restaurant"),
+		},
 		{
 			name:         "no address",
 			restaurantId: restId,
@@ -260,6 +422,43 @@ func Test_Update(t *testing.T) {
 			responseCode: http.StatusOK,
 			responseBody: string(restaurantNoAddressExp),
 		},
+		{
+			name:         "address unchanged is not geocoded",
+			restaurantId: restId,
+			restaurant: model.Restaurant{
+				Id:      &restId,
+				Name:    restName,
+				Address: &model.Address{City: &city},
+			},
+			stored:       &stored,
+			responseCode: http.StatusOK,
+			responseBody: string(restaurantStoredAddressExp),
+			stubError:    stubError{location: "geocoder must not be called"},
+		},
+		{
+			name:         "address changed is geocoded",
+			restaurantId: restId,
+			restaurant: model.Restaurant{
+				Id:      &restId,
+				Name:    restName,
+				Address: &model.Address{},
+			},
+			stored:       &stored,
+			responseCode: http.StatusOK,
+			responseBody: string(restaurantExp),
+		},
+		{
+			name:         "restaurant with address does not exist",
+			restaurantId: restId,
+			restaurant: model.Restaurant{
+				Id:      &restId,
+				Name:    restName,
+				Address: &model.Address{City: &city},
+			},
+			notExist:     true,
+			responseCode: http.StatusNotFound,
+			responseBody: problem(http.StatusNotFound, httpResponse.CodeNotFound, "the restaurant was not found"),
+		},
 		{
 			name:         "restaurantId is nil",
 			restaurantId: restId,
@@ -267,7 +466,18 @@ func Test_Update(t *testing.T) {
 				Name: restName,
 			},
 			responseCode: http.StatusBadRequest,
-			responseBody: `{"Message":"restaurantId in URL path parameters and restaurant in body do not match"}`,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "restaurantId in URL path parameters and restaurant in body do not match"),
+		},
+		{
+			name:         "invalid opening hours",
+			restaurantId: restId,
+			restaurant: model.Restaurant{
+				Id:           &restId,
+				Name:         restName,
+				OpeningHours: &model.OpeningHours{Weekly: &[]model.WeeklyInterval{{Day: "Mon", Open: "11:00", Close: "14:00"}}},
+			},
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "openingHours.weekly[0].day \"Mon\" is not a day of the week (monday to sunday)"),
 		},
 		{
 			name:         "mismatch restaurantId",
@@ -277,14 +487,14 @@ func Test_Update(t *testing.T) {
 				Name: restName,
 			},
 			responseCode: http.StatusBadRequest,
-			responseBody: `{"Message":"restaurantId in URL path parameters and restaurant in body do not match"}`,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "restaurantId in URL path parameters and restaurant in body do not match"),
 		},
 		{
 			name:         "storage error",
 			restaurantId: restId,
 			restaurant:   model.Restaurant{Id: &restId},
 			responseCode: http.StatusInternalServerError,
-			responseBody: `{"Message":"an error occurred"}`,
+			responseBody: internalError,
 			stubError:    stubError{restaurant: "an error occurred"},
 		},
 		{
@@ -296,14 +506,14 @@ func Test_Update(t *testing.T) {
 				Address: &model.Address{},
 			},
 			responseCode: http.StatusInternalServerError,
-			responseBody: `{"Message":"an error occurred"}`,
+			responseBody: internalError,
 			stubError:    stubError{location: "an error occurred"},
 		},
 		{
 			name:         "empty request body",
 			emptyReqBody: true,
 			responseCode: http.StatusBadRequest,
-			responseBody: `{"Message":"error request body is empty"}`,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeMalformedBody, "the request body is empty"),
 		},
 	}
 
@@ -313,12 +523,13 @@ func Test_Update(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 			rc := Restaurant{
-				Restaurant: restaurantStorerStub{error: tc.stubError.restaurant},
+				Restaurant: restaurantStorerStub{restaurant: tc.stored, version: 3, notExist: tc.notExist, error: tc.stubError.restaurant, err: tc.stubErr},
 				Location:   locationServiceStub{error: tc.stubError.location},
 			}
 
 			request := events.APIGatewayProxyRequest{
 				PathParameters: map[string]string{"restaurantId": tc.restaurantId},
+				Headers:        map[string]string{"If-Match": tc.ifMatch},
 			}
 			if !tc.emptyReqBody {
 				body, _ := json.Marshal(tc.restaurant)
@@ -328,7 +539,257 @@ func Test_Update(t *testing.T) {
 			resp, _ := rc.Update(request)
 
 			assert.Equal(t, tc.responseCode, resp.StatusCode)
-			assert.Equal(t, tc.responseBody, resp.Body)
+			assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
+			if tc.responseCode == http.StatusOK {
+				assert.Equal(t, `"4"`, resp.Headers["ETag"])
+			}
+		})
+	}
+}
+
+func Test_Patch(t *testing.T) {
+	t.Parallel()
+	restId, restName, city, geocode, timezone := "Rest1", "Rest 1", "Seattle", "47.606200,-122.332100", "America/Los_Angeles"
+	stored := model.Restaurant{
+		Id:   &restId,
+		Name: restName,
+		Address: &model.Address{
+			City:         &city,
+			Location:     &model.Location{Geocode: &geocode},
+			TimezoneName: &timezone,
+		},
+	}
+
+	testCases := []struct {
+		name         string
+		restaurantId string
+		contentType  string
+		ifMatch      string
+		body         string
+		notExist     bool
+		responseCode int
+		responseBody string
+		etag         string
+		stubError    stubError
+		stubErr      error
+	}{
+		{
+			name:         "merge patch without address change does not geocode",
+			restaurantId: restId,
+			contentType:  "application/merge-patch+json",
+			body:         `{"name":"Rest 2","description":"new"}`,
+			responseCode: http.StatusOK,
+			responseBody: `{"address":{"city":"Seattle","location":{"geocode":"47.606200,-122.332100"},"timezoneName":"America/Los_Angeles"},"description":"new","id":"Rest1","name":"Rest 2"}`,
+			etag:         `"4"`,
+			stubError:    stubError{location: "geocode should not be called"},
+		},
+		{
+			name:         "if-match",
+			restaurantId: restId,
+			ifMatch:      `"3"`,
+			body:         `{"name":"Rest 2"}`,
+			responseCode: http.StatusOK,
+			responseBody: `{"address":{"city":"Seattle","location":{"geocode":"47.606200,-122.332100"},"timezoneName":"America/Los_Angeles"},"id":"Rest1","name":"Rest 2"}`,
+			etag:         `"4"`,
+		},
+		{
+			name:         "if-match does not match",
+			restaurantId: restId,
+			ifMatch:      `"2"`,
+			body:         `{"name":"Rest 2"}`,
+			responseCode: http.StatusPreconditionFailed,
+			responseBody: problem(http.StatusPreconditionFailed, httpResponse.CodePreconditionFailed, "If-Match does not match the current ETag of the restaurant"),
+		},
+		{
+			name:         "patch without changes keeps the version",
+			restaurantId: restId,
+			body:         `{"name":"Rest 1"}`,
+			responseCode: http.StatusOK,
+			responseBody: `{"address":{"city":"Seattle","location":{"geocode":"47.606200,-122.332100"},"timezoneName":"America/Los_Angeles"},"id":"Rest1","name":"Rest 1"}`,
+			etag:         `"3"`,
+		},
+		{
+			name:         "merge patch with address change geocodes",
+			restaurantId: restId,
+			body:         `{"address":{"city":"Portland"}}`,
+			responseCode: http.StatusOK,
+			responseBody: `{"address":{"city":"Portland","location":{},"timezoneName":""},"id":"Rest1","name":"Rest 1"}`,
+		},
+		{
+			name:         "merge patch removes address",
+			restaurantId: restId,
+			contentType:  "application/merge-patch+json; charset=utf-8",
+			body:         `{"address":null}`,
+			responseCode: http.StatusOK,
+			responseBody: `{"id":"Rest1","name":"Rest 1"}`,
+			stubError:    stubError{location: "geocode should not be called"},
+		},
+		{
+			name:         "json patch",
+			restaurantId: restId,
+			contentType:  "application/json-patch+json",
+			body:         `[{"op":"replace","path":"/name","value":"Rest 2"},{"op":"add","path":"/phoneNumber","value":"555-1234"}]`,
+			responseCode: http.StatusOK,
+			responseBody: `{"address":{"city":"Seattle","location":{"geocode":"47.606200,-122.332100"},"timezoneName":"America/Los_Angeles"},"id":"Rest1","name":"Rest 2","phoneNumber":"555-1234"}`,
+			stubError:    stubError{location: "geocode should not be called"},
+		},
+		{
+			name:         "json patch test fails",
+			restaurantId: restId,
+			contentType:  "application/json-patch+json",
+			body:         `[{"op":"test","path":"/name","value":"Other"}]`,
+			responseCode: http.StatusUnprocessableEntity,
+			responseBody: problem(http.StatusUnprocessableEntity, httpResponse.CodePatchFailed, "error applying JSON Patch: testing value /name failed: test failed"),
+		},
+		{
+			name:         "malformed json patch",
+			restaurantId: restId,
+			contentType:  "application/json-patch+json",
+			body:         `{"op":"replace"}`,
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeTypeMismatch, "the request body must be an array, not object"),
+		},
+		{
+			name:         "malformed merge patch",
+			restaurantId: restId,
+			body:         `{"name":`,
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeMalformedBody, "the request body is not valid JSON: it ends unexpectedly"),
+		},
+		{
+			name:         "merge patch not an object",
+			restaurantId: restId,
+			contentType:  "application/merge-patch+json",
+			body:         `["name"]`,
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeTypeMismatch, "the request body must be an object, not array"),
+		},
+		{
+			name:         "changing the id",
+			restaurantId: restId,
+			body:         `{"id":"Rest2"}`,
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "the restaurant id cannot be changed"),
+		},
+		{
+			name:         "patched restaurant without a name",
+			restaurantId: restId,
+			body:         `{"name":""}`,
+			responseCode: http.StatusBadRequest,
+			responseBody: httpResponse.NewViolations([]model.Violation{{Field: "name", Message: "minimum string length is 1"}}).Body,
+		},
+		{
+			name:         "patched restaurant with an unknown field",
+			restaurantId: restId,
+			contentType:  "application/json-patch+json",
+			body:         `[{"op":"add","path":"/cuisine","value":"Thai"}]`,
+			responseCode: http.StatusBadRequest,
+			responseBody: httpResponse.NewViolations([]model.Violation{{Field: "cuisine", Message: "is not a known field"}}).Body,
+		},
+		{
+			name:         "invalid opening hours",
+			restaurantId: restId,
+			body:         `{"openingHours":{"weekly":[{"day":"monday","open":"11:00","close":"25:00"}]}}`,
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "openingHours.weekly[0].close \"25:00\" is not a time (HH:MM, or 24:00)"),
+		},
+		{
+			name:         "unsupported content type",
+			restaurantId: restId,
+			contentType:  "text/plain",
+			body:         `name=Rest 2`,
+			responseCode: http.StatusUnsupportedMediaType,
+			responseBody: problem(http.StatusUnsupportedMediaType, httpResponse.CodeUnsupportedMediaType, "Content-Type must be application/merge-patch+json or application/json-patch+json"),
+		},
+		{
+			name:         "restaurant does not exist",
+			restaurantId: restId,
+			body:         `{"name":"Rest 2"}`,
+			notExist:     true,
+			responseCode: http.StatusNotFound,
+			responseBody: problem(http.StatusNotFound, httpResponse.CodeNotFound, "the restaurant was not found"),
+		},
+		{
+			name:         "restaurant deleted while patching",
+			restaurantId: restId,
+			body:         `{"name":"Rest 2"}`,
+			responseCode: http.StatusNotFound,
+			responseBody: problem(http.StatusNotFound, httpResponse.CodeNotFound, "the restaurant was not found"),
+			stubErr:      storage.ErrNotFound,
+		},
+		{
+			name:         "empty restaurantId",
+			body:         `{"name":"Rest 2"}`,
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "restaurantId is empty"),
+		},
+		{
+			name:         "empty request body",
+			restaurantId: restId,
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeMalformedBody, "the request body is empty"),
+		},
+		{
+			name:         "restaurant changed after it was authorized",
+			restaurantId: restId,
+			body:         `{"name":"Rest 2"}`,
+			responseCode: http.StatusConflict,
+			responseBody: problem(http.StatusConflict, httpResponse.CodeConflict, "the restaurant changed while it was written"),
+			stubErr:      storage.ErrPreconditionFailed,
+		},
+		{
+			name:         "restaurant changed after it was authorized with if-match",
+			restaurantId: restId,
+			body:         `{"name":"Rest 2"}`,
+			ifMatch:      `"3"`,
+			responseCode: http.StatusPreconditionFailed,
+			responseBody: problem(http.StatusPreconditionFailed, httpResponse.CodePreconditionFailed, "If-Match does not match the current ETag of the restaurant"),
+			stubErr:      storage.ErrPreconditionFailed,
+		},
+		{
+			name:         "storage error",
+			restaurantId: restId,
+			body:         `{"name":"Rest 2"}`,
+			responseCode: http.StatusInternalServerError,
+			responseBody: internalError,
+			stubError:    stubError{restaurant: "an error occurred"},
+		},
+		{
+			name:         "location error",
+			restaurantId: restId,
+			body:         `{"address":{"city":"Portland"}}`,
+			responseCode: http.StatusInternalServerError,
+			responseBody: internalError,
+			stubError:    stubError{location: "an error occurred"},
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			// Get returns a copy of the stored restaurant for every test case
+			restaurant := stored
+			address := *stored.Address
+			restaurant.Address = &address
+
+			rc := Restaurant{
+				Restaurant: restaurantStorerStub{restaurant: &restaurant, version: 3, notExist: tc.notExist, error: tc.stubError.restaurant, err: tc.stubErr},
+				Location:   locationServiceStub{error: tc.stubError.location},
+			}
+
+			resp, _ := rc.Patch(events.APIGatewayProxyRequest{
+				PathParameters: map[string]string{"restaurantId": tc.restaurantId},
+				Headers:        map[string]string{"content-type": tc.contentType, "if-match": tc.ifMatch},
+				Body:           tc.body,
+			})
+
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
+			if tc.etag != "" {
+				assert.Equal(t, tc.etag, resp.Headers["ETag"])
+			}
 		})
 	}
 }
@@ -339,25 +800,57 @@ func Test_Delete(t *testing.T) {
 	testCases := []struct {
 		name         string
 		restaurantId string
+		ifMatch      string
 		responseCode int
 		responseBody string
 		stubError    string
+		stubErr      error
 	}{
 		{
 			name:         "happy path",
 			restaurantId: "restId",
 			responseCode: http.StatusOK,
+			responseBody: `{"name":""}`,
+		},
+		{
+			name:         "if-match",
+			restaurantId: "restId",
+			ifMatch:      `"3"`,
+			responseCode: http.StatusOK,
+			responseBody: `{"name":""}`,
+		},
+		{
+			name:         "restaurant does not exist",
+			restaurantId: "restId",
+			responseCode: http.StatusNotFound,
+			responseBody: problem(http.StatusNotFound, httpResponse.CodeNotFound, "the restaurant was not found"),
+			stubErr:      storage.ErrNotFound,
+		},
+		{
+			name:         "if-match does not match",
+			restaurantId: "restId",
+			ifMatch:      `"2"`,
+			responseCode: http.StatusPreconditionFailed,
+			responseBody: problem(http.StatusPreconditionFailed, httpResponse.CodePreconditionFailed, "If-Match does not match the current ETag of the restaurant"),
+			stubErr:      storage.ErrPreconditionFailed,
+		},
+		{
+			name:         "malformed if-match",
+			restaurantId: "restId",
+			ifMatch:      `"2", "3"`,
+			responseCode: http.StatusPreconditionFailed,
+			responseBody: problem(http.StatusPreconditionFailed, httpResponse.CodePreconditionFailed, "If-Match does not match the current ETag of the restaurant"),
 		},
 		{
 			name:         "empty restaurantId",
 			responseCode: http.StatusBadRequest,
-			responseBody: `{"Message":"restaurantId is empty"}`,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "restaurantId is empty"),
 		},
 		{
 			name:         "storage error",
 			restaurantId: "restId",
 			responseCode: http.StatusInternalServerError,
-			responseBody: `{"Message":"an error occurred"}`,
+			responseBody: internalError,
 			stubError:    "an error occurred",
 		},
 	}
@@ -367,61 +860,453 @@ func Test_Delete(t *testing.T) {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
-			rc := Restaurant{Restaurant: restaurantStorerStub{error: tc.stubError}}
+			rc := Restaurant{Restaurant: restaurantStorerStub{error: tc.stubError, err: tc.stubErr}}
 
 			resp, _ := rc.Delete(events.APIGatewayProxyRequest{
 				PathParameters: map[string]string{"restaurantId": tc.restaurantId},
+				Headers:        map[string]string{"If-Match": tc.ifMatch},
+			})
+
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
+		})
+	}
+}
+
+func Test_List(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		query        map[string]string
+		stub         restaurantStorerStub
+		responseCode int
+		responseBody string
+	}{
+		{
+			name:         "happy path",
+			responseCode: http.StatusOK,
+			responseBody: `{"items":[{"name":""}]}`,
+		},
+		{
+			name:         "more results",
+			query:        map[string]string{"limit": "1", "nextToken": "token1"},
+			stub:         restaurantStorerStub{nextToken: "token2"},
+			responseCode: http.StatusOK,
+			responseBody: `{"items":[{"name":""}],"nextToken":"token2"}`,
+		},
+		{
+			name:         "no restaurants",
+			stub:         restaurantStorerStub{notExist: true},
+			responseCode: http.StatusOK,
+			responseBody: `{"items":[]}`,
+		},
+		{
+			name:         "limit not a number",
+			query:        map[string]string{"limit": "ten"},
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "limit must be an integer between 1 and 100"),
+		},
+		{
+			name:         "limit out of range",
+			query:        map[string]string{"limit": "101"},
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "limit must be an integer between 1 and 100"),
+		},
+		{
+			name:         "invalid nextToken",
+			query:        map[string]string{"nextToken": "bad"},
+			stub:         restaurantStorerStub{err: storage.ErrInvalidNextToken},
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "nextToken is invalid"),
+		},
+		{
+			name:         "storage error",
+			stub:         restaurantStorerStub{error: "an error occurred"},
+			responseCode: http.StatusInternalServerError,
+			responseBody: internalError,
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			rc := Restaurant{Restaurant: tc.stub}
+
+			resp, _ := rc.List(events.APIGatewayProxyRequest{
+				QueryStringParameters: tc.query,
+			})
+
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
+		})
+	}
+}
+
+func Test_Nearby(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		query        map[string]string
+		stub         restaurantStorerStub
+		responseCode int
+		responseBody string
+	}{
+		{
+			name:         "happy path",
+			query:        map[string]string{"lat": "47.6", "lon": "-122.3", "radiusKm": "2.5"},
+			responseCode: http.StatusOK,
+			responseBody: `{"items":[{"distanceKm":1.5,"restaurant":{"name":""}}]}`,
+		},
+		{
+			name:         "nothing nearby",
+			query:        map[string]string{"lat": "47.6", "lon": "-122.3", "radiusKm": "2.5"},
+			stub:         restaurantStorerStub{notExist: true},
+			responseCode: http.StatusOK,
+			responseBody: `{"items":[]}`,
+		},
+		{
+			name:         "missing lat",
+			query:        map[string]string{"lon": "-122.3", "radiusKm": "2.5"},
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "lat must be a number between -90 and 90"),
+		},
+		{
+			name:         "lon out of range",
+			query:        map[string]string{"lat": "47.6", "lon": "-222.3", "radiusKm": "2.5"},
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "lon must be a number between -180 and 180"),
+		},
+		{
+			name:         "radiusKm too large",
+			query:        map[string]string{"lat": "47.6", "lon": "-122.3", "radiusKm": "51"},
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "radiusKm must be a number greater than 0 and at most 50"),
+		},
+		{
+			name:         "radiusKm too large for the latitude",
+			query:        map[string]string{"lat": "89.9", "lon": "-122.3", "radiusKm": "50"},
+			stub:         restaurantStorerStub{err: storage.ErrRadiusTooLarge},
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "radiusKm is too large for this latitude"),
+		},
+		{
+			name:         "storage error",
+			query:        map[string]string{"lat": "47.6", "lon": "-122.3", "radiusKm": "2.5"},
+			stub:         restaurantStorerStub{error: "an error occurred"},
+			responseCode: http.StatusInternalServerError,
+			responseBody: internalError,
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			rc := Restaurant{Restaurant: tc.stub}
+
+			resp, _ := rc.Nearby(events.APIGatewayProxyRequest{
+				QueryStringParameters: tc.query,
 			})
 
 			assert.Equal(t, tc.responseCode, resp.StatusCode)
-			assert.Equal(t, tc.responseBody, resp.Body)
+			assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
 		})
 	}
 }
 
 type restaurantStorerStub struct {
-	notExist bool
-	error    string
+	restaurant *model.Restaurant
+	version    int64
+	notExist   bool
+	nextToken  string
+	error      string
+	err        error
 }
 
-func (s restaurantStorerStub) Save(_ model.Restaurant) error {
+func (s restaurantStorerStub) Save(_ string, _ model.Restaurant) error {
+	if s.err != nil {
+		return s.err
+	}
 	if s.error != "" {
 		return errors.New(s.error)
 	}
 	return nil
 }
 
-func (s restaurantStorerStub) Get(_ string) (model.Restaurant, bool, error) {
+func (s restaurantStorerStub) Get(_, _ string) (model.Restaurant, int64, bool, error) {
 	if s.error != "" {
-		return model.Restaurant{}, false, errors.New(s.error)
+		return model.Restaurant{}, 0, false, errors.New(s.error)
 	}
 	if s.notExist {
-		return model.Restaurant{}, false, nil
+		return model.Restaurant{}, 0, false, nil
 	}
-	return model.Restaurant{}, true, nil
+	if s.restaurant != nil {
+		return *s.restaurant, s.version, true, nil
+	}
+	return model.Restaurant{}, s.version, true, nil
 }
 
-func (s restaurantStorerStub) Update(_ model.Restaurant) error {
+func (s restaurantStorerStub) Update(_ string, _ model.Restaurant, ifVersion *int64) (int64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	if ifVersion == nil || *ifVersion != s.version {
+		return 0, storage.ErrPreconditionFailed
+	}
 	if s.error != "" {
-		return errors.New(s.error)
+		return 0, errors.New(s.error)
 	}
-	return nil
+	return s.version + 1, nil
 }
 
-func (s restaurantStorerStub) Delete(_ string) error {
+func (s restaurantStorerStub) Patch(_ string, _, _ model.Restaurant, ifVersion *int64) (int64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	if ifVersion == nil || *ifVersion != s.version {
+		return 0, storage.ErrPreconditionFailed
+	}
 	if s.error != "" {
-		return errors.New(s.error)
+		return 0, errors.New(s.error)
 	}
-	return nil
+	return s.version + 1, nil
+}
+
+func (s restaurantStorerStub) Delete(_, _ string, _ *int64) (model.Restaurant, error) {
+	if s.err != nil {
+		return model.Restaurant{}, s.err
+	}
+	if s.error != "" {
+		return model.Restaurant{}, errors.New(s.error)
+	}
+	return model.Restaurant{}, nil
+}
+
+func (s restaurantStorerStub) List(_ string, _ int32, _ string) ([]model.Restaurant, string, error) {
+	if s.err != nil {
+		return nil, "", s.err
+	}
+	if s.error != "" {
+		return nil, "", errors.New(s.error)
+	}
+	if s.notExist {
+		return []model.Restaurant{}, "", nil
+	}
+	return []model.Restaurant{{}}, s.nextToken, nil
+}
+
+func (s restaurantStorerStub) Nearby(_ string, _, _, _ float64) ([]model.NearbyRestaurant, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.error != "" {
+		return nil, errors.New(s.error)
+	}
+	if s.notExist {
+		return []model.NearbyRestaurant{}, nil
+	}
+	return []model.NearbyRestaurant{{DistanceKm: 1.5}}, nil
 }
 
 type locationServiceStub struct {
-	error string
+	location   *model.Location
+	candidates []model.GeocodeCandidate
+	error      string
 }
 
 func (s locationServiceStub) Geocode(_ model.Address) (model.Location, string, error) {
 	if s.error != "" {
 		return model.Location{}, "", errors.New(s.error)
 	}
+	if s.location != nil {
+		return *s.location, "America/Los_Angeles", nil
+	}
 	return model.Location{}, "", nil
 }
+
+func (s locationServiceStub) Candidates(_ model.Address) ([]model.GeocodeCandidate, error) {
+	if s.error != "" {
+		return nil, errors.New(s.error)
+	}
+	return s.candidates, nil
+}
+
+func Test_Locate(t *testing.T) {
+	t.Parallel()
+	city, otherCity, timezone := "Seattle", "Tacoma", "America/Los_Angeles"
+	storedLoc := model.NewLocation(47.6062, -122.3321)
+	stored := model.Address{City: &city, Location: &storedLoc, TimezoneName: &timezone}
+	chosenLoc := model.Location{Latitude: aFloat(47.2529), Longitude: aFloat(-122.4443)}
+	chosenExp := model.NewLocation(47.2529, -122.4443)
+	geocoded := model.NewLocation(47.5, -122.5)
+	geocoded.Relevance = aFloat(0.9)
+	lowRelevance := model.NewLocation(47.5, -122.5)
+	lowRelevance.Relevance = aFloat(0.5)
+	noRelevance := model.NewLocation(47.5, -122.5)
+	storedNoTimezone := model.Address{City: &city, Location: &storedLoc}
+	denverLoc := model.Location{Latitude: aFloat(39.7392), Longitude: aFloat(-104.9903)}
+	denverExp := model.NewLocation(39.7392, -104.9903)
+	invalidTimezone := "PST"
+
+	testCases := []struct {
+		name         string
+		address      model.Address
+		stored       *model.Address
+		location     *model.Location
+		minRelevance float64
+		locationExp  *model.Location
+		// timezoneExp defaults to America/Los_Angeles
+		timezoneExp  string
+		responseCode int
+		responseBody string
+	}{
+		{
+			name:        "geocoded",
+			address:     model.Address{City: &city},
+			location:    &geocoded,
+			locationExp: &geocoded,
+		},
+		{
+			name:        "address unchanged",
+			address:     model.Address{City: &city},
+			stored:      &stored,
+			location:    &geocoded,
+			locationExp: &storedLoc,
+		},
+		{
+			name:        "address changed",
+			address:     model.Address{City: &otherCity, Location: &storedLoc},
+			stored:      &stored,
+			location:    &geocoded,
+			locationExp: &geocoded,
+		},
+		{
+			name:        "chosen location",
+			address:     model.Address{City: &otherCity, Location: &chosenLoc, TimezoneName: &timezone},
+			stored:      &stored,
+			location:    &geocoded,
+			locationExp: &chosenExp,
+		},
+		{
+			name:        "chosen location on create",
+			address:     model.Address{City: &otherCity, Location: &chosenLoc, TimezoneName: &timezone},
+			location:    &geocoded,
+			locationExp: &chosenExp,
+		},
+		{
+			name:        "chosen location without timezone",
+			address:     model.Address{City: &otherCity, Location: &chosenLoc},
+			location:    &geocoded,
+			locationExp: &chosenExp,
+		},
+		{
+			name:        "chosen location in another timezone",
+			address:     model.Address{City: &otherCity, Location: &denverLoc},
+			location:    &geocoded,
+			locationExp: &denverExp,
+			timezoneExp: "America/Denver",
+		},
+		{
+			name:         "chosen location with invalid timezone",
+			address:      model.Address{City: &otherCity, Location: &chosenLoc, TimezoneName: &invalidTimezone},
+			location:     &geocoded,
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "timezoneName \"PST\" is not an IANA time zone"),
+		},
+		{
+			name:        "stored location without timezone",
+			address:     model.Address{City: &city},
+			stored:      &storedNoTimezone,
+			location:    &geocoded,
+			locationExp: &storedLoc,
+		},
+		{
+			name:         "relevance above minimum",
+			address:      model.Address{City: &city},
+			location:     &geocoded,
+			minRelevance: 0.8,
+			locationExp:  &geocoded,
+		},
+		{
+			name:         "relevance below minimum",
+			address:      model.Address{City: &city},
+			location:     &lowRelevance,
+			minRelevance: 0.8,
+			responseCode: http.StatusUnprocessableEntity,
+			responseBody: problem(http.StatusUnprocessableEntity, httpResponse.CodeAddressNotRelevant, "the address matched with relevance 0.50, below the minimum of 0.80, use POST /geocode/preview to choose its location"),
+		},
+		{
+			name:        "relevance below minimum not checked",
+			address:     model.Address{City: &city},
+			location:    &lowRelevance,
+			locationExp: &lowRelevance,
+		},
+		{
+			name:         "no relevance",
+			address:      model.Address{City: &city},
+			location:     &noRelevance,
+			minRelevance: 0.8,
+			locationExp:  &noRelevance,
+		},
+		{
+			name:         "address not found",
+			address:      model.Address{City: &city},
+			minRelevance: 0.8,
+			responseCode: http.StatusUnprocessableEntity,
+			responseBody: problem(http.StatusUnprocessableEntity, httpResponse.CodeAddressNotFound, "the address was not found, use POST /geocode/preview to choose its location"),
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			rc := Restaurant{
+				Location:     locationServiceStub{location: tc.location},
+				MinRelevance: tc.minRelevance,
+			}
+
+			address := tc.address
+			resp := rc.locate(&address, tc.stored)
+
+			if tc.responseCode != 0 {
+				require.NotNil(t, resp)
+				assert.Equal(t, tc.responseCode, resp.StatusCode)
+				assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
+				return
+			}
+			assert.Nil(t, resp)
+			assert.Equal(t, tc.locationExp, address.Location)
+			timezoneExp := tc.timezoneExp
+			if timezoneExp == "" {
+				timezoneExp = timezone
+			}
+			assert.Equal(t, &timezoneExp, address.TimezoneName)
+		})
+	}
+}
+
+func aFloat(f float64) *float64 {
+	return &f
+}
+
+// internalError is the body of a 500 response, without its random correlation id.
+var internalError = problem(http.StatusInternalServerError, httpResponse.CodeInternal, "an internal error occurred")
+
+var correlationIdPattern = regexp.MustCompile(`"correlationId":"[^"]*",`)
+
+// problem returns the body of a problem response.
+func problem(statusCode int, code httpResponse.Code, detail string) string {
+	return httpResponse.NewProblem(statusCode, code, detail).Body
+}
+
+// withoutCorrelationId removes the random correlation id of an internal error from the body.
+func withoutCorrelationId(body string) string {
+	return correlationIdPattern.ReplaceAllString(body, "")
+}
@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/google/uuid"
+	"github.com/lfroomin/restaurant-serverless/internal/dynamo"
+	"github.com/lfroomin/restaurant-serverless/internal/httpRequest"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/policy"
+	"github.com/lfroomin/restaurant-serverless/internal/print"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ReviewStorer stores the reviews of the restaurants of each tenant apart, like RestaurantStorer.
+type ReviewStorer interface {
+	SaveReview(tenantId, restaurantId string, review model.Review) error
+	GetReview(tenantId, restaurantId, reviewId string) (model.Review, bool, error)
+	DeleteReview(tenantId, restaurantId, reviewId string) (model.Review, error)
+	ListReviews(tenantId, restaurantId string, order storage.ReviewOrder, limit int32, nextToken string) ([]model.Review, string, error)
+}
+
+// Review serves the reviews of a restaurant, under /{restaurantId}/reviews. Anyone can read
+// the reviews, authenticated callers can review a restaurant, and admins can delete reviews.
+type Review struct {
+	Restaurant RestaurantStorer
+	Review     ReviewStorer
+	// Policy authorizes each action. When it is nil every action is allowed.
+	Policy Authorizer
+}
+
+// New creates the controller. The reviews are stored in the restaurants table.
+func (rv Review) New(cfg aws.Config, restaurantsTable string) Review {
+	storer := dynamo.New(cfg, restaurantsTable)
+	return Review{Restaurant: storer, Review: storer, Policy: policy.Default}
+}
+
+// Create saves the review and adds its rating to the rating summary of the restaurant.
+func (rv Review) Create(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	print.Request(request)
+
+	restaurantId := request.PathParameters["restaurantId"]
+
+	// Validate input
+	if restaurantId == "" {
+		return httpResponse.NewBadRequest("restaurantId is empty"), nil
+	}
+
+	review := model.Review{}
+	if resp := httpRequest.DecodeJSON(request, &review); resp != nil {
+		return resp, nil
+	}
+
+	if err := review.Validate(); err != nil {
+		return httpResponse.NewBadRequest(err.Error()), nil
+	}
+
+	tenantId, resp := tenantOf(request)
+	if resp != nil {
+		return resp, nil
+	}
+
+	if resp := authorize(rv.Policy, request, policy.ActionCreateReview, nil, nil); resp != nil {
+		return resp, nil
+	}
+
+	id := uuid.NewString()
+	created := time.Now().UTC().Truncate(time.Millisecond)
+	review.Id, review.Created = &id, &created
+	log.Printf("create review tenantId: %s  restaurantId: %s  reviewId: %s  rating: %d\n", tenantId, restaurantId, *review.Id, review.Rating)
+
+	if err := rv.Review.SaveReview(tenantId, restaurantId, review); err != nil {
+		return storageError(err, "restaurant"), nil
+	}
+
+	return httpResponse.New(http.StatusCreated, review), nil
+}
+
+func (rv Review) Read(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	print.Request(request)
+
+	restaurantId := request.PathParameters["restaurantId"]
+	reviewId := request.PathParameters["reviewId"]
+
+	// Validate input
+	if restaurantId == "" || reviewId == "" {
+		return httpResponse.NewBadRequest("restaurantId or reviewId is empty"), nil
+	}
+
+	tenantId, resp := tenantOf(request)
+	if resp != nil {
+		return resp, nil
+	}
+
+	if resp := authorize(rv.Policy, request, policy.ActionRead, nil, nil); resp != nil {
+		return resp, nil
+	}
+
+	log.Printf("read tenantId: %s  restaurantId: %s  reviewId: %s\n", tenantId, restaurantId, reviewId)
+
+	review, exists, err := rv.Review.GetReview(tenantId, restaurantId, reviewId)
+	if err != nil {
+		return httpResponse.NewServerError(err), nil
+	}
+
+	if !exists {
+		return httpResponse.NewNotFound("the review was not found"), nil
+	}
+
+	return httpResponse.New(http.StatusOK, review), nil
+}
+
+// Delete removes the review and its rating from the rating summary of the restaurant.
+func (rv Review) Delete(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	print.Request(request)
+
+	restaurantId := request.PathParameters["restaurantId"]
+	reviewId := request.PathParameters["reviewId"]
+
+	// Validate input
+	if restaurantId == "" || reviewId == "" {
+		return httpResponse.NewBadRequest("restaurantId or reviewId is empty"), nil
+	}
+
+	tenantId, resp := tenantOf(request)
+	if resp != nil {
+		return resp, nil
+	}
+
+	if resp := authorize(rv.Policy, request, policy.ActionDeleteReview, nil, nil); resp != nil {
+		return resp, nil
+	}
+
+	log.Printf("delete tenantId: %s  restaurantId: %s  reviewId: %s\n", tenantId, restaurantId, reviewId)
+
+	review, err := rv.Review.DeleteReview(tenantId, restaurantId, reviewId)
+	if err != nil {
+		return storageError(err, "review"), nil
+	}
+
+	return httpResponse.New(http.StatusOK, review), nil
+}
+
+// List returns a page of the reviews of the restaurant, the newest first or,
+// with the sort query parameter set to highest, the highest rating first.
+func (rv Review) List(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	print.Request(request)
+
+	restaurantId := request.PathParameters["restaurantId"]
+
+	// Validate input
+	if restaurantId == "" {
+		return httpResponse.NewBadRequest("restaurantId is empty"), nil
+	}
+	order := storage.ReviewsNewest
+	if s, ok := request.QueryStringParameters["sort"]; ok {
+		order = storage.ReviewOrder(s)
+		if order != storage.ReviewsNewest && order != storage.ReviewsHighest {
+			return httpResponse.NewBadRequest(fmt.Sprintf("sort must be %s or %s", storage.ReviewsNewest, storage.ReviewsHighest)), nil
+		}
+	}
+	limit, ok := listLimit(request)
+	if !ok {
+		return httpResponse.NewBadRequest(fmt.Sprintf("limit must be an integer between 1 and %d", maxListLimit)), nil
+	}
+	nextToken := request.QueryStringParameters["nextToken"]
+
+	tenantId, resp := tenantOf(request)
+	if resp != nil {
+		return resp, nil
+	}
+
+	if resp := authorize(rv.Policy, request, policy.ActionRead, nil, nil); resp != nil {
+		return resp, nil
+	}
+
+	log.Printf("list reviews tenantId: %s  restaurantId: %s  sort: %s  limit: %d  nextToken: %s\n", tenantId, restaurantId, order, limit, nextToken)
+
+	_, _, exists, err := rv.Restaurant.Get(tenantId, restaurantId)
+	if err != nil {
+		return httpResponse.NewServerError(err), nil
+	}
+	if !exists {
+		return httpResponse.NewNotFound("the restaurant was not found"), nil
+	}
+
+	reviews, token, err := rv.Review.ListReviews(tenantId, restaurantId, order, limit, nextToken)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidNextToken) {
+			return httpResponse.NewBadRequest("nextToken is invalid"), nil
+		}
+		return httpResponse.NewServerError(err), nil
+	}
+
+	list := model.ReviewList{Items: reviews}
+	if token != "" {
+		list.NextToken = &token
+	}
+
+	return httpResponse.New(http.StatusOK, list), nil
+}
@@ -0,0 +1,503 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lfroomin/restaurant-serverless/internal/auth/authtest"
+	"github.com/lfroomin/restaurant-serverless/internal/awsConfig"
+	"github.com/lfroomin/restaurant-serverless/internal/dynamo"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/policy"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_ReviewNew(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := awsConfig.New()
+	require.NoError(t, err)
+
+	rv := Review{}.New(cfg, "RestaurantsTable")
+
+	assert.IsType(t, dynamo.RestaurantStorage{}, rv.Restaurant)
+	assert.IsType(t, dynamo.RestaurantStorage{}, rv.Review)
+	assert.Equal(t, "RestaurantsTable", rv.Review.(dynamo.RestaurantStorage).Table)
+	assert.Equal(t, policy.Default, rv.Policy)
+}
+
+func Test_ReviewCreate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		restaurantId string
+		body         string
+		stub         reviewStorerStub
+		responseCode int
+		responseBody string
+	}{
+		{
+			name:         "happy path",
+			restaurantId: "restId",
+			body:         `{"rating":4,"author":"Ana","text":"Great tacos"}`,
+			responseCode: http.StatusCreated,
+		},
+		{
+			name:         "id and created are set by the service",
+			restaurantId: "restId",
+			body:         `{"id":"myId","created":"2020-01-01T00:00:00Z","rating":4,"author":"Ana","text":"Great tacos"}`,
+			responseCode: http.StatusCreated,
+		},
+		{
+			name:         "invalid rating",
+			restaurantId: "restId",
+			body:         `{"rating":6,"author":"Ana"}`,
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "rating 6 is not between 1 and 5"),
+		},
+		{
+			name:         "restaurant not found",
+			restaurantId: "restId",
+			body:         `{"rating":4,"author":"Ana"}`,
+			stub:         reviewStorerStub{err: storage.ErrNotFound},
+			responseCode: http.StatusNotFound,
+			responseBody: problem(http.StatusNotFound, httpResponse.CodeNotFound, "the restaurant was not found"),
+		},
+		{
+			name:         "storage error",
+			restaurantId: "restId",
+			body:         `{"rating":4,"author":"Ana"}`,
+			stub:         reviewStorerStub{error: "an error occurred"},
+			responseCode: http.StatusInternalServerError,
+			responseBody: internalError,
+		},
+		{
+			name:         "empty request body",
+			restaurantId: "restId",
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeMalformedBody, "the request body is empty"),
+		},
+		{
+			name:         "restaurantId empty",
+			body:         `{"rating":4,"author":"Ana"}`,
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "restaurantId is empty"),
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			rv := Review{Restaurant: restaurantStorerStub{}, Review: tc.stub}
+
+			start := time.Now().Add(-time.Second)
+			resp, _ := rv.Create(events.APIGatewayProxyRequest{
+				PathParameters: map[string]string{"restaurantId": tc.restaurantId},
+				Body:           tc.body,
+			})
+
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			if tc.responseCode != http.StatusCreated {
+				assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
+				return
+			}
+
+			created := model.Review{}
+			require.NoError(t, json.Unmarshal([]byte(resp.Body), &created))
+			require.NotNil(t, created.Id)
+			assert.NotEqual(t, "myId", *created.Id)
+			require.NotNil(t, created.Created)
+			assert.True(t, created.Created.After(start))
+			assert.Equal(t, 4, created.Rating)
+			assert.Equal(t, "Ana", created.Author)
+		})
+	}
+}
+
+func Test_ReviewRead(t *testing.T) {
+	t.Parallel()
+	review := aReview("reviewId", 4)
+
+	testCases := []struct {
+		name         string
+		reviewId     string
+		stub         reviewStorerStub
+		responseCode int
+		responseBody string
+	}{
+		{
+			name:         "happy path",
+			reviewId:     "reviewId",
+			stub:         reviewStorerStub{review: &review},
+			responseCode: http.StatusOK,
+			responseBody: reviewJson(review),
+		},
+		{
+			name:         "review not found",
+			reviewId:     "reviewId",
+			responseCode: http.StatusNotFound,
+			responseBody: problem(http.StatusNotFound, httpResponse.CodeNotFound, "the review was not found"),
+		},
+		{
+			name:         "storage error",
+			reviewId:     "reviewId",
+			stub:         reviewStorerStub{error: "an error occurred"},
+			responseCode: http.StatusInternalServerError,
+			responseBody: internalError,
+		},
+		{
+			name:         "reviewId empty",
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "restaurantId or reviewId is empty"),
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			rv := Review{Restaurant: restaurantStorerStub{}, Review: tc.stub}
+
+			resp, _ := rv.Read(events.APIGatewayProxyRequest{
+				PathParameters: map[string]string{"restaurantId": "restId", "reviewId": tc.reviewId},
+			})
+
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
+		})
+	}
+}
+
+func Test_ReviewDelete(t *testing.T) {
+	t.Parallel()
+	review := aReview("reviewId", 4)
+
+	testCases := []struct {
+		name         string
+		reviewId     string
+		stub         reviewStorerStub
+		responseCode int
+		responseBody string
+	}{
+		{
+			name:         "happy path",
+			reviewId:     "reviewId",
+			stub:         reviewStorerStub{review: &review},
+			responseCode: http.StatusOK,
+			responseBody: reviewJson(review),
+		},
+		{
+			name:         "review not found",
+			reviewId:     "reviewId",
+			stub:         reviewStorerStub{err: storage.ErrNotFound},
+			responseCode: http.StatusNotFound,
+			responseBody: problem(http.StatusNotFound, httpResponse.CodeNotFound, "the review was not found"),
+		},
+		{
+			name:         "storage error",
+			reviewId:     "reviewId",
+			stub:         reviewStorerStub{error: "an error occurred"},
+			responseCode: http.StatusInternalServerError,
+			responseBody: internalError,
+		},
+		{
+			name:         "reviewId empty",
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "restaurantId or reviewId is empty"),
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			rv := Review{Restaurant: restaurantStorerStub{}, Review: tc.stub}
+
+			resp, _ := rv.Delete(events.APIGatewayProxyRequest{
+				PathParameters: map[string]string{"restaurantId": "restId", "reviewId": tc.reviewId},
+			})
+
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
+		})
+	}
+}
+
+func Test_ReviewList(t *testing.T) {
+	t.Parallel()
+	review := aReview("reviewId", 4)
+
+	testCases := []struct {
+		name             string
+		queryParams      map[string]string
+		stub             reviewStorerStub
+		restaurantExists bool
+		order            storage.ReviewOrder
+		responseCode     int
+		responseBody     string
+	}{
+		{
+			name:             "happy path",
+			stub:             reviewStorerStub{review: &review, nextToken: "token2"},
+			restaurantExists: true,
+			order:            storage.ReviewsNewest,
+			responseCode:     http.StatusOK,
+			responseBody:     `{"items":[` + reviewJson(review) + `],"nextToken":"token2"}`,
+		},
+		{
+			name:             "highest rating first",
+			queryParams:      map[string]string{"sort": "highest", "limit": "5", "nextToken": "token1"},
+			stub:             reviewStorerStub{review: &review},
+			restaurantExists: true,
+			order:            storage.ReviewsHighest,
+			responseCode:     http.StatusOK,
+			responseBody:     `{"items":[` + reviewJson(review) + `]}`,
+		},
+		{
+			name:             "no reviews",
+			restaurantExists: true,
+			responseCode:     http.StatusOK,
+			responseBody:     `{"items":[]}`,
+		},
+		{
+			name:             "invalid sort",
+			queryParams:      map[string]string{"sort": "lowest"},
+			restaurantExists: true,
+			responseCode:     http.StatusBadRequest,
+			responseBody:     problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "sort must be newest or highest"),
+		},
+		{
+			name:             "invalid limit",
+			queryParams:      map[string]string{"limit": "0"},
+			restaurantExists: true,
+			responseCode:     http.StatusBadRequest,
+			responseBody:     problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "limit must be an integer between 1 and 100"),
+		},
+		{
+			name:             "invalid next token",
+			queryParams:      map[string]string{"nextToken": "bad"},
+			stub:             reviewStorerStub{err: storage.ErrInvalidNextToken},
+			restaurantExists: true,
+			responseCode:     http.StatusBadRequest,
+			responseBody:     problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "nextToken is invalid"),
+		},
+		{
+			name:         "restaurant not found",
+			responseCode: http.StatusNotFound,
+			responseBody: problem(http.StatusNotFound, httpResponse.CodeNotFound, "the restaurant was not found"),
+		},
+		{
+			name:             "storage error",
+			stub:             reviewStorerStub{error: "an error occurred"},
+			restaurantExists: true,
+			responseCode:     http.StatusInternalServerError,
+			responseBody:     internalError,
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			stub := tc.stub
+			stub.order = new(storage.ReviewOrder)
+			rv := Review{Restaurant: restaurantStorerStub{notExist: !tc.restaurantExists}, Review: stub}
+
+			resp, _ := rv.List(events.APIGatewayProxyRequest{
+				PathParameters:        map[string]string{"restaurantId": "restId"},
+				QueryStringParameters: tc.queryParams,
+			})
+
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
+			if tc.order != "" {
+				assert.Equal(t, tc.order, *stub.order)
+			}
+		})
+	}
+}
+
+func aReview(id string, rating int) model.Review {
+	created := time.Date(2024, 3, 8, 18, 30, 0, 0, time.UTC)
+	return model.Review{Id: &id, Rating: rating, Author: "Ana", Created: &created}
+}
+
+func reviewJson(review model.Review) string {
+	b, _ := json.Marshal(review)
+	return string(b)
+}
+
+type reviewStorerStub struct {
+	review    *model.Review
+	nextToken string
+	// order is set to the order of the last ListReviews call
+	order *storage.ReviewOrder
+	error string
+	err   error
+}
+
+func (s reviewStorerStub) SaveReview(_, _ string, _ model.Review) error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.error != "" {
+		return errors.New(s.error)
+	}
+	return nil
+}
+
+func (s reviewStorerStub) GetReview(_, _, _ string) (model.Review, bool, error) {
+	if s.error != "" {
+		return model.Review{}, false, errors.New(s.error)
+	}
+	if s.review == nil {
+		return model.Review{}, false, nil
+	}
+	return *s.review, true, nil
+}
+
+func (s reviewStorerStub) DeleteReview(_, _, _ string) (model.Review, error) {
+	if s.err != nil {
+		return model.Review{}, s.err
+	}
+	if s.error != "" {
+		return model.Review{}, errors.New(s.error)
+	}
+	if s.review == nil {
+		return model.Review{}, nil
+	}
+	return *s.review, nil
+}
+
+func (s reviewStorerStub) ListReviews(_, _ string, order storage.ReviewOrder, _ int32, _ string) ([]model.Review, string, error) {
+	if s.order != nil {
+		*s.order = order
+	}
+	if s.err != nil {
+		return nil, "", s.err
+	}
+	if s.error != "" {
+		return nil, "", errors.New(s.error)
+	}
+	if s.review == nil {
+		return []model.Review{}, "", nil
+	}
+	return []model.Review{*s.review}, s.nextToken, nil
+}
+
+func Test_ReviewAuthorize(t *testing.T) {
+	t.Parallel()
+
+	issuer := authtest.NewIssuer(t)
+	admin := bearer(t, issuer, "admin1", policy.RoleAdmin)
+	owner := bearer(t, issuer, "owner1")
+	user := bearer(t, issuer, "user1")
+	review := aReview("reviewId", 4)
+
+	testCases := []struct {
+		name          string
+		action        func(Review, events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error)
+		method        string
+		authorization string
+		body          string
+		responseCode  int
+		reason        policy.Reason
+	}{
+		{
+			name:         "anonymous read",
+			action:       Review.Read,
+			method:       http.MethodGet,
+			responseCode: http.StatusOK,
+		},
+		{
+			name:         "anonymous list",
+			action:       Review.List,
+			method:       http.MethodGet,
+			responseCode: http.StatusOK,
+		},
+		{
+			name:         "anonymous create",
+			action:       Review.Create,
+			method:       http.MethodPost,
+			body:         `{"rating":4,"author":"Ana"}`,
+			responseCode: http.StatusUnauthorized,
+		},
+		{
+			name:          "invalid token",
+			action:        Review.Delete,
+			method:        http.MethodDelete,
+			authorization: "Bearer not-a-token",
+			responseCode:  http.StatusUnauthorized,
+		},
+		{
+			name:          "user create",
+			action:        Review.Create,
+			method:        http.MethodPost,
+			authorization: user,
+			body:          `{"rating":4,"author":"Ana"}`,
+			responseCode:  http.StatusCreated,
+		},
+		{
+			name:          "user delete",
+			action:        Review.Delete,
+			method:        http.MethodDelete,
+			authorization: user,
+			responseCode:  http.StatusForbidden,
+			reason:        policy.ReasonAdminRequired,
+		},
+		{
+			name:          "owner delete",
+			action:        Review.Delete,
+			method:        http.MethodDelete,
+			authorization: owner,
+			responseCode:  http.StatusForbidden,
+			reason:        policy.ReasonAdminRequired,
+		},
+		{
+			name:          "admin delete",
+			action:        Review.Delete,
+			method:        http.MethodDelete,
+			authorization: admin,
+			responseCode:  http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			restId, ownerIds := "restId", []string{"owner1"}
+			rv := Review{
+				Restaurant: restaurantStorerStub{restaurant: &model.Restaurant{Id: &restId, Name: "name", OwnerIds: &ownerIds}},
+				Review:     &reviewStorerStub{review: &review},
+				Policy:     policy.Default,
+			}
+			h := authenticated(t, issuer, func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+				return tc.action(rv, request)
+			})
+
+			resp, err := h(events.APIGatewayProxyRequest{
+				HTTPMethod:     tc.method,
+				PathParameters: map[string]string{"restaurantId": restId, "reviewId": "reviewId"},
+				Headers:        map[string]string{"Authorization": tc.authorization},
+				Body:           tc.body,
+			})
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.responseCode, resp.StatusCode, resp.Body)
+			assertForbidden(t, resp, tc.reason)
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/policy"
+	"github.com/lfroomin/restaurant-serverless/internal/print"
+	"github.com/lfroomin/restaurant-serverless/internal/timezone"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Status tells whether the restaurant is open at the time of the at query
+// parameter (now by default), and when it next opens and closes. The opening
+// hours are evaluated in the time zone of the restaurant address.
+func (r Restaurant) Status(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	print.Request(request)
+
+	restaurantId := request.PathParameters["restaurantId"]
+
+	// Validate input
+	if restaurantId == "" {
+		return httpResponse.NewBadRequest("restaurantId is empty"), nil
+	}
+	at := time.Now()
+	if a, ok := request.QueryStringParameters["at"]; ok {
+		var err error
+		if at, err = time.Parse(time.RFC3339, a); err != nil {
+			return httpResponse.NewBadRequest("at must be an RFC 3339 time, such as 2024-03-08T18:30:00Z"), nil
+		}
+	}
+
+	tenantId, resp := tenantOf(request)
+	if resp != nil {
+		return resp, nil
+	}
+	if resp := r.authorize(request, policy.ActionRead, nil, nil); resp != nil {
+		return resp, nil
+	}
+
+	log.Printf("status tenantId: %s  restaurantId: %s  at: %s\n", tenantId, restaurantId, at.Format(time.RFC3339))
+
+	restaurant, _, exists, err := r.Restaurant.Get(tenantId, restaurantId)
+	if err != nil {
+		return httpResponse.NewServerError(err), nil
+	}
+	if !exists {
+		return httpResponse.NewNotFound("the restaurant was not found"), nil
+	}
+
+	if restaurant.OpeningHours == nil {
+		return httpResponse.NewProblem(http.StatusUnprocessableEntity, httpResponse.CodeNoOpeningHours, "the restaurant has no opening hours"), nil
+	}
+	if restaurant.Address == nil || restaurant.Address.TimezoneName == nil || !timezone.Valid(*restaurant.Address.TimezoneName) {
+		return httpResponse.NewProblem(http.StatusUnprocessableEntity, httpResponse.CodeNoTimeZone, "the restaurant has no time zone, its address must be located"), nil
+	}
+
+	loc, err := time.LoadLocation(*restaurant.Address.TimezoneName)
+	if err != nil {
+		return httpResponse.NewServerError(err), nil
+	}
+
+	return httpResponse.New(http.StatusOK, restaurant.OpeningHours.Status(at, loc)), nil
+}
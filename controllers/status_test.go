@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func Test_Status(t *testing.T) {
+	t.Parallel()
+	timezone, invalidTimezone := "America/Los_Angeles", "PST"
+	hours := model.OpeningHours{Weekly: &[]model.WeeklyInterval{{Day: "friday", Open: "18:00", Close: "02:00"}}}
+	restaurant := model.Restaurant{Name: "name", OpeningHours: &hours, Address: &model.Address{TimezoneName: &timezone}}
+	noHours := model.Restaurant{Name: "name", Address: &model.Address{TimezoneName: &timezone}}
+	noTimezone := model.Restaurant{Name: "name", OpeningHours: &hours}
+	badTimezone := model.Restaurant{Name: "name", OpeningHours: &hours, Address: &model.Address{TimezoneName: &invalidTimezone}}
+
+	testCases := []struct {
+		name         string
+		restaurantId string
+		at           string
+		stored       *model.Restaurant
+		notExist     bool
+		stubError    string
+		responseCode int
+		responseBody string
+	}{
+		{
+			name:         "open",
+			restaurantId: "restId",
+			at:           "2024-03-09T09:00:00Z",
+			stored:       &restaurant,
+			responseCode: http.StatusOK,
+			responseBody: `{"at":"2024-03-09T01:00:00-08:00","nextClose":"2024-03-09T02:00:00-08:00","nextOpen":"2024-03-15T18:00:00-07:00","open":true,"timezoneName":"America/Los_Angeles"}`,
+		},
+		{
+			name:         "closed",
+			restaurantId: "restId",
+			at:           "2024-03-09T12:00:00-08:00",
+			stored:       &restaurant,
+			responseCode: http.StatusOK,
+			responseBody: `{"at":"2024-03-09T12:00:00-08:00","nextClose":"2024-03-16T02:00:00-07:00","nextOpen":"2024-03-15T18:00:00-07:00","open":false,"timezoneName":"America/Los_Angeles"}`,
+		},
+		{
+			name:         "invalid at",
+			restaurantId: "restId",
+			at:           "tomorrow",
+			stored:       &restaurant,
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "at must be an RFC 3339 time, such as 2024-03-08T18:30:00Z"),
+		},
+		{
+			name:         "no opening hours",
+			restaurantId: "restId",
+			at:           "2024-03-09T12:00:00Z",
+			stored:       &noHours,
+			responseCode: http.StatusUnprocessableEntity,
+			responseBody: problem(http.StatusUnprocessableEntity, httpResponse.CodeNoOpeningHours, "the restaurant has no opening hours"),
+		},
+		{
+			name:         "no time zone",
+			restaurantId: "restId",
+			at:           "2024-03-09T12:00:00Z",
+			stored:       &noTimezone,
+			responseCode: http.StatusUnprocessableEntity,
+			responseBody: problem(http.StatusUnprocessableEntity, httpResponse.CodeNoTimeZone, "the restaurant has no time zone, its address must be located"),
+		},
+		{
+			name:         "invalid time zone",
+			restaurantId: "restId",
+			at:           "2024-03-09T12:00:00Z",
+			stored:       &badTimezone,
+			responseCode: http.StatusUnprocessableEntity,
+			responseBody: problem(http.StatusUnprocessableEntity, httpResponse.CodeNoTimeZone, "the restaurant has no time zone, its address must be located"),
+		},
+		{
+			name:         "restaurant not found",
+			restaurantId: "restId",
+			at:           "2024-03-09T12:00:00Z",
+			notExist:     true,
+			responseCode: http.StatusNotFound,
+			responseBody: problem(http.StatusNotFound, httpResponse.CodeNotFound, "the restaurant was not found"),
+		},
+		{
+			name:         "storage error",
+			restaurantId: "restId",
+			at:           "2024-03-09T12:00:00Z",
+			stubError:    "an error occurred",
+			responseCode: http.StatusInternalServerError,
+			responseBody: internalError,
+		},
+		{
+			name:         "restaurantId empty",
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "restaurantId is empty"),
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			rc := Restaurant{Restaurant: restaurantStorerStub{restaurant: tc.stored, notExist: tc.notExist, error: tc.stubError}}
+
+			request := events.APIGatewayProxyRequest{PathParameters: map[string]string{"restaurantId": tc.restaurantId}}
+			if tc.at != "" {
+				request.QueryStringParameters = map[string]string{"at": tc.at}
+			}
+			resp, _ := rc.Status(request)
+
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
+		})
+	}
+}
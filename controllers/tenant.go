@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lfroomin/restaurant-serverless/internal/httpRequest"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/policy"
+	"github.com/lfroomin/restaurant-serverless/internal/tenant"
+	"log"
+)
+
+// tenantOf returns the tenant of the request, which all the restaurants it reads and writes
+// belong to. It returns the error response when the request names an invalid tenant, or
+// another tenant than the one of its token.
+func tenantOf(request events.APIGatewayProxyRequest) (string, *events.APIGatewayProxyResponse) {
+	tenantId, err := tenant.FromRequest(request)
+	switch {
+	case errors.Is(err, tenant.ErrMismatch):
+		log.Printf("forbidden subject: %s  tenantId: %s  reason: %s\n", principal(request).Subject, httpRequest.Header(request, tenant.Header), policy.ReasonTenantMismatch)
+		return "", httpResponse.NewForbidden(string(policy.ReasonTenantMismatch), "the X-Tenant-Id header is not the tenant of the token")
+	case err != nil:
+		return "", httpResponse.NewBadRequest("the tenant id must be 1 to 64 letters, digits, hyphens or underscores")
+	}
+	return tenantId, nil
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lfroomin/restaurant-serverless/internal/auth"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/policy"
+	"github.com/lfroomin/restaurant-serverless/internal/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func Test_Tenant(t *testing.T) {
+	t.Parallel()
+
+	restId := "Rest1"
+	admin := &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "admin1"}, Roles: []string{policy.RoleAdmin}, TenantId: "brand-a"}
+
+	testCases := []struct {
+		name         string
+		action       func(Restaurant, events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error)
+		header       string
+		claims       *auth.Claims
+		responseCode int
+		code         httpResponse.Code
+		tenantId     string
+	}{
+		{
+			name:         "anonymous read of the default tenant",
+			action:       Restaurant.Read,
+			responseCode: http.StatusOK,
+			tenantId:     tenant.Default,
+		},
+		{
+			name:         "anonymous read of a tenant",
+			action:       Restaurant.Read,
+			header:       "brand-b",
+			responseCode: http.StatusOK,
+			tenantId:     "brand-b",
+		},
+		{
+			name:         "read of the tenant of the token",
+			action:       Restaurant.Read,
+			claims:       admin,
+			responseCode: http.StatusOK,
+			tenantId:     "brand-a",
+		},
+		{
+			name:         "read of another tenant than the token",
+			action:       Restaurant.Read,
+			header:       "brand-b",
+			claims:       admin,
+			responseCode: http.StatusForbidden,
+			code:         httpResponse.CodeForbidden,
+		},
+		{
+			name:         "admin delete in another tenant than the token",
+			action:       Restaurant.Delete,
+			header:       "brand-b",
+			claims:       admin,
+			responseCode: http.StatusForbidden,
+			code:         httpResponse.CodeForbidden,
+		},
+		{
+			name:         "invalid tenant",
+			action:       Restaurant.Read,
+			header:       "TENANT#brand-a",
+			responseCode: http.StatusBadRequest,
+			code:         httpResponse.CodeInvalidRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var tenantId string
+			rc := Restaurant{
+				Restaurant: tenantRecorderStub{restaurantStorerStub: restaurantStorerStub{restaurant: &model.Restaurant{Id: &restId}, version: 1}, tenantId: &tenantId},
+				Location:   locationServiceStub{},
+				Policy:     policy.Default,
+			}
+
+			request := events.APIGatewayProxyRequest{
+				PathParameters: map[string]string{"restaurantId": restId},
+				Headers:        map[string]string{},
+			}
+			if tc.header != "" {
+				request.Headers[tenant.Header] = tc.header
+			}
+			if tc.claims != nil {
+				request = auth.WithClaims(request, *tc.claims)
+			}
+
+			resp, err := tc.action(rc, request)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.responseCode, resp.StatusCode, resp.Body)
+			assert.Equal(t, tc.tenantId, tenantId)
+			if tc.code != "" {
+				problem := model.Problem{}
+				require.NoError(t, json.Unmarshal([]byte(resp.Body), &problem))
+				assert.Equal(t, string(tc.code), problem.Code)
+				if tc.code == httpResponse.CodeForbidden {
+					require.NotNil(t, problem.Reason)
+					assert.Equal(t, string(policy.ReasonTenantMismatch), *problem.Reason)
+				}
+			}
+		})
+	}
+}
+
+// tenantRecorderStub records the tenant the restaurant is read from
+type tenantRecorderStub struct {
+	restaurantStorerStub
+	tenantId *string
+}
+
+func (s tenantRecorderStub) Get(tenantId, restaurantId string) (model.Restaurant, int64, bool, error) {
+	*s.tenantId = tenantId
+	return s.restaurantStorerStub.Get(tenantId, restaurantId)
+}
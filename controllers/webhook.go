@@ -0,0 +1,243 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/google/uuid"
+	"github.com/lfroomin/restaurant-serverless/internal/dynamo"
+	"github.com/lfroomin/restaurant-serverless/internal/event"
+	"github.com/lfroomin/restaurant-serverless/internal/httpRequest"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/policy"
+	"github.com/lfroomin/restaurant-serverless/internal/print"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const minSecretLength = 16
+
+type WebhookStorer interface {
+	SaveWebhook(tenantId string, w model.Webhook) error
+	GetWebhook(tenantId, webhookId string) (model.Webhook, bool, error)
+	ListWebhooks(tenantId string) ([]model.Webhook, error)
+	DeleteWebhook(tenantId, webhookId string) (model.Webhook, error)
+	ListDeliveries(tenantId, webhookId string, limit int32, nextToken string) ([]model.WebhookDelivery, string, error)
+}
+
+// Webhook serves the webhook subscriptions and their delivery logs, under /webhooks. The
+// webhooks belong to the tenant of the request, and receive the events of its restaurants
+// only. They are managed by the admins.
+type Webhook struct {
+	Webhook WebhookStorer
+	// Policy authorizes each action. When it is nil every action is allowed.
+	Policy Authorizer
+}
+
+// New creates the controller. The webhooks are stored in the restaurants table.
+func (wh Webhook) New(cfg aws.Config, restaurantsTable string) Webhook {
+	return Webhook{Webhook: dynamo.New(cfg, restaurantsTable), Policy: policy.Default}
+}
+
+// Create registers the webhook. Its secret is only returned by Create.
+func (wh Webhook) Create(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	print.Request(request)
+
+	tenantId, resp := wh.authorize(request)
+	if resp != nil {
+		return resp, nil
+	}
+
+	webhook := model.Webhook{}
+	if resp := httpRequest.DecodeJSON(request, &webhook); resp != nil {
+		return resp, nil
+	}
+
+	if err := validateWebhook(webhook); err != nil {
+		return httpResponse.NewBadRequest(err.Error()), nil
+	}
+
+	id := uuid.NewString()
+	created := time.Now().UTC().Truncate(time.Millisecond)
+	webhook.Id, webhook.Created = &id, &created
+	log.Printf("create webhook tenantId: %s  webhookId: %s  url: %s  eventTypes: %v\n", tenantId, *webhook.Id, webhook.Url, webhook.EventTypes)
+
+	if err := wh.Webhook.SaveWebhook(tenantId, webhook); err != nil {
+		return storageError(err, "webhook"), nil
+	}
+
+	return httpResponse.New(http.StatusCreated, webhook), nil
+}
+
+func (wh Webhook) List(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	print.Request(request)
+
+	tenantId, resp := wh.authorize(request)
+	if resp != nil {
+		return resp, nil
+	}
+
+	webhooks, err := wh.Webhook.ListWebhooks(tenantId)
+	if err != nil {
+		return httpResponse.NewServerError(err), nil
+	}
+
+	for i := range webhooks {
+		webhooks[i].Secret = nil
+	}
+
+	return httpResponse.New(http.StatusOK, model.WebhookList{Items: webhooks}), nil
+}
+
+func (wh Webhook) Read(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	print.Request(request)
+
+	webhookId := request.PathParameters["webhookId"]
+
+	// Validate input
+	if webhookId == "" {
+		return httpResponse.NewBadRequest("webhookId is empty"), nil
+	}
+
+	tenantId, resp := wh.authorize(request)
+	if resp != nil {
+		return resp, nil
+	}
+
+	log.Printf("read tenantId: %s  webhookId: %s\n", tenantId, webhookId)
+
+	webhook, exists, err := wh.Webhook.GetWebhook(tenantId, webhookId)
+	if err != nil {
+		return httpResponse.NewServerError(err), nil
+	}
+
+	if !exists {
+		return httpResponse.NewNotFound("the webhook was not found"), nil
+	}
+
+	webhook.Secret = nil
+	return httpResponse.New(http.StatusOK, webhook), nil
+}
+
+// Delete removes the webhook and its delivery log. Its pending deliveries are not sent.
+func (wh Webhook) Delete(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	print.Request(request)
+
+	webhookId := request.PathParameters["webhookId"]
+
+	// Validate input
+	if webhookId == "" {
+		return httpResponse.NewBadRequest("webhookId is empty"), nil
+	}
+
+	tenantId, resp := wh.authorize(request)
+	if resp != nil {
+		return resp, nil
+	}
+
+	log.Printf("delete tenantId: %s  webhookId: %s\n", tenantId, webhookId)
+
+	webhook, err := wh.Webhook.DeleteWebhook(tenantId, webhookId)
+	if err != nil {
+		return storageError(err, "webhook"), nil
+	}
+
+	webhook.Secret = nil
+	return httpResponse.New(http.StatusOK, webhook), nil
+}
+
+// Deliveries returns a page of the delivery log of the webhook, the newest event first.
+func (wh Webhook) Deliveries(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	print.Request(request)
+
+	webhookId := request.PathParameters["webhookId"]
+
+	// Validate input
+	if webhookId == "" {
+		return httpResponse.NewBadRequest("webhookId is empty"), nil
+	}
+	limit, ok := listLimit(request)
+	if !ok {
+		return httpResponse.NewBadRequest(fmt.Sprintf("limit must be an integer between 1 and %d", maxListLimit)), nil
+	}
+	nextToken := request.QueryStringParameters["nextToken"]
+
+	tenantId, resp := wh.authorize(request)
+	if resp != nil {
+		return resp, nil
+	}
+
+	log.Printf("list deliveries tenantId: %s  webhookId: %s  limit: %d  nextToken: %s\n", tenantId, webhookId, limit, nextToken)
+
+	_, exists, err := wh.Webhook.GetWebhook(tenantId, webhookId)
+	if err != nil {
+		return httpResponse.NewServerError(err), nil
+	}
+	if !exists {
+		return httpResponse.NewNotFound("the webhook was not found"), nil
+	}
+
+	deliveries, token, err := wh.Webhook.ListDeliveries(tenantId, webhookId, limit, nextToken)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidNextToken) {
+			return httpResponse.NewBadRequest("nextToken is invalid"), nil
+		}
+		return httpResponse.NewServerError(err), nil
+	}
+
+	list := model.WebhookDeliveryList{Items: deliveries}
+	if token != "" {
+		list.NextToken = &token
+	}
+
+	return httpResponse.New(http.StatusOK, list), nil
+}
+
+// authorize returns the tenant of the request, or the error response when the caller may
+// not manage its webhooks.
+func (wh Webhook) authorize(request events.APIGatewayProxyRequest) (string, *events.APIGatewayProxyResponse) {
+	tenantId, resp := tenantOf(request)
+	if resp != nil {
+		return "", resp
+	}
+	if resp := authorize(wh.Policy, request, policy.ActionManageWebhooks, nil, nil); resp != nil {
+		return "", resp
+	}
+	return tenantId, nil
+}
+
+// validateWebhook returns an error describing the first invalid field of the webhook.
+func validateWebhook(webhook model.Webhook) error {
+	u, err := url.Parse(webhook.Url)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("url %q is not an https URL", webhook.Url)
+	}
+
+	if len(webhook.EventTypes) == 0 {
+		return errors.New("eventTypes is empty")
+	}
+	for _, t := range webhook.EventTypes {
+		if !knownEventType(t) {
+			return fmt.Errorf("event type %q is not one of %v", t, event.Types)
+		}
+	}
+
+	if webhook.Secret == nil || len(*webhook.Secret) < minSecretLength {
+		return fmt.Errorf("secret must have at least %d characters", minSecretLength)
+	}
+	return nil
+}
+
+func knownEventType(t string) bool {
+	for _, known := range event.Types {
+		if t == string(known) {
+			return true
+		}
+	}
+	return false
+}
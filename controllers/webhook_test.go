@@ -0,0 +1,605 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lfroomin/restaurant-serverless/internal/auth/authtest"
+	"github.com/lfroomin/restaurant-serverless/internal/awsConfig"
+	"github.com/lfroomin/restaurant-serverless/internal/dynamo"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/policy"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"github.com/lfroomin/restaurant-serverless/internal/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_WebhookNew(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := awsConfig.New()
+	require.NoError(t, err)
+
+	wh := Webhook{}.New(cfg, "RestaurantsTable")
+
+	assert.IsType(t, dynamo.RestaurantStorage{}, wh.Webhook)
+	assert.Equal(t, "RestaurantsTable", wh.Webhook.(dynamo.RestaurantStorage).Table)
+	assert.Equal(t, policy.Default, wh.Policy)
+}
+
+func Test_WebhookCreate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		body         string
+		stub         webhookStorerStub
+		responseCode int
+		responseBody string
+	}{
+		{
+			name:         "happy path",
+			body:         `{"url":"https://example.com/hook","eventTypes":["RestaurantCreated","RestaurantDeleted"],"secret":"0123456789abcdef"}`,
+			responseCode: http.StatusCreated,
+		},
+		{
+			name:         "id and created are set by the service",
+			body:         `{"id":"myId","created":"2020-01-01T00:00:00Z","url":"https://example.com/hook","eventTypes":["RestaurantCreated","RestaurantDeleted"],"secret":"0123456789abcdef"}`,
+			responseCode: http.StatusCreated,
+		},
+		{
+			name:         "invalid url",
+			body:         `{"url":"ftp://example.com/hook","eventTypes":["RestaurantCreated"],"secret":"0123456789abcdef"}`,
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "url \"ftp://example.com/hook\" is not an https URL"),
+		},
+		{
+			name:         "http url",
+			body:         `{"url":"http://example.com/hook","eventTypes":["RestaurantCreated"],"secret":"0123456789abcdef"}`,
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "url \"http://example.com/hook\" is not an https URL"),
+		},
+		{
+			name:         "relative url",
+			body:         `{"url":"/hook","eventTypes":["RestaurantCreated"],"secret":"0123456789abcdef"}`,
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "url \"/hook\" is not an https URL"),
+		},
+		{
+			name:         "no event types",
+			body:         `{"url":"https://example.com/hook","eventTypes":[],"secret":"0123456789abcdef"}`,
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "eventTypes is empty"),
+		},
+		{
+			name:         "unknown event type",
+			body:         `{"url":"https://example.com/hook","eventTypes":["RestaurantOpened"],"secret":"0123456789abcdef"}`,
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "event type \"RestaurantOpened\" is not one of [RestaurantCreated RestaurantUpdated RestaurantDeleted]"),
+		},
+		{
+			name:         "secret too short",
+			body:         `{"url":"https://example.com/hook","eventTypes":["RestaurantCreated"],"secret":"short"}`,
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "secret must have at least 16 characters"),
+		},
+		{
+			name:         "no secret",
+			body:         `{"url":"https://example.com/hook","eventTypes":["RestaurantCreated"]}`,
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "secret must have at least 16 characters"),
+		},
+		{
+			name:         "storage error",
+			body:         `{"url":"https://example.com/hook","eventTypes":["RestaurantCreated"],"secret":"0123456789abcdef"}`,
+			stub:         webhookStorerStub{error: "an error occurred"},
+			responseCode: http.StatusInternalServerError,
+			responseBody: internalError,
+		},
+		{
+			name:         "empty request body",
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeMalformedBody, "the request body is empty"),
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			wh := Webhook{Webhook: tc.stub}
+
+			start := time.Now().Add(-time.Second)
+			resp, _ := wh.Create(events.APIGatewayProxyRequest{Body: tc.body})
+
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			if tc.responseCode != http.StatusCreated {
+				assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
+				return
+			}
+
+			created := model.Webhook{}
+			require.NoError(t, json.Unmarshal([]byte(resp.Body), &created))
+			require.NotNil(t, created.Id)
+			assert.NotEqual(t, "myId", *created.Id)
+			require.NotNil(t, created.Created)
+			assert.True(t, created.Created.After(start))
+			assert.Equal(t, "https://example.com/hook", created.Url)
+			assert.Equal(t, []string{"RestaurantCreated", "RestaurantDeleted"}, created.EventTypes)
+			// The secret is only returned when the webhook is created
+			require.NotNil(t, created.Secret)
+			assert.Equal(t, "0123456789abcdef", *created.Secret)
+		})
+	}
+}
+
+func Test_WebhookRead(t *testing.T) {
+	t.Parallel()
+	webhook := aWebhook("webhookId")
+
+	testCases := []struct {
+		name         string
+		webhookId    string
+		tenantId     string
+		stub         webhookStorerStub
+		responseCode int
+		responseBody string
+	}{
+		{
+			name:         "happy path",
+			webhookId:    "webhookId",
+			stub:         webhookStorerStub{webhook: &webhook},
+			responseCode: http.StatusOK,
+			responseBody: webhookJson(webhook),
+		},
+		{
+			name:         "webhook of another tenant",
+			webhookId:    "webhookId",
+			tenantId:     "tenant2",
+			stub:         webhookStorerStub{webhook: &webhook},
+			responseCode: http.StatusNotFound,
+			responseBody: problem(http.StatusNotFound, httpResponse.CodeNotFound, "the webhook was not found"),
+		},
+		{
+			name:         "invalid tenant",
+			webhookId:    "webhookId",
+			tenantId:     "tenant/2",
+			stub:         webhookStorerStub{webhook: &webhook},
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "the tenant id must be 1 to 64 letters, digits, hyphens or underscores"),
+		},
+		{
+			name:         "webhook not found",
+			webhookId:    "webhookId",
+			responseCode: http.StatusNotFound,
+			responseBody: problem(http.StatusNotFound, httpResponse.CodeNotFound, "the webhook was not found"),
+		},
+		{
+			name:         "storage error",
+			webhookId:    "webhookId",
+			stub:         webhookStorerStub{error: "an error occurred"},
+			responseCode: http.StatusInternalServerError,
+			responseBody: internalError,
+		},
+		{
+			name:         "webhookId empty",
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "webhookId is empty"),
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			wh := Webhook{Webhook: tc.stub}
+
+			request := events.APIGatewayProxyRequest{PathParameters: map[string]string{"webhookId": tc.webhookId}}
+			if tc.tenantId != "" {
+				request.Headers = map[string]string{"X-Tenant-Id": tc.tenantId}
+			}
+
+			resp, _ := wh.Read(request)
+
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
+			assert.NotContains(t, resp.Body, "secret")
+		})
+	}
+}
+
+func Test_WebhookList(t *testing.T) {
+	t.Parallel()
+	webhook := aWebhook("webhookId")
+
+	testCases := []struct {
+		name         string
+		stub         webhookStorerStub
+		responseCode int
+		responseBody string
+	}{
+		{
+			name:         "happy path",
+			stub:         webhookStorerStub{webhook: &webhook},
+			responseCode: http.StatusOK,
+			responseBody: `{"items":[` + webhookJson(webhook) + `]}`,
+		},
+		{
+			name:         "no webhooks",
+			responseCode: http.StatusOK,
+			responseBody: `{"items":[]}`,
+		},
+		{
+			name:         "storage error",
+			stub:         webhookStorerStub{error: "an error occurred"},
+			responseCode: http.StatusInternalServerError,
+			responseBody: internalError,
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			wh := Webhook{Webhook: tc.stub}
+
+			resp, _ := wh.List(events.APIGatewayProxyRequest{})
+
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
+		})
+	}
+}
+
+func Test_WebhookDelete(t *testing.T) {
+	t.Parallel()
+	webhook := aWebhook("webhookId")
+
+	testCases := []struct {
+		name         string
+		webhookId    string
+		stub         webhookStorerStub
+		responseCode int
+		responseBody string
+	}{
+		{
+			name:         "happy path",
+			webhookId:    "webhookId",
+			stub:         webhookStorerStub{webhook: &webhook},
+			responseCode: http.StatusOK,
+			responseBody: webhookJson(webhook),
+		},
+		{
+			name:         "webhook not found",
+			webhookId:    "webhookId",
+			stub:         webhookStorerStub{err: storage.ErrNotFound},
+			responseCode: http.StatusNotFound,
+			responseBody: problem(http.StatusNotFound, httpResponse.CodeNotFound, "the webhook was not found"),
+		},
+		{
+			name:         "storage error",
+			webhookId:    "webhookId",
+			stub:         webhookStorerStub{error: "an error occurred"},
+			responseCode: http.StatusInternalServerError,
+			responseBody: internalError,
+		},
+		{
+			name:         "webhookId empty",
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "webhookId is empty"),
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			wh := Webhook{Webhook: tc.stub}
+
+			resp, _ := wh.Delete(events.APIGatewayProxyRequest{
+				PathParameters: map[string]string{"webhookId": tc.webhookId},
+			})
+
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
+		})
+	}
+}
+
+func Test_WebhookDeliveries(t *testing.T) {
+	t.Parallel()
+	webhook := aWebhook("webhookId")
+	delivery := model.WebhookDelivery{
+		Id:           "eventId",
+		WebhookId:    "webhookId",
+		EventType:    "RestaurantCreated",
+		RestaurantId: "restId",
+		Status:       model.Delivered,
+		Attempts:     1,
+		Created:      time.Date(2024, 3, 8, 18, 30, 0, 0, time.UTC),
+	}
+	deliveryJson, _ := json.Marshal(delivery)
+
+	testCases := []struct {
+		name         string
+		webhookId    string
+		queryParams  map[string]string
+		stub         webhookStorerStub
+		responseCode int
+		responseBody string
+	}{
+		{
+			name:         "happy path",
+			webhookId:    "webhookId",
+			stub:         webhookStorerStub{webhook: &webhook, delivery: &delivery, nextToken: "token2"},
+			responseCode: http.StatusOK,
+			responseBody: `{"items":[` + string(deliveryJson) + `],"nextToken":"token2"}`,
+		},
+		{
+			name:         "no deliveries",
+			webhookId:    "webhookId",
+			queryParams:  map[string]string{"limit": "5", "nextToken": "token1"},
+			stub:         webhookStorerStub{webhook: &webhook},
+			responseCode: http.StatusOK,
+			responseBody: `{"items":[]}`,
+		},
+		{
+			name:         "invalid limit",
+			webhookId:    "webhookId",
+			queryParams:  map[string]string{"limit": "0"},
+			stub:         webhookStorerStub{webhook: &webhook},
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "limit must be an integer between 1 and 100"),
+		},
+		{
+			name:         "invalid next token",
+			webhookId:    "webhookId",
+			queryParams:  map[string]string{"nextToken": "bad"},
+			stub:         webhookStorerStub{webhook: &webhook, err: storage.ErrInvalidNextToken},
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "nextToken is invalid"),
+		},
+		{
+			name:         "webhook not found",
+			webhookId:    "webhookId",
+			responseCode: http.StatusNotFound,
+			responseBody: problem(http.StatusNotFound, httpResponse.CodeNotFound, "the webhook was not found"),
+		},
+		{
+			name:         "storage error",
+			webhookId:    "webhookId",
+			stub:         webhookStorerStub{error: "an error occurred"},
+			responseCode: http.StatusInternalServerError,
+			responseBody: internalError,
+		},
+		{
+			name:         "webhookId empty",
+			responseCode: http.StatusBadRequest,
+			responseBody: problem(http.StatusBadRequest, httpResponse.CodeInvalidRequest, "webhookId is empty"),
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			wh := Webhook{Webhook: tc.stub}
+
+			resp, _ := wh.Deliveries(events.APIGatewayProxyRequest{
+				PathParameters:        map[string]string{"webhookId": tc.webhookId},
+				QueryStringParameters: tc.queryParams,
+			})
+
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			assert.Equal(t, tc.responseBody, withoutCorrelationId(resp.Body))
+		})
+	}
+}
+
+// aWebhook returns a webhook as it is returned by the API, without its secret.
+func aWebhook(id string) model.Webhook {
+	created := time.Date(2024, 3, 8, 18, 30, 0, 0, time.UTC)
+	return model.Webhook{Id: &id, Url: "https://example.com/hook", EventTypes: []string{"RestaurantCreated"}, Created: &created}
+}
+
+func webhookJson(webhook model.Webhook) string {
+	b, _ := json.Marshal(webhook)
+	return string(b)
+}
+
+// webhookStorerStub returns its webhook with a secret, which the controller must not return.
+// The webhook belongs to the default tenant.
+type webhookStorerStub struct {
+	webhook   *model.Webhook
+	delivery  *model.WebhookDelivery
+	nextToken string
+	error     string
+	err       error
+}
+
+func (s webhookStorerStub) withSecret() model.Webhook {
+	w := *s.webhook
+	secret := "0123456789abcdef"
+	w.Secret = &secret
+	return w
+}
+
+func (s webhookStorerStub) SaveWebhook(_ string, _ model.Webhook) error {
+	if s.error != "" {
+		return errors.New(s.error)
+	}
+	return nil
+}
+
+func (s webhookStorerStub) GetWebhook(tenantId, _ string) (model.Webhook, bool, error) {
+	if s.error != "" {
+		return model.Webhook{}, false, errors.New(s.error)
+	}
+	if s.webhook == nil || tenantId != tenant.Default {
+		return model.Webhook{}, false, nil
+	}
+	return s.withSecret(), true, nil
+}
+
+func (s webhookStorerStub) ListWebhooks(tenantId string) ([]model.Webhook, error) {
+	if s.error != "" {
+		return nil, errors.New(s.error)
+	}
+	if s.webhook == nil || tenantId != tenant.Default {
+		return []model.Webhook{}, nil
+	}
+	return []model.Webhook{s.withSecret()}, nil
+}
+
+func (s webhookStorerStub) DeleteWebhook(_, _ string) (model.Webhook, error) {
+	if s.err != nil {
+		return model.Webhook{}, s.err
+	}
+	if s.error != "" {
+		return model.Webhook{}, errors.New(s.error)
+	}
+	if s.webhook == nil {
+		return model.Webhook{}, nil
+	}
+	return s.withSecret(), nil
+}
+
+func (s webhookStorerStub) ListDeliveries(_, _ string, _ int32, _ string) ([]model.WebhookDelivery, string, error) {
+	if s.err != nil {
+		return nil, "", s.err
+	}
+	if s.error != "" {
+		return nil, "", errors.New(s.error)
+	}
+	if s.delivery == nil {
+		return []model.WebhookDelivery{}, "", nil
+	}
+	return []model.WebhookDelivery{*s.delivery}, s.nextToken, nil
+}
+
+func Test_WebhookAuthorize(t *testing.T) {
+	t.Parallel()
+
+	issuer := authtest.NewIssuer(t)
+	admin := bearer(t, issuer, "admin1", policy.RoleAdmin)
+	user := bearer(t, issuer, "user1")
+	webhook := aWebhook("webhookId")
+
+	testCases := []struct {
+		name          string
+		action        func(Webhook, events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error)
+		method        string
+		authorization string
+		body          string
+		responseCode  int
+		reason        policy.Reason
+	}{
+		{
+			name:         "anonymous list",
+			action:       Webhook.List,
+			method:       http.MethodGet,
+			responseCode: http.StatusForbidden,
+			reason:       policy.ReasonAuthenticationRequired,
+		},
+		{
+			name:         "anonymous deliveries",
+			action:       Webhook.Deliveries,
+			method:       http.MethodGet,
+			responseCode: http.StatusForbidden,
+			reason:       policy.ReasonAuthenticationRequired,
+		},
+		{
+			name:         "anonymous create",
+			action:       Webhook.Create,
+			method:       http.MethodPost,
+			body:         `{"url":"https://example.com/hook","eventTypes":["RestaurantCreated"],"secret":"0123456789abcdef"}`,
+			responseCode: http.StatusUnauthorized,
+		},
+		{
+			name:          "invalid token",
+			action:        Webhook.Read,
+			method:        http.MethodGet,
+			authorization: "Bearer not-a-token",
+			responseCode:  http.StatusUnauthorized,
+		},
+		{
+			name:          "user list",
+			action:        Webhook.List,
+			method:        http.MethodGet,
+			authorization: user,
+			responseCode:  http.StatusForbidden,
+			reason:        policy.ReasonAdminRequired,
+		},
+		{
+			name:          "user create",
+			action:        Webhook.Create,
+			method:        http.MethodPost,
+			authorization: user,
+			body:          `{"url":"https://example.com/hook","eventTypes":["RestaurantCreated"],"secret":"0123456789abcdef"}`,
+			responseCode:  http.StatusForbidden,
+			reason:        policy.ReasonAdminRequired,
+		},
+		{
+			name:          "user delete",
+			action:        Webhook.Delete,
+			method:        http.MethodDelete,
+			authorization: user,
+			responseCode:  http.StatusForbidden,
+			reason:        policy.ReasonAdminRequired,
+		},
+		{
+			name:          "admin create",
+			action:        Webhook.Create,
+			method:        http.MethodPost,
+			authorization: admin,
+			body:          `{"url":"https://example.com/hook","eventTypes":["RestaurantCreated"],"secret":"0123456789abcdef"}`,
+			responseCode:  http.StatusCreated,
+		},
+		{
+			name:          "admin deliveries",
+			action:        Webhook.Deliveries,
+			method:        http.MethodGet,
+			authorization: admin,
+			responseCode:  http.StatusOK,
+		},
+		{
+			name:          "admin delete",
+			action:        Webhook.Delete,
+			method:        http.MethodDelete,
+			authorization: admin,
+			responseCode:  http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			wh := Webhook{Webhook: webhookStorerStub{webhook: &webhook}, Policy: policy.Default}
+			h := authenticated(t, issuer, func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+				return tc.action(wh, request)
+			})
+
+			resp, err := h(events.APIGatewayProxyRequest{
+				HTTPMethod:     tc.method,
+				PathParameters: map[string]string{"webhookId": "webhookId"},
+				Headers:        map[string]string{"Authorization": tc.authorization},
+				Body:           tc.body,
+			})
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.responseCode, resp.StatusCode, resp.Body)
+			assertForbidden(t, resp, tc.reason)
+		})
+	}
+}
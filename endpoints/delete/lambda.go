@@ -4,6 +4,7 @@ import (
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/lfroomin/restaurant-serverless/controllers"
 	"github.com/lfroomin/restaurant-serverless/internal/awsConfig"
+	"github.com/lfroomin/restaurant-serverless/internal/middleware"
 	"log"
 	"os"
 )
@@ -19,7 +20,7 @@ func main() {
 
 	log.Printf("Env Vars: RestaurantsTable: %s\n", restaurantsTable)
 
-	c := controllers.Restaurant{}.New(cfg, restaurantsTable, "")
+	c := controllers.Restaurant{}.New(cfg, restaurantsTable, "", "")
 
-	lambda.Start(c.Delete)
+	lambda.Start(middleware.Wrap(c.Delete))
 }
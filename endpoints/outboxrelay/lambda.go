@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/lfroomin/restaurant-serverless/internal/awsConfig"
+	"github.com/lfroomin/restaurant-serverless/internal/dynamo"
+	"github.com/lfroomin/restaurant-serverless/internal/event"
+	"github.com/lfroomin/restaurant-serverless/internal/outbox"
+	"github.com/lfroomin/restaurant-serverless/internal/webhook"
+	"log"
+	"os"
+)
+
+// main is called only once, when the Lambda is initialised (started for the first time).
+func main() {
+	cfg, err := awsConfig.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	restaurantsTable := os.Getenv("RestaurantsTable")
+	eventBusName := os.Getenv("EventBusName")
+
+	log.Printf("Env Vars: RestaurantsTable: %s  EventBusName: %s\n", restaurantsTable, eventBusName)
+
+	storer := dynamo.New(cfg, restaurantsTable)
+	// The events are sent to the event bus, and to the webhooks by the webhook delivery Lambda
+	publishers := outbox.Publishers{event.NewEventBridgePublisher(cfg, eventBusName), webhook.Dispatcher{Store: storer}}
+	relay := outbox.New(storer, publishers)
+
+	// The Lambda is invoked on a schedule, and publishes the events recorded since the last run
+	lambda.Start(func() error {
+		delivered, err := relay.Drain()
+		log.Printf("delivered %d events\n", delivered)
+		return err
+	})
+}
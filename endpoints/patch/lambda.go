@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/lfroomin/restaurant-serverless/controllers"
+	"github.com/lfroomin/restaurant-serverless/internal/awsConfig"
+	"github.com/lfroomin/restaurant-serverless/internal/middleware"
+	"log"
+	"os"
+	"strconv"
+)
+
+// main is called only once, when the Lambda is initialised (started for the first time).
+func main() {
+	cfg, err := awsConfig.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	restaurantsTable := os.Getenv("RestaurantsTable")
+	placeIndex := os.Getenv("LocationPlaceIndex")
+	geocodeCacheTable := os.Getenv("GeocodeCacheTable")
+	minRelevance, _ := strconv.ParseFloat(os.Getenv("GeocodeMinRelevance"), 64)
+
+	log.Printf("Env Vars: RestaurantsTable: %s  LocationPlaceIndex: %s  GeocodeCacheTable: %s  GeocodeMinRelevance: %f\n",
+		restaurantsTable, placeIndex, geocodeCacheTable, minRelevance)
+
+	c := controllers.Restaurant{}.New(cfg, restaurantsTable, placeIndex, geocodeCacheTable)
+	c.MinRelevance = minRelevance
+
+	lambda.Start(middleware.Wrap(c.Patch))
+}
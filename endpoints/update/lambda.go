@@ -4,8 +4,10 @@ import (
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/lfroomin/restaurant-serverless/controllers"
 	"github.com/lfroomin/restaurant-serverless/internal/awsConfig"
+	"github.com/lfroomin/restaurant-serverless/internal/middleware"
 	"log"
 	"os"
+	"strconv"
 )
 
 // main is called only once, when the Lambda is initialised (started for the first time).
@@ -17,10 +19,14 @@ func main() {
 
 	restaurantsTable := os.Getenv("RestaurantsTable")
 	placeIndex := os.Getenv("LocationPlaceIndex")
+	geocodeCacheTable := os.Getenv("GeocodeCacheTable")
+	minRelevance, _ := strconv.ParseFloat(os.Getenv("GeocodeMinRelevance"), 64)
 
-	log.Printf("Env Vars: RestaurantsTable: %s  LocationPlaceIndex: %s\n", restaurantsTable, placeIndex)
+	log.Printf("Env Vars: RestaurantsTable: %s  LocationPlaceIndex: %s  GeocodeCacheTable: %s  GeocodeMinRelevance: %f\n",
+		restaurantsTable, placeIndex, geocodeCacheTable, minRelevance)
 
-	c := controllers.Restaurant{}.New(cfg, restaurantsTable, placeIndex)
+	c := controllers.Restaurant{}.New(cfg, restaurantsTable, placeIndex, geocodeCacheTable)
+	c.MinRelevance = minRelevance
 
-	lambda.Start(c.Update)
+	lambda.Start(middleware.Wrap(c.Update))
 }
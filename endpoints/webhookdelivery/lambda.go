@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/lfroomin/restaurant-serverless/internal/awsConfig"
+	"github.com/lfroomin/restaurant-serverless/internal/dynamo"
+	"github.com/lfroomin/restaurant-serverless/internal/webhook"
+	"log"
+	"os"
+)
+
+// main is called only once, when the Lambda is initialised (started for the first time).
+func main() {
+	cfg, err := awsConfig.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	restaurantsTable := os.Getenv("RestaurantsTable")
+
+	log.Printf("Env Vars: RestaurantsTable: %s\n", restaurantsTable)
+
+	worker := webhook.NewWorker(dynamo.New(cfg, restaurantsTable))
+
+	// The Lambda is invoked on a schedule, and sends the deliveries that are due
+	lambda.Start(func() error {
+		delivered, err := worker.Drain()
+		log.Printf("delivered %d webhook events\n", delivered)
+		return err
+	})
+}
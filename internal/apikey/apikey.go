@@ -0,0 +1,146 @@
+// Package apikey issues the API keys that partners authenticate their server to server
+// requests with, in the X-Api-Key header, and counts the requests of each key against
+// its quota. Only the hashes of the keys are stored.
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lfroomin/restaurant-serverless/internal/auth"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"strings"
+	"time"
+)
+
+const (
+	Header = "X-Api-Key"
+
+	// secretBytes is the number of random bytes of a key
+	secretBytes = 32
+
+	// subjectPrefix is followed by the id of the API key in the subject of its claims
+	subjectPrefix = "apikey:"
+
+	// The request counts of a period are kept for a day after it ends
+	usageTTL = 24 * time.Hour
+)
+
+var (
+	// ErrInvalid is returned for a key that was not issued, or was rotated or revoked.
+	ErrInvalid = errors.New("invalid API key")
+
+	// ErrQuotaExceeded is returned when the API key has used up the quota of the period.
+	ErrQuotaExceeded = errors.New("the quota of the API key is exceeded")
+)
+
+// Key is an API key as it is stored: the model without the key, and the hash of the key.
+// A revoked API key has no hash.
+type Key struct {
+	model.ApiKey
+	Hash string
+}
+
+type Store interface {
+	GetApiKey(apiKeyId string) (Key, bool, error)
+	// UseApiKey counts a request of the API key in the quota period, and returns the number of
+	// requests counted in the period. It returns storage.ErrLimitExceeded, counting nothing,
+	// when limit requests were already counted. The count can be deleted after expires.
+	UseApiKey(apiKeyId, period string, limit int32, expires time.Time) (int32, error)
+}
+
+// Usage is the quota of an API key in the current period.
+type Usage struct {
+	Limit     int32
+	Remaining int32
+	// Reset is when the next period starts
+	Reset time.Time
+}
+
+// NewKey returns a new random key for the API key. The key starts with the id of the API key,
+// which it is looked up by.
+func NewKey(apiKeyId string) (string, error) {
+	secret := make([]byte, secretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("error generating API key: %w", err)
+	}
+	return apiKeyId + "." + hex.EncodeToString(secret), nil
+}
+
+// Hash returns the hash the key is stored as. The keys are random, so they need no salt or
+// key stretching, unlike passwords.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticate returns the API key of key. It returns ErrInvalid when the key was not
+// issued, or was rotated or revoked.
+func Authenticate(store Store, key string) (Key, error) {
+	apiKeyId, _, ok := strings.Cut(key, ".")
+	if !ok || apiKeyId == "" {
+		return Key{}, ErrInvalid
+	}
+
+	k, exists, err := store.GetApiKey(apiKeyId)
+	if err != nil {
+		return Key{}, err
+	}
+	if !exists || k.Revoked != nil || k.Hash == "" {
+		return Key{}, ErrInvalid
+	}
+	if subtle.ConstantTimeCompare([]byte(k.Hash), []byte(Hash(key))) != 1 {
+		return Key{}, ErrInvalid
+	}
+	return k, nil
+}
+
+// Use counts a request of the API key against its quota in the period of now. It returns
+// ErrQuotaExceeded, with the usage, when the quota of the period is used up.
+func Use(store Store, k Key, now time.Time) (Usage, error) {
+	start, reset := period(k.Quota.Period, now)
+	usage := Usage{Limit: k.Quota.Limit, Reset: reset}
+
+	count, err := store.UseApiKey(*k.Id, start, k.Quota.Limit, reset.Add(usageTTL))
+	if errors.Is(err, storage.ErrLimitExceeded) {
+		return usage, ErrQuotaExceeded
+	}
+	if err != nil {
+		return Usage{}, err
+	}
+
+	if count < k.Quota.Limit {
+		usage.Remaining = k.Quota.Limit - count
+	}
+	return usage, nil
+}
+
+// period returns the UTC day or month of t, such as 2024-03-09 or 2024-03, and when the next one starts.
+func period(p model.ApiKeyQuotaPeriod, t time.Time) (string, time.Time) {
+	t = t.UTC()
+	if p == model.Month {
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start.Format("2006-01"), start.AddDate(0, 1, 0)
+	}
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return start.Format("2006-01-02"), start.AddDate(0, 0, 1)
+}
+
+// Claims returns the claims the requests of the API key are authenticated with. The key has no
+// roles: its subject, apikey:<id>, is authorized like a user, so a write key can only update
+// the restaurants that list it in their ownerIds.
+func Claims(k Key) auth.Claims {
+	claims := auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: subjectPrefix + *k.Id},
+		ApiKeyId:         *k.Id,
+	}
+	if k.TenantId != nil {
+		claims.TenantId = *k.TenantId
+	}
+	return claims
+}
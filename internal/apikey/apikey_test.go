@@ -0,0 +1,212 @@
+package apikey
+
+import (
+	"errors"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_NewKey(t *testing.T) {
+	t.Parallel()
+
+	key, err := NewKey("key1")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, "key1."))
+	assert.Len(t, key, len("key1.")+2*secretBytes)
+
+	other, err := NewKey("key1")
+	require.NoError(t, err)
+	assert.NotEqual(t, key, other)
+	assert.NotEqual(t, Hash(key), Hash(other))
+}
+
+func Test_Authenticate(t *testing.T) {
+	t.Parallel()
+
+	key, err := NewKey("key1")
+	require.NoError(t, err)
+	revoked := time.Now()
+
+	testCases := []struct {
+		name   string
+		key    string
+		stored *Key
+		error  string
+		err    error
+	}{
+		{
+			name:   "valid key",
+			key:    key,
+			stored: &Key{ApiKey: apiKey("key1", model.Read), Hash: Hash(key)},
+		},
+		{
+			name:   "other secret",
+			key:    "key1.0123",
+			stored: &Key{ApiKey: apiKey("key1", model.Read), Hash: Hash(key)},
+			err:    ErrInvalid,
+		},
+		{
+			name: "unknown key",
+			key:  key,
+			err:  ErrInvalid,
+		},
+		{
+			name:   "revoked key",
+			key:    key,
+			stored: &Key{ApiKey: model.ApiKey{Id: aString("key1"), Revoked: &revoked}},
+			err:    ErrInvalid,
+		},
+		{
+			name:   "revoked key with its hash",
+			key:    key,
+			stored: &Key{ApiKey: model.ApiKey{Id: aString("key1"), Revoked: &revoked}, Hash: Hash(key)},
+			err:    ErrInvalid,
+		},
+		{
+			name:   "key without id",
+			key:    strings.TrimPrefix(key, "key1"),
+			stored: &Key{ApiKey: apiKey("key1", model.Read), Hash: Hash(key)},
+			err:    ErrInvalid,
+		},
+		{
+			name: "not an API key",
+			key:  "secret",
+			err:  ErrInvalid,
+		},
+		{
+			name:  "storage error",
+			key:   key,
+			error: "storage error",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := &storeStub{key: tc.stored, error: tc.error}
+
+			k, err := Authenticate(store, tc.key)
+
+			switch {
+			case tc.err != nil:
+				assert.ErrorIs(t, err, tc.err)
+			case tc.error != "":
+				assert.EqualError(t, err, tc.error)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, *tc.stored, k)
+			}
+		})
+	}
+}
+
+func Test_Use(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 3, 9, 23, 30, 0, 0, time.FixedZone("EST", -5*60*60))
+
+	testCases := []struct {
+		name   string
+		period model.ApiKeyQuotaPeriod
+		used   int32
+		usage  Usage
+		key    string
+		err    error
+	}{
+		{
+			name:   "first request of the day",
+			period: model.Day,
+			usage:  Usage{Limit: 3, Remaining: 2, Reset: time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)},
+			key:    "key1#2024-03-10",
+		},
+		{
+			name:   "last request of the month",
+			period: model.Month,
+			used:   2,
+			usage:  Usage{Limit: 3, Remaining: 0, Reset: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+			key:    "key1#2024-03",
+		},
+		{
+			name:   "quota exceeded",
+			period: model.Day,
+			used:   3,
+			usage:  Usage{Limit: 3, Remaining: 0, Reset: time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)},
+			key:    "key1#2024-03-10",
+			err:    ErrQuotaExceeded,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			k := Key{ApiKey: apiKey("key1", model.Read)}
+			k.Quota = model.ApiKeyQuota{Limit: 3, Period: tc.period}
+			store := &storeStub{counts: map[string]int32{tc.key: tc.used}}
+
+			usage, err := Use(store, k, now)
+
+			assert.ErrorIs(t, err, tc.err)
+			assert.Equal(t, tc.usage, usage)
+			assert.Equal(t, tc.usage.Reset.Add(usageTTL), store.expires)
+		})
+	}
+}
+
+func Test_Claims(t *testing.T) {
+	t.Parallel()
+
+	read := Claims(Key{ApiKey: apiKey("key1", model.Read)})
+	assert.Equal(t, "apikey:key1", read.Subject)
+	assert.Equal(t, "key1", read.ApiKeyId)
+	assert.Equal(t, "tenant1", read.TenantId)
+	assert.Empty(t, read.Roles)
+
+	write := Claims(Key{ApiKey: apiKey("key1", model.Write)})
+	assert.Equal(t, "apikey:key1", write.Subject)
+	assert.Empty(t, write.Roles)
+}
+
+func apiKey(id string, scope model.ApiKeyScope) model.ApiKey {
+	return model.ApiKey{Id: &id, Name: "partner", Scope: scope, TenantId: aString("tenant1"), Quota: model.ApiKeyQuota{Limit: 10, Period: model.Day}}
+}
+
+func aString(s string) *string {
+	return &s
+}
+
+// storeStub stores one API key, and counts the requests of the periods in counts
+type storeStub struct {
+	key     *Key
+	counts  map[string]int32
+	expires time.Time
+	error   string
+}
+
+func (s *storeStub) GetApiKey(apiKeyId string) (Key, bool, error) {
+	if s.error != "" {
+		return Key{}, false, errors.New(s.error)
+	}
+	if s.key == nil || *s.key.Id != apiKeyId {
+		return Key{}, false, nil
+	}
+	return *s.key, true, nil
+}
+
+func (s *storeStub) UseApiKey(apiKeyId, period string, limit int32, expires time.Time) (int32, error) {
+	k := apiKeyId + "#" + period
+	s.expires = expires
+	if s.counts[k] >= limit {
+		return 0, storage.ErrLimitExceeded
+	}
+	s.counts[k]++
+	return s.counts[k], nil
+}
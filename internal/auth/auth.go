@@ -0,0 +1,127 @@
+// Package auth verifies the JWT bearer tokens of the requests against the JSON Web Key
+// Set of the issuer, and passes the verified claims to the handlers.
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/golang-jwt/jwt/v5"
+	"log"
+	"os"
+	"time"
+)
+
+const (
+	// claimsKey is the key of the verified claims in the authorizer context of the request
+	claimsKey = "verifiedClaims"
+
+	defaultLeeway = 30 * time.Second
+)
+
+// Claims are the claims of a verified token.
+type Claims struct {
+	jwt.RegisteredClaims
+	// Roles are the roles granted to the subject by the issuer, such as "admin"
+	Roles []string `json:"roles,omitempty"`
+	// TenantId is the tenant the subject belongs to, the default tenant when it is empty
+	TenantId string `json:"tenantId,omitempty"`
+	// ApiKeyId is the API key the request is authenticated with, empty for a bearer token
+	ApiKeyId string `json:"apiKeyId,omitempty"`
+}
+
+type Verifier struct {
+	Keys     *KeySet
+	Issuer   string
+	Audience string
+	// Leeway allows for the clock skew between the issuer and the Lambda
+	Leeway time.Duration
+}
+
+func NewVerifier(keys *KeySet, issuer, audience string) *Verifier {
+	return &Verifier{Keys: keys, Issuer: issuer, Audience: audience, Leeway: defaultLeeway}
+}
+
+// NewVerifierFromEnv returns the Verifier configured by the JwksUrl, JwtIssuer and
+// JwtAudience environment variables, or nil when JwksUrl is not set.
+func NewVerifierFromEnv() (*Verifier, error) {
+	jwksUrl, issuer, audience := os.Getenv("JwksUrl"), os.Getenv("JwtIssuer"), os.Getenv("JwtAudience")
+	if jwksUrl == "" {
+		return nil, nil
+	}
+	if issuer == "" || audience == "" {
+		return nil, errors.New("JwtIssuer and JwtAudience must be set with JwksUrl")
+	}
+
+	log.Printf("Env Vars: JwksUrl: %s  JwtIssuer: %s  JwtAudience: %s\n", jwksUrl, issuer, audience)
+	return NewVerifier(NewKeySet(jwksUrl), issuer, audience), nil
+}
+
+// Verify returns the claims of the token when it is signed with RS256 or ES256 by a key of
+// the key set, is issued by Issuer for Audience, and has not expired.
+func (v *Verifier) Verify(token string) (Claims, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg()}),
+		jwt.WithIssuer(v.Issuer),
+		jwt.WithAudience(v.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(v.Leeway),
+	)
+
+	claims := Claims{}
+	_, err := parser.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.Keys.Key(kid)
+	})
+	if err != nil {
+		return Claims{}, err
+	}
+	return claims, nil
+}
+
+// WithClaims returns the request with the verified claims in its authorizer context, where
+// API Gateway puts the claims of its own authorizers.
+func WithClaims(request events.APIGatewayProxyRequest, claims Claims) events.APIGatewayProxyRequest {
+	authorizer := map[string]any{}
+	for k, v := range request.RequestContext.Authorizer {
+		authorizer[k] = v
+	}
+	authorizer[claimsKey] = claims
+	request.RequestContext.Authorizer = authorizer
+	return request
+}
+
+// FromRequest returns the verified claims of the request. ok is false for an anonymous request.
+func FromRequest(request events.APIGatewayProxyRequest) (claims Claims, ok bool) {
+	switch c := request.RequestContext.Authorizer[claimsKey].(type) {
+	case Claims:
+		return c, true
+	case map[string]any:
+		// The claims of a request that was marshalled, such as by the local server
+		if data, err := json.Marshal(c); err == nil && json.Unmarshal(data, &claims) == nil {
+			return claims, true
+		}
+	}
+	return Claims{}, false
+}
+
+// Error returns the reason a token was rejected, without the details of the key set.
+func Error(err error) string {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "the token has expired"
+	case errors.Is(err, jwt.ErrTokenNotValidYet):
+		return "the token is not valid yet"
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return "the token has the wrong issuer"
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return "the token has the wrong audience"
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+		return "the token is missing a required claim"
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, ErrKeyNotFound):
+		return "the token signature is invalid"
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return "the token is malformed"
+	}
+	return "the token is invalid"
+}
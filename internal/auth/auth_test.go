@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lfroomin/restaurant-serverless/internal/auth/authtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_Verify(t *testing.T) {
+	t.Parallel()
+
+	issuer := authtest.NewIssuer(t)
+	verifier := NewVerifier(NewKeySet(issuer.WriteJWKS(t)), authtest.IssuerURL, authtest.Audience)
+
+	with := func(k string, v any) jwt.MapClaims {
+		claims := authtest.Claims("user1")
+		if v == nil {
+			delete(claims, k)
+		} else {
+			claims[k] = v
+		}
+		return claims
+	}
+	hs256, err := jwt.NewWithClaims(jwt.SigningMethodHS256, authtest.Claims("user1")).SignedString([]byte("secret"))
+	require.NoError(t, err)
+	unknownKey := authtest.NewIssuer(t)
+
+	testCases := []struct {
+		name  string
+		token string
+		error string
+	}{
+		{
+			name:  "RS256",
+			token: issuer.RS256(t, authtest.Claims("user1")),
+		},
+		{
+			name:  "ES256",
+			token: issuer.ES256(t, authtest.Claims("user1")),
+		},
+		{
+			name:  "audience in a list",
+			token: issuer.RS256(t, with("aud", []string{"other", authtest.Audience})),
+		},
+		{
+			name:  "expired",
+			token: issuer.RS256(t, with("exp", time.Now().Add(-time.Hour).Unix())),
+			error: "the token has expired",
+		},
+		{
+			name:  "expired within the leeway",
+			token: issuer.RS256(t, with("exp", time.Now().Add(-10*time.Second).Unix())),
+		},
+		{
+			name:  "no expiry",
+			token: issuer.RS256(t, with("exp", nil)),
+			error: "the token is missing a required claim",
+		},
+		{
+			name:  "not valid yet",
+			token: issuer.ES256(t, with("nbf", time.Now().Add(time.Hour).Unix())),
+			error: "the token is not valid yet",
+		},
+		{
+			name:  "wrong issuer",
+			token: issuer.RS256(t, with("iss", "https://other.example.com/")),
+			error: "the token has the wrong issuer",
+		},
+		{
+			name:  "wrong audience",
+			token: issuer.ES256(t, with("aud", "other-api")),
+			error: "the token has the wrong audience",
+		},
+		{
+			name:  "HS256",
+			token: hs256,
+			error: "the token signature is invalid",
+		},
+		{
+			name:  "signed by another key",
+			token: unknownKey.RS256(t, authtest.Claims("user1")),
+			error: "the token signature is invalid",
+		},
+		{
+			name:  "malformed",
+			token: "not.a.token",
+			error: "the token is malformed",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			claims, err := verifier.Verify(tc.token)
+
+			if tc.error != "" {
+				require.Error(t, err)
+				assert.Equal(t, tc.error, Error(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "user1", claims.Subject)
+		})
+	}
+}
+
+func Test_NewVerifierFromEnv(t *testing.T) {
+	t.Setenv("JwksUrl", "")
+	v, err := NewVerifierFromEnv()
+	require.NoError(t, err)
+	assert.Nil(t, v)
+
+	t.Setenv("JwksUrl", "https://issuer.example.com/.well-known/jwks.json")
+	_, err = NewVerifierFromEnv()
+	assert.Error(t, err)
+
+	t.Setenv("JwtIssuer", authtest.IssuerURL)
+	t.Setenv("JwtAudience", authtest.Audience)
+	v, err = NewVerifierFromEnv()
+	require.NoError(t, err)
+	require.NotNil(t, v)
+	assert.Equal(t, authtest.IssuerURL, v.Issuer)
+	assert.Equal(t, authtest.Audience, v.Audience)
+}
+
+func Test_Claims(t *testing.T) {
+	t.Parallel()
+
+	request := events.APIGatewayProxyRequest{}
+	_, ok := FromRequest(request)
+	assert.False(t, ok)
+
+	request.RequestContext.Authorizer = map[string]any{"principalId": "user1"}
+	request = WithClaims(request, Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "user1"}})
+
+	claims, ok := FromRequest(request)
+	require.True(t, ok)
+	assert.Equal(t, "user1", claims.Subject)
+	assert.Equal(t, "user1", request.RequestContext.Authorizer["principalId"])
+
+	// As decoded from a request that was marshalled
+	request.RequestContext.Authorizer = map[string]any{claimsKey: map[string]any{"sub": "user2", "aud": []any{"restaurant-api"}}}
+	claims, ok = FromRequest(request)
+	require.True(t, ok)
+	assert.Equal(t, "user2", claims.Subject)
+}
@@ -0,0 +1,110 @@
+// Package authtest mints JWTs with locally generated keys, and serves their JWKS, for the
+// tests of the packages that authenticate requests.
+package authtest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const (
+	IssuerURL = "https://issuer.example.com/"
+	Audience  = "restaurant-api"
+
+	RSAKeyId = "rsa-key"
+	ECKeyId  = "ec-key"
+)
+
+// Issuer signs tokens with an RSA and a P-256 key.
+type Issuer struct {
+	RSAKey *rsa.PrivateKey
+	ECKey  *ecdsa.PrivateKey
+}
+
+func NewIssuer(t *testing.T) Issuer {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	return Issuer{RSAKey: rsaKey, ECKey: ecKey}
+}
+
+// JWKS returns the JSON Web Key Set of the public keys of the issuer.
+func (i Issuer) JWKS(t *testing.T) []byte {
+	keys := []map[string]string{
+		{
+			"kty": "RSA",
+			"kid": RSAKeyId,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   encode(i.RSAKey.N),
+			"e":   encode(big.NewInt(int64(i.RSAKey.E))),
+		},
+		{
+			"kty": "EC",
+			"kid": ECKeyId,
+			"use": "sig",
+			"alg": "ES256",
+			"crv": "P-256",
+			"x":   encode(i.ECKey.X),
+			"y":   encode(i.ECKey.Y),
+		},
+	}
+
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	require.NoError(t, err)
+	return data
+}
+
+// WriteJWKS writes the JWKS of the issuer to a temporary file and returns its path.
+func (i Issuer) WriteJWKS(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, i.JWKS(t), 0o600))
+	return path
+}
+
+// Claims returns valid claims of subject, for the audience of the API, that expire in an hour.
+func Claims(subject string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss": IssuerURL,
+		"aud": Audience,
+		"sub": subject,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+}
+
+// RS256 returns the claims signed with the RSA key.
+func (i Issuer) RS256(t *testing.T, claims jwt.Claims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = RSAKeyId
+	signed, err := token.SignedString(i.RSAKey)
+	require.NoError(t, err)
+	return signed
+}
+
+// ES256 returns the claims signed with the P-256 key.
+func (i Issuer) ES256(t *testing.T, claims jwt.Claims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = ECKeyId
+	signed, err := token.SignedString(i.ECKey)
+	require.NoError(t, err)
+	return signed
+}
+
+func encode(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
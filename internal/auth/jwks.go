@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultKeySetTTL     = time.Hour
+	defaultRefreshPeriod = time.Minute
+	defaultFetchTimeout  = 5 * time.Second
+)
+
+var (
+	ErrKeyNotFound = errors.New("key not found")
+	// ErrKeySetUnavailable is returned when the key set cannot be loaded, which is not the fault of the token
+	ErrKeySetUnavailable = errors.New("key set unavailable")
+)
+
+// KeySet is a JSON Web Key Set read from a file or a URL. The keys are cached for TTL,
+// and an unknown key ID reloads them, at most once per RefreshPeriod, so that a rotated
+// signing key is picked up without waiting for the TTL. When the keys cannot be reloaded,
+// the cached keys are still used, and the next attempt waits for RefreshPeriod.
+type KeySet struct {
+	// Source is the path of a JWKS file, or an http(s) URL such as the jwks_uri of the issuer
+	Source        string
+	Client        *http.Client
+	TTL           time.Duration
+	RefreshPeriod time.Duration
+
+	mu       sync.Mutex
+	keys     map[string]crypto.PublicKey
+	loadedAt time.Time
+	// The time and the error of the last attempt to load the keys
+	triedAt time.Time
+	err     error
+	now     func() time.Time
+}
+
+func NewKeySet(source string) *KeySet {
+	return &KeySet{
+		Source:        source,
+		Client:        &http.Client{Timeout: defaultFetchTimeout},
+		TTL:           defaultKeySetTTL,
+		RefreshPeriod: defaultRefreshPeriod,
+		now:           time.Now,
+	}
+}
+
+// Key returns the public key with the key ID kid. An empty kid matches the key when the
+// set has only one.
+func (s *KeySet) Key(kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	_, found := s.find(kid)
+	expired := s.keys == nil || now.Sub(s.loadedAt) >= s.TTL
+	if (expired || !found) && now.Sub(s.triedAt) >= s.RefreshPeriod {
+		s.triedAt = now
+		s.err = s.load(now)
+		if s.err != nil && s.keys != nil {
+			log.Printf("Using the cached keys: %v\n", s.err)
+		}
+	}
+	if s.keys == nil {
+		return nil, s.err
+	}
+
+	key, found := s.find(kid)
+	if !found {
+		return nil, fmt.Errorf("%w: kid %q", ErrKeyNotFound, kid)
+	}
+	return key, nil
+}
+
+func (s *KeySet) find(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, found := s.keys[kid]
+	return key, found
+}
+
+func (s *KeySet) load(now time.Time) error {
+	data, err := s.read()
+	if err != nil {
+		return fmt.Errorf("%w: error reading JWKS %s: %w", ErrKeySetUnavailable, s.Source, err)
+	}
+
+	keys, err := parseKeySet(data)
+	if err != nil {
+		return fmt.Errorf("%w: error parsing JWKS %s: %w", ErrKeySetUnavailable, s.Source, err)
+	}
+
+	s.keys, s.loadedAt = keys, now
+	return nil
+}
+
+func (s *KeySet) read() ([]byte, error) {
+	if !strings.HasPrefix(s.Source, "https://") && !strings.HasPrefix(s.Source, "http://") {
+		return os.ReadFile(strings.TrimPrefix(s.Source, "file://"))
+	}
+
+	resp, err := s.Client.Get(s.Source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseKeySet returns the RSA and P-256 signing keys of the JWKS by key ID. The other
+// keys, such as encryption keys, are skipped, and so are the malformed keys, so that one
+// bad key does not reject the tokens signed with the others.
+func parseKeySet(data []byte) (map[string]crypto.PublicKey, error) {
+	set := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		var key crypto.PublicKey
+		var err error
+		switch {
+		case jwk.Kty == "RSA":
+			key, err = rsaKey(jwk)
+		case jwk.Kty == "EC" && jwk.Crv == "P-256":
+			key, err = ecKey(jwk)
+		default:
+			continue
+		}
+		if err != nil {
+			log.Printf("Skipping JWKS key kid: %s  error: %v\n", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+func rsaKey(jwk jsonWebKey) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding n: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding e: %w", err)
+	}
+
+	exponent := new(big.Int).SetBytes(e)
+	if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid RSA key")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
+
+func ecKey(jwk jsonWebKey) (*ecdsa.PublicKey, error) {
+	x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding x: %w", err)
+	}
+	y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding y: %w", err)
+	}
+
+	key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	if !key.Curve.IsOnCurve(key.X, key.Y) {
+		return nil, errors.New("the point is not on the P-256 curve")
+	}
+	return key, nil
+}
@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"github.com/lfroomin/restaurant-serverless/internal/auth/authtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_KeySetFile(t *testing.T) {
+	t.Parallel()
+
+	issuer := authtest.NewIssuer(t)
+	keys := NewKeySet("file://" + issuer.WriteJWKS(t))
+
+	key, err := keys.Key(authtest.RSAKeyId)
+	require.NoError(t, err)
+	assert.True(t, issuer.RSAKey.PublicKey.Equal(key.(*rsa.PublicKey)))
+
+	key, err = keys.Key(authtest.ECKeyId)
+	require.NoError(t, err)
+	assert.True(t, issuer.ECKey.PublicKey.Equal(key.(*ecdsa.PublicKey)))
+
+	_, err = keys.Key("unknown")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	_, err = NewKeySet("/does/not/exist.json").Key(authtest.RSAKeyId)
+	assert.ErrorIs(t, err, ErrKeySetUnavailable)
+}
+
+func Test_KeySetURL(t *testing.T) {
+	t.Parallel()
+
+	issuer := authtest.NewIssuer(t)
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		_, _ = w.Write(issuer.JWKS(t))
+	}))
+	defer server.Close()
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	keys := NewKeySet(server.URL)
+	keys.now = func() time.Time { return now }
+
+	// The keys are cached
+	for i := 0; i < 3; i++ {
+		_, err := keys.Key(authtest.RSAKeyId)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), fetches.Load())
+
+	// An unknown key ID reloads them, at most once per refresh period
+	_, err := keys.Key("rotated")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	assert.Equal(t, int32(1), fetches.Load())
+
+	now = now.Add(keys.RefreshPeriod)
+	_, err = keys.Key("rotated")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	assert.Equal(t, int32(2), fetches.Load())
+
+	// And they expire after the TTL
+	now = now.Add(keys.TTL)
+	_, err = keys.Key(authtest.ECKeyId)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), fetches.Load())
+}
+
+func Test_KeySetUnavailable(t *testing.T) {
+	t.Parallel()
+
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	keys := NewKeySet(server.URL)
+	keys.now = func() time.Time { return now }
+
+	_, err := keys.Key(authtest.RSAKeyId)
+	assert.ErrorIs(t, err, ErrKeySetUnavailable)
+
+	// The failed loads are retried at most once per refresh period
+	_, err = keys.Key(authtest.RSAKeyId)
+	assert.ErrorIs(t, err, ErrKeySetUnavailable)
+	assert.Equal(t, int32(1), fetches.Load())
+
+	now = now.Add(keys.RefreshPeriod)
+	_, err = keys.Key(authtest.RSAKeyId)
+	assert.ErrorIs(t, err, ErrKeySetUnavailable)
+	assert.Equal(t, int32(2), fetches.Load())
+}
+
+func Test_KeySetStale(t *testing.T) {
+	t.Parallel()
+
+	issuer := authtest.NewIssuer(t)
+	var fetches atomic.Int32
+	var down atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(issuer.JWKS(t))
+	}))
+	defer server.Close()
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	keys := NewKeySet(server.URL)
+	keys.now = func() time.Time { return now }
+
+	_, err := keys.Key(authtest.RSAKeyId)
+	require.NoError(t, err)
+
+	// The cached keys are used when they cannot be reloaded after the TTL
+	down.Store(true)
+	now = now.Add(keys.TTL)
+	key, err := keys.Key(authtest.RSAKeyId)
+	require.NoError(t, err)
+	assert.True(t, issuer.RSAKey.PublicKey.Equal(key.(*rsa.PublicKey)))
+	assert.Equal(t, int32(2), fetches.Load())
+
+	// And the reload is not attempted again before the refresh period
+	_, err = keys.Key(authtest.ECKeyId)
+	require.NoError(t, err)
+	_, err = keys.Key("rotated")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	assert.Equal(t, int32(2), fetches.Load())
+
+	down.Store(false)
+	now = now.Add(keys.RefreshPeriod)
+	_, err = keys.Key(authtest.RSAKeyId)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), fetches.Load())
+
+	// The reloaded keys are cached for the TTL
+	_, err = keys.Key(authtest.RSAKeyId)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), fetches.Load())
+}
+
+func Test_parseKeySet(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name  string
+		jwks  string
+		kids  []string
+		error bool
+	}{
+		{
+			name: "skips encryption and unsupported keys",
+			jwks: `{"keys":[{"kty":"RSA","kid":"enc","use":"enc","n":"AQAB","e":"AQAB"},{"kty":"oct","kid":"hmac","k":"c2VjcmV0"},{"kty":"EC","kid":"p384","crv":"P-384","x":"AA","y":"AA"}]}`,
+			kids: []string{},
+		},
+		{
+			name: "skips an EC point not on the curve",
+			jwks: `{"keys":[{"kty":"EC","kid":"ec","crv":"P-256","x":"AQ","y":"AQ"},{"kty":"RSA","kid":"rsa","n":"AQAB","e":"AQAB"}]}`,
+			kids: []string{"rsa"},
+		},
+		{
+			name: "skips an RSA exponent too small",
+			jwks: `{"keys":[{"kty":"RSA","kid":"small","n":"AQAB","e":"AQ"},{"kty":"RSA","kid":"rsa","n":"AQAB","e":"AQAB"}]}`,
+			kids: []string{"rsa"},
+		},
+		{
+			name: "skips a key that is not base64",
+			jwks: `{"keys":[{"kty":"RSA","kid":"bad","n":"!","e":"AQAB"}]}`,
+			kids: []string{},
+		},
+		{
+			name:  "not JSON",
+			jwks:  `keys`,
+			error: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			keys, err := parseKeySet([]byte(tc.jwks))
+
+			if tc.error {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			kids := []string{}
+			for kid := range keys {
+				kids = append(kids, kid)
+			}
+			assert.ElementsMatch(t, tc.kids, kids)
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/lfroomin/restaurant-serverless/internal/apikey"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"log"
+	"time"
+)
+
+// The API keys are stored in the restaurants table, all in the APIKEYS partition with the sort
+// key APIKEY#<apiKeyId>, so that a key is found by its id alone. The requests of an API key are
+// counted in its own partition APIKEY#<apiKeyId>, with the sort key USAGE#<period>.
+const (
+	apiKeysPartition   = "APIKEYS"
+	apiKeyKeyPrefix    = "APIKEY#"
+	usageSortKeyPrefix = "USAGE#"
+	apiKeyHashAttr     = "KeyHash"
+	usageCountAttr     = "RequestCount"
+)
+
+// apiKeyItem is an API key. A revoked API key has no KeyHash.
+type apiKeyItem struct {
+	RestaurantId string
+	SK           string
+	ApiKey       model.ApiKey
+	KeyHash      string `dynamodbav:",omitempty"`
+}
+
+func (item apiKeyItem) key() apikey.Key {
+	return apikey.Key{ApiKey: item.ApiKey, Hash: item.KeyHash}
+}
+
+// SaveApiKey stores a new API key. Its key is not stored, only its hash.
+func (rs RestaurantStorage) SaveApiKey(k apikey.Key) error {
+	log.Printf("RestaurantStorage.SaveApiKey apiKeyId: %s\n", *k.Id)
+
+	item := apiKeyItem{RestaurantId: apiKeysPartition, SK: apiKeyKeyPrefix + *k.Id, ApiKey: k.ApiKey, KeyHash: k.Hash}
+	item.ApiKey.Key = nil
+	if err := rs.putNew(item); err != nil {
+		return fmt.Errorf("error saving API key %q in dynamo: %w", *k.Id, err)
+	}
+	return nil
+}
+
+func (rs RestaurantStorage) GetApiKey(apiKeyId string) (apikey.Key, bool, error) {
+	log.Printf("RestaurantStorage.GetApiKey apiKeyId: %s\n", apiKeyId)
+
+	input := dynamodb.GetItemInput{
+		Key:       primaryKey(apiKeysPartition, apiKeyKeyPrefix+apiKeyId),
+		TableName: aws.String(rs.Table),
+	}
+
+	data, err := rs.Client.GetItem(context.Background(), &input)
+	if err != nil {
+		return apikey.Key{}, false, fmt.Errorf("error getting API key %q in dynamo: %w", apiKeyId, err)
+	}
+	if data.Item == nil {
+		return apikey.Key{}, false, nil
+	}
+
+	item := apiKeyItem{}
+	if err = attributevalue.UnmarshalMap(data.Item, &item); err != nil {
+		return apikey.Key{}, false, fmt.Errorf("error unmarshalling value: %w", err)
+	}
+	return item.key(), true, nil
+}
+
+// ListApiKeys returns the API keys of the tenant, the revoked ones included, in the order of their ids.
+func (rs RestaurantStorage) ListApiKeys(tenantId string) ([]apikey.Key, error) {
+	log.Printf("RestaurantStorage.ListApiKeys tenantId: %s\n", tenantId)
+
+	data, err := rs.queryPartition(apiKeysPartition, apiKeyKeyPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("error listing API keys in dynamo: %w", err)
+	}
+
+	var items []apiKeyItem
+	if err = attributevalue.UnmarshalListOfMaps(data, &items); err != nil {
+		return nil, fmt.Errorf("error unmarshalling value: %w", err)
+	}
+
+	keys := make([]apikey.Key, 0, len(items))
+	for _, item := range items {
+		if item.ApiKey.TenantId != nil && *item.ApiKey.TenantId == tenantId {
+			keys = append(keys, item.key())
+		}
+	}
+	return keys, nil
+}
+
+// RotateApiKey replaces the hash of the API key, and returns the rotated API key. It returns
+// storage.ErrNotFound when the API key does not exist, and storage.ErrConflict when it is revoked.
+func (rs RestaurantStorage) RotateApiKey(apiKeyId, hash string, rotated time.Time) (apikey.Key, error) {
+	log.Printf("RestaurantStorage.RotateApiKey apiKeyId: %s\n", apiKeyId)
+
+	update := expression.Set(expression.Name(apiKeyHashAttr), expression.Value(hash)).
+		Set(expression.Name("ApiKey.Rotated"), expression.Value(rotated))
+
+	k, err := rs.updateApiKey(apiKeyId, update, expression.AttributeExists(expression.Name(apiKeyHashAttr)))
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		// The condition does not tell whether the API key is revoked or does not exist
+		err = storage.ErrConflict
+		if _, exists, getErr := rs.GetApiKey(apiKeyId); getErr == nil && !exists {
+			err = storage.ErrNotFound
+		}
+	}
+	if err != nil {
+		return apikey.Key{}, fmt.Errorf("error rotating API key %q in dynamo: %w", apiKeyId, err)
+	}
+	return k, nil
+}
+
+// RevokeApiKey removes the hash of the API key, so that its key is rejected, and returns the
+// revoked API key. It returns storage.ErrNotFound when the API key does not exist.
+func (rs RestaurantStorage) RevokeApiKey(apiKeyId string, revoked time.Time) (apikey.Key, error) {
+	log.Printf("RestaurantStorage.RevokeApiKey apiKeyId: %s\n", apiKeyId)
+
+	update := expression.Set(expression.Name("ApiKey.Revoked"), expression.Value(revoked)).
+		Remove(expression.Name(apiKeyHashAttr))
+
+	k, err := rs.updateApiKey(apiKeyId, update, expression.AttributeExists(expression.Name(key)))
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		err = storage.ErrNotFound
+	}
+	if err != nil {
+		return apikey.Key{}, fmt.Errorf("error revoking API key %q in dynamo: %w", apiKeyId, err)
+	}
+	return k, nil
+}
+
+// UseApiKey counts a request of the API key in the period, unless limit requests were already
+// counted, and returns the number of requests of the period. The count expires after expires.
+func (rs RestaurantStorage) UseApiKey(apiKeyId, period string, limit int32, expires time.Time) (int32, error) {
+	expr, err := expression.NewBuilder().
+		WithUpdate(expression.Add(expression.Name(usageCountAttr), expression.Value(1)).
+			Set(expression.Name("ExpiresAt"), expression.Value(expires.Unix()))).
+		WithCondition(expression.AttributeNotExists(expression.Name(usageCountAttr)).
+			Or(expression.LessThan(expression.Name(usageCountAttr), expression.Value(limit)))).
+		Build()
+	if err != nil {
+		return 0, err
+	}
+
+	input := dynamodb.UpdateItemInput{
+		Key:                       primaryKey(apiKeyKeyPrefix+apiKeyId, usageSortKeyPrefix+period),
+		TableName:                 aws.String(rs.Table),
+		UpdateExpression:          expr.Update(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ConditionExpression:       expr.Condition(),
+		ReturnValues:              types.ReturnValueUpdatedNew,
+	}
+
+	data, err := rs.Client.UpdateItem(context.Background(), &input)
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			err = storage.ErrLimitExceeded
+		}
+		return 0, fmt.Errorf("error counting a request of API key %q in dynamo: %w", apiKeyId, err)
+	}
+
+	var count int32
+	if err = attributevalue.Unmarshal(data.Attributes[usageCountAttr], &count); err != nil {
+		return 0, fmt.Errorf("error unmarshalling value: %w", err)
+	}
+	return count, nil
+}
+
+// updateApiKey applies the update to the API key when the condition holds, and returns the updated API key.
+func (rs RestaurantStorage) updateApiKey(apiKeyId string, update expression.UpdateBuilder, cond expression.ConditionBuilder) (apikey.Key, error) {
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return apikey.Key{}, err
+	}
+
+	input := dynamodb.UpdateItemInput{
+		Key:                       primaryKey(apiKeysPartition, apiKeyKeyPrefix+apiKeyId),
+		TableName:                 aws.String(rs.Table),
+		UpdateExpression:          expr.Update(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ConditionExpression:       expr.Condition(),
+		ReturnValues:              types.ReturnValueAllNew,
+	}
+
+	data, err := rs.Client.UpdateItem(context.Background(), &input)
+	if err != nil {
+		return apikey.Key{}, err
+	}
+
+	item := apiKeyItem{}
+	if err = attributevalue.UnmarshalMap(data.Attributes, &item); err != nil {
+		return apikey.Key{}, fmt.Errorf("error unmarshalling value: %w", err)
+	}
+	return item.key(), nil
+}
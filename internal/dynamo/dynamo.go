@@ -2,14 +2,21 @@ package dynamo
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/lfroomin/restaurant-serverless/internal/event"
 	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
 	"log"
+	"reflect"
+	"strings"
 	"time"
 )
 
@@ -18,9 +25,44 @@ type dynamoRestaurantStorer interface {
 	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
 	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
 	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+	BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
 }
 
-const key = "RestaurantId"
+// The restaurants, their menus and their reviews are stored in the same table. The
+// partition key is the restaurant id prefixed with its tenant, TENANT#<tenantId>#<restaurantId>,
+// so a restaurant can only be read or written with the id of its tenant. The sort key tells
+// the items of a partition apart.
+const (
+	key         = "RestaurantId"
+	sortKey     = "SK"
+	tenantAttr  = "TenantId"
+	versionAttr = "Version"
+
+	tenantKeyPrefix = "TENANT#"
+
+	restaurantSortKey   = "RESTAURANT"
+	menuSortKeyPrefix   = "MENU#"
+	reviewSortKeyPrefix = "REVIEW#"
+
+	// The restaurants of a tenant are listed with the sparse TenantIndex, keyed by their tenant,
+	// TENANT#<tenantId>, and sorted by their partition key. The other items are not in it.
+	tenantIndex     = "TenantIndex"
+	tenantIndexAttr = "RestaurantTenant"
+
+	// maxWriteAttempts is how many times a write is tried when the restaurant changes
+	// between reading it, for the event of the change, and writing it
+	maxWriteAttempts = 3
+
+	// maxBatchWriteItems is the most items a BatchWriteItem writes, and maxBatchWriteAttempts how
+	// many times the items it leaves unprocessed are written, after a backoff that starts at
+	// batchWriteBackoff and doubles each time
+	maxBatchWriteItems    = 25
+	maxBatchWriteAttempts = 5
+	batchWriteBackoff     = 50 * time.Millisecond
+)
 
 type RestaurantStorage struct {
 	Client dynamoRestaurantStorer
@@ -28,9 +70,38 @@ type RestaurantStorage struct {
 }
 
 type restaurantItem struct {
+	// RestaurantId is the partition key of the restaurant
 	RestaurantId string
-	Restaurant   model.Restaurant
-	Updated      int64
+	SK           string
+	TenantId     string
+	// RestaurantTenant is the partition of the restaurant in the TenantIndex
+	RestaurantTenant string `dynamodbav:",omitempty"`
+	Restaurant       model.Restaurant
+	Updated          int64
+	Version          int64
+	Geohash          string `dynamodbav:",omitempty"`
+	GeohashPrefix    string `dynamodbav:",omitempty"`
+	// The number of reviews of each rating, incremented and decremented with ADD
+	// by SaveReview and DeleteReview. ADD only applies to top-level attributes.
+	Rating1 int `dynamodbav:",omitempty"`
+	Rating2 int `dynamodbav:",omitempty"`
+	Rating3 int `dynamodbav:",omitempty"`
+	Rating4 int `dynamodbav:",omitempty"`
+	Rating5 int `dynamodbav:",omitempty"`
+}
+
+// migrate converts a restaurant stored in an older format to the current format.
+// The converted restaurant is stored by the next write of the restaurant.
+func (item *restaurantItem) migrate() {
+	item.Restaurant.BackfillCoordinates()
+}
+
+// restaurant returns the migrated restaurant with the summary of its ratings.
+func (item *restaurantItem) restaurant() model.Restaurant {
+	item.migrate()
+	restaurant := item.Restaurant
+	restaurant.Rating = model.NewRatingSummary([model.MaxRating]int{item.Rating1, item.Rating2, item.Rating3, item.Rating4, item.Rating5})
+	return restaurant
 }
 
 func New(cfg aws.Config, table string) RestaurantStorage {
@@ -40,108 +111,527 @@ func New(cfg aws.Config, table string) RestaurantStorage {
 	}
 }
 
-func (rs RestaurantStorage) Save(restaurant model.Restaurant) error {
-	log.Printf("RestaurantStorage.Save restaurantId: %s\n", *restaurant.Id)
-
-	r := restaurantItem{
-		RestaurantId: *restaurant.Id,
-		Restaurant:   restaurant,
-		Updated:      time.Now().UnixMilli(),
-	}
+// Save stores the new restaurant of the tenant and records a RestaurantCreated event in the outbox.
+func (rs RestaurantStorage) Save(tenantId string, restaurant model.Restaurant) error {
+	log.Printf("RestaurantStorage.Save tenantId: %s  restaurantId: %s\n", tenantId, *restaurant.Id)
 
-	av, err := attributevalue.MarshalMap(r)
+	item := newRestaurantItem(tenantId, restaurant)
+	av, err := attributevalue.MarshalMap(item)
 	if err != nil {
 		return fmt.Errorf("error marshalling value: %w", err)
 	}
 
-	input := &dynamodb.PutItemInput{
-		Item:      av,
-		TableName: aws.String(rs.Table),
+	expr, err := expression.NewBuilder().WithCondition(expression.AttributeNotExists(expression.Name(key))).Build()
+	if err != nil {
+		return err
 	}
 
-	_, err = rs.Client.PutItem(context.Background(), input)
-	if err != nil {
+	write := types.TransactWriteItem{Put: &types.Put{
+		Item:                     av,
+		TableName:                aws.String(rs.Table),
+		ConditionExpression:      expr.Condition(),
+		ExpressionAttributeNames: expr.Names(),
+	}}
+
+	after := item.restaurant()
+	if err = rs.writeWithEvent(write, event.New(tenantId, event.RestaurantCreated, nil, &after, item.Version)); err != nil {
+		if writeConditionFailed(err) {
+			err = storage.ErrConflict
+		}
 		return fmt.Errorf("error saving restaurant %q in dynamo: %w", *restaurant.Id, err)
 	}
 	return nil
 }
 
-func (rs RestaurantStorage) Get(restaurantId string) (model.Restaurant, bool, error) {
-	log.Printf("RestaurantStorage.Get restaurantId: %s\n", restaurantId)
+// Get returns the restaurant of the tenant and its version. The version is incremented by every write.
+func (rs RestaurantStorage) Get(tenantId, restaurantId string) (model.Restaurant, int64, bool, error) {
+	log.Printf("RestaurantStorage.Get tenantId: %s  restaurantId: %s\n", tenantId, restaurantId)
 
 	input := dynamodb.GetItemInput{
-		Key: map[string]types.AttributeValue{
-			key: &types.AttributeValueMemberS{Value: restaurantId},
-		},
+		Key:       primaryKey(partitionKey(tenantId, restaurantId), restaurantSortKey),
 		TableName: aws.String(rs.Table),
 	}
 
 	item := &restaurantItem{}
 	data, err := rs.Client.GetItem(context.Background(), &input)
 	if err != nil {
-		return model.Restaurant{}, false, fmt.Errorf("error getting restaurant %q in dynamo: %w", restaurantId, err)
+		return model.Restaurant{}, 0, false, fmt.Errorf("error getting restaurant %q in dynamo: %w", restaurantId, err)
 	}
 
 	if data.Item != nil {
 		if err = attributevalue.UnmarshalMap(data.Item, &item); err != nil {
-			return model.Restaurant{}, false, fmt.Errorf("error unmarshalling value: %w", err)
+			return model.Restaurant{}, 0, false, fmt.Errorf("error unmarshalling value: %w", err)
 		}
-		return item.Restaurant, true, nil
+		return item.restaurant(), item.Version, true, nil
 	}
 
-	return model.Restaurant{}, false, nil
+	return model.Restaurant{}, 0, false, nil
 }
 
-func (rs RestaurantStorage) Update(restaurant model.Restaurant) error {
-	log.Printf("RestaurantStorage.Update restaurantId: %s\n", *restaurant.Id)
-
-	cond := expression.Equal(expression.Name(key), expression.Value(*restaurant.Id))
+// Update replaces the restaurant, records a RestaurantUpdated event in the outbox and returns
+// its new version. If ifVersion is not nil the update only succeeds when it matches the stored version.
+func (rs RestaurantStorage) Update(tenantId string, restaurant model.Restaurant, ifVersion *int64) (int64, error) {
+	log.Printf("RestaurantStorage.Update tenantId: %s  restaurantId: %s\n", tenantId, *restaurant.Id)
 
+	item := newRestaurantItem(tenantId, restaurant)
 	update := expression.Set(
 		expression.Name("Restaurant"),
-		expression.Value(restaurant),
+		expression.Value(item.Restaurant),
 	).Set(
 		expression.Name("Updated"),
-		expression.Value(time.Now().UnixMilli()),
+		expression.Value(item.Updated),
+	).Add(
+		expression.Name(versionAttr),
+		expression.Value(1),
 	)
 
+	// Keep the geohash index in step with the location of the restaurant
+	if item.Geohash != "" {
+		update = update.Set(expression.Name(geohashAttr), expression.Value(item.Geohash)).
+			Set(expression.Name(geohashPrefixAttr), expression.Value(item.GeohashPrefix))
+	} else {
+		update = update.Remove(expression.Name(geohashAttr)).
+			Remove(expression.Name(geohashPrefixAttr))
+	}
+
+	version, err := rs.writeRestaurant(tenantId, *restaurant.Id, ifVersion, func(stored model.Restaurant, version int64) (types.TransactWriteItem, event.Event, error) {
+		// The rating summary is kept by the update
+		after := item.Restaurant
+		after.Rating = stored.Rating
+
+		write, err := updateWrite(rs.Table, item.RestaurantId, update, versionCondition(item.RestaurantId, &version))
+		return write, event.New(tenantId, event.RestaurantUpdated, &stored, &after, version+1), err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error updating restaurant %q in dynamo: %w", *restaurant.Id, err)
+	}
+	return version, nil
+}
+
+// Patch writes only the attributes of the restaurant that differ between
+// original and patched, so concurrent patches of different fields do not
+// overwrite each other. It records a RestaurantUpdated event in the outbox
+// and returns the new version of the restaurant.
+func (rs RestaurantStorage) Patch(tenantId string, original, patched model.Restaurant, ifVersion *int64) (int64, error) {
+	log.Printf("RestaurantStorage.Patch tenantId: %s  restaurantId: %s\n", tenantId, *patched.Id)
+
+	// The rating summary is not stored with the restaurant
+	original.Rating, patched.Rating = nil, nil
+
+	before, err := attributevalue.MarshalMap(original)
+	if err != nil {
+		return 0, fmt.Errorf("error marshalling value: %w", err)
+	}
+	after, err := attributevalue.MarshalMap(patched)
+	if err != nil {
+		return 0, fmt.Errorf("error marshalling value: %w", err)
+	}
+
+	item := newRestaurantItem(tenantId, patched)
+	update := expression.Set(expression.Name("Updated"), expression.Value(item.Updated)).
+		Add(expression.Name(versionAttr), expression.Value(1))
+
+	var changed []string
+	fields := reflect.ValueOf(patched)
+	for name, av := range after {
+		if !reflect.DeepEqual(before[name], av) {
+			update = update.Set(expression.Name("Restaurant."+name), expression.Value(fields.FieldByName(name).Interface()))
+			changed = append(changed, name)
+		}
+	}
+
+	if !reflect.DeepEqual(before["Address"], after["Address"]) {
+		if item.Geohash != "" {
+			update = update.Set(expression.Name(geohashAttr), expression.Value(item.Geohash)).
+				Set(expression.Name(geohashPrefixAttr), expression.Value(item.GeohashPrefix))
+		} else {
+			update = update.Remove(expression.Name(geohashAttr)).
+				Remove(expression.Name(geohashPrefixAttr))
+		}
+	}
+
+	version, err := rs.writeRestaurant(tenantId, *patched.Id, ifVersion, func(stored model.Restaurant, version int64) (types.TransactWriteItem, event.Event, error) {
+		// The stored restaurant with the patched fields, as the update leaves it
+		result := stored
+		r := reflect.ValueOf(&result).Elem()
+		for _, name := range changed {
+			r.FieldByName(name).Set(fields.FieldByName(name))
+		}
+
+		write, err := updateWrite(rs.Table, item.RestaurantId, update, versionCondition(item.RestaurantId, &version))
+		return write, event.New(tenantId, event.RestaurantUpdated, &stored, &result, version+1), err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error patching restaurant %q in dynamo: %w", *patched.Id, err)
+	}
+	return version, nil
+}
+
+// Delete removes the restaurant of the tenant, its menus and its reviews, records a RestaurantDeleted
+// event in the outbox and returns the restaurant as it was before the delete. If ifVersion is not nil
+// the delete only succeeds when it matches the stored version.
+func (rs RestaurantStorage) Delete(tenantId, restaurantId string, ifVersion *int64) (model.Restaurant, error) {
+	log.Printf("RestaurantStorage.Delete tenantId: %s  restaurantId: %s\n", tenantId, restaurantId)
+
+	pk := partitionKey(tenantId, restaurantId)
+	var deleted model.Restaurant
+	_, err := rs.writeRestaurant(tenantId, restaurantId, ifVersion, func(stored model.Restaurant, version int64) (types.TransactWriteItem, event.Event, error) {
+		deleted = stored
+		e := event.New(tenantId, event.RestaurantDeleted, &stored, nil, 0)
+
+		expr, err := expression.NewBuilder().WithCondition(versionCondition(pk, &version)).Build()
+		if err != nil {
+			return types.TransactWriteItem{}, e, err
+		}
+		return types.TransactWriteItem{Delete: &types.Delete{
+			TableName:                 aws.String(rs.Table),
+			Key:                       primaryKey(pk, restaurantSortKey),
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+		}}, e, nil
+	})
+	if errors.Is(err, storage.ErrNotFound) {
+		// The menus and reviews that a previous Delete of the restaurant failed to delete
+		if cerr := rs.deleteChildren(pk); cerr != nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		return model.Restaurant{}, fmt.Errorf("error deleting restaurant %q from dynamo: %w", restaurantId, err)
+	}
+
+	if err = rs.deleteChildren(pk); err != nil {
+		return model.Restaurant{}, fmt.Errorf("error deleting the menus and reviews of restaurant %q from dynamo: %w", restaurantId, err)
+	}
+	return deleted, nil
+}
+
+// writeRestaurant reads the restaurant and writes it, with the write and event built from the
+// restaurant and version that were read. The write must be conditioned on that version; when
+// the restaurant changed in between it is read and written again. It returns the new version.
+func (rs RestaurantStorage) writeRestaurant(tenantId, restaurantId string, ifVersion *int64, build func(stored model.Restaurant, version int64) (types.TransactWriteItem, event.Event, error)) (int64, error) {
+	for attempt := 1; ; attempt++ {
+		stored, version, exists, err := rs.Get(tenantId, restaurantId)
+		if err != nil {
+			return 0, err
+		}
+		if !exists {
+			return 0, storage.ErrNotFound
+		}
+		if ifVersion != nil && *ifVersion != version {
+			return 0, fmt.Errorf("version %d does not match: %w", *ifVersion, storage.ErrPreconditionFailed)
+		}
+
+		write, e, err := build(stored, version)
+		if err != nil {
+			return 0, err
+		}
+
+		err = rs.writeWithEvent(write, e)
+		if err == nil {
+			return version + 1, nil
+		}
+		if !writeConditionFailed(err) {
+			return 0, err
+		}
+		if attempt == maxWriteAttempts {
+			return 0, fmt.Errorf("the restaurant changed during %d attempts to write it: %w", attempt, err)
+		}
+	}
+}
+
+// updateWrite returns the transaction write of the update of the restaurant in the partition when the condition holds.
+func updateWrite(table, pk string, update expression.UpdateBuilder, cond expression.ConditionBuilder) (types.TransactWriteItem, error) {
 	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return types.TransactWriteItem{}, err
+	}
+
+	return types.TransactWriteItem{Update: &types.Update{
+		Key:                       primaryKey(pk, restaurantSortKey),
+		TableName:                 aws.String(table),
+		UpdateExpression:          expr.Update(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ConditionExpression:       expr.Condition(),
+	}}, nil
+}
+
+// partitionKey returns the partition key of the restaurant of the tenant.
+// tenantPartition returns the partition of the restaurants of the tenant in the TenantIndex.
+func tenantPartition(tenantId string) string {
+	return tenantKeyPrefix + tenantId
+}
+
+func partitionKey(tenantId, restaurantId string) string {
+	return tenantKeyPrefix + tenantId + "#" + restaurantId
+}
+
+func primaryKey(pk, sk string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		key:     &types.AttributeValueMemberS{Value: pk},
+		sortKey: &types.AttributeValueMemberS{Value: sk},
+	}
+}
+
+// versionCondition requires the restaurant of the partition to exist and, if ifVersion is not nil, to
+// have that version. Restaurants saved before versions were introduced have no version attribute and
+// match version 0.
+func versionCondition(pk string, ifVersion *int64) expression.ConditionBuilder {
+	cond := expression.Equal(expression.Name(key), expression.Value(pk))
+	if ifVersion == nil {
+		return cond
+	}
+
+	version := expression.Equal(expression.Name(versionAttr), expression.Value(*ifVersion))
+	if *ifVersion == 0 {
+		version = version.Or(expression.AttributeNotExists(expression.Name(versionAttr)))
+	}
+	return cond.And(version)
+}
+
+// queryPartition returns the items of the restaurant partition whose sort key starts
+// with skPrefix (all the items when it is empty), following the pages of the query.
+func (rs RestaurantStorage) queryPartition(pk, skPrefix string) ([]map[string]types.AttributeValue, error) {
+	keyCond := expression.Key(key).Equal(expression.Value(pk))
+	if skPrefix != "" {
+		keyCond = keyCond.And(expression.Key(sortKey).BeginsWith(skPrefix))
+	}
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	input := dynamodb.QueryInput{
+		TableName:                 aws.String(rs.Table),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+
+	var items []map[string]types.AttributeValue
+	for {
+		data, err := rs.Client.Query(context.Background(), &input)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, data.Items...)
+
+		if len(data.LastEvaluatedKey) == 0 {
+			return items, nil
+		}
+		input.ExclusiveStartKey = data.LastEvaluatedKey
+	}
+}
+
+// deleteChildren deletes the menus and reviews of a deleted restaurant, in batches. Its events
+// are left in the outbox, to be delivered. It returns an error with the number of menus and
+// reviews left when some could not be deleted, which deleting the restaurant again deletes.
+func (rs RestaurantStorage) deleteChildren(pk string) error {
+	items, err := rs.queryPartition(pk, "")
 	if err != nil {
 		return err
 	}
 
+	var requests []types.WriteRequest
+	for _, item := range items {
+		if sk, ok := item[sortKey].(*types.AttributeValueMemberS); !ok || sk.Value == restaurantSortKey || strings.HasPrefix(sk.Value, outboxSortKeyPrefix) {
+			continue
+		}
+		requests = append(requests, types.WriteRequest{DeleteRequest: &types.DeleteRequest{
+			Key: map[string]types.AttributeValue{key: item[key], sortKey: item[sortKey]},
+		}})
+	}
+
+	// All the batches are written, so that as few children as possible are left
+	left := 0
+	var errs []error
+	for start := 0; start < len(requests); start += maxBatchWriteItems {
+		end := start + maxBatchWriteItems
+		if end > len(requests) {
+			end = len(requests)
+		}
+		unprocessed, err := rs.batchWrite(requests[start:end])
+		if err != nil {
+			errs = append(errs, err)
+		}
+		left += len(unprocessed)
+	}
+	if left == 0 {
+		return nil
+	}
+
+	log.Printf("RestaurantStorage.deleteChildren pk: %s  left: %d\n", pk, left)
+	err = fmt.Errorf("%d of the %d menus and reviews were not deleted", left, len(requests))
+	return errors.Join(append([]error{err}, errs...)...)
+}
+
+// batchWrite writes the requests with BatchWriteItem. The requests that DynamoDB leaves
+// unprocessed, such as when the table is throttled, are written again after a backoff. It
+// returns the requests that are still unprocessed after maxBatchWriteAttempts, or all of them
+// on an error.
+func (rs RestaurantStorage) batchWrite(requests []types.WriteRequest) ([]types.WriteRequest, error) {
+	backoff := batchWriteBackoff
+	for attempt := 1; ; attempt++ {
+		input := dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{rs.Table: requests},
+		}
+		output, err := rs.Client.BatchWriteItem(context.Background(), &input)
+		if err != nil {
+			return requests, err
+		}
+
+		requests = output.UnprocessedItems[rs.Table]
+		if len(requests) == 0 || attempt == maxBatchWriteAttempts {
+			return requests, nil
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// updateItem applies the update to the restaurant of the tenant when the condition holds and returns the new version.
+func (rs RestaurantStorage) updateItem(tenantId, restaurantId string, update expression.UpdateBuilder, cond expression.ConditionBuilder, ifVersion *int64) (int64, error) {
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return 0, err
+	}
+
 	input := dynamodb.UpdateItemInput{
-		Key: map[string]types.AttributeValue{
-			key: &types.AttributeValueMemberS{Value: *restaurant.Id},
-		},
+		Key:                       primaryKey(partitionKey(tenantId, restaurantId), restaurantSortKey),
 		TableName:                 aws.String(rs.Table),
 		UpdateExpression:          expr.Update(),
 		ExpressionAttributeNames:  expr.Names(),
 		ExpressionAttributeValues: expr.Values(),
 		ConditionExpression:       expr.Condition(),
+		ReturnValues:              types.ReturnValueUpdatedNew,
 	}
 
-	_, err = rs.Client.UpdateItem(context.Background(), &input)
+	data, err := rs.Client.UpdateItem(context.Background(), &input)
 	if err != nil {
-		return fmt.Errorf("error updating restaurant %q in dynamo: %w", *restaurant.Id, err)
+		return 0, rs.conditionError(err, tenantId, restaurantId, ifVersion)
 	}
-	return nil
+
+	item := restaurantItem{}
+	if data != nil {
+		if err = attributevalue.UnmarshalMap(data.Attributes, &item); err != nil {
+			return 0, fmt.Errorf("error unmarshalling value: %w", err)
+		}
+	}
+	return item.Version, nil
 }
 
-func (rs RestaurantStorage) Delete(restaurantId string) error {
-	log.Printf("RestaurantStorage.Delete restaurantId: %s\n", restaurantId)
+// conditionError converts a failed versionCondition into storage.ErrNotFound when the
+// restaurant does not exist, or storage.ErrPreconditionFailed when its version differs.
+func (rs RestaurantStorage) conditionError(err error, tenantId, restaurantId string, ifVersion *int64) error {
+	var ccf *types.ConditionalCheckFailedException
+	if !errors.As(err, &ccf) {
+		return err
+	}
 
-	input := dynamodb.DeleteItemInput{
-		TableName: aws.String(rs.Table),
-		Key: map[string]types.AttributeValue{
-			key: &types.AttributeValueMemberS{Value: restaurantId},
-		},
+	if ifVersion != nil {
+		// The condition does not tell which part failed, so check whether the restaurant exists
+		if _, _, exists, getErr := rs.Get(tenantId, restaurantId); getErr != nil || exists {
+			return fmt.Errorf("version %d does not match: %w", *ifVersion, storage.ErrPreconditionFailed)
+		}
 	}
+	return storage.ErrNotFound
+}
 
-	_, err := rs.Client.DeleteItem(context.Background(), &input)
+// List returns up to limit restaurants of the tenant starting after the position encoded
+// in nextToken. The returned token is empty when there are no more restaurants. Only the
+// partition of the tenant in the TenantIndex is read.
+func (rs RestaurantStorage) List(tenantId string, limit int32, nextToken string) ([]model.Restaurant, string, error) {
+	log.Printf("RestaurantStorage.List tenantId: %s  limit: %d  nextToken: %s\n", tenantId, limit, nextToken)
+
+	partition := tenantPartition(tenantId)
+	expr, err := expression.NewBuilder().
+		WithKeyCondition(expression.Key(tenantIndexAttr).Equal(expression.Value(partition))).
+		Build()
 	if err != nil {
-		return fmt.Errorf("error deleting restaurant %q from dynamo: %w", restaurantId, err)
+		return nil, "", err
 	}
 
-	return nil
+	input := dynamodb.QueryInput{
+		TableName:                 aws.String(rs.Table),
+		IndexName:                 aws.String(tenantIndex),
+		Limit:                     aws.Int32(limit),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+
+	if nextToken != "" {
+		startKey, err := decodeNextToken(nextToken)
+		if err != nil {
+			return nil, "", err
+		}
+		// A token of another tenant, or of another listing, is not a position in this one
+		if s, ok := startKey[tenantIndexAttr].(*types.AttributeValueMemberS); !ok || s.Value != partition {
+			return nil, "", storage.ErrInvalidNextToken
+		}
+		input.ExclusiveStartKey = startKey
+	}
+
+	data, err := rs.Client.Query(context.Background(), &input)
+	if err != nil {
+		return nil, "", fmt.Errorf("error listing restaurants in dynamo: %w", err)
+	}
+
+	var items []restaurantItem
+	if err = attributevalue.UnmarshalListOfMaps(data.Items, &items); err != nil {
+		return nil, "", fmt.Errorf("error unmarshalling value: %w", err)
+	}
+
+	restaurants := make([]model.Restaurant, 0, len(items))
+	for _, item := range items {
+		restaurants = append(restaurants, item.restaurant())
+	}
+
+	token, err := encodeNextToken(data.LastEvaluatedKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return restaurants, token, nil
+}
+
+// encodeNextToken converts a LastEvaluatedKey into an opaque, URL safe token.
+func encodeNextToken(lastEvaluatedKey map[string]types.AttributeValue) (string, error) {
+	if len(lastEvaluatedKey) == 0 {
+		return "", nil
+	}
+
+	var k map[string]any
+	if err := attributevalue.UnmarshalMap(lastEvaluatedKey, &k); err != nil {
+		return "", fmt.Errorf("error unmarshalling last evaluated key: %w", err)
+	}
+
+	b, err := json.Marshal(k)
+	if err != nil {
+		return "", fmt.Errorf("error encoding next token: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodeNextToken converts a token created by encodeNextToken back into an ExclusiveStartKey.
+func decodeNextToken(token string) (map[string]types.AttributeValue, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, storage.ErrInvalidNextToken
+	}
+
+	var k map[string]any
+	if err = json.Unmarshal(b, &k); err != nil || len(k) == 0 {
+		return nil, storage.ErrInvalidNextToken
+	}
+
+	startKey, err := attributevalue.MarshalMap(k)
+	if err != nil {
+		return nil, storage.ErrInvalidNextToken
+	}
+
+	return startKey, nil
 }
@@ -3,10 +3,15 @@ package dynamo
 import (
 	"context"
 	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/lfroomin/restaurant-serverless/internal/event"
 	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
 )
 
@@ -15,15 +20,23 @@ func Test_Save(t *testing.T) {
 	restId := "restId"
 
 	testCases := []struct {
-		name       string
-		restaurant model.Restaurant
-		stubError  string
-		errMsg     string
+		name            string
+		restaurant      model.Restaurant
+		conditionFailed bool
+		stubError       string
+		err             error
+		errMsg          string
 	}{
 		{
 			name:       "happy path",
 			restaurant: model.Restaurant{Id: &restId},
 		},
+		{
+			name:            "restaurant already exists",
+			restaurant:      model.Restaurant{Id: &restId},
+			conditionFailed: true,
+			err:             storage.ErrConflict,
+		},
 		{
 			name:       "error",
 			restaurant: model.Restaurant{Id: &restId},
@@ -38,12 +51,14 @@ func Test_Save(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 			rs := RestaurantStorage{
-				Client: dynamoRestaurantStorerStub{error: tc.stubError},
+				Client: dynamoRestaurantStorerStub{conditionFailed: tc.conditionFailed, error: tc.stubError},
 				Table:  "RestaurantsTable-Test",
 			}
-			err := rs.Save(tc.restaurant)
+			err := rs.Save("tenant1", tc.restaurant)
 
-			if tc.errMsg != "" {
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+			} else if tc.errMsg != "" {
 				if assert.Error(t, err) {
 					assert.Equal(t, tc.errMsg, err.Error())
 				}
@@ -86,7 +101,7 @@ func Test_Get(t *testing.T) {
 			rs := RestaurantStorage{
 				Client: dynamoRestaurantStorerStub{restaurantId: tc.restId, error: tc.stubError},
 			}
-			restaurant, ok, err := rs.Get(tc.restId)
+			restaurant, version, ok, err := rs.Get("tenant1", tc.restId)
 
 			if tc.errMsg != "" {
 				if assert.Error(t, err) {
@@ -95,6 +110,7 @@ func Test_Get(t *testing.T) {
 			} else if tc.restId != "" {
 				assert.Nil(t, err)
 				assert.Equal(t, model.Restaurant{Id: &tc.restId}, restaurant)
+				assert.Equal(t, int64(3), version)
 				assert.True(t, ok)
 			} else {
 				assert.Nil(t, err)
@@ -109,21 +125,50 @@ func Test_Update(t *testing.T) {
 	t.Parallel()
 	restId := "restId"
 
+	version, otherVersion := int64(3), int64(2)
+
 	testCases := []struct {
-		name       string
-		restaurant model.Restaurant
-		stubError  string
-		errMsg     string
+		name            string
+		restaurant      model.Restaurant
+		ifVersion       *int64
+		notExist        bool
+		conditionFailed bool
+		stubError       string
+		err             error
+		errMsg          string
 	}{
 		{
 			name:       "happy path",
+			restaurant: model.Restaurant{Id: &restId, Name: "name"},
+		},
+		{
+			name:       "if version",
+			restaurant: model.Restaurant{Id: &restId, Name: "name"},
+			ifVersion:  &version,
+		},
+		{
+			name:       "version does not match",
+			restaurant: model.Restaurant{Id: &restId},
+			ifVersion:  &otherVersion,
+			err:        storage.ErrPreconditionFailed,
+		},
+		{
+			name:       "restaurant does not exist",
 			restaurant: model.Restaurant{Id: &restId},
+			notExist:   true,
+			err:        storage.ErrNotFound,
+		},
+		{
+			name:            "restaurant changed while writing",
+			restaurant:      model.Restaurant{Id: &restId},
+			conditionFailed: true,
+			errMsg:          "error updating restaurant \"restId\" in dynamo: the restaurant changed during 3 attempts to write it: TransactionCanceledException: Transaction cancelled",
 		},
 		{
 			name:       "error",
 			restaurant: model.Restaurant{Id: &restId},
 			stubError:  "an error occurred",
-			errMsg:     "error updating restaurant \"restId\" in dynamo: an error occurred",
+			errMsg:     "error updating restaurant \"restId\" in dynamo: error getting restaurant \"restId\" in dynamo: an error occurred",
 		},
 	}
 
@@ -132,41 +177,205 @@ func Test_Update(t *testing.T) {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
+			inputs := &stubInputs{}
+			stub := dynamoRestaurantStorerStub{inputs: inputs, conditionFailed: tc.conditionFailed, error: tc.stubError}
+			if !tc.notExist {
+				stub.restaurantId = restId
+			}
 			rs := RestaurantStorage{
-				Client: dynamoRestaurantStorerStub{error: tc.stubError},
+				Client: stub,
 				Table:  "RestaurantsTable-Test",
 			}
-			err := rs.Update(tc.restaurant)
+			newVersion, err := rs.Update("tenant1", tc.restaurant, tc.ifVersion)
 
-			if tc.errMsg != "" {
+			switch {
+			case tc.err != nil:
+				assert.ErrorIs(t, err, tc.err)
+				assert.Nil(t, inputs.transact)
+			case tc.errMsg != "":
 				if assert.Error(t, err) {
 					assert.Equal(t, tc.errMsg, err.Error())
 				}
-			} else {
+			default:
 				assert.Nil(t, err)
+				assert.Equal(t, int64(4), newVersion)
+
+				// The update is conditioned on the version that was read, and written with its event
+				update := inputs.transact.TransactItems[0].Update
+				require.NotNil(t, update)
+				assert.Equal(t, "(#0 = :0) AND (#1 = :1)", *update.ConditionExpression)
+				assert.Equal(t, &types.AttributeValueMemberN{Value: "3"}, update.ExpressionAttributeValues[":1"])
+				e := outboxEvent(t, inputs.transact)
+				assert.Equal(t, event.RestaurantUpdated, e.Type)
+				assert.Equal(t, []string{"name"}, e.ChangedFields)
+				assert.Equal(t, int64(4), e.Version)
+			}
+		})
+	}
+}
+
+func Test_Patch(t *testing.T) {
+	t.Parallel()
+	restId, name, newName, phone := "restId", "Rest 1", "Rest 2", "555-1234"
+	geocode := "47.606200,-122.332100"
+
+	testCases := []struct {
+		name            string
+		original        model.Restaurant
+		patched         model.Restaurant
+		ifVersion       *int64
+		notExist        bool
+		conditionFailed bool
+		stubError       string
+		setAttrs        []string
+		changedFields   []string
+		err             error
+		errMsg          string
+	}{
+		{
+			name:          "happy path",
+			original:      model.Restaurant{Id: &restId, Name: name, PhoneNumber: &phone},
+			patched:       model.Restaurant{Id: &restId, Name: newName, PhoneNumber: &phone},
+			setAttrs:      []string{"Restaurant", "Name", "Updated", versionAttr},
+			changedFields: []string{"name"},
+		},
+		{
+			name:          "remove a field",
+			original:      model.Restaurant{Id: &restId, Name: name, PhoneNumber: &phone},
+			patched:       model.Restaurant{Id: &restId, Name: name},
+			setAttrs:      []string{"Restaurant", "PhoneNumber", "Updated", versionAttr},
+			changedFields: []string{},
+		},
+		{
+			name:     "address changed",
+			original: model.Restaurant{Id: &restId, Name: name},
+			patched: model.Restaurant{Id: &restId, Name: name, Address: &model.Address{
+				Location: &model.Location{Geocode: &geocode},
+			}},
+			setAttrs:      []string{"Restaurant", "Address", "Updated", versionAttr, geohashAttr, geohashPrefixAttr},
+			changedFields: []string{"address"},
+		},
+		{
+			name:          "nothing changed",
+			original:      model.Restaurant{Id: &restId, Name: name},
+			patched:       model.Restaurant{Id: &restId, Name: name},
+			setAttrs:      []string{"Updated", versionAttr},
+			changedFields: []string{},
+		},
+		{
+			name:      "restaurant deleted",
+			original:  model.Restaurant{Id: &restId, Name: name},
+			patched:   model.Restaurant{Id: &restId, Name: newName},
+			ifVersion: new(int64),
+			notExist:  true,
+			err:       storage.ErrNotFound,
+		},
+		{
+			name:      "error",
+			original:  model.Restaurant{Id: &restId, Name: name},
+			patched:   model.Restaurant{Id: &restId, Name: newName},
+			stubError: "an error occurred",
+			errMsg:    "error patching restaurant \"restId\" in dynamo: error getting restaurant \"restId\" in dynamo: an error occurred",
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			inputs := &stubInputs{}
+			stub := dynamoRestaurantStorerStub{inputs: inputs, conditionFailed: tc.conditionFailed, error: tc.stubError}
+			if !tc.notExist {
+				stub.restaurantId = restId
 			}
+			rs := RestaurantStorage{
+				Client: stub,
+				Table:  "RestaurantsTable-Test",
+			}
+			version, err := rs.Patch("tenant1", tc.original, tc.patched, tc.ifVersion)
+
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			if tc.errMsg != "" {
+				if assert.Error(t, err) {
+					assert.Equal(t, tc.errMsg, err.Error())
+				}
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, int64(4), version)
+
+			var names []string
+			for _, n := range inputs.transact.TransactItems[0].Update.ExpressionAttributeNames {
+				if n != key {
+					names = append(names, n)
+				}
+			}
+			assert.ElementsMatch(t, tc.setAttrs, names)
+
+			// The event compares the stored restaurant, which has no name or phone number, with the
+			// stored restaurant after the patch
+			e := outboxEvent(t, inputs.transact)
+			assert.Equal(t, tc.changedFields, e.ChangedFields)
 		})
 	}
 }
 
 func Test_Delete(t *testing.T) {
 	t.Parallel()
+	version, otherVersion := int64(3), int64(2)
 
 	testCases := []struct {
-		name      string
-		restId    string
-		stubError string
-		errMsg    string
+		name            string
+		restId          string
+		ifVersion       *int64
+		unversioned     bool
+		notExist        bool
+		conditionFailed bool
+		stubError       string
+		condition       string
+		err             error
+		errMsg          string
 	}{
 		{
-			name:   "happy path",
-			restId: "restId",
+			name:      "happy path",
+			restId:    "restId",
+			condition: "(#0 = :0) AND (#1 = :1)",
+		},
+		{
+			name:      "if version",
+			restId:    "restId",
+			ifVersion: &version,
+			condition: "(#0 = :0) AND (#1 = :1)",
+		},
+		{
+			name:        "legacy restaurant without a version",
+			restId:      "restId",
+			ifVersion:   new(int64),
+			unversioned: true,
+			condition:   "(#0 = :0) AND ((#1 = :1) OR (attribute_not_exists (#1)))",
+		},
+		{
+			name:      "version does not match",
+			restId:    "restId",
+			ifVersion: &otherVersion,
+			err:       storage.ErrPreconditionFailed,
+		},
+		{
+			name:     "restaurant does not exist",
+			restId:   "restId",
+			notExist: true,
+			err:      storage.ErrNotFound,
 		},
 		{
 			name:      "error",
 			restId:    "restId",
 			stubError: "an error occurred",
-			errMsg:    "error deleting restaurant \"restId\" from dynamo: an error occurred",
+			errMsg:    "error deleting restaurant \"restId\" from dynamo: error getting restaurant \"restId\" in dynamo: an error occurred",
 		},
 	}
 
@@ -175,33 +384,172 @@ func Test_Delete(t *testing.T) {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
+			inputs := &stubInputs{}
+			stub := dynamoRestaurantStorerStub{inputs: inputs, unversioned: tc.unversioned, conditionFailed: tc.conditionFailed, error: tc.stubError}
+			if !tc.notExist {
+				stub.restaurantId = tc.restId
+			}
 			rs := RestaurantStorage{
-				Client: dynamoRestaurantStorerStub{error: tc.stubError},
+				Client: stub,
 				Table:  "RestaurantsTable-Test",
 			}
-			err := rs.Delete(tc.restId)
+			restaurant, err := rs.Delete("tenant1", tc.restId, tc.ifVersion)
 
-			if tc.errMsg != "" {
+			switch {
+			case tc.err != nil:
+				assert.ErrorIs(t, err, tc.err)
+			case tc.errMsg != "":
 				if assert.Error(t, err) {
 					assert.Equal(t, tc.errMsg, err.Error())
 				}
-			} else {
+			default:
+				assert.Nil(t, err)
+				require.NotNil(t, inputs.transact.TransactItems[0].Delete)
+				assert.Equal(t, tc.condition, *inputs.transact.TransactItems[0].Delete.ConditionExpression)
+				assert.Equal(t, model.Restaurant{Id: &tc.restId}, restaurant)
+
+				e := outboxEvent(t, inputs.transact)
+				assert.Equal(t, event.RestaurantDeleted, e.Type)
+				assert.Equal(t, &restaurant, e.Before)
+			}
+		})
+	}
+}
+
+// outboxEvent returns the event written to the outbox by the transaction.
+func outboxEvent(t *testing.T, input *dynamodb.TransactWriteItemsInput) event.Event {
+	require.Len(t, input.TransactItems, 2)
+	put := input.TransactItems[1].Put
+	require.NotNil(t, put)
+
+	item := outboxItem{}
+	require.NoError(t, attributevalue.UnmarshalMap(put.Item, &item))
+	assert.Equal(t, outboxShard(item.Event.Id), item.OutboxStatus)
+	return item.Event
+}
+
+func Test_List(t *testing.T) {
+	t.Parallel()
+	restId := "restId"
+
+	testCases := []struct {
+		name         string
+		nextToken    string
+		restaurantId string
+		stubError    string
+		restaurants  []model.Restaurant
+		expNextToken string
+		err          error
+		errMsg       string
+	}{
+		{
+			name:        "happy path",
+			restaurants: []model.Restaurant{{Id: &restId}},
+		},
+		{
+			name:         "more results",
+			restaurantId: restId,
+			nextToken:    "eyJSZXN0YXVyYW50SWQiOiJyZXN0MCIsIlJlc3RhdXJhbnRUZW5hbnQiOiJURU5BTlQjdGVuYW50MSJ9",
+			restaurants:  []model.Restaurant{{Id: &restId}},
+			expNextToken: "eyJSZXN0YXVyYW50SWQiOiJyZXN0SWQiLCJSZXN0YXVyYW50VGVuYW50IjoiVEVOQU5UI3RlbmFudDEifQ",
+		},
+		{
+			name:      "nextToken of another tenant",
+			nextToken: "eyJSZXN0YXVyYW50SWQiOiJyZXN0MCIsIlJlc3RhdXJhbnRUZW5hbnQiOiJURU5BTlQjdGVuYW50MiJ9",
+			err:       storage.ErrInvalidNextToken,
+		},
+		{
+			name:      "nextToken of a scan",
+			nextToken: "eyJSZXN0YXVyYW50SWQiOiJyZXN0MCJ9",
+			err:       storage.ErrInvalidNextToken,
+		},
+		{
+			name:      "nextToken not base64",
+			nextToken: "not base64!",
+			err:       storage.ErrInvalidNextToken,
+		},
+		{
+			name:      "nextToken not a key",
+			nextToken: "WzEsMl0",
+			err:       storage.ErrInvalidNextToken,
+		},
+		{
+			name:      "error",
+			stubError: "an error occurred",
+			errMsg:    "error listing restaurants in dynamo: an error occurred",
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			rs := RestaurantStorage{
+				Client: dynamoRestaurantStorerStub{restaurantId: tc.restaurantId, restaurants: tc.restaurants, error: tc.stubError},
+				Table:  "RestaurantsTable-Test",
+			}
+			restaurants, nextToken, err := rs.List("tenant1", 10, tc.nextToken)
+
+			switch {
+			case tc.err != nil:
+				assert.ErrorIs(t, err, tc.err)
+			case tc.errMsg != "":
+				if assert.Error(t, err) {
+					assert.Equal(t, tc.errMsg, err.Error())
+				}
+			default:
 				assert.Nil(t, err)
+				assert.Equal(t, tc.restaurants, restaurants)
+				assert.Equal(t, tc.expNextToken, nextToken)
 			}
 		})
 	}
 }
 
+func Test_NextToken(t *testing.T) {
+	t.Parallel()
+
+	startKey := map[string]types.AttributeValue{
+		key:       &types.AttributeValueMemberS{Value: "restId"},
+		"Updated": &types.AttributeValueMemberN{Value: "12345"},
+	}
+
+	token, err := encodeNextToken(startKey)
+	require.NoError(t, err)
+
+	decoded, err := decodeNextToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, startKey, decoded)
+
+	token, err = encodeNextToken(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, token)
+}
+
 type dynamoRestaurantStorerStub struct {
 	restaurantId string
 	restaurants  []model.Restaurant
-	error        string
+	items        []restaurantItem
+	inputs       *stubInputs
+	// unversioned makes the restaurant one stored before versions were introduced
+	unversioned     bool
+	conditionFailed bool
+	error           string
+}
+
+// stubInputs records the requests made to the stub
+type stubInputs struct {
+	transact *dynamodb.TransactWriteItemsInput
 }
 
 func (s dynamoRestaurantStorerStub) PutItem(_ context.Context, _ *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
 	if s.error != "" {
 		return nil, errors.New(s.error)
 	}
+	if s.conditionFailed {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
 	return nil, nil
 }
 
@@ -210,7 +558,11 @@ func (s dynamoRestaurantStorerStub) GetItem(_ context.Context, _ *dynamodb.GetIt
 		return nil, errors.New(s.error)
 	}
 	if s.restaurantId != "" {
-		return restaurantItemOutput(s.restaurantId)
+		output, err := restaurantItemOutput(s.restaurantId)
+		if err == nil && s.unversioned {
+			delete(output.Item, versionAttr)
+		}
+		return output, err
 	}
 	return &dynamodb.GetItemOutput{}, nil
 }
@@ -219,14 +571,75 @@ func (s dynamoRestaurantStorerStub) UpdateItem(_ context.Context, _ *dynamodb.Up
 	if s.error != "" {
 		return nil, errors.New(s.error)
 	}
-	return nil, nil
+	if s.conditionFailed {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+	return &dynamodb.UpdateItemOutput{
+		Attributes: map[string]types.AttributeValue{versionAttr: &types.AttributeValueMemberN{Value: "4"}},
+	}, nil
 }
 
-func (s dynamoRestaurantStorerStub) DeleteItem(_ context.Context, _ *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+func (s dynamoRestaurantStorerStub) DeleteItem(_ context.Context, input *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
 	if s.error != "" {
 		return nil, errors.New(s.error)
 	}
-	return nil, nil
+	if s.conditionFailed {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+	item, err := restaurantItemOutput(input.Key[key].(*types.AttributeValueMemberS).Value)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.DeleteItemOutput{Attributes: item.Item}, nil
+}
+
+func (s dynamoRestaurantStorerStub) Scan(_ context.Context, _ *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	if s.error != "" {
+		return nil, errors.New(s.error)
+	}
+	return &dynamodb.ScanOutput{}, nil
+}
+
+// queryTenant returns the restaurants of the stub as the page of the TenantIndex of tenant1.
+func (s dynamoRestaurantStorerStub) queryTenant() (*dynamodb.QueryOutput, error) {
+	output := &dynamodb.QueryOutput{}
+	for _, restaurant := range s.restaurants {
+		av, err := attributevalue.MarshalMap(restaurantItem{RestaurantId: *restaurant.Id, RestaurantTenant: tenantPartition("tenant1"), Restaurant: restaurant})
+		if err != nil {
+			return nil, err
+		}
+		output.Items = append(output.Items, av)
+	}
+	if s.restaurantId != "" {
+		output.LastEvaluatedKey = map[string]types.AttributeValue{
+			key:             &types.AttributeValueMemberS{Value: s.restaurantId},
+			tenantIndexAttr: &types.AttributeValueMemberS{Value: tenantPartition("tenant1")},
+		}
+	}
+	return output, nil
+}
+
+func (s dynamoRestaurantStorerStub) TransactWriteItems(_ context.Context, input *dynamodb.TransactWriteItemsInput, _ ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	if s.inputs != nil {
+		s.inputs.transact = input
+	}
+	if s.error != "" {
+		return nil, errors.New(s.error)
+	}
+	if s.conditionFailed {
+		return nil, &types.TransactionCanceledException{
+			Message:             aws.String("Transaction cancelled"),
+			CancellationReasons: []types.CancellationReason{{Code: aws.String("ConditionalCheckFailed")}, {Code: aws.String("None")}},
+		}
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func (s dynamoRestaurantStorerStub) BatchWriteItem(_ context.Context, _ *dynamodb.BatchWriteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	if s.error != "" {
+		return nil, errors.New(s.error)
+	}
+	return &dynamodb.BatchWriteItemOutput{}, nil
 }
 
 func restaurantItemOutput(restaurantId string) (*dynamodb.GetItemOutput, error) {
@@ -237,6 +650,7 @@ func restaurantItemOutput(restaurantId string) (*dynamodb.GetItemOutput, error)
 		RestaurantId: restaurantId,
 		Restaurant:   restaurant,
 		Updated:      12345,
+		Version:      3,
 	}
 
 	av, err := attributevalue.MarshalMap(restaurantItem)
@@ -0,0 +1,552 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// fakeDynamoClient is an in-memory table that interprets the subset of the
+// expression language produced by the expression builder for this package.
+type fakeDynamoClient struct {
+	mu    sync.Mutex
+	items map[string]map[string]types.AttributeValue
+}
+
+func newFakeDynamoClient() *fakeDynamoClient {
+	return &fakeDynamoClient{items: map[string]map[string]types.AttributeValue{}}
+}
+
+func (c *fakeDynamoClient) PutItem(_ context.Context, params *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := itemKey(params.Item)
+	ok, err := evalCondition(params.ConditionExpression, c.items[id], params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+	}
+
+	c.items[id] = copyItem(params.Item)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (c *fakeDynamoClient) GetItem(_ context.Context, params *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return &dynamodb.GetItemOutput{Item: copyItem(c.items[itemKey(params.Key)])}, nil
+}
+
+func (c *fakeDynamoClient) UpdateItem(_ context.Context, params *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := itemKey(params.Key)
+	existing := c.items[id]
+	ok, err := evalCondition(params.ConditionExpression, existing, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+	}
+
+	item := copyItem(existing)
+	if item == nil {
+		item = copyItem(params.Key)
+	}
+	if err = applyUpdate(aws.ToString(params.UpdateExpression), item, params.ExpressionAttributeNames, params.ExpressionAttributeValues); err != nil {
+		return nil, err
+	}
+	c.items[id] = item
+
+	// All the new attributes are returned, which includes the updated ones
+	return &dynamodb.UpdateItemOutput{Attributes: copyItem(item)}, nil
+}
+
+func (c *fakeDynamoClient) DeleteItem(_ context.Context, params *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := itemKey(params.Key)
+	existing := c.items[id]
+	ok, err := evalCondition(params.ConditionExpression, existing, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+	}
+
+	delete(c.items, id)
+	output := &dynamodb.DeleteItemOutput{}
+	if params.ReturnValues == types.ReturnValueAllOld {
+		output.Attributes = existing
+	}
+	return output, nil
+}
+
+func (c *fakeDynamoClient) Scan(_ context.Context, params *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ids := make([]string, 0, len(c.items))
+	for id := range c.items {
+		if params.ExclusiveStartKey == nil || id > itemKey(params.ExclusiveStartKey) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	// Like DynamoDB, the limit is the number of items evaluated before the filter
+	output := &dynamodb.ScanOutput{Items: []map[string]types.AttributeValue{}}
+	evaluated := 0
+	for _, id := range ids {
+		if params.Limit != nil && evaluated == int(*params.Limit) {
+			break
+		}
+		evaluated++
+		item := c.items[id]
+		// The key is returned whenever the limit is reached
+		output.LastEvaluatedKey = map[string]types.AttributeValue{key: item[key], sortKey: item[sortKey]}
+
+		ok, err := evalCondition(params.FilterExpression, item, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			output.Items = append(output.Items, copyItem(item))
+		}
+	}
+	if params.Limit == nil || evaluated < int(*params.Limit) {
+		output.LastEvaluatedKey = nil
+	}
+	return output, nil
+}
+
+func (c *fakeDynamoClient) Query(_ context.Context, params *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// The items of the table are sorted by the sort key, the ones of an index by its sort key
+	sortAttr, hashAttr := sortKey, ""
+	switch index := aws.ToString(params.IndexName); index {
+	case "":
+	case tenantIndex:
+		sortAttr, hashAttr = key, tenantIndexAttr
+	case geohashIndex:
+		sortAttr = geohashAttr
+	case newestReviewIndex:
+		sortAttr = newestReviewAttr
+	case highestReviewIndex:
+		sortAttr = highestReviewAttr
+	case outboxIndex:
+		sortAttr, hashAttr = outboxNextAttr, outboxStatusAttr
+	case deliveryIndex:
+		sortAttr = deliveryNextAttr
+	default:
+		return nil, fmt.Errorf("fake client: unknown index %q", index)
+	}
+	forward := params.ScanIndexForward == nil || *params.ScanIndexForward
+
+	var items []map[string]types.AttributeValue
+	for _, item := range c.items {
+		// Items without the index keys are not in the index
+		if item[sortAttr] == nil || (hashAttr != "" && item[hashAttr] == nil) || (sortAttr == geohashAttr && item[geohashPrefixAttr] == nil) || (sortAttr == outboxNextAttr && item[outboxStatusAttr] == nil) ||
+			(sortAttr == deliveryNextAttr && item[deliveryStatusAttr] == nil) {
+			continue
+		}
+		if start := params.ExclusiveStartKey; start != nil {
+			if after := str(item[sortAttr]) > str(start[sortAttr]); after != forward || str(item[sortAttr]) == str(start[sortAttr]) {
+				continue
+			}
+		}
+		ok, err := evalCondition(params.KeyConditionExpression, item, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			items = append(items, copyItem(item))
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return (str(items[i][sortAttr]) < str(items[j][sortAttr])) == forward
+	})
+
+	output := &dynamodb.QueryOutput{Items: items}
+	if params.Limit != nil && len(items) > int(*params.Limit) {
+		output.Items = items[:*params.Limit]
+		last := output.Items[len(output.Items)-1]
+		output.LastEvaluatedKey = map[string]types.AttributeValue{key: last[key], sortKey: last[sortKey], sortAttr: last[sortAttr]}
+		if hashAttr != "" {
+			output.LastEvaluatedKey[hashAttr] = last[hashAttr]
+		}
+	}
+	output.Count = int32(len(output.Items))
+	return output, nil
+}
+
+// TransactWriteItems applies all the writes when all their conditions hold. Otherwise it
+// cancels the transaction with a reason for each write, like DynamoDB.
+func (c *fakeDynamoClient) TransactWriteItems(_ context.Context, params *dynamodb.TransactWriteItemsInput, _ ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	reasons := make([]types.CancellationReason, len(params.TransactItems))
+	canceled := false
+	for i, w := range params.TransactItems {
+		var id string
+		var cond *string
+		var names map[string]string
+		var values map[string]types.AttributeValue
+		switch {
+		case w.Put != nil:
+			id, cond, names, values = itemKey(w.Put.Item), w.Put.ConditionExpression, w.Put.ExpressionAttributeNames, w.Put.ExpressionAttributeValues
+		case w.Update != nil:
+			id, cond, names, values = itemKey(w.Update.Key), w.Update.ConditionExpression, w.Update.ExpressionAttributeNames, w.Update.ExpressionAttributeValues
+		case w.Delete != nil:
+			id, cond, names, values = itemKey(w.Delete.Key), w.Delete.ConditionExpression, w.Delete.ExpressionAttributeNames, w.Delete.ExpressionAttributeValues
+		default:
+			return nil, fmt.Errorf("fake client: unsupported transaction write %d", i)
+		}
+
+		ok, err := evalCondition(cond, c.items[id], names, values)
+		if err != nil {
+			return nil, err
+		}
+		reasons[i].Code = aws.String("None")
+		if !ok {
+			reasons[i].Code = aws.String("ConditionalCheckFailed")
+			canceled = true
+		}
+	}
+	if canceled {
+		return nil, &types.TransactionCanceledException{Message: aws.String("Transaction cancelled"), CancellationReasons: reasons}
+	}
+
+	for _, w := range params.TransactItems {
+		switch {
+		case w.Put != nil:
+			c.items[itemKey(w.Put.Item)] = copyItem(w.Put.Item)
+		case w.Update != nil:
+			id := itemKey(w.Update.Key)
+			item := copyItem(c.items[id])
+			if item == nil {
+				item = copyItem(w.Update.Key)
+			}
+			if err := applyUpdate(aws.ToString(w.Update.UpdateExpression), item, w.Update.ExpressionAttributeNames, w.Update.ExpressionAttributeValues); err != nil {
+				return nil, err
+			}
+			c.items[id] = item
+		case w.Delete != nil:
+			delete(c.items, itemKey(w.Delete.Key))
+		}
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+// BatchWriteItem applies the puts and deletes of the requests, which have no conditions.
+func (c *fakeDynamoClient) BatchWriteItem(_ context.Context, params *dynamodb.BatchWriteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, requests := range params.RequestItems {
+		if len(requests) > 25 {
+			return nil, fmt.Errorf("fake client: %d requests in a batch, more than 25", len(requests))
+		}
+		for _, r := range requests {
+			switch {
+			case r.PutRequest != nil:
+				c.items[itemKey(r.PutRequest.Item)] = copyItem(r.PutRequest.Item)
+			case r.DeleteRequest != nil:
+				delete(c.items, itemKey(r.DeleteRequest.Key))
+			default:
+				return nil, fmt.Errorf("fake client: unsupported batch write")
+			}
+		}
+	}
+	return &dynamodb.BatchWriteItemOutput{UnprocessedItems: map[string][]types.WriteRequest{}}, nil
+}
+
+// itemKey returns the primary key of the item, the partition key then the sort key.
+func itemKey(item map[string]types.AttributeValue) string {
+	return str(item[key]) + "|" + str(item[sortKey])
+}
+
+func str(av types.AttributeValue) string {
+	if s, ok := av.(*types.AttributeValueMemberS); ok {
+		return s.Value
+	}
+	return ""
+}
+
+func copyItem(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	if item == nil {
+		return nil
+	}
+	c := make(map[string]types.AttributeValue, len(item))
+	for k, v := range item {
+		c[k] = copyValue(v)
+	}
+	return c
+}
+
+func copyValue(av types.AttributeValue) types.AttributeValue {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberM:
+		return &types.AttributeValueMemberM{Value: copyItem(v.Value)}
+	case *types.AttributeValueMemberL:
+		l := make([]types.AttributeValue, len(v.Value))
+		for i := range v.Value {
+			l[i] = copyValue(v.Value[i])
+		}
+		return &types.AttributeValueMemberL{Value: l}
+	default:
+		return av
+	}
+}
+
+// applyUpdate applies SET, ADD and REMOVE clauses. Each clause starts on a new line.
+func applyUpdate(update string, item map[string]types.AttributeValue, names map[string]string, values map[string]types.AttributeValue) error {
+	for _, clause := range strings.Split(strings.TrimSpace(update), "\n") {
+		action, actions, _ := strings.Cut(strings.TrimSpace(clause), " ")
+		for _, a := range strings.Split(actions, ", ") {
+			switch action {
+			case "SET":
+				path, value, found := strings.Cut(a, " = ")
+				if !found {
+					return fmt.Errorf("fake client: unsupported SET action %q", a)
+				}
+				if err := setPath(item, resolvePath(path, names), values[strings.TrimSpace(value)]); err != nil {
+					return err
+				}
+			case "ADD":
+				path, value, _ := strings.Cut(a, " ")
+				p := resolvePath(path, names)
+				n, err := number(getPath(item, p))
+				if err != nil {
+					return err
+				}
+				inc, err := number(values[value])
+				if err != nil {
+					return err
+				}
+				if err = setPath(item, p, &types.AttributeValueMemberN{Value: strconv.FormatInt(n+inc, 10)}); err != nil {
+					return err
+				}
+			case "REMOVE":
+				p := resolvePath(a, names)
+				if parent, ok := getPath(item, p[:len(p)-1]).(*types.AttributeValueMemberM); ok {
+					delete(parent.Value, p[len(p)-1])
+				} else if len(p) == 1 {
+					delete(item, p[0])
+				}
+			default:
+				return fmt.Errorf("fake client: unsupported update action %q", action)
+			}
+		}
+	}
+	return nil
+}
+
+func number(av types.AttributeValue) (int64, error) {
+	if av == nil {
+		return 0, nil
+	}
+	n, ok := av.(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, fmt.Errorf("fake client: %T is not a number", av)
+	}
+	return strconv.ParseInt(n.Value, 10, 64)
+}
+
+func resolvePath(path string, names map[string]string) []string {
+	parts := strings.Split(strings.TrimSpace(path), ".")
+	for i, p := range parts {
+		if name, ok := names[p]; ok {
+			parts[i] = name
+		}
+	}
+	return parts
+}
+
+func getPath(item map[string]types.AttributeValue, path []string) types.AttributeValue {
+	var av types.AttributeValue = &types.AttributeValueMemberM{Value: item}
+	for _, p := range path {
+		m, ok := av.(*types.AttributeValueMemberM)
+		if !ok {
+			return nil
+		}
+		av = m.Value[p]
+	}
+	return av
+}
+
+func setPath(item map[string]types.AttributeValue, path []string, value types.AttributeValue) error {
+	parent, ok := getPath(item, path[:len(path)-1]).(*types.AttributeValueMemberM)
+	if !ok {
+		return fmt.Errorf("fake client: the document path %q is invalid for update", strings.Join(path, "."))
+	}
+	parent.Value[path[len(path)-1]] = copyValue(value)
+	return nil
+}
+
+// evalCondition evaluates a condition or key condition expression. A nil expression is always true.
+func evalCondition(cond *string, item map[string]types.AttributeValue, names map[string]string, values map[string]types.AttributeValue) (bool, error) {
+	if cond == nil {
+		return true, nil
+	}
+
+	p := &conditionParser{
+		tokens: tokenize(*cond),
+		item:   item,
+		names:  names,
+		values: values,
+	}
+	ok, err := p.or()
+	if err == nil && p.pos != len(p.tokens) {
+		err = fmt.Errorf("fake client: unexpected %q in condition %q", p.tokens[p.pos], *cond)
+	}
+	return ok, err
+}
+
+func tokenize(s string) []string {
+	var tokens []string
+	for _, field := range strings.Fields(s) {
+		for field != "" {
+			i := strings.IndexAny(field, "(),")
+			switch {
+			case i < 0:
+				tokens = append(tokens, field)
+				field = ""
+			case i == 0:
+				tokens = append(tokens, field[:1])
+				field = field[1:]
+			default:
+				tokens = append(tokens, field[:i])
+				field = field[i:]
+			}
+		}
+	}
+	return tokens
+}
+
+type conditionParser struct {
+	tokens []string
+	pos    int
+	item   map[string]types.AttributeValue
+	names  map[string]string
+	values map[string]types.AttributeValue
+}
+
+func (p *conditionParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *conditionParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *conditionParser) expect(t string) error {
+	if got := p.next(); got != t {
+		return fmt.Errorf("fake client: expected %q in condition, got %q", t, got)
+	}
+	return nil
+}
+
+func (p *conditionParser) or() (bool, error) {
+	result, err := p.and()
+	for err == nil && p.peek() == "OR" {
+		p.next()
+		var r bool
+		r, err = p.and()
+		result = result || r
+	}
+	return result, err
+}
+
+func (p *conditionParser) and() (bool, error) {
+	result, err := p.unary()
+	for err == nil && p.peek() == "AND" {
+		p.next()
+		var r bool
+		r, err = p.unary()
+		result = result && r
+	}
+	return result, err
+}
+
+func (p *conditionParser) unary() (bool, error) {
+	switch t := p.next(); t {
+	case "NOT":
+		r, err := p.unary()
+		return !r, err
+	case "(":
+		r, err := p.or()
+		if err != nil {
+			return false, err
+		}
+		return r, p.expect(")")
+	case "attribute_exists", "attribute_not_exists":
+		if err := p.expect("("); err != nil {
+			return false, err
+		}
+		exists := p.operand(p.next()) != nil
+		return exists == (t == "attribute_exists"), p.expect(")")
+	case "begins_with":
+		if err := p.expect("("); err != nil {
+			return false, err
+		}
+		s := str(p.operand(p.next()))
+		if err := p.expect(","); err != nil {
+			return false, err
+		}
+		prefix := str(p.operand(p.next()))
+		return strings.HasPrefix(s, prefix), p.expect(")")
+	default:
+		left := p.operand(t)
+		op := p.next()
+		right := p.operand(p.next())
+		switch op {
+		case "=":
+			return left != nil && reflect.DeepEqual(left, right), nil
+		case "<>":
+			return !reflect.DeepEqual(left, right), nil
+		case "<":
+			if _, ok := left.(*types.AttributeValueMemberN); ok {
+				l, err := number(left)
+				if err != nil {
+					return false, err
+				}
+				r, err := number(right)
+				return l < r, err
+			}
+			return left != nil && str(left) < str(right), nil
+		}
+		return false, fmt.Errorf("fake client: unsupported comparison %q", op)
+	}
+}
+
+// operand returns the value of an expression attribute value or of an attribute of the item.
+func (p *conditionParser) operand(t string) types.AttributeValue {
+	if strings.HasPrefix(t, ":") {
+		return p.values[t]
+	}
+	return getPath(p.item, resolvePath(t, p.names))
+}
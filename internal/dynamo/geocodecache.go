@@ -0,0 +1,98 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/lfroomin/restaurant-serverless/internal/geocode"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"log"
+	"time"
+)
+
+const geocodeCacheKey = "AddressKey"
+
+type dynamoGeocodeCacher interface {
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+}
+
+// GeocodeCache is a geocode.Cache stored in a DynamoDB table. Entries expire
+// after TTL; the table's time to live attribute is ExpiresAt.
+type GeocodeCache struct {
+	Client dynamoGeocodeCacher
+	Table  string
+	TTL    time.Duration
+}
+
+type geocodeCacheItem struct {
+	AddressKey   string
+	Location     model.Location
+	TimezoneName string
+	ExpiresAt    int64
+}
+
+func NewGeocodeCache(cfg aws.Config, table string, ttl time.Duration) GeocodeCache {
+	return GeocodeCache{
+		Client: dynamodb.NewFromConfig(cfg),
+		Table:  table,
+		TTL:    ttl,
+	}
+}
+
+func (gc GeocodeCache) Get(key string) (geocode.CacheEntry, bool, error) {
+	input := dynamodb.GetItemInput{
+		Key: map[string]types.AttributeValue{
+			geocodeCacheKey: &types.AttributeValueMemberS{Value: key},
+		},
+		TableName: aws.String(gc.Table),
+	}
+
+	data, err := gc.Client.GetItem(context.Background(), &input)
+	if err != nil {
+		return geocode.CacheEntry{}, false, fmt.Errorf("error getting geocode %q in dynamo: %w", key, err)
+	}
+	if data == nil || data.Item == nil {
+		return geocode.CacheEntry{}, false, nil
+	}
+
+	item := geocodeCacheItem{}
+	if err = attributevalue.UnmarshalMap(data.Item, &item); err != nil {
+		return geocode.CacheEntry{}, false, fmt.Errorf("error unmarshalling value: %w", err)
+	}
+
+	// DynamoDB deletes expired items some time after they expire
+	if item.ExpiresAt <= time.Now().Unix() {
+		return geocode.CacheEntry{}, false, nil
+	}
+
+	item.Location.BackfillCoordinates()
+	return geocode.CacheEntry{Location: item.Location, TimezoneName: item.TimezoneName}, true, nil
+}
+
+func (gc GeocodeCache) Put(key string, entry geocode.CacheEntry) error {
+	log.Printf("GeocodeCache.Put key: %s\n", key)
+
+	av, err := attributevalue.MarshalMap(geocodeCacheItem{
+		AddressKey:   key,
+		Location:     entry.Location,
+		TimezoneName: entry.TimezoneName,
+		ExpiresAt:    time.Now().Add(gc.TTL).Unix(),
+	})
+	if err != nil {
+		return fmt.Errorf("error marshalling value: %w", err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		Item:      av,
+		TableName: aws.String(gc.Table),
+	}
+
+	if _, err = gc.Client.PutItem(context.Background(), input); err != nil {
+		return fmt.Errorf("error saving geocode %q in dynamo: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/lfroomin/restaurant-serverless/internal/geocode"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_GeocodeCache(t *testing.T) {
+	t.Parallel()
+	entry := geocode.CacheEntry{Location: model.NewLocation(47.6062, -122.3321), TimezoneName: "America/Los_Angeles"}
+
+	testCases := []struct {
+		name   string
+		ttl    time.Duration
+		found  bool
+		error  string
+		errMsg string
+	}{
+		{
+			name:  "happy path",
+			ttl:   time.Hour,
+			found: true,
+		},
+		{
+			name: "expired",
+			ttl:  -time.Second,
+		},
+		{
+			name:   "dynamo error",
+			ttl:    time.Hour,
+			error:  "an error occurred",
+			errMsg: `error saving geocode "key" in dynamo: an error occurred`,
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			gc := GeocodeCache{Client: &geocodeCacheStub{items: map[string]map[string]types.AttributeValue{}, error: tc.error}, Table: "cache", TTL: tc.ttl}
+
+			err := gc.Put("key", entry)
+			if tc.errMsg != "" {
+				assert.EqualError(t, err, tc.errMsg)
+				return
+			}
+			require.NoError(t, err)
+
+			got, found, err := gc.Get("key")
+			require.NoError(t, err)
+			assert.Equal(t, tc.found, found)
+			if tc.found {
+				assert.Equal(t, entry, got)
+			}
+
+			_, found, err = gc.Get("other key")
+			require.NoError(t, err)
+			assert.False(t, found)
+		})
+	}
+}
+
+type geocodeCacheStub struct {
+	items map[string]map[string]types.AttributeValue
+	error string
+}
+
+func (s *geocodeCacheStub) PutItem(_ context.Context, input *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	if s.error != "" {
+		return nil, errors.New(s.error)
+	}
+	var item geocodeCacheItem
+	if err := attributevalue.UnmarshalMap(input.Item, &item); err != nil {
+		return nil, err
+	}
+	s.items[item.AddressKey] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (s *geocodeCacheStub) GetItem(_ context.Context, input *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	if s.error != "" {
+		return nil, errors.New(s.error)
+	}
+	k := input.Key[geocodeCacheKey].(*types.AttributeValueMemberS).Value
+	return &dynamodb.GetItemOutput{Item: s.items[k]}, nil
+}
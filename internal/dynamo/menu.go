@@ -0,0 +1,169 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"log"
+	"time"
+)
+
+// menuItem is a menu stored in the partition of its restaurant, with the sort key MENU#<menuId>.
+type menuItem struct {
+	RestaurantId string
+	SK           string
+	Menu         model.Menu
+	Updated      int64
+}
+
+func (rs RestaurantStorage) SaveMenu(tenantId, restaurantId string, menu model.Menu) error {
+	log.Printf("RestaurantStorage.SaveMenu tenantId: %s  restaurantId: %s  menuId: %s\n", tenantId, restaurantId, *menu.Id)
+
+	err := rs.putMenu(partitionKey(tenantId, restaurantId), menu, expression.AttributeNotExists(expression.Name(key)))
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			err = storage.ErrConflict
+		}
+		return fmt.Errorf("error saving menu %q of restaurant %q in dynamo: %w", *menu.Id, restaurantId, err)
+	}
+	return nil
+}
+
+func (rs RestaurantStorage) GetMenu(tenantId, restaurantId, menuId string) (model.Menu, bool, error) {
+	log.Printf("RestaurantStorage.GetMenu tenantId: %s  restaurantId: %s  menuId: %s\n", tenantId, restaurantId, menuId)
+
+	input := dynamodb.GetItemInput{
+		Key:       primaryKey(partitionKey(tenantId, restaurantId), menuSortKeyPrefix+menuId),
+		TableName: aws.String(rs.Table),
+	}
+
+	data, err := rs.Client.GetItem(context.Background(), &input)
+	if err != nil {
+		return model.Menu{}, false, fmt.Errorf("error getting menu %q of restaurant %q in dynamo: %w", menuId, restaurantId, err)
+	}
+	if data.Item == nil {
+		return model.Menu{}, false, nil
+	}
+
+	item := menuItem{}
+	if err = attributevalue.UnmarshalMap(data.Item, &item); err != nil {
+		return model.Menu{}, false, fmt.Errorf("error unmarshalling value: %w", err)
+	}
+	return item.Menu, true, nil
+}
+
+// UpdateMenu replaces the menu, which must exist.
+func (rs RestaurantStorage) UpdateMenu(tenantId, restaurantId string, menu model.Menu) error {
+	log.Printf("RestaurantStorage.UpdateMenu tenantId: %s  restaurantId: %s  menuId: %s\n", tenantId, restaurantId, *menu.Id)
+
+	err := rs.putMenu(partitionKey(tenantId, restaurantId), menu, expression.AttributeExists(expression.Name(key)))
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			err = storage.ErrNotFound
+		}
+		return fmt.Errorf("error updating menu %q of restaurant %q in dynamo: %w", *menu.Id, restaurantId, err)
+	}
+	return nil
+}
+
+// DeleteMenu removes the menu and returns it as it was before the delete.
+func (rs RestaurantStorage) DeleteMenu(tenantId, restaurantId, menuId string) (model.Menu, error) {
+	log.Printf("RestaurantStorage.DeleteMenu tenantId: %s  restaurantId: %s  menuId: %s\n", tenantId, restaurantId, menuId)
+
+	expr, err := expression.NewBuilder().WithCondition(expression.AttributeExists(expression.Name(key))).Build()
+	if err != nil {
+		return model.Menu{}, err
+	}
+
+	input := dynamodb.DeleteItemInput{
+		TableName:                aws.String(rs.Table),
+		Key:                      primaryKey(partitionKey(tenantId, restaurantId), menuSortKeyPrefix+menuId),
+		ConditionExpression:      expr.Condition(),
+		ExpressionAttributeNames: expr.Names(),
+		ReturnValues:             types.ReturnValueAllOld,
+	}
+
+	data, err := rs.Client.DeleteItem(context.Background(), &input)
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			err = storage.ErrNotFound
+		}
+		return model.Menu{}, fmt.Errorf("error deleting menu %q of restaurant %q from dynamo: %w", menuId, restaurantId, err)
+	}
+
+	item := menuItem{}
+	if data != nil {
+		if err = attributevalue.UnmarshalMap(data.Attributes, &item); err != nil {
+			return model.Menu{}, fmt.Errorf("error unmarshalling value: %w", err)
+		}
+	}
+	return item.Menu, nil
+}
+
+// ListMenus returns all the menus of the restaurant, ordered by id. They are
+// read with a query of the restaurant partition, so no table scan is needed.
+func (rs RestaurantStorage) ListMenus(tenantId, restaurantId string) ([]model.Menu, error) {
+	log.Printf("RestaurantStorage.ListMenus tenantId: %s  restaurantId: %s\n", tenantId, restaurantId)
+
+	items, err := rs.queryMenus(partitionKey(tenantId, restaurantId))
+	if err != nil {
+		return nil, fmt.Errorf("error listing the menus of restaurant %q in dynamo: %w", restaurantId, err)
+	}
+
+	menus := make([]model.Menu, 0, len(items))
+	for _, item := range items {
+		menus = append(menus, item.Menu)
+	}
+	return menus, nil
+}
+
+func (rs RestaurantStorage) putMenu(pk string, menu model.Menu, cond expression.ConditionBuilder) error {
+	av, err := attributevalue.MarshalMap(menuItem{
+		RestaurantId: pk,
+		SK:           menuSortKeyPrefix + *menu.Id,
+		Menu:         menu,
+		Updated:      time.Now().UnixMilli(),
+	})
+	if err != nil {
+		return fmt.Errorf("error marshalling value: %w", err)
+	}
+
+	expr, err := expression.NewBuilder().WithCondition(cond).Build()
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		Item:                     av,
+		TableName:                aws.String(rs.Table),
+		ConditionExpression:      expr.Condition(),
+		ExpressionAttributeNames: expr.Names(),
+	}
+
+	_, err = rs.Client.PutItem(context.Background(), input)
+	return err
+}
+
+// queryMenus returns the menu items of the restaurant partition.
+func (rs RestaurantStorage) queryMenus(pk string) ([]menuItem, error) {
+	data, err := rs.queryPartition(pk, menuSortKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []menuItem
+	if err = attributevalue.UnmarshalListOfMaps(data, &items); err != nil {
+		return nil, fmt.Errorf("error unmarshalling value: %w", err)
+	}
+	return items, nil
+}
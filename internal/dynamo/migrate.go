@@ -0,0 +1,126 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/lfroomin/restaurant-serverless/internal/tenant"
+	"log"
+	"strings"
+)
+
+// Migrate copies the items of the table of from, written by an older version of the service,
+// to the table of rs in the current format. A DynamoDB table cannot get a sort key, so the
+// restaurants stored before it was added must be copied to a new table. The items already in
+// rs are kept, so an interrupted migration can be run again. The items stored before the
+// tenants were added are copied to the default tenant. It returns the number of items
+// copied and skipped.
+func (rs RestaurantStorage) Migrate(from RestaurantStorage) (int, int, error) {
+	log.Printf("RestaurantStorage.Migrate from: %s  to: %s\n", from.Table, rs.Table)
+
+	expr, err := expression.NewBuilder().WithCondition(expression.AttributeNotExists(expression.Name(key))).Build()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	copied, skipped := 0, 0
+	input := dynamodb.ScanInput{TableName: aws.String(from.Table)}
+	for {
+		data, err := from.Client.Scan(context.Background(), &input)
+		if err != nil {
+			return copied, skipped, fmt.Errorf("error scanning %q in dynamo: %w", from.Table, err)
+		}
+
+		for _, item := range data.Items {
+			put := dynamodb.PutItemInput{
+				Item:                     migrateItem(item),
+				TableName:                aws.String(rs.Table),
+				ConditionExpression:      expr.Condition(),
+				ExpressionAttributeNames: expr.Names(),
+			}
+			if _, err = rs.Client.PutItem(context.Background(), &put); err != nil {
+				var ccf *types.ConditionalCheckFailedException
+				if errors.As(err, &ccf) {
+					skipped++
+					continue
+				}
+				return copied, skipped, fmt.Errorf("error copying item to %q in dynamo: %w", rs.Table, err)
+			}
+			copied++
+		}
+
+		if len(data.LastEvaluatedKey) == 0 {
+			return copied, skipped, nil
+		}
+		input.ExclusiveStartKey = data.LastEvaluatedKey
+	}
+}
+
+// migrateItem returns the item in the current format. The restaurants stored before the sort
+// key was added get the sort key of a restaurant, and the items stored before the tenants were
+// added are moved to the partitions of the default tenant. The restaurants are put in the
+// TenantIndex of their tenant, which lists them.
+func migrateItem(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	migrated := make(map[string]types.AttributeValue, len(item)+1)
+	for name, value := range item {
+		migrated[name] = value
+	}
+
+	if _, ok := migrated[sortKey]; !ok {
+		migrated[sortKey] = &types.AttributeValueMemberS{Value: restaurantSortKey}
+	}
+
+	pk, sk := attributeString(migrated[key]), attributeString(migrated[sortKey])
+	// The undelivered events are moved to their shard of the OutboxIndex
+	if attributeString(migrated[outboxStatusAttr]) == outboxPending {
+		migrated[outboxStatusAttr] = &types.AttributeValueMemberS{Value: outboxShard(strings.TrimPrefix(sk, outboxSortKeyPrefix))}
+	}
+	if strings.HasPrefix(pk, tenantKeyPrefix) || tenantless(pk) {
+		// The restaurants of a tenant stored before the TenantIndex are added to it
+		if tenantId := attributeString(migrated[tenantAttr]); sk == restaurantSortKey && tenantId != "" {
+			migrated[tenantIndexAttr] = &types.AttributeValueMemberS{Value: tenantPartition(tenantId)}
+		}
+		return migrated
+	}
+
+	// The restaurants, the menus, the reviews, the outbox, the webhooks and their deliveries
+	migrated[key] = &types.AttributeValueMemberS{Value: partitionKey(tenant.Default, pk)}
+	if sk == restaurantSortKey || strings.HasPrefix(sk, webhookKeyPrefix) {
+		migrated[tenantAttr] = &types.AttributeValueMemberS{Value: tenant.Default}
+	}
+	if sk == restaurantSortKey {
+		migrated[tenantIndexAttr] = &types.AttributeValueMemberS{Value: tenantPartition(tenant.Default)}
+	}
+	if hash := attributeString(migrated[geohashAttr]); len(hash) >= geohashPrefixPrecision {
+		migrated[geohashPrefixAttr] = &types.AttributeValueMemberS{Value: geohashPartition(tenant.Default, hash)}
+	}
+	// The events of the outbox and the deliveries name their tenant
+	for _, name := range []string{"Event", "Delivery"} {
+		if m, ok := migrated[name].(*types.AttributeValueMemberM); ok {
+			nested := make(map[string]types.AttributeValue, len(m.Value)+1)
+			for n, v := range m.Value {
+				nested[n] = v
+			}
+			nested[tenantAttr] = &types.AttributeValueMemberS{Value: tenant.Default}
+			migrated[name] = &types.AttributeValueMemberM{Value: nested}
+		}
+	}
+	return migrated
+}
+
+// tenantless reports whether the partition is one of the API keys or the rate limits, which
+// are not in the partitions of a tenant.
+func tenantless(pk string) bool {
+	return pk == apiKeysPartition || strings.HasPrefix(pk, apiKeyKeyPrefix) || strings.HasPrefix(pk, bucketKeyPrefix)
+}
+
+func attributeString(av types.AttributeValue) string {
+	if s, ok := av.(*types.AttributeValueMemberS); ok {
+		return s.Value
+	}
+	return ""
+}
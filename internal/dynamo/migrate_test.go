@@ -0,0 +1,126 @@
+package dynamo
+
+import (
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_Migrate(t *testing.T) {
+	t.Parallel()
+
+	// A restaurant stored before the sort key and the tenants were added
+	restId, name := "restId", "Restaurant 1"
+	legacy, err := attributevalue.MarshalMap(struct {
+		RestaurantId string
+		Restaurant   model.Restaurant
+		Version      int64
+	}{RestaurantId: restId, Restaurant: model.Restaurant{Id: &restId, Name: name}, Version: 3})
+	require.NoError(t, err)
+
+	from := RestaurantStorage{Client: newFakeDynamoClient(), Table: "old"}
+	from.Client.(*fakeDynamoClient).items[itemKey(legacy)] = legacy
+	client := newFakeDynamoClient()
+	rs := RestaurantStorage{Client: client, Table: "restaurants"}
+
+	copied, skipped, err := rs.Migrate(from)
+	require.NoError(t, err)
+	assert.Equal(t, 1, copied)
+	assert.Equal(t, 0, skipped)
+
+	// The restaurant is in the default tenant
+	got, version, found, err := rs.Get(tenant.Default, restId)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, name, got.Name)
+	assert.Equal(t, int64(3), version)
+
+	// And it is listed with the restaurants of the default tenant only
+	list, _, err := rs.List(tenant.Default, 10, "")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, restId, *list[0].Id)
+	list, _, err = rs.List("tenant1", 10, "")
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	// Running the migration again does not overwrite the copied items
+	copied, skipped, err = rs.Migrate(from)
+	require.NoError(t, err)
+	assert.Equal(t, 0, copied)
+	assert.Equal(t, 1, skipped)
+}
+
+func Test_MigrateItem(t *testing.T) {
+	t.Parallel()
+
+	s := func(v string) types.AttributeValue { return &types.AttributeValueMemberS{Value: v} }
+	m := func(attrs map[string]types.AttributeValue) types.AttributeValue {
+		return &types.AttributeValueMemberM{Value: attrs}
+	}
+
+	testCases := []struct {
+		name     string
+		item     map[string]types.AttributeValue
+		expected map[string]types.AttributeValue
+	}{
+		{
+			name:     "restaurant",
+			item:     map[string]types.AttributeValue{key: s("restId"), "Geohash": s("c23nb62w2"), "GeohashPrefix": s("c23")},
+			expected: map[string]types.AttributeValue{key: s("TENANT#default#restId"), sortKey: s("RESTAURANT"), tenantAttr: s("default"), tenantIndexAttr: s("TENANT#default"), "Geohash": s("c23nb62w2"), "GeohashPrefix": s("TENANT#default#c23")},
+		},
+		{
+			name:     "menu",
+			item:     map[string]types.AttributeValue{key: s("restId"), sortKey: s("MENU#menuId")},
+			expected: map[string]types.AttributeValue{key: s("TENANT#default#restId"), sortKey: s("MENU#menuId")},
+		},
+		{
+			name:     "event in the outbox",
+			item:     map[string]types.AttributeValue{key: s("restId"), sortKey: s("OUTBOX#eventId"), "Event": m(map[string]types.AttributeValue{"Id": s("eventId")})},
+			expected: map[string]types.AttributeValue{key: s("TENANT#default#restId"), sortKey: s("OUTBOX#eventId"), "Event": m(map[string]types.AttributeValue{"Id": s("eventId"), tenantAttr: s("default")})},
+		},
+		{
+			name:     "undelivered event in the outbox",
+			item:     map[string]types.AttributeValue{key: s("TENANT#tenant1#restId"), sortKey: s("OUTBOX#eventId"), outboxStatusAttr: s("PENDING")},
+			expected: map[string]types.AttributeValue{key: s("TENANT#tenant1#restId"), sortKey: s("OUTBOX#eventId"), outboxStatusAttr: s(outboxShard("eventId"))},
+		},
+		{
+			name:     "webhook",
+			item:     map[string]types.AttributeValue{key: s("WEBHOOKS"), sortKey: s("WEBHOOK#webhookId")},
+			expected: map[string]types.AttributeValue{key: s("TENANT#default#WEBHOOKS"), sortKey: s("WEBHOOK#webhookId"), tenantAttr: s("default")},
+		},
+		{
+			name:     "delivery",
+			item:     map[string]types.AttributeValue{key: s("WEBHOOK#webhookId"), sortKey: s("DELIVERY#1#eventId"), "Delivery": m(map[string]types.AttributeValue{"Id": s("eventId")})},
+			expected: map[string]types.AttributeValue{key: s("TENANT#default#WEBHOOK#webhookId"), sortKey: s("DELIVERY#1#eventId"), "Delivery": m(map[string]types.AttributeValue{"Id": s("eventId"), tenantAttr: s("default")})},
+		},
+		{
+			name:     "item of a tenant",
+			item:     map[string]types.AttributeValue{key: s("TENANT#tenant1#restId"), sortKey: s("RESTAURANT"), tenantAttr: s("tenant1")},
+			expected: map[string]types.AttributeValue{key: s("TENANT#tenant1#restId"), sortKey: s("RESTAURANT"), tenantAttr: s("tenant1"), tenantIndexAttr: s("TENANT#tenant1")},
+		},
+		{
+			name:     "API key",
+			item:     map[string]types.AttributeValue{key: s("APIKEYS"), sortKey: s("APIKEY#keyId")},
+			expected: map[string]types.AttributeValue{key: s("APIKEYS"), sortKey: s("APIKEY#keyId")},
+		},
+		{
+			name:     "rate limit bucket",
+			item:     map[string]types.AttributeValue{key: s("RATELIMIT#ip:192.0.2.1#GET /"), sortKey: s("BUCKET")},
+			expected: map[string]types.AttributeValue{key: s("RATELIMIT#ip:192.0.2.1#GET /"), sortKey: s("BUCKET")},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, migrateItem(tc.item))
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/lfroomin/restaurant-serverless/internal/geohash"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"log"
+	"sort"
+	"time"
+)
+
+const (
+	geohashIndex      = "GeohashIndex"
+	geohashAttr       = "Geohash"
+	geohashPrefixAttr = "GeohashPrefix"
+
+	// geohashPrecision is the length of the geohash stored for each restaurant (cells of about 5m x 5m).
+	geohashPrecision = 9
+	// geohashPrefixPrecision is the length of the geohash used, prefixed with the tenant, as
+	// the partition key of the geohash index (cells of about 156km x 156km).
+	geohashPrefixPrecision = 3
+)
+
+func newRestaurantItem(tenantId string, restaurant model.Restaurant) restaurantItem {
+	item := restaurantItem{
+		RestaurantId:     partitionKey(tenantId, *restaurant.Id),
+		SK:               restaurantSortKey,
+		TenantId:         tenantId,
+		RestaurantTenant: tenantPartition(tenantId),
+		Restaurant:       restaurant,
+		Updated:          time.Now().UnixMilli(),
+		Version:          1,
+	}
+	// The rating summary is derived from the rating attributes of the item
+	item.Restaurant.Rating = nil
+
+	if lat, lon, ok := restaurant.Coordinates(); ok {
+		item.Geohash = geohash.Encode(lat, lon, geohashPrecision)
+		item.GeohashPrefix = geohashPartition(tenantId, item.Geohash)
+	}
+
+	return item
+}
+
+// geohashPartition returns the partition of the geohash index of the restaurants of the
+// tenant in the cell of the geohash, TENANT#<tenantId>#<geohash prefix>.
+func geohashPartition(tenantId, hash string) string {
+	return tenantKeyPrefix + tenantId + "#" + hash[:geohashPrefixPrecision]
+}
+
+// Nearby returns the restaurants of the tenant within radiusKm of the coordinates, nearest
+// first. The geohash cell containing the coordinates and its neighbors are queried
+// through the geohash index, so no table scan is needed.
+func (rs RestaurantStorage) Nearby(tenantId string, lat, lon, radiusKm float64) ([]model.NearbyRestaurant, error) {
+	log.Printf("RestaurantStorage.Nearby tenantId: %s  lat: %f  lon: %f  radiusKm: %f\n", tenantId, lat, lon, radiusKm)
+
+	precision := geohash.PrecisionForRadius(lat, radiusKm)
+	if precision < geohashPrefixPrecision {
+		return nil, storage.ErrRadiusTooLarge
+	}
+	if precision > geohashPrecision {
+		precision = geohashPrecision
+	}
+
+	nearby := []model.NearbyRestaurant{}
+	for _, cell := range geohash.Neighbors(geohash.Encode(lat, lon, precision)) {
+		items, err := rs.queryGeohashCell(tenantId, cell)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range items {
+			restaurant := item.restaurant()
+			rLat, rLon, ok := restaurant.Coordinates()
+			if !ok {
+				continue
+			}
+			if d := geohash.DistanceKm(lat, lon, rLat, rLon); d <= radiusKm {
+				nearby = append(nearby, model.NearbyRestaurant{DistanceKm: d, Restaurant: restaurant})
+			}
+		}
+	}
+
+	sort.SliceStable(nearby, func(i, j int) bool {
+		return nearby[i].DistanceKm < nearby[j].DistanceKm
+	})
+
+	return nearby, nil
+}
+
+// queryGeohashCell returns all the restaurants of the tenant whose geohash starts with cell.
+func (rs RestaurantStorage) queryGeohashCell(tenantId, cell string) ([]restaurantItem, error) {
+	keyCond := expression.Key(geohashPrefixAttr).Equal(expression.Value(geohashPartition(tenantId, cell)))
+	if len(cell) > geohashPrefixPrecision {
+		keyCond = keyCond.And(expression.Key(geohashAttr).BeginsWith(cell))
+	}
+
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	input := dynamodb.QueryInput{
+		TableName:                 aws.String(rs.Table),
+		IndexName:                 aws.String(geohashIndex),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+
+	var items []restaurantItem
+	paginator := dynamodb.NewQueryPaginator(rs.Client, &input)
+	for paginator.HasMorePages() {
+		data, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("error querying restaurants near geohash %q in dynamo: %w", cell, err)
+		}
+
+		var page []restaurantItem
+		if err = attributevalue.UnmarshalListOfMaps(data.Items, &page); err != nil {
+			return nil, fmt.Errorf("error unmarshalling value: %w", err)
+		}
+		items = append(items, page...)
+	}
+
+	return items, nil
+}
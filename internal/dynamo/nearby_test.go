@@ -0,0 +1,236 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/lfroomin/restaurant-serverless/internal/geohash"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func Test_NewRestaurantItem(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		geocode       *string
+		geohash       string
+		geohashPrefix string
+	}{
+		{
+			name:          "happy path",
+			geocode:       aString("47.606200,-122.332100"),
+			geohash:       "c23nb62qp",
+			geohashPrefix: "TENANT#tenant1#c23",
+		},
+		{
+			name: "no geocode",
+		},
+		{
+			name:    "malformed geocode",
+			geocode: aString("47.6062"),
+		},
+		{
+			name:    "geocode not a number",
+			geocode: aString("north,west"),
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			restId := "restId"
+			restaurant := model.Restaurant{
+				Id:      &restId,
+				Address: &model.Address{Location: &model.Location{Geocode: tc.geocode}},
+			}
+
+			item := newRestaurantItem("tenant1", restaurant)
+
+			assert.Equal(t, "TENANT#tenant1#restId", item.RestaurantId)
+			assert.Equal(t, "tenant1", item.TenantId)
+			assert.Equal(t, tc.geohash, item.Geohash)
+			assert.Equal(t, tc.geohashPrefix, item.GeohashPrefix)
+			assert.NotZero(t, item.Updated)
+			assert.Equal(t, int64(1), item.Version)
+		})
+	}
+}
+
+func Test_Nearby(t *testing.T) {
+	t.Parallel()
+
+	// A search center close to the east edge of its geohash cell
+	box, _ := geohash.Decode(geohash.Encode(47.6, -122.33, geohash.PrecisionForRadius(47.6, 1)))
+	lat, edgeLon := 47.6, box.MaxLon
+
+	testCases := []struct {
+		name      string
+		lat       float64
+		lon       float64
+		radiusKm  float64
+		stored    map[string][2]float64
+		expIds    []string
+		stubError string
+		err       error
+		errMsg    string
+	}{
+		{
+			name:     "nearest first across a cell boundary",
+			lat:      lat,
+			lon:      edgeLon - 0.001,
+			radiusKm: 1,
+			stored: map[string][2]float64{
+				"sameCell":     {lat, edgeLon - 0.008},
+				"acrossEdge":   {lat, edgeLon + 0.002},
+				"outOfRadius":  {lat, edgeLon + 0.02},
+				"farAway":      {45.5152, -122.6784},
+				"diagonalCell": {box.MinLat - 0.001, edgeLon + 0.001},
+			},
+			expIds: []string{"acrossEdge", "sameCell", "diagonalCell"},
+		},
+		{
+			name:     "across the antimeridian",
+			lat:      0,
+			lon:      179.999,
+			radiusKm: 1,
+			stored: map[string][2]float64{
+				"east": {0, -179.999},
+				"west": {0, 179.995},
+			},
+			expIds: []string{"east", "west"},
+		},
+		{
+			name:     "nothing nearby",
+			lat:      lat,
+			lon:      edgeLon,
+			radiusKm: 5,
+			stored: map[string][2]float64{
+				"farAway": {45.5152, -122.6784},
+			},
+			expIds: []string{},
+		},
+		{
+			name:     "large radius",
+			lat:      lat,
+			lon:      edgeLon,
+			radiusKm: 50,
+			stored: map[string][2]float64{
+				"tacoma": {47.2529, -122.4443},
+			},
+			expIds: []string{"tacoma"},
+		},
+		{
+			name:     "radius too large",
+			lat:      89,
+			lon:      0,
+			radiusKm: 50,
+			err:      storage.ErrRadiusTooLarge,
+		},
+		{
+			name:      "error",
+			lat:       lat,
+			lon:       edgeLon,
+			radiusKm:  1,
+			stubError: "an error occurred",
+			errMsg:    "an error occurred",
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var items []restaurantItem
+			for id, coords := range tc.stored {
+				id := id
+				geocode := fmt.Sprintf("%f,%f", coords[0], coords[1])
+				items = append(items, newRestaurantItem("tenant1", model.Restaurant{
+					Id:      &id,
+					Address: &model.Address{Location: &model.Location{Geocode: &geocode}},
+				}))
+			}
+
+			rs := RestaurantStorage{
+				Client: dynamoRestaurantStorerStub{items: items, error: tc.stubError},
+				Table:  "RestaurantsTable-Test",
+			}
+			nearby, err := rs.Nearby("tenant1", tc.lat, tc.lon, tc.radiusKm)
+
+			switch {
+			case tc.err != nil:
+				assert.ErrorIs(t, err, tc.err)
+			case tc.errMsg != "":
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.errMsg)
+				}
+			default:
+				require.NoError(t, err)
+				ids := []string{}
+				for i, n := range nearby {
+					ids = append(ids, *n.Restaurant.Id)
+					assert.LessOrEqual(t, n.DistanceKm, tc.radiusKm)
+					if i > 0 {
+						assert.LessOrEqual(t, nearby[i-1].DistanceKm, n.DistanceKm)
+					}
+				}
+				assert.Equal(t, tc.expIds, ids)
+			}
+		})
+	}
+}
+
+// Query emulates the geohash index: an item matches when every value of the key condition
+// is its partition (the tenant and the geohash prefix) or a prefix of its geohash (the cell).
+// A query of the table, such as the menus of a restaurant, returns no items.
+func (s dynamoRestaurantStorerStub) Query(_ context.Context, input *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	if s.error != "" {
+		return nil, errors.New(s.error)
+	}
+	if input.IndexName == nil {
+		return &dynamodb.QueryOutput{}, nil
+	}
+	if *input.IndexName == tenantIndex {
+		return s.queryTenant()
+	}
+	if *input.IndexName != geohashIndex {
+		return nil, fmt.Errorf("unexpected index %q", *input.IndexName)
+	}
+
+	output := &dynamodb.QueryOutput{}
+	for _, item := range s.items {
+		match := item.Geohash != ""
+		for _, v := range input.ExpressionAttributeValues {
+			value := v.(*types.AttributeValueMemberS).Value
+			if value != item.GeohashPrefix && !strings.HasPrefix(item.Geohash, value) {
+				match = false
+			}
+		}
+		if !match {
+			continue
+		}
+
+		av, err := attributevalue.MarshalMap(item)
+		if err != nil {
+			return nil, err
+		}
+		output.Items = append(output.Items, av)
+	}
+	return output, nil
+}
+
+func aString(s string) *string {
+	return &s
+}
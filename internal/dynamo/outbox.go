@@ -0,0 +1,254 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/lfroomin/restaurant-serverless/internal/event"
+	"github.com/lfroomin/restaurant-serverless/internal/outbox"
+	"hash/fnv"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The events of the changes of a restaurant are written to its partition, with the sort key
+// OUTBOX#<eventId>, in the same transaction as the change. The undelivered ones are in the
+// sparse OutboxIndex, keyed by their shard, PENDING#<n>, and sorted by their next attempt.
+// The events are spread over outboxShards shards by their id, so that the writes of all the
+// restaurants do not go to a single partition of the index.
+const (
+	outboxSortKeyPrefix = "OUTBOX#"
+	outboxIndex         = "OutboxIndex"
+	outboxStatusAttr    = "OutboxStatus"
+	outboxNextAttr      = "OutboxNextAttempt"
+	outboxPending       = "PENDING"
+	outboxShards        = 8
+
+	// Delivered events are kept for a week, then deleted by the time to live of the table
+	outboxDeliveredTTL = 7 * 24 * time.Hour
+)
+
+type outboxItem struct {
+	RestaurantId      string
+	SK                string
+	Event             event.Event
+	Attempts          int
+	LastError         string `dynamodbav:",omitempty"`
+	OutboxStatus      string `dynamodbav:",omitempty"`
+	OutboxNextAttempt string `dynamodbav:",omitempty"`
+	Delivered         int64  `dynamodbav:",omitempty"`
+	ExpiresAt         int64  `dynamodbav:",omitempty"`
+}
+
+// outboxShard returns the shard of the OutboxIndex of the undelivered event, PENDING#<n>.
+func outboxShard(eventId string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(eventId))
+	return fmt.Sprintf("%s#%d", outboxPending, h.Sum32()%outboxShards)
+}
+
+// outboxPartitions returns the shards of the OutboxIndex, and the partition PENDING of the
+// events recorded before the index was sharded, which are delivered from it.
+func outboxPartitions() []string {
+	partitions := []string{outboxPending}
+	for n := 0; n < outboxShards; n++ {
+		partitions = append(partitions, fmt.Sprintf("%s#%d", outboxPending, n))
+	}
+	return partitions
+}
+
+// nextAttemptKey returns the index sort key of the event due at t. The time is zero
+// padded, so the keys sort in time order.
+func nextAttemptKey(t time.Time, eventId string) string {
+	return fmt.Sprintf("%019d#%s", t.UnixMilli(), eventId)
+}
+
+func (item outboxItem) record() (outbox.Record, error) {
+	padded, _, _ := strings.Cut(item.OutboxNextAttempt, "#")
+	ms, err := strconv.ParseInt(padded, 10, 64)
+	if err != nil {
+		return outbox.Record{}, fmt.Errorf("error parsing the next attempt %q of event %q: %w", item.OutboxNextAttempt, item.Event.Id, err)
+	}
+	return outbox.Record{Event: item.Event, Attempts: item.Attempts, NextAttempt: time.UnixMilli(ms), LastError: item.LastError}, nil
+}
+
+// Due returns up to limit undelivered records with a next attempt at or before now, the
+// earliest first. Each shard of the OutboxIndex is queried for its earliest limit records.
+func (rs RestaurantStorage) Due(now time.Time, limit int32) ([]outbox.Record, error) {
+	var items []outboxItem
+	for _, partition := range outboxPartitions() {
+		shard, err := rs.dueItems(partition, now, limit)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, shard...)
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].OutboxNextAttempt < items[j].OutboxNextAttempt })
+	if len(items) > int(limit) {
+		items = items[:limit]
+	}
+
+	records := make([]outbox.Record, 0, len(items))
+	for _, item := range items {
+		r, err := item.record()
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// dueItems returns up to limit items of the partition of the OutboxIndex with a next attempt
+// at or before now, the earliest first.
+func (rs RestaurantStorage) dueItems(partition string, now time.Time, limit int32) ([]outboxItem, error) {
+	// The keys of the records due at now are lower than the ones due a millisecond later
+	expr, err := expression.NewBuilder().
+		WithKeyCondition(expression.Key(outboxStatusAttr).Equal(expression.Value(partition)).
+			And(expression.Key(outboxNextAttr).LessThan(expression.Value(fmt.Sprintf("%019d", now.UnixMilli()+1))))).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	input := dynamodb.QueryInput{
+		TableName:                 aws.String(rs.Table),
+		IndexName:                 aws.String(outboxIndex),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		Limit:                     aws.Int32(limit),
+	}
+
+	data, err := rs.Client.Query(context.Background(), &input)
+	if err != nil {
+		return nil, fmt.Errorf("error listing the outbox in dynamo: %w", err)
+	}
+
+	var items []outboxItem
+	if err = attributevalue.UnmarshalListOfMaps(data.Items, &items); err != nil {
+		return nil, fmt.Errorf("error unmarshalling value: %w", err)
+	}
+	return items, nil
+}
+
+// Claim moves the next attempt of the record to until, unless it changed since it was returned by Due.
+func (rs RestaurantStorage) Claim(record outbox.Record, until time.Time) (bool, error) {
+	log.Printf("RestaurantStorage.Claim restaurantId: %s  eventId: %s\n", record.Event.RestaurantId, record.Event.Id)
+
+	update := expression.Set(expression.Name(outboxNextAttr), expression.Value(nextAttemptKey(until, record.Event.Id)))
+	cond := expression.Equal(expression.Name(outboxNextAttr), expression.Value(nextAttemptKey(record.NextAttempt, record.Event.Id)))
+
+	err := rs.updateOutbox(record, update, &cond)
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error claiming event %q in dynamo: %w", record.Event.Id, err)
+	}
+	return true, nil
+}
+
+func (rs RestaurantStorage) Retry(record outbox.Record, nextAttempt time.Time, lastError string) error {
+	log.Printf("RestaurantStorage.Retry restaurantId: %s  eventId: %s\n", record.Event.RestaurantId, record.Event.Id)
+
+	update := expression.Set(expression.Name(outboxNextAttr), expression.Value(nextAttemptKey(nextAttempt, record.Event.Id))).
+		Set(expression.Name("LastError"), expression.Value(lastError)).
+		Add(expression.Name("Attempts"), expression.Value(1))
+
+	if err := rs.updateOutbox(record, update, nil); err != nil {
+		return fmt.Errorf("error retrying event %q in dynamo: %w", record.Event.Id, err)
+	}
+	return nil
+}
+
+// MarkDelivered removes the record from the outbox index. It expires after outboxDeliveredTTL.
+func (rs RestaurantStorage) MarkDelivered(record outbox.Record) error {
+	log.Printf("RestaurantStorage.MarkDelivered restaurantId: %s  eventId: %s\n", record.Event.RestaurantId, record.Event.Id)
+
+	now := time.Now()
+	update := expression.Set(expression.Name("Delivered"), expression.Value(now.UnixMilli())).
+		Set(expression.Name("ExpiresAt"), expression.Value(now.Add(outboxDeliveredTTL).Unix())).
+		Remove(expression.Name(outboxStatusAttr)).
+		Remove(expression.Name(outboxNextAttr))
+
+	if err := rs.updateOutbox(record, update, nil); err != nil {
+		return fmt.Errorf("error marking event %q delivered in dynamo: %w", record.Event.Id, err)
+	}
+	return nil
+}
+
+// updateOutbox applies the update to the record when it exists and the condition, if any, holds.
+func (rs RestaurantStorage) updateOutbox(record outbox.Record, update expression.UpdateBuilder, cond *expression.ConditionBuilder) error {
+	c := expression.AttributeExists(expression.Name(key))
+	if cond != nil {
+		c = c.And(*cond)
+	}
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(c).Build()
+	if err != nil {
+		return err
+	}
+
+	input := dynamodb.UpdateItemInput{
+		Key:                       primaryKey(partitionKey(record.Event.TenantId, record.Event.RestaurantId), outboxSortKeyPrefix+record.Event.Id),
+		TableName:                 aws.String(rs.Table),
+		UpdateExpression:          expr.Update(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ConditionExpression:       expr.Condition(),
+	}
+
+	_, err = rs.Client.UpdateItem(context.Background(), &input)
+	return err
+}
+
+// writeWithEvent applies the write of the restaurant and records the event in the outbox, due
+// now, in a single transaction. The event is only recorded when the write succeeds.
+func (rs RestaurantStorage) writeWithEvent(write types.TransactWriteItem, e event.Event) error {
+	av, err := attributevalue.MarshalMap(outboxItem{
+		RestaurantId:      partitionKey(e.TenantId, e.RestaurantId),
+		SK:                outboxSortKeyPrefix + e.Id,
+		Event:             e,
+		OutboxStatus:      outboxShard(e.Id),
+		OutboxNextAttempt: nextAttemptKey(time.Now(), e.Id),
+	})
+	if err != nil {
+		return fmt.Errorf("error marshalling value: %w", err)
+	}
+
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			write,
+			{Put: &types.Put{Item: av, TableName: aws.String(rs.Table)}},
+		},
+	}
+
+	_, err = rs.Client.TransactWriteItems(context.Background(), input)
+	return err
+}
+
+// writeConditionFailed reports whether a transaction written by writeWithEvent or
+// writeWithRating was canceled because the condition of its first write failed.
+func writeConditionFailed(err error) bool {
+	return transactionConditionFailed(err, 0)
+}
+
+// transactionConditionFailed reports whether a transaction was canceled because the
+// condition of its write i failed.
+func transactionConditionFailed(err error, i int) bool {
+	var tce *types.TransactionCanceledException
+	if !errors.As(err, &tce) || len(tce.CancellationReasons) <= i {
+		return false
+	}
+	return aws.ToString(tce.CancellationReasons[i].Code) == "ConditionalCheckFailed"
+}
@@ -0,0 +1,98 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/lfroomin/restaurant-serverless/internal/ratelimit"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"time"
+)
+
+// The token buckets of the rate limits are stored in the restaurants table, each in its own
+// partition RATELIMIT#<key>, and deleted by the TTL once they are full again.
+const (
+	bucketKeyPrefix = "RATELIMIT#"
+	bucketSortKey   = "BUCKET"
+)
+
+type bucketItem struct {
+	RestaurantId string
+	SK           string
+	Tokens       float64
+	Updated      time.Time
+	Version      int64
+	ExpiresAt    int64
+}
+
+// GetBucket returns the token bucket of bucketKey. It is read consistently, so that SaveBucket
+// rarely finds that it changed in between.
+func (rs RestaurantStorage) GetBucket(bucketKey string) (ratelimit.Bucket, bool, error) {
+	input := dynamodb.GetItemInput{
+		Key:            primaryKey(bucketKeyPrefix+bucketKey, bucketSortKey),
+		TableName:      aws.String(rs.Table),
+		ConsistentRead: aws.Bool(true),
+	}
+
+	data, err := rs.Client.GetItem(context.Background(), &input)
+	if err != nil {
+		return ratelimit.Bucket{}, false, fmt.Errorf("error getting rate limit bucket %q in dynamo: %w", bucketKey, err)
+	}
+	if data.Item == nil {
+		return ratelimit.Bucket{}, false, nil
+	}
+
+	item := bucketItem{}
+	if err = attributevalue.UnmarshalMap(data.Item, &item); err != nil {
+		return ratelimit.Bucket{}, false, fmt.Errorf("error unmarshalling value: %w", err)
+	}
+	return ratelimit.Bucket{Tokens: item.Tokens, Updated: item.Updated, Version: item.Version}, true, nil
+}
+
+// SaveBucket stores the token bucket of bucketKey if the stored one has the previous version, or if
+// there is none and the bucket has version 1. Otherwise it returns storage.ErrPreconditionFailed.
+func (rs RestaurantStorage) SaveBucket(bucketKey string, b ratelimit.Bucket, expires time.Time) error {
+	item := bucketItem{
+		RestaurantId: bucketKeyPrefix + bucketKey,
+		SK:           bucketSortKey,
+		Tokens:       b.Tokens,
+		Updated:      b.Updated,
+		Version:      b.Version,
+		ExpiresAt:    expires.Unix(),
+	}
+	av, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return fmt.Errorf("error marshalling value: %w", err)
+	}
+
+	cond := expression.AttributeNotExists(expression.Name(key))
+	if b.Version > 1 {
+		cond = expression.Equal(expression.Name(versionAttr), expression.Value(b.Version-1))
+	}
+	expr, err := expression.NewBuilder().WithCondition(cond).Build()
+	if err != nil {
+		return err
+	}
+
+	input := dynamodb.PutItemInput{
+		Item:                      av,
+		TableName:                 aws.String(rs.Table),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+
+	if _, err = rs.Client.PutItem(context.Background(), &input); err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			err = storage.ErrPreconditionFailed
+		}
+		return fmt.Errorf("error saving rate limit bucket %q in dynamo: %w", bucketKey, err)
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"log"
+	"strconv"
+)
+
+// The reviews are listed through local secondary indexes of the table, sorted by
+// attributes that only the review items have.
+const (
+	newestReviewIndex  = "NewestReviewIndex"
+	newestReviewAttr   = "NewestReviewSK"
+	highestReviewIndex = "HighestReviewIndex"
+	highestReviewAttr  = "HighestReviewSK"
+)
+
+// reviewItem is a review stored in the partition of its restaurant, with the sort key REVIEW#<reviewId>.
+type reviewItem struct {
+	RestaurantId    string
+	SK              string
+	Review          model.Review
+	NewestReviewSK  string
+	HighestReviewSK string
+}
+
+func newReviewItem(pk string, review model.Review) reviewItem {
+	// The creation time is zero padded, so the keys sort in time order
+	newest := fmt.Sprintf("%019d#%s", review.Created.UnixNano(), *review.Id)
+	return reviewItem{
+		RestaurantId:    pk,
+		SK:              reviewSortKeyPrefix + *review.Id,
+		Review:          review,
+		NewestReviewSK:  newest,
+		HighestReviewSK: fmt.Sprintf("%d#%s", review.Rating, newest),
+	}
+}
+
+// SaveReview stores the review and adds its rating to the rating summary of the restaurant,
+// in one transaction that is canceled when the restaurant does not exist.
+func (rs RestaurantStorage) SaveReview(tenantId, restaurantId string, review model.Review) error {
+	log.Printf("RestaurantStorage.SaveReview tenantId: %s  restaurantId: %s  reviewId: %s\n", tenantId, restaurantId, *review.Id)
+
+	av, err := attributevalue.MarshalMap(newReviewItem(partitionKey(tenantId, restaurantId), review))
+	if err != nil {
+		return fmt.Errorf("error marshalling value: %w", err)
+	}
+
+	expr, err := expression.NewBuilder().WithCondition(expression.AttributeNotExists(expression.Name(key))).Build()
+	if err != nil {
+		return err
+	}
+
+	put := types.TransactWriteItem{Put: &types.Put{
+		Item:                     av,
+		TableName:                aws.String(rs.Table),
+		ConditionExpression:      expr.Condition(),
+		ExpressionAttributeNames: expr.Names(),
+	}}
+
+	if err = rs.writeWithRating(tenantId, restaurantId, put, review.Rating, 1); err != nil {
+		if writeConditionFailed(err) {
+			err = storage.ErrConflict
+		}
+		return fmt.Errorf("error saving review %q of restaurant %q in dynamo: %w", *review.Id, restaurantId, err)
+	}
+	return nil
+}
+
+func (rs RestaurantStorage) GetReview(tenantId, restaurantId, reviewId string) (model.Review, bool, error) {
+	log.Printf("RestaurantStorage.GetReview tenantId: %s  restaurantId: %s  reviewId: %s\n", tenantId, restaurantId, reviewId)
+
+	input := dynamodb.GetItemInput{
+		Key:       primaryKey(partitionKey(tenantId, restaurantId), reviewSortKeyPrefix+reviewId),
+		TableName: aws.String(rs.Table),
+	}
+
+	data, err := rs.Client.GetItem(context.Background(), &input)
+	if err != nil {
+		return model.Review{}, false, fmt.Errorf("error getting review %q of restaurant %q in dynamo: %w", reviewId, restaurantId, err)
+	}
+	if data.Item == nil {
+		return model.Review{}, false, nil
+	}
+
+	item := reviewItem{}
+	if err = attributevalue.UnmarshalMap(data.Item, &item); err != nil {
+		return model.Review{}, false, fmt.Errorf("error unmarshalling value: %w", err)
+	}
+	return item.Review, true, nil
+}
+
+// DeleteReview removes the review and its rating from the rating summary of the restaurant,
+// in one transaction, and returns the review as it was before the delete.
+func (rs RestaurantStorage) DeleteReview(tenantId, restaurantId, reviewId string) (model.Review, error) {
+	log.Printf("RestaurantStorage.DeleteReview tenantId: %s  restaurantId: %s  reviewId: %s\n", tenantId, restaurantId, reviewId)
+
+	// The rating of the review tells which count of the summary to decrement. The reviews
+	// are never changed, so the review deleted is the one read if it still exists.
+	review, exists, err := rs.GetReview(tenantId, restaurantId, reviewId)
+	if err != nil {
+		return model.Review{}, err
+	}
+	if !exists {
+		return model.Review{}, fmt.Errorf("error deleting review %q of restaurant %q from dynamo: %w", reviewId, restaurantId, storage.ErrNotFound)
+	}
+
+	expr, err := expression.NewBuilder().WithCondition(expression.AttributeExists(expression.Name(key))).Build()
+	if err != nil {
+		return model.Review{}, err
+	}
+
+	del := types.TransactWriteItem{Delete: &types.Delete{
+		Key:                      primaryKey(partitionKey(tenantId, restaurantId), reviewSortKeyPrefix+reviewId),
+		TableName:                aws.String(rs.Table),
+		ConditionExpression:      expr.Condition(),
+		ExpressionAttributeNames: expr.Names(),
+	}}
+
+	if err = rs.writeWithRating(tenantId, restaurantId, del, review.Rating, -1); err != nil {
+		if writeConditionFailed(err) {
+			err = storage.ErrNotFound
+		}
+		return model.Review{}, fmt.Errorf("error deleting review %q of restaurant %q from dynamo: %w", reviewId, restaurantId, err)
+	}
+	return review, nil
+}
+
+// ListReviews returns up to limit reviews of the restaurant in the order, starting after
+// the position encoded in nextToken. The returned token is empty when there are no more reviews.
+func (rs RestaurantStorage) ListReviews(tenantId, restaurantId string, order storage.ReviewOrder, limit int32, nextToken string) ([]model.Review, string, error) {
+	log.Printf("RestaurantStorage.ListReviews tenantId: %s  restaurantId: %s  order: %s  limit: %d  nextToken: %s\n", tenantId, restaurantId, order, limit, nextToken)
+
+	pk := partitionKey(tenantId, restaurantId)
+	index, sortAttr := newestReviewIndex, newestReviewAttr
+	if order == storage.ReviewsHighest {
+		index, sortAttr = highestReviewIndex, highestReviewAttr
+	}
+
+	expr, err := expression.NewBuilder().
+		WithKeyCondition(expression.Key(key).Equal(expression.Value(pk))).
+		Build()
+	if err != nil {
+		return nil, "", err
+	}
+
+	input := dynamodb.QueryInput{
+		TableName:                 aws.String(rs.Table),
+		IndexName:                 aws.String(index),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int32(limit),
+	}
+
+	if nextToken != "" {
+		startKey, err := decodeNextToken(nextToken)
+		if err != nil {
+			return nil, "", err
+		}
+		// A token of another restaurant or order would be rejected by DynamoDB
+		id, ok := startKey[key].(*types.AttributeValueMemberS)
+		if !ok || id.Value != pk || startKey[sortAttr] == nil {
+			return nil, "", storage.ErrInvalidNextToken
+		}
+		input.ExclusiveStartKey = startKey
+	}
+
+	data, err := rs.Client.Query(context.Background(), &input)
+	if err != nil {
+		return nil, "", fmt.Errorf("error listing the reviews of restaurant %q in dynamo: %w", restaurantId, err)
+	}
+
+	var items []reviewItem
+	if err = attributevalue.UnmarshalListOfMaps(data.Items, &items); err != nil {
+		return nil, "", fmt.Errorf("error unmarshalling value: %w", err)
+	}
+
+	reviews := make([]model.Review, 0, len(items))
+	for _, item := range items {
+		reviews = append(reviews, item.Review)
+	}
+
+	token, err := encodeNextToken(data.LastEvaluatedKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return reviews, token, nil
+}
+
+// writeWithRating writes the review and atomically adds n to the number of reviews of the
+// restaurant with the rating, in one transaction. The version of the restaurant is not
+// incremented, so that the reviews do not fail the updates of the owners conditioned on the
+// version they read: the updates never write the rating counters. The transaction is canceled with
+// storage.ErrNotFound when the restaurant does not exist, and otherwise with the
+// TransactionCanceledException of the condition of the write.
+func (rs RestaurantStorage) writeWithRating(tenantId, restaurantId string, write types.TransactWriteItem, rating, n int) error {
+	if rating < model.MinRating || rating > model.MaxRating {
+		return fmt.Errorf("rating %d is not between %d and %d", rating, model.MinRating, model.MaxRating)
+	}
+
+	pk := partitionKey(tenantId, restaurantId)
+	expr, err := expression.NewBuilder().
+		WithUpdate(expression.Add(expression.Name("Rating"+strconv.Itoa(rating)), expression.Value(n))).
+		WithCondition(versionCondition(pk, nil)).
+		Build()
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			write,
+			{Update: &types.Update{
+				Key:                       primaryKey(pk, restaurantSortKey),
+				TableName:                 aws.String(rs.Table),
+				UpdateExpression:          expr.Update(),
+				ConditionExpression:       expr.Condition(),
+				ExpressionAttributeNames:  expr.Names(),
+				ExpressionAttributeValues: expr.Values(),
+			}},
+		},
+	}
+
+	_, err = rs.Client.TransactWriteItems(context.Background(), input)
+	if transactionConditionFailed(err, 1) {
+		return storage.ErrNotFound
+	}
+	return err
+}
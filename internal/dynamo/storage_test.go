@@ -0,0 +1,302 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/lfroomin/restaurant-serverless/internal/event"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/ratelimit"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"github.com/lfroomin/restaurant-serverless/internal/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_Conformance(t *testing.T) {
+	t.Parallel()
+
+	storagetest.Run(t, func() storagetest.RestaurantStorer {
+		return RestaurantStorage{Client: newFakeDynamoClient(), Table: "restaurants"}
+	})
+	storagetest.RunMenus(t, func() storagetest.RestaurantMenuStorer {
+		return RestaurantStorage{Client: newFakeDynamoClient(), Table: "restaurants"}
+	})
+	storagetest.RunReviews(t, func() storagetest.RestaurantReviewStorer {
+		return RestaurantStorage{Client: newFakeDynamoClient(), Table: "restaurants"}
+	})
+	storagetest.RunOutbox(t, func() storagetest.RestaurantOutboxStorer {
+		return RestaurantStorage{Client: newFakeDynamoClient(), Table: "restaurants"}
+	})
+	storagetest.RunWebhooks(t, func() storagetest.WebhookStorer {
+		return RestaurantStorage{Client: newFakeDynamoClient(), Table: "restaurants"}
+	})
+	storagetest.RunApiKeys(t, func() storagetest.ApiKeyStorer {
+		return RestaurantStorage{Client: newFakeDynamoClient(), Table: "restaurants"}
+	})
+	storagetest.RunRateLimits(t, func() ratelimit.Store {
+		return RestaurantStorage{Client: newFakeDynamoClient(), Table: "restaurants"}
+	})
+}
+
+func Test_MigrateCoordinates(t *testing.T) {
+	t.Parallel()
+
+	// A restaurant stored before latitude and longitude existed
+	restId, geocode := "restId", "47.606200,-122.332100"
+	legacy := model.Restaurant{Id: &restId, Address: &model.Address{Location: &model.Location{Geocode: &geocode}}}
+	item, err := attributevalue.MarshalMap(restaurantItem{RestaurantId: partitionKey("tenant1", restId), TenantId: "tenant1", RestaurantTenant: tenantPartition("tenant1"), SK: restaurantSortKey, Restaurant: legacy, Version: 1})
+	require.NoError(t, err)
+
+	client := newFakeDynamoClient()
+	client.items[itemKey(item)] = item
+	rs := RestaurantStorage{Client: client, Table: "restaurants"}
+
+	expected := model.NewLocation(47.6062, -122.3321)
+
+	got, _, _, err := rs.Get("tenant1", restId)
+	require.NoError(t, err)
+	assert.Equal(t, &expected, got.Address.Location)
+
+	list, _, err := rs.List("tenant1", 10, "")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, &expected, list[0].Address.Location)
+
+	// The migrated restaurant is stored by the next write
+	_, err = rs.Update("tenant1", got, nil)
+	require.NoError(t, err)
+	assert.Contains(t, client.items[itemKey(item)]["Restaurant"].(*types.AttributeValueMemberM).Value["Address"].(*types.AttributeValueMemberM).Value["Location"].(*types.AttributeValueMemberM).Value, "Latitude")
+}
+
+func Test_ReviewWritesAreTransactions(t *testing.T) {
+	t.Parallel()
+
+	client := &writeCountingClient{fakeDynamoClient: newFakeDynamoClient()}
+	rs := RestaurantStorage{Client: client, Table: "restaurants"}
+
+	restId := "restId"
+	require.NoError(t, rs.Save("tenant1", model.Restaurant{Id: &restId, Name: "name"}))
+	client.writes = 0
+
+	reviewId, created := "reviewId", time.Date(2024, 3, 8, 18, 30, 0, 0, time.UTC)
+	review := model.Review{Id: &reviewId, Rating: 4, Author: "Ana", Created: &created}
+	require.NoError(t, rs.SaveReview("tenant1", restId, review))
+
+	// A review saved again changes neither the review nor the rating summary
+	assert.ErrorIs(t, rs.SaveReview("tenant1", restId, review), storage.ErrConflict)
+	r, _, _, err := rs.Get("tenant1", restId)
+	require.NoError(t, err)
+	require.NotNil(t, r.Rating)
+	assert.Equal(t, 1, r.Rating.Count)
+
+	_, err = rs.DeleteReview("tenant1", restId, reviewId)
+	require.NoError(t, err)
+	r, _, _, err = rs.Get("tenant1", restId)
+	require.NoError(t, err)
+	assert.Nil(t, r.Rating)
+
+	// Every write of a review and of its rating was part of a transaction
+	assert.Zero(t, client.writes)
+}
+
+func Test_ListQueriesTheTenant(t *testing.T) {
+	t.Parallel()
+
+	client := &scanFailingClient{fakeDynamoClient: newFakeDynamoClient()}
+	rs := RestaurantStorage{Client: client, Table: "restaurants"}
+
+	for _, tenantId := range []string{"tenant1", "tenant2"} {
+		for _, id := range []string{"rest1", "rest2", "rest3"} {
+			id := id
+			require.NoError(t, rs.Save(tenantId, model.Restaurant{Id: &id, Name: "name"}))
+			menuId := "menu" + id
+			require.NoError(t, rs.SaveMenu(tenantId, id, model.Menu{Id: &menuId, Name: "menu"}))
+		}
+	}
+
+	// The pages are full: the menus and the other tenant are not in the index
+	restaurants, next, err := rs.List("tenant1", 2, "")
+	require.NoError(t, err)
+	require.Len(t, restaurants, 2)
+	assert.Equal(t, "rest1", *restaurants[0].Id)
+	assert.Equal(t, "rest2", *restaurants[1].Id)
+	require.NotEmpty(t, next)
+
+	restaurants, next, err = rs.List("tenant1", 2, next)
+	require.NoError(t, err)
+	require.Len(t, restaurants, 1)
+	assert.Equal(t, "rest3", *restaurants[0].Id)
+	assert.Empty(t, next)
+}
+
+func Test_DeleteRetriesTheUnprocessedChildren(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		unprocessed int
+		err         bool
+	}{
+		{
+			name:        "unprocessed then processed",
+			unprocessed: 2,
+		},
+		{
+			name:        "always unprocessed",
+			unprocessed: maxBatchWriteAttempts,
+			err:         true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := &unprocessingClient{fakeDynamoClient: newFakeDynamoClient(), unprocessed: tc.unprocessed}
+			rs := RestaurantStorage{Client: client, Table: "restaurants"}
+
+			// The menus take two batches
+			restId := "restId"
+			require.NoError(t, rs.Save("tenant1", model.Restaurant{Id: &restId, Name: "name"}))
+			for i := 0; i < 30; i++ {
+				menuId := fmt.Sprintf("menu%02d", i)
+				require.NoError(t, rs.SaveMenu("tenant1", restId, model.Menu{Id: &menuId, Name: "menu"}))
+			}
+
+			_, err := rs.Delete("tenant1", restId, nil)
+
+			items, qerr := rs.queryPartition(partitionKey("tenant1", restId), menuSortKeyPrefix)
+			require.NoError(t, qerr)
+			if !tc.err {
+				require.NoError(t, err)
+				assert.Empty(t, items)
+				return
+			}
+
+			// The first menu of each batch is left, and deleted by deleting the restaurant again
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), "2 of the 30 menus and reviews were not deleted")
+			}
+			assert.Len(t, items, 2)
+
+			client.unprocessed = 0
+			_, err = rs.Delete("tenant1", restId, nil)
+			assert.ErrorIs(t, err, storage.ErrNotFound)
+			items, err = rs.queryPartition(partitionKey("tenant1", restId), menuSortKeyPrefix)
+			require.NoError(t, err)
+			assert.Empty(t, items)
+		})
+	}
+}
+
+func Test_OutboxShards(t *testing.T) {
+	t.Parallel()
+
+	client := newFakeDynamoClient()
+	rs := RestaurantStorage{Client: client, Table: "restaurants"}
+
+	for i := 0; i < 20; i++ {
+		id := fmt.Sprintf("rest%02d", i)
+		require.NoError(t, rs.Save("tenant1", model.Restaurant{Id: &id, Name: "name"}))
+	}
+
+	// An event recorded before the index was sharded
+	legacy, err := attributevalue.MarshalMap(outboxItem{
+		RestaurantId:      partitionKey("tenant1", "rest00"),
+		SK:                outboxSortKeyPrefix + "legacy",
+		Event:             event.Event{Id: "legacy", TenantId: "tenant1", RestaurantId: "rest00"},
+		OutboxStatus:      outboxPending,
+		OutboxNextAttempt: nextAttemptKey(time.Now().Add(-time.Hour), "legacy"),
+	})
+	require.NoError(t, err)
+	client.items[itemKey(legacy)] = legacy
+
+	// The events are spread over the shards
+	shards := map[string]bool{}
+	for _, item := range client.items {
+		if status := attributeString(item[outboxStatusAttr]); status != "" && status != outboxPending {
+			shards[status] = true
+		}
+	}
+	assert.Greater(t, len(shards), 1)
+
+	records, err := rs.Due(time.Now(), 30)
+	require.NoError(t, err)
+	require.Len(t, records, 21)
+	assert.Equal(t, "legacy", records[0].Event.Id)
+	for i := 1; i < len(records); i++ {
+		assert.False(t, records[i].NextAttempt.Before(records[i-1].NextAttempt))
+	}
+
+	// The earliest records of all the shards
+	records, err = rs.Due(time.Now(), 5)
+	require.NoError(t, err)
+	require.Len(t, records, 5)
+	assert.Equal(t, "legacy", records[0].Event.Id)
+}
+
+// unprocessingClient leaves the first request of each batch write of the fake client
+// unprocessed, the number of times of unprocessed.
+type unprocessingClient struct {
+	*fakeDynamoClient
+	unprocessed int
+	calls       map[string]int
+}
+
+func (c *unprocessingClient) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	if c.calls == nil {
+		c.calls = map[string]int{}
+	}
+
+	unprocessed := map[string][]types.WriteRequest{}
+	for table, requests := range params.RequestItems {
+		first := itemKey(requests[0].DeleteRequest.Key)
+		c.calls[first]++
+		if c.calls[first] <= c.unprocessed {
+			unprocessed[table] = requests[:1]
+			params = &dynamodb.BatchWriteItemInput{RequestItems: map[string][]types.WriteRequest{table: requests[1:]}}
+		}
+	}
+
+	if _, err := c.fakeDynamoClient.BatchWriteItem(ctx, params, optFns...); err != nil {
+		return nil, err
+	}
+	return &dynamodb.BatchWriteItemOutput{UnprocessedItems: unprocessed}, nil
+}
+
+// scanFailingClient fails the scans of the fake client, which List must not make.
+type scanFailingClient struct {
+	*fakeDynamoClient
+}
+
+func (c *scanFailingClient) Scan(_ context.Context, _ *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	return nil, errors.New("the table must not be scanned")
+}
+
+// writeCountingClient counts the writes of the fake client that are not part of a transaction.
+type writeCountingClient struct {
+	*fakeDynamoClient
+	writes int
+}
+
+func (c *writeCountingClient) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	c.writes++
+	return c.fakeDynamoClient.PutItem(ctx, params, optFns...)
+}
+
+func (c *writeCountingClient) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	c.writes++
+	return c.fakeDynamoClient.UpdateItem(ctx, params, optFns...)
+}
+
+func (c *writeCountingClient) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	c.writes++
+	return c.fakeDynamoClient.DeleteItem(ctx, params, optFns...)
+}
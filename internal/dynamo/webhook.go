@@ -0,0 +1,419 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"github.com/lfroomin/restaurant-serverless/internal/webhook"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The webhooks are stored in the restaurants table, in the partition of the webhooks of their
+// tenant TENANT#<tenantId>#WEBHOOKS with the sort key WEBHOOK#<webhookId>. The deliveries of a
+// webhook are in its own partition TENANT#<tenantId>#WEBHOOK#<webhookId>, with the sort key
+// DELIVERY#<event time>#<eventId>. The pending ones of all the tenants are in the sparse
+// WebhookDeliveryIndex, keyed by a constant status and sorted by their next attempt.
+const (
+	webhooksPartition     = "WEBHOOKS"
+	webhookKeyPrefix      = "WEBHOOK#"
+	deliverySortKeyPrefix = "DELIVERY#"
+	deliveryIndex         = "WebhookDeliveryIndex"
+	deliveryStatusAttr    = "DeliveryStatus"
+	deliveryNextAttr      = "DeliveryNextAttempt"
+	deliveryPending       = "PENDING"
+
+	// Delivered and dead lettered deliveries are kept for 30 days, then deleted by the time to live of the table
+	deliveryLogTTL = 30 * 24 * time.Hour
+)
+
+type webhookItem struct {
+	RestaurantId string
+	SK           string
+	TenantId     string
+	Webhook      model.Webhook
+}
+
+// deliveryItem is a delivery without its next attempt, which is in the index sort key.
+type deliveryItem struct {
+	RestaurantId        string
+	SK                  string
+	Delivery            webhook.Delivery
+	DeliveryStatus      string `dynamodbav:",omitempty"`
+	DeliveryNextAttempt string `dynamodbav:",omitempty"`
+	ExpiresAt           int64  `dynamodbav:",omitempty"`
+}
+
+func newDeliveryItem(d webhook.Delivery) deliveryItem {
+	item := deliveryItem{
+		RestaurantId: deliveriesPartitionKey(d.TenantId, d.WebhookId),
+		SK:           deliverySortKey(d),
+		Delivery:     d,
+	}
+	item.Delivery.NextAttempt = nil
+	if d.Status == model.Pending && d.NextAttempt != nil {
+		item.DeliveryStatus, item.DeliveryNextAttempt = deliveryPending, deliveryNextAttemptKey(*d.NextAttempt, d)
+	} else {
+		item.ExpiresAt = time.Now().Add(deliveryLogTTL).Unix()
+	}
+	return item
+}
+
+func (item deliveryItem) delivery() (webhook.Delivery, error) {
+	d := item.Delivery
+	if item.DeliveryNextAttempt != "" {
+		padded, _, _ := strings.Cut(item.DeliveryNextAttempt, "#")
+		ms, err := strconv.ParseInt(padded, 10, 64)
+		if err != nil {
+			return webhook.Delivery{}, fmt.Errorf("error parsing the next attempt %q of delivery %q: %w", item.DeliveryNextAttempt, d.Id, err)
+		}
+		next := time.UnixMilli(ms)
+		d.NextAttempt = &next
+	}
+	return d, nil
+}
+
+// webhooksPartitionKey returns the partition key of the webhooks of the tenant.
+func webhooksPartitionKey(tenantId string) string {
+	return partitionKey(tenantId, webhooksPartition)
+}
+
+// deliveriesPartitionKey returns the partition key of the deliveries of the webhook.
+func deliveriesPartitionKey(tenantId, webhookId string) string {
+	return partitionKey(tenantId, webhookKeyPrefix+webhookId)
+}
+
+// deliverySortKey returns the sort key of the delivery. The event time is zero padded, so the keys sort in time order.
+func deliverySortKey(d webhook.Delivery) string {
+	return fmt.Sprintf("%s%019d#%s", deliverySortKeyPrefix, d.Created.UnixNano(), d.Id)
+}
+
+// deliveryNextAttemptKey returns the index sort key of the delivery due at t.
+func deliveryNextAttemptKey(t time.Time, d webhook.Delivery) string {
+	return fmt.Sprintf("%019d#%s#%s", t.UnixMilli(), d.WebhookId, d.Id)
+}
+
+func (rs RestaurantStorage) SaveWebhook(tenantId string, w model.Webhook) error {
+	log.Printf("RestaurantStorage.SaveWebhook tenantId: %s  webhookId: %s\n", tenantId, *w.Id)
+
+	err := rs.putNew(webhookItem{RestaurantId: webhooksPartitionKey(tenantId), SK: webhookKeyPrefix + *w.Id, TenantId: tenantId, Webhook: w})
+	if err != nil {
+		return fmt.Errorf("error saving webhook %q in dynamo: %w", *w.Id, err)
+	}
+	return nil
+}
+
+// GetWebhook returns the webhook with its secret.
+func (rs RestaurantStorage) GetWebhook(tenantId, webhookId string) (model.Webhook, bool, error) {
+	log.Printf("RestaurantStorage.GetWebhook tenantId: %s  webhookId: %s\n", tenantId, webhookId)
+
+	input := dynamodb.GetItemInput{
+		Key:       primaryKey(webhooksPartitionKey(tenantId), webhookKeyPrefix+webhookId),
+		TableName: aws.String(rs.Table),
+	}
+
+	data, err := rs.Client.GetItem(context.Background(), &input)
+	if err != nil {
+		return model.Webhook{}, false, fmt.Errorf("error getting webhook %q in dynamo: %w", webhookId, err)
+	}
+	if data.Item == nil {
+		return model.Webhook{}, false, nil
+	}
+
+	item := webhookItem{}
+	if err = attributevalue.UnmarshalMap(data.Item, &item); err != nil {
+		return model.Webhook{}, false, fmt.Errorf("error unmarshalling value: %w", err)
+	}
+	return item.Webhook, true, nil
+}
+
+// ListWebhooks returns the webhooks of the tenant with their secrets, in the order of their ids.
+func (rs RestaurantStorage) ListWebhooks(tenantId string) ([]model.Webhook, error) {
+	log.Printf("RestaurantStorage.ListWebhooks tenantId: %s\n", tenantId)
+
+	data, err := rs.queryPartition(webhooksPartitionKey(tenantId), webhookKeyPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("error listing webhooks in dynamo: %w", err)
+	}
+
+	var items []webhookItem
+	if err = attributevalue.UnmarshalListOfMaps(data, &items); err != nil {
+		return nil, fmt.Errorf("error unmarshalling value: %w", err)
+	}
+
+	webhooks := make([]model.Webhook, 0, len(items))
+	for _, item := range items {
+		webhooks = append(webhooks, item.Webhook)
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook removes the webhook and its deliveries, and returns the webhook as it was before the delete.
+func (rs RestaurantStorage) DeleteWebhook(tenantId, webhookId string) (model.Webhook, error) {
+	log.Printf("RestaurantStorage.DeleteWebhook tenantId: %s  webhookId: %s\n", tenantId, webhookId)
+
+	expr, err := expression.NewBuilder().WithCondition(expression.AttributeExists(expression.Name(key))).Build()
+	if err != nil {
+		return model.Webhook{}, err
+	}
+
+	input := dynamodb.DeleteItemInput{
+		TableName:                aws.String(rs.Table),
+		Key:                      primaryKey(webhooksPartitionKey(tenantId), webhookKeyPrefix+webhookId),
+		ConditionExpression:      expr.Condition(),
+		ExpressionAttributeNames: expr.Names(),
+		ReturnValues:             types.ReturnValueAllOld,
+	}
+
+	data, err := rs.Client.DeleteItem(context.Background(), &input)
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			err = storage.ErrNotFound
+		}
+		return model.Webhook{}, fmt.Errorf("error deleting webhook %q from dynamo: %w", webhookId, err)
+	}
+
+	item := webhookItem{}
+	if data != nil {
+		if err = attributevalue.UnmarshalMap(data.Attributes, &item); err != nil {
+			return model.Webhook{}, fmt.Errorf("error unmarshalling value: %w", err)
+		}
+	}
+
+	deliveries, err := rs.queryPartition(deliveriesPartitionKey(tenantId, webhookId), "")
+	if err != nil {
+		return model.Webhook{}, fmt.Errorf("error deleting the deliveries of webhook %q from dynamo: %w", webhookId, err)
+	}
+	for _, d := range deliveries {
+		input := dynamodb.DeleteItemInput{
+			TableName: aws.String(rs.Table),
+			Key:       map[string]types.AttributeValue{key: d[key], sortKey: d[sortKey]},
+		}
+		if _, err = rs.Client.DeleteItem(context.Background(), &input); err != nil {
+			return model.Webhook{}, fmt.Errorf("error deleting the deliveries of webhook %q from dynamo: %w", webhookId, err)
+		}
+	}
+
+	return item.Webhook, nil
+}
+
+// ListDeliveries returns up to limit deliveries of the webhook, the newest event first, starting
+// after the position encoded in nextToken. The returned token is empty when there are no more deliveries.
+func (rs RestaurantStorage) ListDeliveries(tenantId, webhookId string, limit int32, nextToken string) ([]model.WebhookDelivery, string, error) {
+	log.Printf("RestaurantStorage.ListDeliveries tenantId: %s  webhookId: %s  limit: %d  nextToken: %s\n", tenantId, webhookId, limit, nextToken)
+
+	partition := deliveriesPartitionKey(tenantId, webhookId)
+	expr, err := expression.NewBuilder().
+		WithKeyCondition(expression.Key(key).Equal(expression.Value(partition)).
+			And(expression.Key(sortKey).BeginsWith(deliverySortKeyPrefix))).
+		Build()
+	if err != nil {
+		return nil, "", err
+	}
+
+	input := dynamodb.QueryInput{
+		TableName:                 aws.String(rs.Table),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int32(limit),
+	}
+
+	if nextToken != "" {
+		startKey, err := decodeNextToken(nextToken)
+		if err != nil {
+			return nil, "", err
+		}
+		// A token of another webhook, or of a webhook of another tenant, would be rejected by DynamoDB
+		id, ok := startKey[key].(*types.AttributeValueMemberS)
+		if !ok || id.Value != partition {
+			return nil, "", storage.ErrInvalidNextToken
+		}
+		input.ExclusiveStartKey = startKey
+	}
+
+	data, err := rs.Client.Query(context.Background(), &input)
+	if err != nil {
+		return nil, "", fmt.Errorf("error listing the deliveries of webhook %q in dynamo: %w", webhookId, err)
+	}
+
+	var items []deliveryItem
+	if err = attributevalue.UnmarshalListOfMaps(data.Items, &items); err != nil {
+		return nil, "", fmt.Errorf("error unmarshalling value: %w", err)
+	}
+
+	deliveries := make([]model.WebhookDelivery, 0, len(items))
+	for _, item := range items {
+		d, err := item.delivery()
+		if err != nil {
+			return nil, "", err
+		}
+		deliveries = append(deliveries, d.WebhookDelivery)
+	}
+
+	token, err := encodeNextToken(data.LastEvaluatedKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return deliveries, token, nil
+}
+
+// SaveDelivery stores a new delivery, unless the webhook already has a delivery of the event.
+func (rs RestaurantStorage) SaveDelivery(delivery webhook.Delivery) error {
+	log.Printf("RestaurantStorage.SaveDelivery tenantId: %s  webhookId: %s  eventId: %s\n", delivery.TenantId, delivery.WebhookId, delivery.Id)
+
+	if err := rs.putNew(newDeliveryItem(delivery)); err != nil {
+		return fmt.Errorf("error saving delivery of event %q to webhook %q in dynamo: %w", delivery.Id, delivery.WebhookId, err)
+	}
+	return nil
+}
+
+// DueDeliveries returns up to limit pending deliveries with a next attempt at or before now, the earliest first.
+func (rs RestaurantStorage) DueDeliveries(now time.Time, limit int32) ([]webhook.Delivery, error) {
+	// The keys of the deliveries due at now are lower than the ones due a millisecond later
+	expr, err := expression.NewBuilder().
+		WithKeyCondition(expression.Key(deliveryStatusAttr).Equal(expression.Value(deliveryPending)).
+			And(expression.Key(deliveryNextAttr).LessThan(expression.Value(fmt.Sprintf("%019d", now.UnixMilli()+1))))).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	input := dynamodb.QueryInput{
+		TableName:                 aws.String(rs.Table),
+		IndexName:                 aws.String(deliveryIndex),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		Limit:                     aws.Int32(limit),
+	}
+
+	data, err := rs.Client.Query(context.Background(), &input)
+	if err != nil {
+		return nil, fmt.Errorf("error listing the due webhook deliveries in dynamo: %w", err)
+	}
+
+	var items []deliveryItem
+	if err = attributevalue.UnmarshalListOfMaps(data.Items, &items); err != nil {
+		return nil, fmt.Errorf("error unmarshalling value: %w", err)
+	}
+
+	deliveries := make([]webhook.Delivery, 0, len(items))
+	for _, item := range items {
+		d, err := item.delivery()
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
+
+// ClaimDelivery moves the next attempt of the delivery to until, unless it changed since it was returned by DueDeliveries.
+func (rs RestaurantStorage) ClaimDelivery(delivery webhook.Delivery, until time.Time) (bool, error) {
+	log.Printf("RestaurantStorage.ClaimDelivery tenantId: %s  webhookId: %s  eventId: %s\n", delivery.TenantId, delivery.WebhookId, delivery.Id)
+
+	if delivery.NextAttempt == nil {
+		return false, nil
+	}
+
+	expr, err := expression.NewBuilder().
+		WithUpdate(expression.Set(expression.Name(deliveryNextAttr), expression.Value(deliveryNextAttemptKey(until, delivery)))).
+		WithCondition(expression.Equal(expression.Name(deliveryNextAttr), expression.Value(deliveryNextAttemptKey(*delivery.NextAttempt, delivery)))).
+		Build()
+	if err != nil {
+		return false, err
+	}
+
+	input := dynamodb.UpdateItemInput{
+		Key:                       primaryKey(deliveriesPartitionKey(delivery.TenantId, delivery.WebhookId), deliverySortKey(delivery)),
+		TableName:                 aws.String(rs.Table),
+		UpdateExpression:          expr.Update(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ConditionExpression:       expr.Condition(),
+	}
+
+	_, err = rs.Client.UpdateItem(context.Background(), &input)
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error claiming delivery of event %q to webhook %q in dynamo: %w", delivery.Id, delivery.WebhookId, err)
+	}
+	return true, nil
+}
+
+// UpdateDelivery replaces the delivery. A delivery that is no longer pending leaves the
+// index, and expires after deliveryLogTTL.
+func (rs RestaurantStorage) UpdateDelivery(delivery webhook.Delivery) error {
+	log.Printf("RestaurantStorage.UpdateDelivery tenantId: %s  webhookId: %s  eventId: %s  status: %s\n", delivery.TenantId, delivery.WebhookId, delivery.Id, delivery.Status)
+
+	av, err := attributevalue.MarshalMap(newDeliveryItem(delivery))
+	if err != nil {
+		return fmt.Errorf("error marshalling value: %w", err)
+	}
+
+	expr, err := expression.NewBuilder().WithCondition(expression.AttributeExists(expression.Name(key))).Build()
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		Item:                     av,
+		TableName:                aws.String(rs.Table),
+		ConditionExpression:      expr.Condition(),
+		ExpressionAttributeNames: expr.Names(),
+	}
+
+	if _, err = rs.Client.PutItem(context.Background(), input); err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			err = storage.ErrNotFound
+		}
+		return fmt.Errorf("error updating delivery of event %q to webhook %q in dynamo: %w", delivery.Id, delivery.WebhookId, err)
+	}
+	return nil
+}
+
+// putNew stores the item unless an item with its key exists, which is a storage.ErrConflict.
+func (rs RestaurantStorage) putNew(item any) error {
+	av, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return fmt.Errorf("error marshalling value: %w", err)
+	}
+
+	expr, err := expression.NewBuilder().WithCondition(expression.AttributeNotExists(expression.Name(key))).Build()
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		Item:                     av,
+		TableName:                aws.String(rs.Table),
+		ConditionExpression:      expr.Condition(),
+		ExpressionAttributeNames: expr.Names(),
+	}
+
+	if _, err = rs.Client.PutItem(context.Background(), input); err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return storage.ErrConflict
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+// Package event defines the domain events of the restaurants and the publishers that send them.
+package event
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Source is the source of the events sent to EventBridge.
+const Source = "restaurant-serverless"
+
+type Type string
+
+const (
+	RestaurantCreated Type = "RestaurantCreated"
+	RestaurantUpdated Type = "RestaurantUpdated"
+	RestaurantDeleted Type = "RestaurantDeleted"
+)
+
+// Types are the types of all the events.
+var Types = []Type{RestaurantCreated, RestaurantUpdated, RestaurantDeleted}
+
+// Event is a change of a restaurant. Before is nil for RestaurantCreated, and After
+// is nil for RestaurantDeleted.
+type Event struct {
+	Id            string            `json:"id"`
+	Type          Type              `json:"type"`
+	TenantId      string            `json:"tenantId"`
+	RestaurantId  string            `json:"restaurantId"`
+	Time          time.Time         `json:"time"`
+	Version       int64             `json:"version,omitempty"`
+	Before        *model.Restaurant `json:"before,omitempty"`
+	After         *model.Restaurant `json:"after,omitempty"`
+	ChangedFields []string          `json:"changedFields"`
+}
+
+// New creates an event of the change from before to after of a restaurant of the tenant, with
+// the version of the restaurant after the change (0 when it is deleted).
+func New(tenantId string, t Type, before, after *model.Restaurant, version int64) Event {
+	e := Event{
+		Id:            uuid.NewString(),
+		Type:          t,
+		TenantId:      tenantId,
+		Time:          time.Now().UTC().Truncate(time.Millisecond),
+		Version:       version,
+		Before:        before,
+		After:         after,
+		ChangedFields: ChangedFields(before, after),
+	}
+	if after != nil && after.Id != nil {
+		e.RestaurantId = *after.Id
+	} else if before != nil && before.Id != nil {
+		e.RestaurantId = *before.Id
+	}
+	return e
+}
+
+// ChangedFields returns the sorted JSON names of the top level fields of the restaurant
+// that differ between before and after. A nil restaurant has no fields.
+func ChangedFields(before, after *model.Restaurant) []string {
+	b, a := fields(before), fields(after)
+
+	changed := []string{}
+	for name, v := range a {
+		if !reflect.DeepEqual(v, b[name]) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range b {
+		if _, ok := a[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func fields(restaurant *model.Restaurant) map[string]any {
+	m := map[string]any{}
+	if restaurant == nil {
+		return m
+	}
+	// A restaurant always marshals to a JSON object
+	b, _ := json.Marshal(restaurant)
+	_ = json.Unmarshal(b, &m)
+	return m
+}
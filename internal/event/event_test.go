@@ -0,0 +1,95 @@
+package event
+
+import (
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_ChangedFields(t *testing.T) {
+	t.Parallel()
+	id, city, otherCity, description := "restId", "Seattle", "Portland", "Thai"
+
+	testCases := []struct {
+		name    string
+		before  *model.Restaurant
+		after   *model.Restaurant
+		changed []string
+	}{
+		{
+			name:    "created",
+			after:   &model.Restaurant{Id: &id, Name: "name", Description: &description},
+			changed: []string{"description", "id", "name"},
+		},
+		{
+			name:    "deleted",
+			before:  &model.Restaurant{Id: &id, Name: "name"},
+			changed: []string{"id", "name"},
+		},
+		{
+			name:    "field changed",
+			before:  &model.Restaurant{Id: &id, Name: "name"},
+			after:   &model.Restaurant{Id: &id, Name: "new name"},
+			changed: []string{"name"},
+		},
+		{
+			name:    "field added and removed",
+			before:  &model.Restaurant{Id: &id, Name: "name", Description: &description},
+			after:   &model.Restaurant{Id: &id, Name: "name", Address: &model.Address{City: &city}},
+			changed: []string{"address", "description"},
+		},
+		{
+			name:    "nested field changed",
+			before:  &model.Restaurant{Id: &id, Name: "name", Address: &model.Address{City: &city}},
+			after:   &model.Restaurant{Id: &id, Name: "name", Address: &model.Address{City: &otherCity}},
+			changed: []string{"address"},
+		},
+		{
+			name:    "unchanged",
+			before:  &model.Restaurant{Id: &id, Name: "name"},
+			after:   &model.Restaurant{Id: &id, Name: "name"},
+			changed: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.changed, ChangedFields(tc.before, tc.after))
+		})
+	}
+}
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	id := "restId"
+	restaurant := model.Restaurant{Id: &id, Name: "name"}
+
+	created := New("tenant1", RestaurantCreated, nil, &restaurant, 1)
+	assert.NotEmpty(t, created.Id)
+	assert.Equal(t, RestaurantCreated, created.Type)
+	assert.Equal(t, "tenant1", created.TenantId)
+	assert.Equal(t, id, created.RestaurantId)
+	assert.Equal(t, int64(1), created.Version)
+	assert.False(t, created.Time.IsZero())
+
+	deleted := New("tenant1", RestaurantDeleted, &restaurant, nil, 0)
+	assert.NotEqual(t, created.Id, deleted.Id)
+	assert.Equal(t, id, deleted.RestaurantId)
+	assert.Nil(t, deleted.After)
+}
+
+func Test_MemoryPublisher(t *testing.T) {
+	t.Parallel()
+	id := "restId"
+	p := NewMemoryPublisher()
+
+	assert.Empty(t, p.Events())
+
+	e := New("tenant1", RestaurantCreated, nil, &model.Restaurant{Id: &id}, 1)
+	assert.NoError(t, p.Publish(e))
+	assert.Equal(t, []Event{e}, p.Events())
+}
@@ -0,0 +1,61 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"log"
+)
+
+type eventPutter interface {
+	PutEvents(ctx context.Context, params *eventbridge.PutEventsInput, optFns ...func(*eventbridge.Options)) (*eventbridge.PutEventsOutput, error)
+}
+
+// EventBridgePublisher sends the events to an EventBridge event bus, with the event
+// type as the detail type and the event as the detail.
+type EventBridgePublisher struct {
+	Client  eventPutter
+	BusName string
+}
+
+func NewEventBridgePublisher(cfg aws.Config, busName string) EventBridgePublisher {
+	return EventBridgePublisher{
+		Client:  eventbridge.NewFromConfig(cfg),
+		BusName: busName,
+	}
+}
+
+func (p EventBridgePublisher) Publish(e Event) error {
+	log.Printf("EventBridgePublisher.Publish type: %s  restaurantId: %s  eventId: %s\n", e.Type, e.RestaurantId, e.Id)
+
+	detail, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("error marshalling event: %w", err)
+	}
+
+	input := &eventbridge.PutEventsInput{
+		Entries: []types.PutEventsRequestEntry{{
+			EventBusName: aws.String(p.BusName),
+			Source:       aws.String(Source),
+			DetailType:   aws.String(string(e.Type)),
+			Detail:       aws.String(string(detail)),
+			Time:         aws.Time(e.Time),
+		}},
+	}
+
+	data, err := p.Client.PutEvents(context.Background(), input)
+	if err != nil {
+		return fmt.Errorf("error publishing event %q to %q: %w", e.Id, p.BusName, err)
+	}
+	if data.FailedEntryCount > 0 {
+		var code, message string
+		if len(data.Entries) > 0 {
+			code, message = aws.ToString(data.Entries[0].ErrorCode), aws.ToString(data.Entries[0].ErrorMessage)
+		}
+		return fmt.Errorf("error publishing event %q to %q: %s %s", e.Id, p.BusName, code, message)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/lfroomin/restaurant-serverless/internal/awsConfig"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_NewEventBridgePublisher(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := awsConfig.New()
+	require.NoError(t, err)
+
+	p := NewEventBridgePublisher(cfg, "bus")
+
+	assert.IsType(t, &eventbridge.Client{}, p.Client)
+	assert.Equal(t, "bus", p.BusName)
+}
+
+func Test_EventBridgePublish(t *testing.T) {
+	t.Parallel()
+	id := "restId"
+	e := New("tenant1", RestaurantUpdated, &model.Restaurant{Id: &id, Name: "old"}, &model.Restaurant{Id: &id, Name: "new"}, 2)
+
+	testCases := []struct {
+		name   string
+		stub   eventPutterStub
+		errMsg string
+	}{
+		{
+			name: "happy path",
+		},
+		{
+			name:   "failed entry",
+			stub:   eventPutterStub{failed: true},
+			errMsg: `error publishing event "` + e.Id + `" to "bus": ThrottlingException rate exceeded`,
+		},
+		{
+			name:   "client error",
+			stub:   eventPutterStub{error: "an error occurred"},
+			errMsg: `error publishing event "` + e.Id + `" to "bus": an error occurred`,
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			stub := tc.stub
+			stub.input = &eventbridge.PutEventsInput{}
+
+			err := EventBridgePublisher{Client: stub, BusName: "bus"}.Publish(e)
+
+			if tc.errMsg != "" {
+				assert.EqualError(t, err, tc.errMsg)
+				return
+			}
+			require.NoError(t, err)
+
+			require.Len(t, stub.input.Entries, 1)
+			entry := stub.input.Entries[0]
+			assert.Equal(t, "bus", *entry.EventBusName)
+			assert.Equal(t, Source, *entry.Source)
+			assert.Equal(t, "RestaurantUpdated", *entry.DetailType)
+
+			detail := Event{}
+			require.NoError(t, json.Unmarshal([]byte(*entry.Detail), &detail))
+			assert.Equal(t, e.Id, detail.Id)
+			assert.Equal(t, []string{"name"}, detail.ChangedFields)
+			assert.Equal(t, "old", detail.Before.Name)
+			assert.Equal(t, "new", detail.After.Name)
+		})
+	}
+}
+
+type eventPutterStub struct {
+	// input is set to the input of the last PutEvents call
+	input  *eventbridge.PutEventsInput
+	failed bool
+	error  string
+}
+
+func (s eventPutterStub) PutEvents(_ context.Context, input *eventbridge.PutEventsInput, _ ...func(*eventbridge.Options)) (*eventbridge.PutEventsOutput, error) {
+	if s.error != "" {
+		return nil, errors.New(s.error)
+	}
+	*s.input = *input
+	if s.failed {
+		return &eventbridge.PutEventsOutput{
+			FailedEntryCount: 1,
+			Entries:          []types.PutEventsResultEntry{{ErrorCode: aws.String("ThrottlingException"), ErrorMessage: aws.String("rate exceeded")}},
+		}, nil
+	}
+	return &eventbridge.PutEventsOutput{Entries: []types.PutEventsResultEntry{{EventId: aws.String("id")}}}, nil
+}
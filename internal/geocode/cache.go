@@ -0,0 +1,77 @@
+package geocode
+
+import (
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"log"
+	"strings"
+)
+
+type geocoder interface {
+	Geocode(address model.Address) (model.Location, string, error)
+	Candidates(address model.Address) ([]model.GeocodeCandidate, error)
+}
+
+// CacheEntry is the result of geocoding an address.
+type CacheEntry struct {
+	Location     model.Location
+	TimezoneName string
+}
+
+// Cache stores geocoding results by normalized address.
+type Cache interface {
+	Get(key string) (CacheEntry, bool, error)
+	Put(key string, entry CacheEntry) error
+}
+
+// CachingGeocoder is a Geocoder that looks up addresses in a cache before calling
+// the wrapped geocoder. CachingGeocoders can be nested to check a fast cache first.
+type CachingGeocoder struct {
+	Geocoder geocoder
+	Cache    Cache
+}
+
+func (cg CachingGeocoder) Geocode(address model.Address) (model.Location, string, error) {
+	key := NormalizeAddress(address)
+
+	// A failing cache must not fail the request, it only costs a geocoding call
+	entry, found, err := cg.Cache.Get(key)
+	if err != nil {
+		log.Printf("error getting geocode cache entry: %s\n", err.Error())
+	}
+	if found {
+		log.Printf("Geocode cache hit: %s\n", key)
+		return entry.Location, entry.TimezoneName, nil
+	}
+
+	loc, timezoneName, err := cg.Geocoder.Geocode(address)
+	if err != nil {
+		return model.Location{}, "", err
+	}
+
+	// Addresses that were not found are not cached, so a later call can find them
+	if loc.Geocode != nil {
+		if err = cg.Cache.Put(key, CacheEntry{Location: loc, TimezoneName: timezoneName}); err != nil {
+			log.Printf("error putting geocode cache entry: %s\n", err.Error())
+		}
+	}
+
+	return loc, timezoneName, nil
+}
+
+// Candidates is not cached, it is used to choose a location before saving a restaurant.
+func (cg CachingGeocoder) Candidates(address model.Address) ([]model.GeocodeCandidate, error) {
+	return cg.Geocoder.Candidates(address)
+}
+
+// NormalizeAddress returns the cache key of the address. Addresses that differ
+// only in case, whitespace or trailing commas have the same key.
+func NormalizeAddress(address model.Address) string {
+	fields := []*string{address.Line1, address.Line2, address.City, address.State, address.ZipCode, address.Country}
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		if f != nil {
+			parts[i] = strings.Trim(strings.Join(strings.Fields(strings.ToLower(*f)), " "), ",")
+		}
+	}
+	return strings.Join(parts, "|")
+}
@@ -0,0 +1,159 @@
+package geocode
+
+import (
+	"errors"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_CachingGeocoder(t *testing.T) {
+	t.Parallel()
+	line1, city := "123 Pike St", "Seattle"
+	geocode := "47.606200,-122.332100"
+	address := model.Address{Line1: &line1, City: &city}
+	key := NormalizeAddress(address)
+	cached := CacheEntry{Location: model.Location{Geocode: &geocode}, TimezoneName: "America/Los_Angeles"}
+
+	testCases := []struct {
+		name         string
+		cache        *cacheStub
+		geocoder     geocoderStub
+		loc          model.Location
+		timezoneName string
+		calls        int
+		cachedAfter  bool
+		errMsg       string
+	}{
+		{
+			name:         "cache hit",
+			cache:        &cacheStub{entries: map[string]CacheEntry{key: cached}},
+			loc:          cached.Location,
+			timezoneName: cached.TimezoneName,
+			cachedAfter:  true,
+		},
+		{
+			name:         "cache miss",
+			cache:        &cacheStub{entries: map[string]CacheEntry{}},
+			geocoder:     geocoderStub{geocode: &geocode},
+			loc:          cached.Location,
+			timezoneName: cached.TimezoneName,
+			calls:        1,
+			cachedAfter:  true,
+		},
+		{
+			name:     "address not found is not cached",
+			cache:    &cacheStub{entries: map[string]CacheEntry{}},
+			geocoder: geocoderStub{},
+			calls:    1,
+		},
+		{
+			name:     "geocoder error",
+			cache:    &cacheStub{entries: map[string]CacheEntry{}},
+			geocoder: geocoderStub{error: "an error occurred"},
+			calls:    1,
+			errMsg:   "an error occurred",
+		},
+		{
+			name:         "cache error",
+			cache:        &cacheStub{entries: map[string]CacheEntry{}, error: "cache unavailable"},
+			geocoder:     geocoderStub{geocode: &geocode},
+			loc:          cached.Location,
+			timezoneName: cached.TimezoneName,
+			calls:        1,
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			calls := 0
+			tc.geocoder.calls = &calls
+			cg := CachingGeocoder{Geocoder: tc.geocoder, Cache: tc.cache}
+
+			loc, timezoneName, err := cg.Geocode(address)
+
+			if tc.errMsg != "" {
+				assert.EqualError(t, err, tc.errMsg)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.loc, loc)
+			assert.Equal(t, tc.timezoneName, timezoneName)
+			assert.Equal(t, tc.calls, calls)
+			_, found := tc.cache.entries[key]
+			assert.Equal(t, tc.cachedAfter, found)
+		})
+	}
+}
+
+func Test_NormalizeAddress(t *testing.T) {
+	t.Parallel()
+	a := func(s string) *string { return &s }
+
+	key := NormalizeAddress(model.Address{Line1: a("123  Pike St,"), City: a(" SEATTLE "), Country: a("USA")})
+
+	assert.Equal(t, "123 pike st||seattle|||usa", key)
+	assert.Equal(t, key, NormalizeAddress(model.Address{Line1: a("123 pike st"), City: a("Seattle"), Country: a("usa")}))
+	assert.NotEqual(t, key, NormalizeAddress(model.Address{Line2: a("123 pike st"), City: a("Seattle"), Country: a("usa")}))
+}
+
+type cacheStub struct {
+	entries map[string]CacheEntry
+	error   string
+}
+
+func (s *cacheStub) Get(key string) (CacheEntry, bool, error) {
+	if s.error != "" {
+		return CacheEntry{}, false, errors.New(s.error)
+	}
+	entry, found := s.entries[key]
+	return entry, found, nil
+}
+
+func (s *cacheStub) Put(key string, entry CacheEntry) error {
+	if s.error != "" {
+		return errors.New(s.error)
+	}
+	s.entries[key] = entry
+	return nil
+}
+
+type geocoderStub struct {
+	geocode *string
+	error   string
+	calls   *int
+}
+
+func (s geocoderStub) Geocode(_ model.Address) (model.Location, string, error) {
+	*s.calls++
+	if s.error != "" {
+		return model.Location{}, "", errors.New(s.error)
+	}
+	if s.geocode == nil {
+		return model.Location{}, "", nil
+	}
+	return model.Location{Geocode: s.geocode}, "America/Los_Angeles", nil
+}
+
+func (s geocoderStub) Candidates(_ model.Address) ([]model.GeocodeCandidate, error) {
+	*s.calls++
+	return []model.GeocodeCandidate{}, nil
+}
+
+func Test_CachingGeocoderCandidates(t *testing.T) {
+	t.Parallel()
+	calls := 0
+	cache := &cacheStub{entries: map[string]CacheEntry{}}
+	cg := CachingGeocoder{Geocoder: geocoderStub{calls: &calls}, Cache: cache}
+
+	_, err := cg.Candidates(model.Address{})
+	assert.NoError(t, err)
+	_, err = cg.Candidates(model.Address{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, calls)
+	assert.Empty(t, cache.entries)
+}
@@ -3,10 +3,10 @@ package geocode
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/location"
 	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/timezone"
 	"log"
 	"strings"
 )
@@ -27,7 +27,23 @@ func New(cfg aws.Config, placeIndex string) LocationService {
 	}
 }
 
+// Geocode returns the location of the best match for the address, and its timezone.
+// The location is empty when the address is not found.
 func (ls LocationService) Geocode(address model.Address) (model.Location, string, error) {
+	candidates, err := ls.Candidates(address)
+	if err != nil || len(candidates) == 0 {
+		return model.Location{}, "", err
+	}
+
+	var timezoneName string
+	if candidates[0].TimezoneName != nil {
+		timezoneName = *candidates[0].TimezoneName
+	}
+	return candidates[0].Location, timezoneName, nil
+}
+
+// Candidates returns the locations matching the address, best match first.
+func (ls LocationService) Candidates(address model.Address) ([]model.GeocodeCandidate, error) {
 
 	text := join(address.Line1, address.Line2, address.City, address.State, address.ZipCode, address.Country)
 
@@ -41,31 +57,41 @@ func (ls LocationService) Geocode(address model.Address) (model.Location, string
 
 	data, err := ls.Client.SearchPlaceIndexForText(context.Background(), input)
 	if err != nil {
-		return model.Location{}, "", err
+		return nil, err
 	}
 
 	d, _ := json.Marshal(data)
 	log.Printf("Location output: %s\n", d)
 
-	loc := model.Location{}
-	var timezoneName string
-	if data != nil && len(data.Results) > 0 {
-		place := data.Results[0].Place
-		geocode := fmt.Sprintf("%f,%f", place.Geometry.Point[1], place.Geometry.Point[0])
-		loc = model.Location{
-			Geocode:       &geocode,
-			AddressNumber: place.AddressNumber,
-			Street:        place.Street,
-			Municipality:  place.Municipality,
-			PostalCode:    place.PostalCode,
-			Region:        place.Region,
-			SubRegion:     place.SubRegion,
-			Country:       place.Country,
+	candidates := []model.GeocodeCandidate{}
+	if data == nil {
+		return candidates, nil
+	}
+
+	for _, result := range data.Results {
+		place := result.Place
+		// Point has the format lon,lat
+		loc := model.NewLocation(place.Geometry.Point[1], place.Geometry.Point[0])
+		loc.AddressNumber = place.AddressNumber
+		loc.Street = place.Street
+		loc.Municipality = place.Municipality
+		loc.PostalCode = place.PostalCode
+		loc.Region = place.Region
+		loc.SubRegion = place.SubRegion
+		loc.Country = place.Country
+		loc.Relevance = result.Relevance
+
+		// The place index omits the time zone of some places
+		var name *string
+		if place.TimeZone != nil {
+			name = place.TimeZone.Name
 		}
-		timezoneName = *place.TimeZone.Name
+		timezoneName := timezone.Resolve(name, *loc.Latitude, *loc.Longitude)
+
+		candidates = append(candidates, model.GeocodeCandidate{Location: loc, TimezoneName: &timezoneName})
 	}
 
-	return loc, timezoneName, nil
+	return candidates, nil
 }
 
 func join(strs ...*string) string {
@@ -30,10 +30,16 @@ func Test_Geocode(t *testing.T) {
 		Country: &country,
 	}
 
-	geocode := "123.000000,456.000000"
+	geocode := "47.606200,-122.332100"
+	lat, lon, relevance := 47.6062, -122.3321, 0.9
+	timezone := "America/Vancouver"
 	subRegion := "sub" + state
 	locationExp := model.Location{
 		Geocode:       &geocode,
+		Latitude:      &lat,
+		Longitude:     &lon,
+		Point:         &model.GeoJsonPoint{Type: "Point", Coordinates: []float64{lon, lat}},
+		Relevance:     &relevance,
 		AddressNumber: &addressNumber,
 		Street:        &street,
 		Municipality:  &city,
@@ -68,7 +74,7 @@ func Test_Geocode(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 			lc := LocationService{
-				Client:     placeSearcherStub{error: tc.stubError},
+				Client:     placeSearcherStub{timezone: &timezone, error: tc.stubError},
 				PlaceIndex: "",
 			}
 			loc, timezoneName, err := lc.Geocode(tc.address)
@@ -80,14 +86,18 @@ func Test_Geocode(t *testing.T) {
 			} else {
 				assert.Nil(t, err)
 				assert.Equal(t, tc.loc, loc)
-				assert.NotNil(t, timezoneName)
+				assert.Equal(t, "America/Vancouver", timezoneName)
 			}
 		})
 	}
 }
 
 type placeSearcherStub struct {
-	error string
+	// results is the number of results, 0 for one result and less than 0 for no results
+	results int
+	// timezone is the name of the time zone of the places, nil when the places have none
+	timezone *string
+	error    string
 }
 
 func (s placeSearcherStub) SearchPlaceIndexForText(_ context.Context, input *location.SearchPlaceIndexForTextInput, _ ...func(*location.Options)) (*location.SearchPlaceIndexForTextOutput, error) {
@@ -98,9 +108,7 @@ func (s placeSearcherStub) SearchPlaceIndexForText(_ context.Context, input *loc
 	// This depends on input.Text that looks like "123 street line2 city state zip country"
 	inputText := strings.Split(*input.Text, " ")
 
-	geometry := types.PlaceGeometry{Point: []float64{456, 123}}
-	timezoneStr := "timezone"
-	timezone := types.TimeZone{Name: &timezoneStr, Offset: new(int32)}
+	geometry := types.PlaceGeometry{Point: []float64{-122.3321, 47.6062}}
 	subRegion := "sub" + inputText[4]
 	place := types.Place{
 		Geometry:      &geometry,
@@ -111,8 +119,115 @@ func (s placeSearcherStub) SearchPlaceIndexForText(_ context.Context, input *loc
 		Region:        &inputText[4],
 		SubRegion:     &subRegion,
 		Country:       &inputText[6],
-		TimeZone:      &timezone,
 	}
+	if s.timezone != nil {
+		place.TimeZone = &types.TimeZone{Name: s.timezone, Offset: new(int32)}
+	}
+
+	relevance := 0.9
+	if s.results > 0 {
+		results := []types.SearchForTextResult{}
+		for i := 0; i < s.results; i++ {
+			r := relevance / float64(i+1)
+			results = append(results, types.SearchForTextResult{Place: &place, Relevance: &r})
+		}
+		return &location.SearchPlaceIndexForTextOutput{Results: results}, nil
+	}
+	if s.results < 0 {
+		return &location.SearchPlaceIndexForTextOutput{}, nil
+	}
+
+	return &location.SearchPlaceIndexForTextOutput{Results: []types.SearchForTextResult{{Place: &place, Relevance: &relevance}}}, nil
+}
+
+func Test_Candidates(t *testing.T) {
+	t.Parallel()
+	line1, city := "123 street", "city"
+	address := model.Address{Line1: &line1, Line2: &line1, City: &city, State: &city, ZipCode: &city, Country: &city}
+	vancouver, invalid := "America/Vancouver", "PST"
+
+	testCases := []struct {
+		name       string
+		results    int
+		timezone   *string
+		relevances []float64
+		// timezoneName is the expected time zone of the candidates
+		timezoneName string
+	}{
+		{
+			name:         "several candidates",
+			results:      3,
+			timezone:     &vancouver,
+			relevances:   []float64{0.9, 0.45, 0.3},
+			timezoneName: vancouver,
+		},
+		{
+			name:         "no time zone",
+			relevances:   []float64{0.9},
+			timezoneName: "America/Los_Angeles",
+		},
+		{
+			name:         "invalid time zone",
+			timezone:     &invalid,
+			relevances:   []float64{0.9},
+			timezoneName: "America/Los_Angeles",
+		},
+		{
+			name:       "not found",
+			results:    -1,
+			relevances: []float64{},
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ls := LocationService{Client: placeSearcherStub{results: tc.results, timezone: tc.timezone}}
+
+			candidates, err := ls.Candidates(address)
+
+			assert.NoError(t, err)
+			relevances := []float64{}
+			for _, c := range candidates {
+				relevances = append(relevances, *c.Location.Relevance)
+				assert.Equal(t, tc.timezoneName, *c.TimezoneName)
+			}
+			assert.InDeltaSlice(t, tc.relevances, relevances, 0.0001)
+
+			// Geocode returns the best candidate
+			loc, _, err := ls.Geocode(address)
+			assert.NoError(t, err)
+			if len(candidates) > 0 {
+				assert.Equal(t, candidates[0].Location, loc)
+			} else {
+				assert.Equal(t, model.Location{}, loc)
+			}
+		})
+	}
+}
+
+func Test_StubGeocode(t *testing.T) {
+	t.Parallel()
+	line1 := "123 street"
+	city := "city"
+	other := "456 street"
+
+	loc, timezoneName, err := Stub{}.Geocode(model.Address{Line1: &line1, City: &city})
+	assert.NoError(t, err)
+	assert.Equal(t, stubTimezone, timezoneName)
+	assert.Equal(t, &line1, loc.Street)
+	assert.Equal(t, &city, loc.Municipality)
+
+	lat, lon, ok := model.Restaurant{Address: &model.Address{Location: &loc}}.Coordinates()
+	assert.True(t, ok)
+	assert.InDelta(t, stubLat, lat, 0.05)
+	assert.InDelta(t, stubLon, lon, 0.05)
+
+	again, _, _ := Stub{}.Geocode(model.Address{Line1: &line1, City: &city})
+	assert.Equal(t, loc.Geocode, again.Geocode)
 
-	return &location.SearchPlaceIndexForTextOutput{Results: []types.SearchForTextResult{{Place: &place}}}, nil
+	different, _, _ := Stub{}.Geocode(model.Address{Line1: &other, City: &city})
+	assert.NotEqual(t, loc.Geocode, different.Geocode)
 }
@@ -0,0 +1,59 @@
+package geocode
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LRUCache is an in-memory Cache holding up to capacity entries. It lives as
+// long as the Lambda execution environment, so it only helps warm invocations.
+type LRUCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+type lruEntry struct {
+	key   string
+	entry CacheEntry
+}
+
+func NewLRUCache(capacity int) *LRUCache {
+	return &LRUCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+	}
+}
+
+func (c *LRUCache) Get(key string) (CacheEntry, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, found := c.entries[key]
+	if !found {
+		return CacheEntry{}, false, nil
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(lruEntry).entry, true, nil
+}
+
+func (c *LRUCache) Put(key string, entry CacheEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, found := c.entries[key]; found {
+		e.Value = lruEntry{key: key, entry: entry}
+		c.order.MoveToFront(e)
+		return nil
+	}
+
+	c.entries[key] = c.order.PushFront(lruEntry{key: key, entry: entry})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(lruEntry).key)
+	}
+	return nil
+}
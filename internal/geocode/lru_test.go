@@ -0,0 +1,38 @@
+package geocode
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_LRUCache(t *testing.T) {
+	t.Parallel()
+	c := NewLRUCache(2)
+
+	_, found, err := c.Get("a")
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	assert.NoError(t, c.Put("a", CacheEntry{TimezoneName: "a"}))
+	assert.NoError(t, c.Put("b", CacheEntry{TimezoneName: "b"}))
+
+	// Reading a makes b the least recently used entry
+	entry, found, _ := c.Get("a")
+	assert.True(t, found)
+	assert.Equal(t, "a", entry.TimezoneName)
+
+	assert.NoError(t, c.Put("c", CacheEntry{TimezoneName: "c"}))
+	_, found, _ = c.Get("b")
+	assert.False(t, found)
+	_, found, _ = c.Get("a")
+	assert.True(t, found)
+	_, found, _ = c.Get("c")
+	assert.True(t, found)
+
+	// Replacing an entry does not evict anything
+	assert.NoError(t, c.Put("c", CacheEntry{TimezoneName: "c2"}))
+	entry, _, _ = c.Get("c")
+	assert.Equal(t, "c2", entry.TimezoneName)
+	_, found, _ = c.Get("a")
+	assert.True(t, found)
+}
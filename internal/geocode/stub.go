@@ -0,0 +1,53 @@
+package geocode
+
+import (
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"hash/fnv"
+	"log"
+)
+
+const (
+	stubLat      = 47.6062
+	stubLon      = -122.3321
+	stubTimezone = "America/Los_Angeles"
+)
+
+var stubRelevance = 1.0
+
+// Stub geocodes addresses without calling the AWS Location service, so the API
+// can run offline. Every address is placed within about 5km of downtown Seattle,
+// and the same address always has the same coordinates.
+type Stub struct{}
+
+func (Stub) Geocode(address model.Address) (model.Location, string, error) {
+	text := join(address.Line1, address.Line2, address.City, address.State, address.ZipCode, address.Country)
+
+	log.Printf("Stub geocode address: %s\n", text)
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(text))
+	sum := h.Sum32()
+
+	// Offsets between -0.05 and 0.05 degrees
+	lat := stubLat + float64(sum&0xffff)/0xffff*0.1 - 0.05
+	lon := stubLon + float64(sum>>16)/0xffff*0.1 - 0.05
+	loc := model.NewLocation(lat, lon)
+	loc.Street = address.Line1
+	loc.Municipality = address.City
+	loc.Region = address.State
+	loc.PostalCode = address.ZipCode
+	loc.Country = address.Country
+
+	loc.Relevance = &stubRelevance
+
+	return loc, stubTimezone, nil
+}
+
+// Candidates returns the only location the stub finds for an address.
+func (s Stub) Candidates(address model.Address) ([]model.GeocodeCandidate, error) {
+	loc, timezoneName, err := s.Geocode(address)
+	if err != nil {
+		return nil, err
+	}
+	return []model.GeocodeCandidate{{Location: loc, TimezoneName: &timezoneName}}, nil
+}
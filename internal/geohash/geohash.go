@@ -0,0 +1,173 @@
+package geohash
+
+import (
+	"math"
+	"strings"
+)
+
+const (
+	base32        = "0123456789bcdefghjkmnpqrstuvwxyz"
+	earthRadiusKm = 6371.0088
+	kmPerDegree   = math.Pi * earthRadiusKm / 180
+	MaxPrecision  = 12
+)
+
+// Box is the area covered by a geohash cell.
+type Box struct {
+	MinLat, MaxLat float64
+	MinLon, MaxLon float64
+}
+
+func (b Box) Center() (float64, float64) {
+	return (b.MinLat + b.MaxLat) / 2, (b.MinLon + b.MaxLon) / 2
+}
+
+// Encode returns the geohash of the coordinates with the given number of characters.
+func Encode(lat, lon float64, precision int) string {
+	minLat, maxLat := -90.0, 90.0
+	minLon, maxLon := -180.0, 180.0
+
+	var sb strings.Builder
+	bit, ch, even := 0, 0, true
+	for sb.Len() < precision {
+		if even {
+			mid := (minLon + maxLon) / 2
+			if lon >= mid {
+				ch = ch<<1 | 1
+				minLon = mid
+			} else {
+				ch = ch << 1
+				maxLon = mid
+			}
+		} else {
+			mid := (minLat + maxLat) / 2
+			if lat >= mid {
+				ch = ch<<1 | 1
+				minLat = mid
+			} else {
+				ch = ch << 1
+				maxLat = mid
+			}
+		}
+		even = !even
+
+		if bit++; bit == 5 {
+			sb.WriteByte(base32[ch])
+			bit, ch = 0, 0
+		}
+	}
+
+	return sb.String()
+}
+
+// Decode returns the cell covered by the geohash. ok is false if the hash contains an invalid character.
+func Decode(hash string) (box Box, ok bool) {
+	box = Box{MinLat: -90, MaxLat: 90, MinLon: -180, MaxLon: 180}
+
+	even := true
+	for _, c := range hash {
+		idx := strings.IndexRune(base32, c)
+		if idx < 0 {
+			return Box{}, false
+		}
+		for n := 4; n >= 0; n-- {
+			bit := idx >> n & 1
+			if even {
+				mid := (box.MinLon + box.MaxLon) / 2
+				if bit == 1 {
+					box.MinLon = mid
+				} else {
+					box.MaxLon = mid
+				}
+			} else {
+				mid := (box.MinLat + box.MaxLat) / 2
+				if bit == 1 {
+					box.MinLat = mid
+				} else {
+					box.MaxLat = mid
+				}
+			}
+			even = !even
+		}
+	}
+
+	return box, true
+}
+
+// Neighbors returns the geohash followed by the (up to) eight cells surrounding it.
+// Longitude wraps around the antimeridian; there are no cells beyond the poles.
+func Neighbors(hash string) []string {
+	box, ok := Decode(hash)
+	if !ok {
+		return nil
+	}
+
+	lat, lon := box.Center()
+	latStep, lonStep := box.MaxLat-box.MinLat, box.MaxLon-box.MinLon
+
+	cells := []string{hash}
+	seen := map[string]bool{hash: true}
+	for _, dLat := range []float64{-1, 0, 1} {
+		for _, dLon := range []float64{-1, 0, 1} {
+			nLat := lat + dLat*latStep
+			if nLat < -90 || nLat > 90 {
+				continue
+			}
+			nLon := lon + dLon*lonStep
+			if nLon < -180 {
+				nLon += 360
+			} else if nLon >= 180 {
+				nLon -= 360
+			}
+
+			cell := Encode(nLat, nLon, len(hash))
+			if !seen[cell] {
+				seen[cell] = true
+				cells = append(cells, cell)
+			}
+		}
+	}
+
+	return cells
+}
+
+// CellSizeDegrees returns the height (latitude) and width (longitude) in degrees
+// of the cells with the given number of characters.
+func CellSizeDegrees(precision int) (float64, float64) {
+	bits := 5 * precision
+	lonBits := (bits + 1) / 2
+	latBits := bits / 2
+	return 180 / math.Pow(2, float64(latBits)), 360 / math.Pow(2, float64(lonBits))
+}
+
+// PrecisionForRadius returns the longest geohash length whose cells are at least
+// radiusKm high and wide everywhere within radiusKm of lat, so that a cell and its
+// neighbors cover the whole circle. It returns 0 if even a single character cell
+// is too small.
+func PrecisionForRadius(lat, radiusKm float64) int {
+	// The width of a cell shrinks towards the poles, so use the latitude of the
+	// circle that is furthest from the equator.
+	maxLat := math.Min(math.Abs(lat)+radiusKm/kmPerDegree, 90)
+	cosLat := math.Cos(maxLat * math.Pi / 180)
+
+	for p := MaxPrecision; p > 0; p-- {
+		latDeg, lonDeg := CellSizeDegrees(p)
+		if latDeg*kmPerDegree >= radiusKm && lonDeg*kmPerDegree*cosLat >= radiusKm {
+			return p
+		}
+	}
+
+	return 0
+}
+
+// DistanceKm returns the great-circle (haversine) distance between two coordinates.
+func DistanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := math.Pi / 180
+	dLat := (lat2 - lat1) * toRad
+	dLon := (lon2 - lon1) * toRad
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
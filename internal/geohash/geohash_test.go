@@ -0,0 +1,178 @@
+package geohash
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_Encode(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		lat       float64
+		lon       float64
+		precision int
+		hash      string
+	}{
+		{
+			name:      "jutland",
+			lat:       57.64911,
+			lon:       10.40744,
+			precision: 11,
+			hash:      "u4pruydqqvj",
+		},
+		{
+			name:      "seattle",
+			lat:       47.6062,
+			lon:       -122.3321,
+			precision: 6,
+			hash:      "c23nb6",
+		},
+		{
+			name:      "south west corner",
+			lat:       -90,
+			lon:       -180,
+			precision: 3,
+			hash:      "000",
+		},
+		{
+			name:      "north east corner",
+			lat:       90,
+			lon:       180,
+			precision: 3,
+			hash:      "zzz",
+		},
+		{
+			name:      "zero precision",
+			lat:       12,
+			lon:       34,
+			precision: 0,
+			hash:      "",
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.hash, Encode(tc.lat, tc.lon, tc.precision))
+		})
+	}
+}
+
+func Test_Decode(t *testing.T) {
+	t.Parallel()
+
+	box, ok := Decode("u4pruydqqvj")
+	assert.True(t, ok)
+	lat, lon := box.Center()
+	assert.InDelta(t, 57.64911, lat, 0.0001)
+	assert.InDelta(t, 10.40744, lon, 0.0001)
+	assert.Equal(t, "u4pruydqqvj", Encode(lat, lon, 11))
+
+	_, ok = Decode("abc")
+	assert.False(t, ok)
+}
+
+func Test_Neighbors(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name  string
+		hash  string
+		cells []string
+	}{
+		{
+			name:  "interior cell",
+			hash:  "ezs42",
+			cells: []string{"ezs42", "ezefp", "ezs40", "ezs41", "ezefr", "ezs43", "ezefx", "ezs48", "ezs49"},
+		},
+		{
+			name:  "cell on the antimeridian",
+			hash:  "2",
+			cells: []string{"2", "0", "1", "3", "r", "p", "x", "8", "9"},
+		},
+		{
+			name:  "cell on the north pole",
+			hash:  "b",
+			cells: []string{"b", "z", "8", "9", "c", "x"},
+		},
+		{
+			name: "invalid hash",
+			hash: "ai",
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.ElementsMatch(t, tc.cells, Neighbors(tc.hash))
+		})
+	}
+}
+
+func Test_PrecisionForRadius(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		lat       float64
+		radiusKm  float64
+		precision int
+	}{
+		{
+			name:      "one kilometer",
+			lat:       47.6,
+			radiusKm:  1,
+			precision: 5,
+		},
+		{
+			name:      "ten kilometers",
+			lat:       47.6,
+			radiusKm:  10,
+			precision: 4,
+		},
+		{
+			name:      "fifty kilometers",
+			lat:       47.6,
+			radiusKm:  50,
+			precision: 3,
+		},
+		{
+			name:      "tiny radius",
+			lat:       0,
+			radiusKm:  0.0001,
+			precision: 11,
+		},
+		{
+			name:      "larger than the earth",
+			lat:       0,
+			radiusKm:  25000,
+			precision: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.precision, PrecisionForRadius(tc.lat, tc.radiusKm))
+		})
+	}
+}
+
+func Test_DistanceKm(t *testing.T) {
+	t.Parallel()
+
+	// Seattle to Portland
+	assert.InDelta(t, 233.9, DistanceKm(47.6062, -122.3321, 45.5152, -122.6784), 0.5)
+	assert.Equal(t, 0.0, DistanceKm(10, 20, 10, 20))
+}
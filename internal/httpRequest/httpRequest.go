@@ -0,0 +1,144 @@
+// Package httpRequest reads the headers and decodes the body of the API Gateway proxy
+// requests, mapping each kind of bad body to the same 4xx response for all the endpoints.
+package httpRequest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+const (
+	JSONContentType = "application/json"
+
+	// MaxBodySize is the size in bytes of the largest body that is decoded
+	MaxBodySize = 256 << 10
+)
+
+// Header returns the value of the request header, ignoring the case of the name.
+func Header(request events.APIGatewayProxyRequest, name string) string {
+	for k, v := range request.Headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// MediaType returns the media type of the Content-Type header, in lower case and without
+// its parameters such as the charset, or "" when the request has no Content-Type.
+func MediaType(request events.APIGatewayProxyRequest) string {
+	mediaType, _, _ := strings.Cut(Header(request, "Content-Type"), ";")
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+// Body returns the body of the request, decoded from base64 when API Gateway encoded it.
+// It returns the error response when the body is empty or larger than MaxBodySize.
+func Body(request events.APIGatewayProxyRequest) ([]byte, *events.APIGatewayProxyResponse) {
+	body := []byte(request.Body)
+	if request.IsBase64Encoded {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(request.Body); err != nil {
+			return nil, httpResponse.NewProblem(http.StatusBadRequest, httpResponse.CodeMalformedBody, "the request body is not valid base64")
+		}
+	}
+
+	if len(body) == 0 {
+		return nil, httpResponse.NewProblem(http.StatusBadRequest, httpResponse.CodeMalformedBody, "the request body is empty")
+	}
+	if len(body) > MaxBodySize {
+		return nil, httpResponse.NewProblem(http.StatusRequestEntityTooLarge, httpResponse.CodeBodyTooLarge,
+			fmt.Sprintf("the request body is larger than %d bytes", MaxBodySize))
+	}
+	return body, nil
+}
+
+// DecodeJSON decodes the body of the request into v, like Unmarshal. The Content-Type of
+// the request must be application/json, or absent.
+func DecodeJSON(request events.APIGatewayProxyRequest, v any) *events.APIGatewayProxyResponse {
+	if mediaType := MediaType(request); mediaType != "" && mediaType != JSONContentType {
+		return httpResponse.NewProblem(http.StatusUnsupportedMediaType, httpResponse.CodeUnsupportedMediaType,
+			fmt.Sprintf("Content-Type must be %s", JSONContentType))
+	}
+
+	body, resp := Body(request)
+	if resp != nil {
+		return resp
+	}
+	return Unmarshal(body, v)
+}
+
+// Unmarshal decodes the JSON document of a request body into v. It returns the error
+// response when the body is not a single JSON document, has a value of the wrong type
+// or a field that v does not have.
+func Unmarshal(body []byte, v any) *events.APIGatewayProxyResponse {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return decodeError(err)
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return httpResponse.NewProblem(http.StatusBadRequest, httpResponse.CodeMalformedBody,
+			fmt.Sprintf("the request body has more data after the JSON document, at byte %d", decoder.InputOffset()))
+	}
+	return nil
+}
+
+func decodeError(err error) *events.APIGatewayProxyResponse {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		return httpResponse.NewProblem(http.StatusBadRequest, httpResponse.CodeMalformedBody,
+			fmt.Sprintf("the request body is not valid JSON at byte %d: %s", syntaxErr.Offset, syntaxErr.Error()))
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return httpResponse.NewProblem(http.StatusBadRequest, httpResponse.CodeMalformedBody, "the request body is not valid JSON: it ends unexpectedly")
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return httpResponse.NewProblem(http.StatusBadRequest, httpResponse.CodeTypeMismatch,
+				fmt.Sprintf("the request body must be %s, not %s", jsonType(typeErr.Type), typeErr.Value))
+		}
+		return httpResponse.NewProblem(http.StatusBadRequest, httpResponse.CodeTypeMismatch,
+			fmt.Sprintf("field %q must be %s, not %s, at byte %d", typeErr.Field, jsonType(typeErr.Type), typeErr.Value, typeErr.Offset))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// The error of DisallowUnknownFields has no type
+		return httpResponse.NewProblem(http.StatusBadRequest, httpResponse.CodeUnknownField,
+			fmt.Sprintf("the request body has an %s", strings.TrimPrefix(err.Error(), "json: ")))
+	default:
+		// Such as a date-time that is not RFC 3339
+		return httpResponse.NewBadRequest(fmt.Sprintf("the request body is invalid: %s", err.Error()))
+	}
+}
+
+// jsonType returns the JSON type that is decoded into the Go type t.
+func jsonType(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
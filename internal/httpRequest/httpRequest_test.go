@@ -0,0 +1,191 @@
+package httpRequest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func Test_DecodeJSON(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		contentType  string
+		body         string
+		base64       bool
+		responseCode int
+		code         httpResponse.Code
+		detail       string
+	}{
+		{
+			name:        "happy path",
+			contentType: "application/json",
+			body:        `{"name":"name","address":{"city":"Seattle"}}`,
+		},
+		{
+			name:        "content type with charset",
+			contentType: "Application/JSON; charset=utf-8",
+			body:        `{"name":"name"}`,
+		},
+		{
+			name: "no content type",
+			body: `{"name":"name"}`,
+		},
+		{
+			name:   "base64 body",
+			body:   base64.StdEncoding.EncodeToString([]byte(`{"name":"name"}`)),
+			base64: true,
+		},
+		{
+			name:         "wrong content type",
+			contentType:  "text/plain",
+			body:         `{"name":"name"}`,
+			responseCode: http.StatusUnsupportedMediaType,
+			code:         httpResponse.CodeUnsupportedMediaType,
+			detail:       "Content-Type must be application/json",
+		},
+		{
+			name:         "empty body",
+			responseCode: http.StatusBadRequest,
+			code:         httpResponse.CodeMalformedBody,
+			detail:       "the request body is empty",
+		},
+		{
+			name:         "invalid base64",
+			body:         "{not base64}",
+			base64:       true,
+			responseCode: http.StatusBadRequest,
+			code:         httpResponse.CodeMalformedBody,
+			detail:       "the request body is not valid base64",
+		},
+		{
+			name:         "body too large",
+			body:         `{"name":"` + strings.Repeat("a", MaxBodySize) + `"}`,
+			responseCode: http.StatusRequestEntityTooLarge,
+			code:         httpResponse.CodeBodyTooLarge,
+			detail:       "the request body is larger than 262144 bytes",
+		},
+		{
+			name:         "syntax error",
+			body:         `{"name":"name",}`,
+			responseCode: http.StatusBadRequest,
+			code:         httpResponse.CodeMalformedBody,
+			detail:       "the request body is not valid JSON at byte 16: invalid character '}' looking for beginning of object key string",
+		},
+		{
+			name:         "truncated",
+			body:         `{"name":"name"`,
+			responseCode: http.StatusBadRequest,
+			code:         httpResponse.CodeMalformedBody,
+			detail:       "the request body is not valid JSON: it ends unexpectedly",
+		},
+		{
+			name:         "trailing data",
+			body:         `{"name":"name"} {}`,
+			responseCode: http.StatusBadRequest,
+			code:         httpResponse.CodeMalformedBody,
+			detail:       "the request body has more data after the JSON document, at byte 17",
+		},
+		{
+			name:         "type mismatch",
+			body:         `{"name":1}`,
+			responseCode: http.StatusBadRequest,
+			code:         httpResponse.CodeTypeMismatch,
+			detail:       `field "name" must be a string, not number, at byte 9`,
+		},
+		{
+			name:         "nested type mismatch",
+			body:         `{"name":"name","address":{"city":true}}`,
+			responseCode: http.StatusBadRequest,
+			code:         httpResponse.CodeTypeMismatch,
+			detail:       `field "address.city" must be a string, not bool, at byte 37`,
+		},
+		{
+			name:         "not an object",
+			body:         `["name"]`,
+			responseCode: http.StatusBadRequest,
+			code:         httpResponse.CodeTypeMismatch,
+			detail:       "the request body must be an object, not array",
+		},
+		{
+			name:         "unknown field",
+			body:         `{"name":"name","cuisine":"thai"}`,
+			responseCode: http.StatusBadRequest,
+			code:         httpResponse.CodeUnknownField,
+			detail:       `the request body has an unknown field "cuisine"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			request := events.APIGatewayProxyRequest{
+				Headers:         map[string]string{},
+				Body:            tc.body,
+				IsBase64Encoded: tc.base64,
+			}
+			if tc.contentType != "" {
+				request.Headers["content-type"] = tc.contentType
+			}
+
+			restaurant := model.Restaurant{}
+			resp := DecodeJSON(request, &restaurant)
+
+			if tc.responseCode == 0 {
+				require.Nil(t, resp)
+				assert.Equal(t, "name", restaurant.Name)
+				return
+			}
+			require.NotNil(t, resp)
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			problem := model.Problem{}
+			require.NoError(t, json.Unmarshal([]byte(resp.Body), &problem))
+			assert.Equal(t, string(tc.code), problem.Code)
+			require.NotNil(t, problem.Detail)
+			assert.Equal(t, tc.detail, *problem.Detail)
+		})
+	}
+}
+
+func Test_MediaType(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		headers   map[string]string
+		mediaType string
+	}{
+		{
+			name:      "plain",
+			headers:   map[string]string{"Content-Type": "application/merge-patch+json"},
+			mediaType: "application/merge-patch+json",
+		},
+		{
+			name:      "parameters and case",
+			headers:   map[string]string{"CONTENT-TYPE": " Application/JSON ; charset=utf-8"},
+			mediaType: "application/json",
+		},
+		{
+			name: "absent",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.mediaType, MediaType(events.APIGatewayProxyRequest{Headers: tc.headers}))
+		})
+	}
+}
@@ -4,22 +4,74 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
 	"github.com/lfroomin/restaurant-serverless/internal/print"
+	"log"
 	"net/http"
 )
 
+const (
+	ProblemContentType = "application/problem+json"
+
+	// problemTypePrefix is followed by the code of the problem in its type URI
+	problemTypePrefix = "urn:restaurant-serverless:problem:"
+)
+
+// Code is the machine-readable code of a problem. Clients can rely on it, unlike the detail.
+type Code string
+
+const (
+	CodeInvalidRequest       Code = "INVALID_REQUEST"
+	CodeMalformedBody        Code = "MALFORMED_BODY"
+	CodeTypeMismatch         Code = "TYPE_MISMATCH"
+	CodeUnknownField         Code = "UNKNOWN_FIELD"
+	CodeBodyTooLarge         Code = "BODY_TOO_LARGE"
+	CodeUnauthorized         Code = "UNAUTHORIZED"
+	CodeForbidden            Code = "FORBIDDEN"
+	CodeNotFound             Code = "NOT_FOUND"
+	CodeMethodNotAllowed     Code = "METHOD_NOT_ALLOWED"
+	CodeConflict             Code = "CONFLICT"
+	CodePreconditionFailed   Code = "PRECONDITION_FAILED"
+	CodeUnsupportedMediaType Code = "UNSUPPORTED_MEDIA_TYPE"
+	CodePatchFailed          Code = "PATCH_FAILED"
+	CodeAddressNotFound      Code = "ADDRESS_NOT_FOUND"
+	CodeAddressNotRelevant   Code = "ADDRESS_NOT_RELEVANT"
+	CodeNoOpeningHours       Code = "NO_OPENING_HOURS"
+	CodeNoTimeZone           Code = "NO_TIME_ZONE"
+	CodeQuotaExceeded        Code = "QUOTA_EXCEEDED"
+	CodeRateLimited          Code = "RATE_LIMITED"
+	CodeInternal             Code = "INTERNAL_ERROR"
+)
+
 var CORSHeaders = map[string]string{
 	"Access-Control-Allow-Origin":      "*",
 	"Access-Control-Allow-Credentials": "true",
+	"Access-Control-Expose-Headers":    "ETag, WWW-Authenticate, Retry-After, X-Quota-Limit, X-Quota-Remaining, X-Quota-Reset, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset",
 }
 
 func New(statusCode int, data any) *events.APIGatewayProxyResponse {
+	return NewWithHeaders(statusCode, data, nil)
+}
+
+// NewWithHeaders is New with additional response headers, such as ETag.
+func NewWithHeaders(statusCode int, data any, headers map[string]string) *events.APIGatewayProxyResponse {
 	response := &events.APIGatewayProxyResponse{
 		StatusCode: statusCode,
 		Headers:    CORSHeaders,
 	}
 
-	defer print.Json("Response", response)
+	if len(headers) > 0 {
+		response.Headers = make(map[string]string, len(CORSHeaders)+len(headers))
+		for k, v := range CORSHeaders {
+			response.Headers[k] = v
+		}
+		for k, v := range headers {
+			response.Headers[k] = v
+		}
+	}
+
+	defer print.Response(response)
 
 	if data == nil {
 		return response
@@ -43,20 +95,52 @@ func NewNoEncode(statusCode int, data string) *events.APIGatewayProxyResponse {
 		Body:       data,
 	}
 
-	defer print.Json("Response", response)
+	defer print.Response(response)
 
 	return response
 }
 
-func NewMessage(statusCode int, msg string) *events.APIGatewayProxyResponse {
-	data := map[string]string{"Message": msg}
-	return New(statusCode, data)
+// NewProblem returns an RFC 7807 problem details response. The detail is omitted when it
+// is empty. Its instance is set to the request ID by middleware.ProblemInstance.
+func NewProblem(statusCode int, code Code, detail string) *events.APIGatewayProxyResponse {
+	problem := model.Problem{Status: statusCode, Code: string(code)}
+	if detail != "" {
+		problem.Detail = &detail
+	}
+	return newProblem(problem)
+}
+
+func NewBadRequest(detail string) *events.APIGatewayProxyResponse {
+	return NewProblem(http.StatusBadRequest, CodeInvalidRequest, detail)
+}
+
+func NewNotFound(detail string) *events.APIGatewayProxyResponse {
+	return NewProblem(http.StatusNotFound, CodeNotFound, detail)
 }
 
-func NewBadRequest(msg string) *events.APIGatewayProxyResponse {
-	return NewMessage(http.StatusBadRequest, msg)
+// NewForbidden responds 403 with the machine-readable reason a policy denied the request.
+func NewForbidden(reason, detail string) *events.APIGatewayProxyResponse {
+	return newProblem(model.Problem{Status: http.StatusForbidden, Code: string(CodeForbidden), Detail: &detail, Reason: &reason})
+}
+
+// NewViolations responds 400 with the fields of the request that do not conform to the OpenAPI spec.
+func NewViolations(violations []model.Violation) *events.APIGatewayProxyResponse {
+	detail := "the request does not conform to the API specification"
+	return newProblem(model.Problem{Status: http.StatusBadRequest, Code: string(CodeInvalidRequest), Detail: &detail, Violations: &violations})
+}
+
+// NewServerError responds 500 without the error, which may reveal internals such as the
+// AWS error messages. The error is logged with a correlation id that is returned instead.
+func NewServerError(err error) *events.APIGatewayProxyResponse {
+	correlationId := uuid.NewString()
+	log.Printf("internal error correlationId: %s  error: %v\n", correlationId, err)
+
+	detail := "an internal error occurred"
+	return newProblem(model.Problem{Status: http.StatusInternalServerError, Code: string(CodeInternal), Detail: &detail, CorrelationId: &correlationId})
 }
 
-func NewServerError(msg string) *events.APIGatewayProxyResponse {
-	return NewMessage(http.StatusInternalServerError, msg)
+func newProblem(problem model.Problem) *events.APIGatewayProxyResponse {
+	problem.Type = problemTypePrefix + problem.Code
+	problem.Title = http.StatusText(problem.Status)
+	return NewWithHeaders(problem.Status, problem, map[string]string{"Content-Type": ProblemContentType})
 }
@@ -1,7 +1,11 @@
 package httpResponse
 
 import (
+	"encoding/json"
+	"errors"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
 )
 
@@ -57,6 +61,18 @@ func Test_New(t *testing.T) {
 	}
 }
 
+func Test_NewWithHeaders(t *testing.T) {
+	t.Parallel()
+
+	output := NewWithHeaders(http.StatusOK, map[string]int{"Field1": 1}, map[string]string{"ETag": `"3"`})
+
+	assert.Equal(t, http.StatusOK, output.StatusCode)
+	assert.Equal(t, `{"Field1":1}`, output.Body)
+	assert.Equal(t, `"3"`, output.Headers["ETag"])
+	assert.Equal(t, "*", output.Headers["Access-Control-Allow-Origin"])
+	assert.NotContains(t, CORSHeaders, "ETag")
+}
+
 func Test_NewNoEncode(t *testing.T) {
 	t.Parallel()
 
@@ -93,25 +109,28 @@ func Test_NewNoEncode(t *testing.T) {
 	}
 }
 
-func Test_NewMessage(t *testing.T) {
+func Test_NewProblem(t *testing.T) {
 	t.Parallel()
 
 	testCases := []struct {
 		name    string
 		code    int
-		msg     string
+		problem Code
+		detail  string
 		expBody string
 	}{
 		{
 			name:    "happy path",
-			code:    http.StatusForbidden,
-			msg:     "happy",
-			expBody: `{"Message":"happy"}`,
+			code:    http.StatusNotFound,
+			problem: CodeNotFound,
+			detail:  "the restaurant was not found",
+			expBody: `{"code":"NOT_FOUND","detail":"the restaurant was not found","status":404,"title":"Not Found","type":"urn:restaurant-serverless:problem:NOT_FOUND"}`,
 		},
 		{
-			name:    "empty message",
-			code:    http.StatusForbidden,
-			expBody: `{"Message":""}`,
+			name:    "empty detail",
+			code:    http.StatusMethodNotAllowed,
+			problem: CodeMethodNotAllowed,
+			expBody: `{"code":"METHOD_NOT_ALLOWED","status":405,"title":"Method Not Allowed","type":"urn:restaurant-serverless:problem:METHOD_NOT_ALLOWED"}`,
 		},
 	}
 
@@ -121,10 +140,12 @@ func Test_NewMessage(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 
-			output := NewMessage(tc.code, tc.msg)
+			output := NewProblem(tc.code, tc.problem, tc.detail)
 
 			assert.Equal(t, tc.code, output.StatusCode)
 			assert.Equal(t, tc.expBody, output.Body)
+			assert.Equal(t, ProblemContentType, output.Headers["Content-Type"])
+			assert.Equal(t, "*", output.Headers["Access-Control-Allow-Origin"])
 		})
 	}
 }
@@ -134,13 +155,32 @@ func Test_NewBadRequest(t *testing.T) {
 
 	output := NewBadRequest("happy")
 	assert.Equal(t, http.StatusBadRequest, output.StatusCode)
-	assert.Equal(t, `{"Message":"happy"}`, output.Body)
+	assert.Equal(t, `{"code":"INVALID_REQUEST","detail":"happy","status":400,"title":"Bad Request","type":"urn:restaurant-serverless:problem:INVALID_REQUEST"}`, output.Body)
+}
+
+func Test_NewViolations(t *testing.T) {
+	t.Parallel()
+
+	output := NewViolations([]model.Violation{{Field: "name", Message: "is required"}})
+	assert.Equal(t, http.StatusBadRequest, output.StatusCode)
+	assert.Equal(t, `{"code":"INVALID_REQUEST","detail":"the request does not conform to the API specification","status":400,"title":"Bad Request","type":"urn:restaurant-serverless:problem:INVALID_REQUEST","violations":[{"field":"name","message":"is required"}]}`, output.Body)
 }
 
 func Test_NewServerError(t *testing.T) {
 	t.Parallel()
 
-	output := NewServerError("happy")
+	output := NewServerError(errors.New("AccessDeniedException: not authorized to perform dynamodb:GetItem"))
 	assert.Equal(t, http.StatusInternalServerError, output.StatusCode)
-	assert.Equal(t, `{"Message":"happy"}`, output.Body)
+	assert.Equal(t, ProblemContentType, output.Headers["Content-Type"])
+
+	problem := model.Problem{}
+	require.NoError(t, json.Unmarshal([]byte(output.Body), &problem))
+	assert.Equal(t, "INTERNAL_ERROR", problem.Code)
+	assert.Equal(t, "an internal error occurred", *problem.Detail)
+	require.NotNil(t, problem.CorrelationId)
+	assert.NotEmpty(t, *problem.CorrelationId)
+	assert.NotContains(t, output.Body, "dynamodb")
+
+	// Every error has its own correlation id
+	assert.NotEqual(t, output.Body, NewServerError(errors.New("error")).Body)
 }
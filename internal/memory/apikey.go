@@ -0,0 +1,133 @@
+package memory
+
+import (
+	"fmt"
+	"github.com/lfroomin/restaurant-serverless/internal/apikey"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"log"
+	"sort"
+	"time"
+)
+
+// SaveApiKey stores a new API key. Its key is not stored, only its hash.
+func (rs *RestaurantStorage) SaveApiKey(k apikey.Key) error {
+	log.Printf("memory.RestaurantStorage.SaveApiKey apiKeyId: %s\n", *k.Id)
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if _, exists := rs.apiKeys[*k.Id]; exists {
+		return fmt.Errorf("error saving API key %q: %w", *k.Id, storage.ErrConflict)
+	}
+
+	c, err := clone(k)
+	if err != nil {
+		return err
+	}
+	c.Key = nil
+	rs.apiKeys[*k.Id] = c
+	return nil
+}
+
+func (rs *RestaurantStorage) GetApiKey(apiKeyId string) (apikey.Key, bool, error) {
+	log.Printf("memory.RestaurantStorage.GetApiKey apiKeyId: %s\n", apiKeyId)
+
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	k, exists := rs.apiKeys[apiKeyId]
+	if !exists {
+		return apikey.Key{}, false, nil
+	}
+
+	c, err := clone(k)
+	if err != nil {
+		return apikey.Key{}, false, err
+	}
+	return c, true, nil
+}
+
+// ListApiKeys returns the API keys of the tenant, the revoked ones included, in the order of their ids.
+func (rs *RestaurantStorage) ListApiKeys(tenantId string) ([]apikey.Key, error) {
+	log.Printf("memory.RestaurantStorage.ListApiKeys tenantId: %s\n", tenantId)
+
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	keys := []apikey.Key{}
+	for _, k := range rs.apiKeys {
+		if k.TenantId == nil || *k.TenantId != tenantId {
+			continue
+		}
+		c, err := clone(k)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, c)
+	}
+	sort.Slice(keys, func(i, j int) bool { return *keys[i].Id < *keys[j].Id })
+	return keys, nil
+}
+
+// RotateApiKey replaces the hash of the API key, and returns the rotated API key. It returns
+// storage.ErrNotFound when the API key does not exist, and storage.ErrConflict when it is revoked.
+func (rs *RestaurantStorage) RotateApiKey(apiKeyId, hash string, rotated time.Time) (apikey.Key, error) {
+	log.Printf("memory.RestaurantStorage.RotateApiKey apiKeyId: %s\n", apiKeyId)
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	k, exists := rs.apiKeys[apiKeyId]
+	if !exists {
+		return apikey.Key{}, fmt.Errorf("error rotating API key %q: %w", apiKeyId, storage.ErrNotFound)
+	}
+	if k.Hash == "" {
+		return apikey.Key{}, fmt.Errorf("error rotating API key %q: %w", apiKeyId, storage.ErrConflict)
+	}
+
+	k.Hash, k.Rotated = hash, &rotated
+	return rs.putApiKey(k)
+}
+
+// RevokeApiKey removes the hash of the API key, so that its key is rejected, and returns the
+// revoked API key. It returns storage.ErrNotFound when the API key does not exist.
+func (rs *RestaurantStorage) RevokeApiKey(apiKeyId string, revoked time.Time) (apikey.Key, error) {
+	log.Printf("memory.RestaurantStorage.RevokeApiKey apiKeyId: %s\n", apiKeyId)
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	k, exists := rs.apiKeys[apiKeyId]
+	if !exists {
+		return apikey.Key{}, fmt.Errorf("error revoking API key %q: %w", apiKeyId, storage.ErrNotFound)
+	}
+
+	k.Hash, k.Revoked = "", &revoked
+	return rs.putApiKey(k)
+}
+
+// UseApiKey counts a request of the API key in the period, unless limit requests were already
+// counted, and returns the number of requests of the period. The counts are never deleted.
+func (rs *RestaurantStorage) UseApiKey(apiKeyId, period string, limit int32, _ time.Time) (int32, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if rs.apiUsage[apiKeyId] == nil {
+		rs.apiUsage[apiKeyId] = map[string]int32{}
+	}
+	if rs.apiUsage[apiKeyId][period] >= limit {
+		return 0, fmt.Errorf("error counting a request of API key %q: %w", apiKeyId, storage.ErrLimitExceeded)
+	}
+	rs.apiUsage[apiKeyId][period]++
+	return rs.apiUsage[apiKeyId][period], nil
+}
+
+// putApiKey stores a copy of the API key and returns another. The caller holds the lock.
+func (rs *RestaurantStorage) putApiKey(k apikey.Key) (apikey.Key, error) {
+	c, err := clone(k)
+	if err != nil {
+		return apikey.Key{}, err
+	}
+	rs.apiKeys[*k.Id] = c
+	return clone(c)
+}
@@ -0,0 +1,348 @@
+// Package memory is an in-process implementation of the restaurant storage for
+// local development and tests. It has the same semantics as the DynamoDB storage.
+package memory
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/lfroomin/restaurant-serverless/internal/apikey"
+	"github.com/lfroomin/restaurant-serverless/internal/event"
+	"github.com/lfroomin/restaurant-serverless/internal/geohash"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/outbox"
+	"github.com/lfroomin/restaurant-serverless/internal/ratelimit"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"github.com/lfroomin/restaurant-serverless/internal/webhook"
+	"log"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+type RestaurantStorage struct {
+	mu    sync.RWMutex
+	items map[restaurantKey]restaurantItem
+	// menus and reviews are keyed by restaurant, then by their id
+	menus   map[restaurantKey]map[string]model.Menu
+	reviews map[restaurantKey]map[string]model.Review
+	// outbox has the undelivered events of the changes of the restaurants, keyed by event id
+	outbox map[string]outbox.Record
+	// webhooks are keyed by their tenant and id, and their deliveries by webhook, then by event id
+	webhooks   map[webhookKey]model.Webhook
+	deliveries map[webhookKey]map[string]webhook.Delivery
+	// apiKeys are keyed by their id, and the request counts by API key id, then by period
+	apiKeys  map[string]apikey.Key
+	apiUsage map[string]map[string]int32
+	// buckets are the token buckets of the rate limits, keyed by their key
+	buckets map[string]ratelimit.Bucket
+}
+
+// restaurantKey identifies a restaurant within its tenant, so a restaurant id of
+// another tenant never finds it.
+type restaurantKey struct {
+	TenantId     string
+	RestaurantId string
+}
+
+// webhookKey identifies a webhook within its tenant.
+type webhookKey struct {
+	TenantId  string
+	WebhookId string
+}
+
+type restaurantItem struct {
+	Restaurant model.Restaurant
+	Updated    int64
+	Version    int64
+	// Ratings is the number of reviews of each rating, Ratings[0] being the reviews rated 1
+	Ratings [model.MaxRating]int
+}
+
+// restaurant returns a copy of the restaurant with the summary of its ratings.
+func (item restaurantItem) restaurant() (model.Restaurant, error) {
+	r, err := clone(item.Restaurant)
+	if err != nil {
+		return model.Restaurant{}, err
+	}
+	r.Rating = model.NewRatingSummary(item.Ratings)
+	return r, nil
+}
+
+func New() *RestaurantStorage {
+	return &RestaurantStorage{
+		items:      map[restaurantKey]restaurantItem{},
+		menus:      map[restaurantKey]map[string]model.Menu{},
+		reviews:    map[restaurantKey]map[string]model.Review{},
+		outbox:     map[string]outbox.Record{},
+		webhooks:   map[webhookKey]model.Webhook{},
+		deliveries: map[webhookKey]map[string]webhook.Delivery{},
+		apiKeys:    map[string]apikey.Key{},
+		apiUsage:   map[string]map[string]int32{},
+		buckets:    map[string]ratelimit.Bucket{},
+	}
+}
+
+func (rs *RestaurantStorage) Save(tenantId string, restaurant model.Restaurant) error {
+	log.Printf("memory.RestaurantStorage.Save tenantId: %s  restaurantId: %s\n", tenantId, *restaurant.Id)
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	k := restaurantKey{tenantId, *restaurant.Id}
+	if _, exists := rs.items[k]; exists {
+		return fmt.Errorf("error saving restaurant %q: %w", *restaurant.Id, storage.ErrConflict)
+	}
+
+	r, err := clone(restaurant)
+	if err != nil {
+		return err
+	}
+	r.Rating = nil
+	item := restaurantItem{Restaurant: r, Updated: time.Now().UnixMilli(), Version: 1}
+
+	after, err := item.restaurant()
+	if err != nil {
+		return err
+	}
+	rs.items[k] = item
+	rs.record(event.New(tenantId, event.RestaurantCreated, nil, &after, item.Version))
+	return nil
+}
+
+// Get returns the restaurant and its version. The version is incremented by every write.
+func (rs *RestaurantStorage) Get(tenantId, restaurantId string) (model.Restaurant, int64, bool, error) {
+	log.Printf("memory.RestaurantStorage.Get tenantId: %s  restaurantId: %s\n", tenantId, restaurantId)
+
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	item, exists := rs.items[restaurantKey{tenantId, restaurantId}]
+	if !exists {
+		return model.Restaurant{}, 0, false, nil
+	}
+
+	r, err := item.restaurant()
+	if err != nil {
+		return model.Restaurant{}, 0, false, err
+	}
+	return r, item.Version, true, nil
+}
+
+// Update replaces the restaurant and returns its new version. If ifVersion is
+// not nil the update only succeeds when it matches the stored version.
+func (rs *RestaurantStorage) Update(tenantId string, restaurant model.Restaurant, ifVersion *int64) (int64, error) {
+	log.Printf("memory.RestaurantStorage.Update tenantId: %s  restaurantId: %s\n", tenantId, *restaurant.Id)
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	k := restaurantKey{tenantId, *restaurant.Id}
+	item, err := rs.current(k, ifVersion)
+	if err != nil {
+		return 0, fmt.Errorf("error updating restaurant %q: %w", *restaurant.Id, err)
+	}
+
+	before, err := item.restaurant()
+	if err != nil {
+		return 0, err
+	}
+
+	if item.Restaurant, err = clone(restaurant); err != nil {
+		return 0, err
+	}
+	item.Restaurant.Rating = nil
+	return rs.putWithEvent(k, item, before)
+}
+
+// Patch writes only the fields of the restaurant that differ between original
+// and patched, so concurrent patches of different fields do not overwrite each
+// other. It returns the new version of the restaurant.
+func (rs *RestaurantStorage) Patch(tenantId string, original, patched model.Restaurant, ifVersion *int64) (int64, error) {
+	log.Printf("memory.RestaurantStorage.Patch tenantId: %s  restaurantId: %s\n", tenantId, *patched.Id)
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	k := restaurantKey{tenantId, *patched.Id}
+	item, err := rs.current(k, ifVersion)
+	if err != nil {
+		return 0, fmt.Errorf("error patching restaurant %q: %w", *patched.Id, err)
+	}
+
+	stored, err := item.restaurant()
+	if err != nil {
+		return 0, err
+	}
+
+	patched, err = clone(patched)
+	if err != nil {
+		return 0, err
+	}
+
+	before := reflect.ValueOf(original)
+	after := reflect.ValueOf(patched)
+	fields := reflect.ValueOf(&item.Restaurant).Elem()
+	for i := 0; i < after.NumField(); i++ {
+		if !reflect.DeepEqual(before.Field(i).Interface(), after.Field(i).Interface()) {
+			fields.Field(i).Set(after.Field(i))
+		}
+	}
+	item.Restaurant.Rating = nil
+
+	return rs.putWithEvent(k, item, stored)
+}
+
+// Delete removes the restaurant, its menus and its reviews, and returns the restaurant as it was before the delete.
+// If ifVersion is not nil the delete only succeeds when it matches the stored version.
+func (rs *RestaurantStorage) Delete(tenantId, restaurantId string, ifVersion *int64) (model.Restaurant, error) {
+	log.Printf("memory.RestaurantStorage.Delete tenantId: %s  restaurantId: %s\n", tenantId, restaurantId)
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	k := restaurantKey{tenantId, restaurantId}
+	item, err := rs.current(k, ifVersion)
+	if err != nil {
+		return model.Restaurant{}, fmt.Errorf("error deleting restaurant %q: %w", restaurantId, err)
+	}
+
+	before, err := item.restaurant()
+	if err != nil {
+		return model.Restaurant{}, err
+	}
+
+	delete(rs.items, k)
+	delete(rs.menus, k)
+	delete(rs.reviews, k)
+	rs.record(event.New(tenantId, event.RestaurantDeleted, &before, nil, 0))
+	return before, nil
+}
+
+// List returns up to limit restaurants of the tenant, ordered by id, starting after the position
+// encoded in nextToken. The returned token is empty when there are no more restaurants.
+func (rs *RestaurantStorage) List(tenantId string, limit int32, nextToken string) ([]model.Restaurant, string, error) {
+	log.Printf("memory.RestaurantStorage.List tenantId: %s  limit: %d  nextToken: %s\n", tenantId, limit, nextToken)
+
+	startAfter := ""
+	if nextToken != "" {
+		b, err := base64.RawURLEncoding.DecodeString(nextToken)
+		if err != nil || len(b) == 0 {
+			return nil, "", storage.ErrInvalidNextToken
+		}
+		startAfter = string(b)
+	}
+
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	ids := make([]string, 0, len(rs.items))
+	for k := range rs.items {
+		if k.TenantId == tenantId && k.RestaurantId > startAfter {
+			ids = append(ids, k.RestaurantId)
+		}
+	}
+	sort.Strings(ids)
+
+	token := ""
+	if len(ids) > int(limit) {
+		ids = ids[:limit]
+		token = base64.RawURLEncoding.EncodeToString([]byte(ids[len(ids)-1]))
+	}
+
+	restaurants := make([]model.Restaurant, 0, len(ids))
+	for _, id := range ids {
+		r, err := rs.items[restaurantKey{tenantId, id}].restaurant()
+		if err != nil {
+			return nil, "", err
+		}
+		restaurants = append(restaurants, r)
+	}
+
+	return restaurants, token, nil
+}
+
+// Nearby returns the restaurants of the tenant within radiusKm of the coordinates, nearest first.
+func (rs *RestaurantStorage) Nearby(tenantId string, lat, lon, radiusKm float64) ([]model.NearbyRestaurant, error) {
+	log.Printf("memory.RestaurantStorage.Nearby tenantId: %s  lat: %f  lon: %f  radiusKm: %f\n", tenantId, lat, lon, radiusKm)
+
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	nearby := []model.NearbyRestaurant{}
+	for k, item := range rs.items {
+		if k.TenantId != tenantId {
+			continue
+		}
+		rLat, rLon, ok := item.Restaurant.Coordinates()
+		if !ok {
+			continue
+		}
+		if d := geohash.DistanceKm(lat, lon, rLat, rLon); d <= radiusKm {
+			r, err := item.restaurant()
+			if err != nil {
+				return nil, err
+			}
+			nearby = append(nearby, model.NearbyRestaurant{DistanceKm: d, Restaurant: r})
+		}
+	}
+
+	sort.Slice(nearby, func(i, j int) bool {
+		if nearby[i].DistanceKm == nearby[j].DistanceKm {
+			return *nearby[i].Restaurant.Id < *nearby[j].Restaurant.Id
+		}
+		return nearby[i].DistanceKm < nearby[j].DistanceKm
+	})
+
+	return nearby, nil
+}
+
+// current returns the stored restaurant when it exists and, if ifVersion is not nil, has that version.
+// Callers must hold the write lock.
+func (rs *RestaurantStorage) current(k restaurantKey, ifVersion *int64) (restaurantItem, error) {
+	item, exists := rs.items[k]
+	if !exists {
+		return restaurantItem{}, storage.ErrNotFound
+	}
+	if ifVersion != nil && *ifVersion != item.Version {
+		return restaurantItem{}, fmt.Errorf("version %d does not match: %w", *ifVersion, storage.ErrPreconditionFailed)
+	}
+	return item, nil
+}
+
+// put stores the item as the next version of the restaurant and returns that version.
+// Callers must hold the write lock.
+func (rs *RestaurantStorage) put(k restaurantKey, item restaurantItem) int64 {
+	item.Version++
+	item.Updated = time.Now().UnixMilli()
+	rs.items[k] = item
+	return item.Version
+}
+
+// putWithEvent stores the item like put, and records the change from before in the outbox.
+// Callers must hold the write lock.
+func (rs *RestaurantStorage) putWithEvent(k restaurantKey, item restaurantItem, before model.Restaurant) (int64, error) {
+	after, err := item.restaurant()
+	if err != nil {
+		return 0, err
+	}
+
+	version := rs.put(k, item)
+	rs.record(event.New(k.TenantId, event.RestaurantUpdated, &before, &after, version))
+	return version, nil
+}
+
+// clone returns a deep copy of the value so callers cannot modify the stored values.
+func clone[T any](value T) (T, error) {
+	var c T
+	b, err := json.Marshal(value)
+	if err != nil {
+		return c, fmt.Errorf("error marshalling value: %w", err)
+	}
+
+	if err = json.Unmarshal(b, &c); err != nil {
+		return c, fmt.Errorf("error unmarshalling value: %w", err)
+	}
+	return c, nil
+}
@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/ratelimit"
+	"github.com/lfroomin/restaurant-serverless/internal/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_Conformance(t *testing.T) {
+	t.Parallel()
+
+	storagetest.Run(t, func() storagetest.RestaurantStorer {
+		return New()
+	})
+	storagetest.RunMenus(t, func() storagetest.RestaurantMenuStorer {
+		return New()
+	})
+	storagetest.RunReviews(t, func() storagetest.RestaurantReviewStorer {
+		return New()
+	})
+	storagetest.RunOutbox(t, func() storagetest.RestaurantOutboxStorer {
+		return New()
+	})
+	storagetest.RunWebhooks(t, func() storagetest.WebhookStorer {
+		return New()
+	})
+	storagetest.RunApiKeys(t, func() storagetest.ApiKeyStorer {
+		return New()
+	})
+	storagetest.RunRateLimits(t, func() ratelimit.Store {
+		return New()
+	})
+}
+
+func Test_Updated(t *testing.T) {
+	t.Parallel()
+
+	restId := "restId"
+	rs := New()
+	require.NoError(t, rs.Save("tenant1", model.Restaurant{Id: &restId, Name: "before"}))
+	saved := rs.items[restaurantKey{"tenant1", restId}].Updated
+	assert.NotZero(t, saved)
+
+	_, err := rs.Update("tenant1", model.Restaurant{Id: &restId, Name: "after"}, nil)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, rs.items[restaurantKey{"tenant1", restId}].Updated, saved)
+}
+
+func Test_StoredRestaurantIsCopied(t *testing.T) {
+	t.Parallel()
+
+	restId := "restId"
+	description := "before"
+	rs := New()
+	require.NoError(t, rs.Save("tenant1", model.Restaurant{Id: &restId, Description: &description}))
+
+	// Modifying the saved or returned restaurant must not change the stored restaurant
+	description = "after"
+	got, _, _, err := rs.Get("tenant1", restId)
+	require.NoError(t, err)
+	assert.Equal(t, "before", *got.Description)
+
+	*got.Description = "after"
+	got, _, _, err = rs.Get("tenant1", restId)
+	require.NoError(t, err)
+	assert.Equal(t, "before", *got.Description)
+}
@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"fmt"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"log"
+	"sort"
+)
+
+func (rs *RestaurantStorage) SaveMenu(tenantId, restaurantId string, menu model.Menu) error {
+	log.Printf("memory.RestaurantStorage.SaveMenu tenantId: %s  restaurantId: %s  menuId: %s\n", tenantId, restaurantId, *menu.Id)
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	k := restaurantKey{tenantId, restaurantId}
+	if _, exists := rs.menus[k][*menu.Id]; exists {
+		return fmt.Errorf("error saving menu %q of restaurant %q: %w", *menu.Id, restaurantId, storage.ErrConflict)
+	}
+	return rs.putMenu(k, menu)
+}
+
+func (rs *RestaurantStorage) GetMenu(tenantId, restaurantId, menuId string) (model.Menu, bool, error) {
+	log.Printf("memory.RestaurantStorage.GetMenu tenantId: %s  restaurantId: %s  menuId: %s\n", tenantId, restaurantId, menuId)
+
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	k := restaurantKey{tenantId, restaurantId}
+	menu, exists := rs.menus[k][menuId]
+	if !exists {
+		return model.Menu{}, false, nil
+	}
+
+	m, err := clone(menu)
+	if err != nil {
+		return model.Menu{}, false, err
+	}
+	return m, true, nil
+}
+
+// UpdateMenu replaces the menu, which must exist.
+func (rs *RestaurantStorage) UpdateMenu(tenantId, restaurantId string, menu model.Menu) error {
+	log.Printf("memory.RestaurantStorage.UpdateMenu tenantId: %s  restaurantId: %s  menuId: %s\n", tenantId, restaurantId, *menu.Id)
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	k := restaurantKey{tenantId, restaurantId}
+	if _, exists := rs.menus[k][*menu.Id]; !exists {
+		return fmt.Errorf("error updating menu %q of restaurant %q: %w", *menu.Id, restaurantId, storage.ErrNotFound)
+	}
+	return rs.putMenu(k, menu)
+}
+
+// DeleteMenu removes the menu and returns it as it was before the delete.
+func (rs *RestaurantStorage) DeleteMenu(tenantId, restaurantId, menuId string) (model.Menu, error) {
+	log.Printf("memory.RestaurantStorage.DeleteMenu tenantId: %s  restaurantId: %s  menuId: %s\n", tenantId, restaurantId, menuId)
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	k := restaurantKey{tenantId, restaurantId}
+	menu, exists := rs.menus[k][menuId]
+	if !exists {
+		return model.Menu{}, fmt.Errorf("error deleting menu %q of restaurant %q: %w", menuId, restaurantId, storage.ErrNotFound)
+	}
+
+	delete(rs.menus[k], menuId)
+	return menu, nil
+}
+
+// ListMenus returns all the menus of the restaurant, ordered by id.
+func (rs *RestaurantStorage) ListMenus(tenantId, restaurantId string) ([]model.Menu, error) {
+	log.Printf("memory.RestaurantStorage.ListMenus tenantId: %s  restaurantId: %s\n", tenantId, restaurantId)
+
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	k := restaurantKey{tenantId, restaurantId}
+	menus := make([]model.Menu, 0, len(rs.menus[k]))
+	for _, menu := range rs.menus[k] {
+		m, err := clone(menu)
+		if err != nil {
+			return nil, err
+		}
+		menus = append(menus, m)
+	}
+
+	sort.Slice(menus, func(i, j int) bool { return *menus[i].Id < *menus[j].Id })
+	return menus, nil
+}
+
+// putMenu stores a copy of the menu. Callers must hold the write lock.
+func (rs *RestaurantStorage) putMenu(k restaurantKey, menu model.Menu) error {
+	m, err := clone(menu)
+	if err != nil {
+		return err
+	}
+
+	if rs.menus[k] == nil {
+		rs.menus[k] = map[string]model.Menu{}
+	}
+	rs.menus[k][*menu.Id] = m
+	return nil
+}
@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"github.com/lfroomin/restaurant-serverless/internal/event"
+	"github.com/lfroomin/restaurant-serverless/internal/outbox"
+	"log"
+	"sort"
+	"time"
+)
+
+// Due returns up to limit undelivered records with a next attempt at or before now, the earliest first.
+func (rs *RestaurantStorage) Due(now time.Time, limit int32) ([]outbox.Record, error) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	records := []outbox.Record{}
+	for _, r := range rs.outbox {
+		if !r.NextAttempt.After(now) {
+			records = append(records, r)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].NextAttempt.Equal(records[j].NextAttempt) {
+			return records[i].Event.Id < records[j].Event.Id
+		}
+		return records[i].NextAttempt.Before(records[j].NextAttempt)
+	})
+
+	if len(records) > int(limit) {
+		records = records[:limit]
+	}
+	return records, nil
+}
+
+// Claim moves the next attempt of the record to until, unless it changed since it was returned by Due.
+func (rs *RestaurantStorage) Claim(record outbox.Record, until time.Time) (bool, error) {
+	log.Printf("memory.RestaurantStorage.Claim eventId: %s\n", record.Event.Id)
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	stored, exists := rs.outbox[record.Event.Id]
+	if !exists || !stored.NextAttempt.Equal(record.NextAttempt) {
+		return false, nil
+	}
+	stored.NextAttempt = millis(until)
+	rs.outbox[record.Event.Id] = stored
+	return true, nil
+}
+
+func (rs *RestaurantStorage) Retry(record outbox.Record, nextAttempt time.Time, lastError string) error {
+	log.Printf("memory.RestaurantStorage.Retry eventId: %s\n", record.Event.Id)
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if stored, exists := rs.outbox[record.Event.Id]; exists {
+		stored.Attempts++
+		stored.NextAttempt = millis(nextAttempt)
+		stored.LastError = lastError
+		rs.outbox[record.Event.Id] = stored
+	}
+	return nil
+}
+
+// MarkDelivered removes the record from the outbox.
+func (rs *RestaurantStorage) MarkDelivered(record outbox.Record) error {
+	log.Printf("memory.RestaurantStorage.MarkDelivered eventId: %s\n", record.Event.Id)
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	delete(rs.outbox, record.Event.Id)
+	return nil
+}
+
+// record adds the event to the outbox, due now. Callers must hold the write lock.
+func (rs *RestaurantStorage) record(e event.Event) {
+	rs.outbox[e.Id] = outbox.Record{Event: e, NextAttempt: millis(time.Now())}
+}
+
+// millis returns the time with the millisecond precision of the DynamoDB outbox.
+func millis(t time.Time) time.Time {
+	return time.UnixMilli(t.UnixMilli())
+}
@@ -0,0 +1,30 @@
+package memory
+
+import (
+	"fmt"
+	"github.com/lfroomin/restaurant-serverless/internal/ratelimit"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"time"
+)
+
+func (rs *RestaurantStorage) GetBucket(key string) (ratelimit.Bucket, bool, error) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	b, exists := rs.buckets[key]
+	return b, exists, nil
+}
+
+// SaveBucket stores the token bucket of key if the stored one has the previous version, or if
+// there is none and the bucket has version 1. Otherwise it returns storage.ErrPreconditionFailed.
+// The buckets are never deleted.
+func (rs *RestaurantStorage) SaveBucket(key string, b ratelimit.Bucket, _ time.Time) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if stored := rs.buckets[key]; stored.Version != b.Version-1 {
+		return fmt.Errorf("version %d of rate limit bucket %q does not match: %w", stored.Version, key, storage.ErrPreconditionFailed)
+	}
+	rs.buckets[key] = b
+	return nil
+}
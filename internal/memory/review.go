@@ -0,0 +1,144 @@
+package memory
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"log"
+	"sort"
+)
+
+// SaveReview stores the review and adds its rating to the rating summary of the restaurant.
+func (rs *RestaurantStorage) SaveReview(tenantId, restaurantId string, review model.Review) error {
+	log.Printf("memory.RestaurantStorage.SaveReview tenantId: %s  restaurantId: %s  reviewId: %s\n", tenantId, restaurantId, *review.Id)
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	k := restaurantKey{tenantId, restaurantId}
+	item, exists := rs.items[k]
+	if !exists {
+		return fmt.Errorf("error saving review %q of restaurant %q: %w", *review.Id, restaurantId, storage.ErrNotFound)
+	}
+	if _, exists = rs.reviews[k][*review.Id]; exists {
+		return fmt.Errorf("error saving review %q of restaurant %q: %w", *review.Id, restaurantId, storage.ErrConflict)
+	}
+	if review.Rating < model.MinRating || review.Rating > model.MaxRating {
+		return fmt.Errorf("error saving review %q of restaurant %q: rating %d is not between %d and %d",
+			*review.Id, restaurantId, review.Rating, model.MinRating, model.MaxRating)
+	}
+
+	r, err := clone(review)
+	if err != nil {
+		return err
+	}
+	if rs.reviews[k] == nil {
+		rs.reviews[k] = map[string]model.Review{}
+	}
+	rs.reviews[k][*review.Id] = r
+
+	// The rating summary is not part of the version, which the owners update the restaurant with
+	item.Ratings[review.Rating-model.MinRating]++
+	rs.items[k] = item
+	return nil
+}
+
+func (rs *RestaurantStorage) GetReview(tenantId, restaurantId, reviewId string) (model.Review, bool, error) {
+	log.Printf("memory.RestaurantStorage.GetReview tenantId: %s  restaurantId: %s  reviewId: %s\n", tenantId, restaurantId, reviewId)
+
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	k := restaurantKey{tenantId, restaurantId}
+	review, exists := rs.reviews[k][reviewId]
+	if !exists {
+		return model.Review{}, false, nil
+	}
+
+	r, err := clone(review)
+	if err != nil {
+		return model.Review{}, false, err
+	}
+	return r, true, nil
+}
+
+// DeleteReview removes the review and its rating from the rating summary of the
+// restaurant, and returns the review as it was before the delete.
+func (rs *RestaurantStorage) DeleteReview(tenantId, restaurantId, reviewId string) (model.Review, error) {
+	log.Printf("memory.RestaurantStorage.DeleteReview tenantId: %s  restaurantId: %s  reviewId: %s\n", tenantId, restaurantId, reviewId)
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	k := restaurantKey{tenantId, restaurantId}
+	review, exists := rs.reviews[k][reviewId]
+	if !exists {
+		return model.Review{}, fmt.Errorf("error deleting review %q of restaurant %q: %w", reviewId, restaurantId, storage.ErrNotFound)
+	}
+
+	delete(rs.reviews[k], reviewId)
+	if item, exists := rs.items[k]; exists {
+		item.Ratings[review.Rating-model.MinRating]--
+		rs.items[k] = item
+	}
+	return review, nil
+}
+
+// ListReviews returns up to limit reviews of the restaurant in the order, starting after
+// the position encoded in nextToken. The returned token is empty when there are no more reviews.
+func (rs *RestaurantStorage) ListReviews(tenantId, restaurantId string, order storage.ReviewOrder, limit int32, nextToken string) ([]model.Review, string, error) {
+	log.Printf("memory.RestaurantStorage.ListReviews tenantId: %s  restaurantId: %s  order: %s  limit: %d  nextToken: %s\n", tenantId, restaurantId, order, limit, nextToken)
+
+	startAfter := ""
+	if nextToken != "" {
+		b, err := base64.RawURLEncoding.DecodeString(nextToken)
+		if err != nil || len(b) == 0 {
+			return nil, "", storage.ErrInvalidNextToken
+		}
+		startAfter = string(b)
+	}
+
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	stored := rs.reviews[restaurantKey{tenantId, restaurantId}]
+	// The reviews are in descending order of their keys
+	keys := map[string]model.Review{}
+	sorted := make([]string, 0, len(stored))
+	for _, review := range stored {
+		k := reviewKey(review, order)
+		if startAfter == "" || k < startAfter {
+			keys[k] = review
+			sorted = append(sorted, k)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(sorted)))
+
+	token := ""
+	if len(sorted) > int(limit) {
+		sorted = sorted[:limit]
+		token = base64.RawURLEncoding.EncodeToString([]byte(sorted[len(sorted)-1]))
+	}
+
+	reviews := make([]model.Review, 0, len(sorted))
+	for _, k := range sorted {
+		r, err := clone(keys[k])
+		if err != nil {
+			return nil, "", err
+		}
+		reviews = append(reviews, r)
+	}
+
+	return reviews, token, nil
+}
+
+// reviewKey returns a key of the review that sorts in ascending order of the creation
+// time, or of the rating then the creation time.
+func reviewKey(review model.Review, order storage.ReviewOrder) string {
+	newest := fmt.Sprintf("%019d#%s", review.Created.UnixNano(), *review.Id)
+	if order == storage.ReviewsHighest {
+		return fmt.Sprintf("%d#%s", review.Rating, newest)
+	}
+	return newest
+}
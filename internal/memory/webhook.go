@@ -0,0 +1,240 @@
+package memory
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"github.com/lfroomin/restaurant-serverless/internal/webhook"
+	"log"
+	"sort"
+	"time"
+)
+
+func (rs *RestaurantStorage) SaveWebhook(tenantId string, w model.Webhook) error {
+	log.Printf("memory.RestaurantStorage.SaveWebhook tenantId: %s  webhookId: %s\n", tenantId, *w.Id)
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	k := webhookKey{tenantId, *w.Id}
+	if _, exists := rs.webhooks[k]; exists {
+		return fmt.Errorf("error saving webhook %q: %w", *w.Id, storage.ErrConflict)
+	}
+
+	c, err := clone(w)
+	if err != nil {
+		return err
+	}
+	rs.webhooks[k] = c
+	return nil
+}
+
+// GetWebhook returns the webhook with its secret.
+func (rs *RestaurantStorage) GetWebhook(tenantId, webhookId string) (model.Webhook, bool, error) {
+	log.Printf("memory.RestaurantStorage.GetWebhook tenantId: %s  webhookId: %s\n", tenantId, webhookId)
+
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	w, exists := rs.webhooks[webhookKey{tenantId, webhookId}]
+	if !exists {
+		return model.Webhook{}, false, nil
+	}
+
+	c, err := clone(w)
+	if err != nil {
+		return model.Webhook{}, false, err
+	}
+	return c, true, nil
+}
+
+// ListWebhooks returns the webhooks of the tenant with their secrets, in the order of their ids.
+func (rs *RestaurantStorage) ListWebhooks(tenantId string) ([]model.Webhook, error) {
+	log.Printf("memory.RestaurantStorage.ListWebhooks tenantId: %s\n", tenantId)
+
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	webhooks := []model.Webhook{}
+	for k, w := range rs.webhooks {
+		if k.TenantId != tenantId {
+			continue
+		}
+		c, err := clone(w)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, c)
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return *webhooks[i].Id < *webhooks[j].Id })
+	return webhooks, nil
+}
+
+// DeleteWebhook removes the webhook and its deliveries, and returns the webhook as it was before the delete.
+func (rs *RestaurantStorage) DeleteWebhook(tenantId, webhookId string) (model.Webhook, error) {
+	log.Printf("memory.RestaurantStorage.DeleteWebhook tenantId: %s  webhookId: %s\n", tenantId, webhookId)
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	k := webhookKey{tenantId, webhookId}
+	w, exists := rs.webhooks[k]
+	if !exists {
+		return model.Webhook{}, fmt.Errorf("error deleting webhook %q: %w", webhookId, storage.ErrNotFound)
+	}
+
+	delete(rs.webhooks, k)
+	delete(rs.deliveries, k)
+	return w, nil
+}
+
+// ListDeliveries returns up to limit deliveries of the webhook, the newest event first, starting
+// after the position encoded in nextToken. The returned token is empty when there are no more deliveries.
+func (rs *RestaurantStorage) ListDeliveries(tenantId, webhookId string, limit int32, nextToken string) ([]model.WebhookDelivery, string, error) {
+	log.Printf("memory.RestaurantStorage.ListDeliveries tenantId: %s  webhookId: %s  limit: %d  nextToken: %s\n", tenantId, webhookId, limit, nextToken)
+
+	startAfter := ""
+	if nextToken != "" {
+		b, err := base64.RawURLEncoding.DecodeString(nextToken)
+		if err != nil || len(b) == 0 {
+			return nil, "", storage.ErrInvalidNextToken
+		}
+		startAfter = string(b)
+	}
+
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	// The deliveries are in descending order of their keys
+	keys := map[string]webhook.Delivery{}
+	deliveries := rs.deliveries[webhookKey{tenantId, webhookId}]
+	sorted := make([]string, 0, len(deliveries))
+	for _, d := range deliveries {
+		k := deliveryKey(d)
+		if startAfter == "" || k < startAfter {
+			keys[k] = d
+			sorted = append(sorted, k)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(sorted)))
+
+	token := ""
+	if len(sorted) > int(limit) {
+		sorted = sorted[:limit]
+		token = base64.RawURLEncoding.EncodeToString([]byte(sorted[len(sorted)-1]))
+	}
+
+	page := make([]model.WebhookDelivery, 0, len(sorted))
+	for _, k := range sorted {
+		d, err := clone(keys[k].WebhookDelivery)
+		if err != nil {
+			return nil, "", err
+		}
+		page = append(page, d)
+	}
+
+	return page, token, nil
+}
+
+// SaveDelivery stores a new delivery, unless the webhook already has a delivery of the event.
+func (rs *RestaurantStorage) SaveDelivery(delivery webhook.Delivery) error {
+	log.Printf("memory.RestaurantStorage.SaveDelivery tenantId: %s  webhookId: %s  eventId: %s\n", delivery.TenantId, delivery.WebhookId, delivery.Id)
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	k := deliveriesKey(delivery)
+	if _, exists := rs.deliveries[k][delivery.Id]; exists {
+		return fmt.Errorf("error saving delivery of event %q to webhook %q: %w", delivery.Id, delivery.WebhookId, storage.ErrConflict)
+	}
+	if rs.deliveries[k] == nil {
+		rs.deliveries[k] = map[string]webhook.Delivery{}
+	}
+	return rs.putDelivery(delivery)
+}
+
+// DueDeliveries returns up to limit pending deliveries with a next attempt at or before now, the earliest first.
+func (rs *RestaurantStorage) DueDeliveries(now time.Time, limit int32) ([]webhook.Delivery, error) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	due := []webhook.Delivery{}
+	for _, deliveries := range rs.deliveries {
+		for _, d := range deliveries {
+			if d.Status == model.Pending && d.NextAttempt != nil && !d.NextAttempt.After(now) {
+				c, err := clone(d)
+				if err != nil {
+					return nil, err
+				}
+				due = append(due, c)
+			}
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].NextAttempt.Equal(*due[j].NextAttempt) {
+			return due[i].WebhookId+due[i].Id < due[j].WebhookId+due[j].Id
+		}
+		return due[i].NextAttempt.Before(*due[j].NextAttempt)
+	})
+
+	if len(due) > int(limit) {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+// ClaimDelivery moves the next attempt of the delivery to until, unless it changed since it was returned by DueDeliveries.
+func (rs *RestaurantStorage) ClaimDelivery(delivery webhook.Delivery, until time.Time) (bool, error) {
+	log.Printf("memory.RestaurantStorage.ClaimDelivery tenantId: %s  webhookId: %s  eventId: %s\n", delivery.TenantId, delivery.WebhookId, delivery.Id)
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	k := deliveriesKey(delivery)
+	stored, exists := rs.deliveries[k][delivery.Id]
+	if !exists || stored.Status != model.Pending || delivery.NextAttempt == nil || !stored.NextAttempt.Equal(*delivery.NextAttempt) {
+		return false, nil
+	}
+	next := millis(until)
+	stored.NextAttempt = &next
+	rs.deliveries[k][delivery.Id] = stored
+	return true, nil
+}
+
+func (rs *RestaurantStorage) UpdateDelivery(delivery webhook.Delivery) error {
+	log.Printf("memory.RestaurantStorage.UpdateDelivery tenantId: %s  webhookId: %s  eventId: %s  status: %s\n", delivery.TenantId, delivery.WebhookId, delivery.Id, delivery.Status)
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if _, exists := rs.deliveries[deliveriesKey(delivery)][delivery.Id]; !exists {
+		return fmt.Errorf("error updating delivery of event %q to webhook %q: %w", delivery.Id, delivery.WebhookId, storage.ErrNotFound)
+	}
+	return rs.putDelivery(delivery)
+}
+
+// putDelivery stores a copy of the delivery, with the millisecond precision of the
+// next attempt of the DynamoDB storage. Callers must hold the write lock.
+func (rs *RestaurantStorage) putDelivery(delivery webhook.Delivery) error {
+	d, err := clone(delivery)
+	if err != nil {
+		return err
+	}
+	if d.NextAttempt != nil {
+		next := millis(*d.NextAttempt)
+		d.NextAttempt = &next
+	}
+	rs.deliveries[deliveriesKey(d)][d.Id] = d
+	return nil
+}
+
+// deliveriesKey returns the key of the deliveries of the webhook of the delivery.
+func deliveriesKey(d webhook.Delivery) webhookKey {
+	return webhookKey{d.TenantId, d.WebhookId}
+}
+
+// deliveryKey returns a key of the delivery that sorts in ascending order of the time of its event.
+func deliveryKey(d webhook.Delivery) string {
+	return fmt.Sprintf("%019d#%s", d.Created.UnixNano(), d.Id)
+}
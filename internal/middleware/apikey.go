@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lfroomin/restaurant-serverless/internal/apikey"
+	"github.com/lfroomin/restaurant-serverless/internal/auth"
+	"github.com/lfroomin/restaurant-serverless/internal/httpRequest"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/policy"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// apiKeyContext is the entry of the authorizer context of the request with the API key that
+// authenticated it, which Quota counts the request against.
+const apiKeyContext = "apiKey"
+
+// ApiKeys authenticates the requests with an API key in the X-Api-Key header and passes them
+// to next with the claims of the key, so that Authenticate lets them through, and with the key,
+// so that Quota counts them against its quota once they are rate limited. Read-only keys are
+// rejected with 403 for the other methods. Requests without an API key are passed on unchanged.
+func ApiKeys(store apikey.Store, next Handler) Handler {
+	return func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		key := httpRequest.Header(request, apikey.Header)
+		if key == "" {
+			return next(request)
+		}
+		if store == nil {
+			return invalidApiKey("API keys are not accepted"), nil
+		}
+		if httpRequest.Header(request, "Authorization") != "" {
+			return invalidApiKey("a request is authenticated with either an API key or a bearer token, not both"), nil
+		}
+
+		k, err := apikey.Authenticate(store, key)
+		if errors.Is(err, apikey.ErrInvalid) {
+			return invalidApiKey("the API key is invalid"), nil
+		}
+		if err != nil {
+			return httpResponse.NewServerError(err), nil
+		}
+
+		if k.Scope == model.Read && !isRead(request.HTTPMethod) {
+			return httpResponse.NewForbidden(string(policy.ReasonReadOnlyApiKey), "the API key is read-only"), nil
+		}
+
+		log.Printf("authenticated apiKeyId: %s\n", *k.Id)
+		request = auth.WithClaims(request, apikey.Claims(k))
+		// The key without its hash, as the request is logged
+		request.RequestContext.Authorizer[apiKeyContext] = k.ApiKey
+		return next(request)
+	}
+}
+
+// Quota counts the requests authenticated by ApiKeys against the quota of their API key, and
+// tells the quota left in the X-Quota-* headers of the response. Used up keys are rejected
+// with 429. It runs after RateLimit, so that the requests it rejects do not use the quota.
+// The other requests are passed on unchanged.
+func Quota(store apikey.Store, next Handler) Handler {
+	return func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		apiKey, ok := request.RequestContext.Authorizer[apiKeyContext].(model.ApiKey)
+		if !ok || store == nil {
+			return next(request)
+		}
+
+		usage, err := apikey.Use(store, apikey.Key{ApiKey: apiKey}, time.Now())
+		if errors.Is(err, apikey.ErrQuotaExceeded) {
+			log.Printf("quota exceeded apiKeyId: %s\n", *apiKey.Id)
+			resp := httpResponse.NewProblem(http.StatusTooManyRequests, httpResponse.CodeQuotaExceeded, "the quota of the API key is used up until "+usage.Reset.Format(time.RFC3339))
+			resp.Headers["Retry-After"] = strconv.FormatInt(int64(time.Until(usage.Reset).Seconds())+1, 10)
+			return withQuota(resp, usage), nil
+		}
+		if err != nil {
+			return httpResponse.NewServerError(err), nil
+		}
+
+		resp, err := next(request)
+		if err != nil || resp == nil {
+			return resp, err
+		}
+		return withQuota(resp, usage), nil
+	}
+}
+
+// invalidApiKey responds 401, without a challenge as API keys have no authentication scheme.
+func invalidApiKey(detail string) *events.APIGatewayProxyResponse {
+	return httpResponse.NewProblem(http.StatusUnauthorized, httpResponse.CodeUnauthorized, detail)
+}
+
+func withQuota(resp *events.APIGatewayProxyResponse, usage apikey.Usage) *events.APIGatewayProxyResponse {
+	return withHeaders(resp, map[string]string{
+		"X-Quota-Limit":     strconv.Itoa(int(usage.Limit)),
+		"X-Quota-Remaining": strconv.Itoa(int(usage.Remaining)),
+		"X-Quota-Reset":     strconv.FormatInt(usage.Reset.Unix(), 10),
+	})
+}
@@ -0,0 +1,240 @@
+package middleware
+
+import (
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lfroomin/restaurant-serverless/internal/apikey"
+	"github.com/lfroomin/restaurant-serverless/internal/auth"
+	"github.com/lfroomin/restaurant-serverless/internal/auth/authtest"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/memory"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func Test_ApiKeys(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		method        string
+		scope         model.ApiKeyScope
+		limit         int32
+		revoked       bool
+		key           string
+		authorization string
+		responseCode  int
+		code          httpResponse.Code
+		subject       string
+		remaining     string
+	}{
+		{
+			name:         "read key reads",
+			method:       http.MethodGet,
+			scope:        model.Read,
+			limit:        3,
+			responseCode: http.StatusOK,
+			subject:      "apikey:key1",
+			remaining:    "2",
+		},
+		{
+			name:         "write key writes",
+			method:       http.MethodPost,
+			scope:        model.Write,
+			limit:        3,
+			responseCode: http.StatusOK,
+			subject:      "apikey:key1",
+			remaining:    "2",
+		},
+		{
+			name:         "read key writes",
+			method:       http.MethodDelete,
+			scope:        model.Read,
+			limit:        3,
+			responseCode: http.StatusForbidden,
+			code:         httpResponse.CodeForbidden,
+		},
+		{
+			name:         "quota exceeded",
+			method:       http.MethodGet,
+			scope:        model.Read,
+			responseCode: http.StatusTooManyRequests,
+			code:         httpResponse.CodeQuotaExceeded,
+			remaining:    "0",
+		},
+		{
+			name:         "other key",
+			method:       http.MethodGet,
+			scope:        model.Read,
+			limit:        3,
+			key:          "key1.0123",
+			responseCode: http.StatusUnauthorized,
+			code:         httpResponse.CodeUnauthorized,
+		},
+		{
+			name:         "revoked key",
+			method:       http.MethodGet,
+			scope:        model.Read,
+			limit:        3,
+			revoked:      true,
+			responseCode: http.StatusUnauthorized,
+			code:         httpResponse.CodeUnauthorized,
+		},
+		{
+			name:          "key and bearer token",
+			method:        http.MethodGet,
+			scope:         model.Read,
+			limit:         3,
+			authorization: "Bearer token",
+			responseCode:  http.StatusUnauthorized,
+			code:          httpResponse.CodeUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := memory.New()
+			key := saveApiKey(t, store, tc.scope, tc.limit)
+			if tc.revoked {
+				_, err := store.RevokeApiKey("key1", time.Now())
+				require.NoError(t, err)
+			}
+			if tc.key != "" {
+				key = tc.key
+			}
+
+			var subject string
+			next := func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+				if claims, ok := auth.FromRequest(request); ok {
+					subject = claims.Subject
+				}
+				return httpResponse.New(http.StatusOK, nil), nil
+			}
+
+			request := events.APIGatewayProxyRequest{HTTPMethod: tc.method, Headers: map[string]string{"x-api-key": key}}
+			if tc.authorization != "" {
+				request.Headers["Authorization"] = tc.authorization
+			}
+
+			resp, err := ApiKeys(store, Quota(store, next))(request)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			assert.Equal(t, tc.subject, subject)
+			assert.Equal(t, tc.remaining, resp.Headers["X-Quota-Remaining"])
+			if tc.remaining != "" {
+				assert.Equal(t, strconv.Itoa(int(tc.limit)), resp.Headers["X-Quota-Limit"])
+				assert.NotEmpty(t, resp.Headers["X-Quota-Reset"])
+			}
+			if tc.code != "" {
+				problem := model.Problem{}
+				require.NoError(t, json.Unmarshal([]byte(resp.Body), &problem))
+				assert.Equal(t, string(tc.code), problem.Code)
+			}
+			if tc.responseCode == http.StatusTooManyRequests {
+				assert.NotEmpty(t, resp.Headers["Retry-After"])
+			}
+			// The shared CORS headers are not changed
+			assert.NotContains(t, httpResponse.CORSHeaders, "X-Quota-Remaining")
+		})
+	}
+}
+
+func Test_ApiKeysWithoutKey(t *testing.T) {
+	t.Parallel()
+
+	next := func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		_, ok := auth.FromRequest(request)
+		assert.False(t, ok)
+		return &events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+	}
+
+	resp, err := ApiKeys(memory.New(), next)(events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Without a store, the API keys are rejected
+	resp, err = ApiKeys(nil, next)(events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, Headers: map[string]string{apikey.Header: "key1.0123"}})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+// Test_ApiKeysAuthenticate checks that the requests of an API key skip the bearer token.
+func Test_ApiKeysAuthenticate(t *testing.T) {
+	t.Parallel()
+
+	issuer := authtest.NewIssuer(t)
+	verifier := auth.NewVerifier(auth.NewKeySet(issuer.WriteJWKS(t)), authtest.IssuerURL, authtest.Audience)
+	store := memory.New()
+	key := saveApiKey(t, store, model.Write, 3)
+	next := func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		return &events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+	}
+
+	resp, err := ApiKeys(store, Authenticate(verifier, next))(events.APIGatewayProxyRequest{
+		HTTPMethod: http.MethodPost,
+		Headers:    map[string]string{apikey.Header: key},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+// Test_QuotaAfterRateLimit checks that the requests rejected by the rate limits do not use the
+// quota of the API key.
+func Test_QuotaAfterRateLimit(t *testing.T) {
+	t.Parallel()
+
+	store := memory.New()
+	key := saveApiKey(t, store, model.Read, 10)
+	limits := RateLimits{
+		Default:  ratelimit.Limit{Burst: 1, Per: time.Hour},
+		Geocode:  ratelimit.Limit{Burst: 1, Per: time.Hour},
+		SourceIP: ratelimit.Limit{Burst: 100, Per: time.Hour},
+	}
+	next := func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		return httpResponse.New(http.StatusOK, nil), nil
+	}
+	handler := ApiKeys(store, RateLimit(store, limits, Quota(store, next)))
+	request := events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, Resource: "/", Headers: map[string]string{apikey.Header: key}}
+
+	resp, err := handler(request)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "9", resp.Headers["X-Quota-Remaining"])
+
+	for i := 0; i < 3; i++ {
+		resp, err = handler(request)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Empty(t, resp.Headers["X-Quota-Remaining"])
+	}
+
+	// Only the request the rate limit let through was counted
+	count, err := store.UseApiKey("key1", time.Now().UTC().Format("2006-01-02"), 10, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), count)
+}
+
+// saveApiKey stores the API key key1 of tenant1 and returns its key.
+func saveApiKey(t *testing.T, store *memory.RestaurantStorage, scope model.ApiKeyScope, limit int32) string {
+	id, tenantId := "key1", "tenant1"
+	key, err := apikey.NewKey(id)
+	require.NoError(t, err)
+
+	k := apikey.Key{
+		ApiKey: model.ApiKey{Id: &id, Name: "partner", Scope: scope, Quota: model.ApiKeyQuota{Limit: limit, Period: model.Day}, TenantId: &tenantId},
+		Hash:   apikey.Hash(key),
+	}
+	require.NoError(t, store.SaveApiKey(k))
+	return key
+}
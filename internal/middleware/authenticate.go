@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lfroomin/restaurant-serverless/internal/auth"
+	"github.com/lfroomin/restaurant-serverless/internal/httpRequest"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// verifier verifies the bearer tokens of the Lambdas. It is nil, and the API open, when
+// JwksUrl is not set, such as for the local server.
+var verifier = loadVerifier()
+
+func loadVerifier() *auth.Verifier {
+	v, err := auth.NewVerifierFromEnv()
+	if err != nil {
+		log.Fatalf("error configuring authentication: %s", err.Error())
+	}
+	if v == nil {
+		log.Println("JwksUrl is not set, so the requests are not authenticated")
+	}
+	return v
+}
+
+// Authenticate verifies the JWT bearer token of the Authorization header and passes the
+// request to next with its claims, which handlers get with auth.FromRequest. Requests
+// without a token are passed on anonymously when they only read, and otherwise rejected
+// with 401, like requests with an invalid token. Requests that ApiKeys authenticated, and
+// every request when the verifier is nil, are passed on.
+func Authenticate(verifier *auth.Verifier, next Handler) Handler {
+	if verifier == nil {
+		return next
+	}
+
+	return func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		if claims, ok := auth.FromRequest(request); ok && claims.ApiKeyId != "" {
+			return next(request)
+		}
+
+		authorization := httpRequest.Header(request, "Authorization")
+		if authorization == "" {
+			if isRead(request.HTTPMethod) {
+				return next(request)
+			}
+			return unauthorized("", "a bearer token is required"), nil
+		}
+
+		scheme, token, _ := strings.Cut(authorization, " ")
+		if !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+			return unauthorized("invalid_request", "the Authorization header must be a bearer token"), nil
+		}
+
+		claims, err := verifier.Verify(strings.TrimSpace(token))
+		if errors.Is(err, auth.ErrKeySetUnavailable) {
+			return httpResponse.NewServerError(err), nil
+		}
+		if err != nil {
+			log.Printf("invalid token: %s\n", err.Error())
+			return unauthorized("invalid_token", auth.Error(err)), nil
+		}
+
+		log.Printf("authenticated subject: %s\n", claims.Subject)
+		return next(auth.WithClaims(request, claims))
+	}
+}
+
+func isRead(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// unauthorized responds 401 with the RFC 6750 challenge of the error.
+func unauthorized(bearerError, detail string) *events.APIGatewayProxyResponse {
+	resp := httpResponse.NewProblem(http.StatusUnauthorized, httpResponse.CodeUnauthorized, detail)
+	challenge := "Bearer"
+	if bearerError != "" {
+		challenge = fmt.Sprintf("Bearer error=%q, error_description=%q", bearerError, detail)
+	}
+	resp.Headers["WWW-Authenticate"] = challenge
+	return resp
+}
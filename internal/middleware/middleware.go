@@ -0,0 +1,68 @@
+// Package middleware wraps the Lambda handlers of the controllers with the checks that
+// are shared by all the endpoints, so that they are applied before the handler runs.
+package middleware
+
+import (
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lfroomin/restaurant-serverless/internal/apikey"
+	"github.com/lfroomin/restaurant-serverless/internal/awsConfig"
+	"github.com/lfroomin/restaurant-serverless/internal/dynamo"
+	"github.com/lfroomin/restaurant-serverless/internal/ratelimit"
+	"log"
+	"os"
+)
+
+// Handler is the signature of the Lambda handlers in the controllers package.
+type Handler func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error)
+
+// Store has the API keys and the rate limit buckets.
+type Store interface {
+	apikey.Store
+	ratelimit.Store
+}
+
+// store is the restaurants table of the Lambdas. It is nil, so that the API keys are rejected
+// and the requests are not rate limited, when RestaurantsTable is not set.
+var store = loadStore()
+
+func loadStore() Store {
+	table := os.Getenv("RestaurantsTable")
+	if table == "" {
+		log.Println("RestaurantsTable is not set, so the API keys are not accepted and the requests are not rate limited")
+		return nil
+	}
+
+	cfg, err := awsConfig.New()
+	if err != nil {
+		log.Fatalf("error configuring the middleware storage: %s", err.Error())
+	}
+	return dynamo.New(cfg, table)
+}
+
+// Wrap wraps a handler of the API in the middleware shared by all the endpoints.
+func Wrap(h Handler) Handler {
+	return WrapWithStore(store, h)
+}
+
+// WrapWithStore is Wrap with the API keys and rate limit buckets of the store, such as the
+// storage of the local server. The requests are rate limited by their source IP before they
+// are authenticated, then by their client once it is known, and only the requests the rate
+// limits let through are counted against the quota of their API key.
+func WrapWithStore(s Store, h Handler) Handler {
+	return ProblemInstance(RateLimitSourceIP(s, rateLimits,
+		ApiKeys(s, Authenticate(verifier, RateLimit(s, rateLimits, Quota(s, Validate(h)))))))
+}
+
+// withHeaders adds the headers to a copy of the headers of the response, which may be
+// shared, such as httpResponse.CORSHeaders.
+func withHeaders(resp *events.APIGatewayProxyResponse, headers map[string]string) *events.APIGatewayProxyResponse {
+	h := make(map[string]string, len(resp.Headers)+len(headers))
+	for k, v := range resp.Headers {
+		h[k] = v
+	}
+	for k, v := range headers {
+		h[k] = v
+	}
+	resp.Headers = h
+	return resp
+}
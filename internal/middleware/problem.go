@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"log"
+)
+
+// ProblemInstance sets the instance of the problem responses of next to the API Gateway
+// request ID. The request ID of an internal error is logged with its correlation id.
+func ProblemInstance(next Handler) Handler {
+	return func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		response, err := next(request)
+		requestId := request.RequestContext.RequestID
+		if err != nil || response == nil || requestId == "" || response.Headers["Content-Type"] != httpResponse.ProblemContentType {
+			return response, err
+		}
+
+		problem := model.Problem{}
+		if err := json.Unmarshal([]byte(response.Body), &problem); err != nil {
+			log.Printf("error unmarshalling problem response: %s\n", err.Error())
+			return response, nil
+		}
+		if problem.CorrelationId != nil {
+			log.Printf("internal error requestId: %s  correlationId: %s\n", requestId, *problem.CorrelationId)
+		}
+
+		problem.Instance = &requestId
+		body, err := json.Marshal(problem)
+		if err != nil {
+			log.Printf("error marshalling problem response: %s\n", err.Error())
+			return response, nil
+		}
+		response.Body = string(body)
+		return response, nil
+	}
+}
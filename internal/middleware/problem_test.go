@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func Test_ProblemInstance(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		requestId    string
+		response     *events.APIGatewayProxyResponse
+		responseBody string
+	}{
+		{
+			name:         "problem",
+			requestId:    "reqId",
+			response:     httpResponse.NewNotFound("the restaurant was not found"),
+			responseBody: `{"code":"NOT_FOUND","detail":"the restaurant was not found","instance":"reqId","status":404,"title":"Not Found","type":"urn:restaurant-serverless:problem:NOT_FOUND"}`,
+		},
+		{
+			name:         "not a problem",
+			requestId:    "reqId",
+			response:     httpResponse.New(http.StatusOK, map[string]string{"name": "name"}),
+			responseBody: `{"name":"name"}`,
+		},
+		{
+			name:         "no request id",
+			response:     httpResponse.NewNotFound("the restaurant was not found"),
+			responseBody: `{"code":"NOT_FOUND","detail":"the restaurant was not found","status":404,"title":"Not Found","type":"urn:restaurant-serverless:problem:NOT_FOUND"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			next := func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+				return tc.response, nil
+			}
+			request := events.APIGatewayProxyRequest{RequestContext: events.APIGatewayProxyRequestContext{RequestID: tc.requestId}}
+
+			resp, err := ProblemInstance(next)(request)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.responseBody, resp.Body)
+		})
+	}
+}
+
+func Test_ProblemInstanceServerError(t *testing.T) {
+	t.Parallel()
+
+	next := func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		return httpResponse.NewServerError(errors.New("ResourceNotFoundException: table not found")), nil
+	}
+	request := events.APIGatewayProxyRequest{RequestContext: events.APIGatewayProxyRequestContext{RequestID: "reqId"}}
+
+	resp, err := ProblemInstance(next)(request)
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Contains(t, resp.Body, `"correlationId":`)
+	assert.Contains(t, resp.Body, `"instance":"reqId"`)
+	assert.NotContains(t, resp.Body, "table not found")
+}
@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lfroomin/restaurant-serverless/internal/auth"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/ratelimit"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// geocodeBucket is the bucket of the endpoints that geocode an address with the Location
+// service, which they share so that a client cannot use up its budget by spreading the
+// requests over them.
+const geocodeBucket = "geocode"
+
+// geocodeEndpoints are the methods and resources of the endpoints that geocode an address.
+var geocodeEndpoints = map[string]bool{
+	http.MethodPost + " /":                true,
+	http.MethodPost + " /{restaurantId}":  true,
+	http.MethodPatch + " /{restaurantId}": true,
+	http.MethodPost + " /geocode/preview": true,
+}
+
+// sourceIPBucket is the bucket of all the requests of a source IP, whoever they authenticate as.
+const sourceIPBucket = "all"
+
+// RateLimits are the rates the clients can call each endpoint at, the stricter rate they
+// can call the endpoints that geocode an address at, and the rate each source IP can send
+// requests at before they are authenticated.
+type RateLimits struct {
+	Default  ratelimit.Limit
+	Geocode  ratelimit.Limit
+	SourceIP ratelimit.Limit
+}
+
+// rateLimits are the rates per minute of RateLimitPerMinute, GeocodeRateLimitPerMinute and
+// SourceIpRateLimitPerMinute, 60, 10 and 300 when they are not set.
+var rateLimits = RateLimits{
+	Default:  ratelimit.Limit{Burst: perMinute("RateLimitPerMinute", 60), Per: time.Minute},
+	Geocode:  ratelimit.Limit{Burst: perMinute("GeocodeRateLimitPerMinute", 10), Per: time.Minute},
+	SourceIP: ratelimit.Limit{Burst: perMinute("SourceIpRateLimitPerMinute", 300), Per: time.Minute},
+}
+
+func perMinute(env string, def int32) int32 {
+	v := os.Getenv(env)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 32)
+	if err != nil || n < 1 {
+		log.Fatalf("%s must be a positive integer: %q", env, v)
+	}
+	return int32(n)
+}
+
+// RateLimitSourceIP takes a token from the bucket of the source IP of the request, whatever
+// its endpoint and credentials, and rejects the request with 429 and Retry-After when the
+// bucket is empty. It runs before ApiKeys and Authenticate, so that a client cannot look up
+// API keys or try tokens faster than the rate, and the requests it rejects do not use the
+// quota of an API key. A nil store does not limit the requests.
+func RateLimitSourceIP(store ratelimit.Store, limits RateLimits, next Handler) Handler {
+	if store == nil {
+		return next
+	}
+
+	return func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		key := "ip:" + request.RequestContext.Identity.SourceIP + "#" + sourceIPBucket
+
+		result, err := take(store, key, limits.SourceIP)
+		if err != nil {
+			return httpResponse.NewServerError(err), nil
+		}
+		if !result.Allowed {
+			return rateLimited(key, result), nil
+		}
+
+		// The RateLimit-* headers are those of the bucket of the client on the endpoint
+		return next(request)
+	}
+}
+
+// RateLimit takes a token from the bucket of the client of the request on its endpoint, and
+// rejects the request with 429 and Retry-After when the bucket is empty. The client is the
+// API key or the subject of the token that authenticated the request, or else its source IP.
+// The responses have the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers of
+// the bucket. A nil store does not limit the requests.
+func RateLimit(store ratelimit.Store, limits RateLimits, next Handler) Handler {
+	if store == nil {
+		return next
+	}
+
+	return func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		bucket, limit := rateLimitBucket(request, limits)
+		key := client(request) + "#" + bucket
+
+		result, err := take(store, key, limit)
+		if err != nil {
+			return httpResponse.NewServerError(err), nil
+		}
+		if !result.Allowed {
+			return rateLimited(key, result), nil
+		}
+
+		resp, err := next(request)
+		if err != nil || resp == nil {
+			return resp, err
+		}
+		return withRateLimit(resp, result), nil
+	}
+}
+
+// take takes a token from the bucket of the key.
+func take(store ratelimit.Store, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	result, err := ratelimit.Take(store, key, limit, time.Now())
+	if errors.Is(err, ratelimit.ErrContended) {
+		// The client sends so many concurrent requests that it is over its rate anyway
+		return ratelimit.Result{Limit: limit.Burst, RetryAfter: time.Second}, nil
+	}
+	return result, err
+}
+
+// rateLimited responds 429 with the time until the bucket of the key has a token again.
+func rateLimited(key string, result ratelimit.Result) *events.APIGatewayProxyResponse {
+	log.Printf("rate limited key: %s\n", key)
+	retryAfter := ceilSeconds(result.RetryAfter)
+	resp := httpResponse.NewProblem(http.StatusTooManyRequests, httpResponse.CodeRateLimited,
+		fmt.Sprintf("too many requests, retry in %d seconds", retryAfter))
+	resp.Headers["Retry-After"] = strconv.FormatInt(retryAfter, 10)
+	return withRateLimit(resp, result)
+}
+
+// rateLimitBucket returns the name and the limit of the bucket of the endpoint of the request.
+func rateLimitBucket(request events.APIGatewayProxyRequest, limits RateLimits) (string, ratelimit.Limit) {
+	resource := request.Resource
+	if resource == "" {
+		resource = request.RequestContext.ResourcePath
+	}
+
+	endpoint := request.HTTPMethod + " " + resource
+	if geocodeEndpoints[endpoint] {
+		return geocodeBucket, limits.Geocode
+	}
+	return endpoint, limits.Default
+}
+
+// client returns who sent the request: its API key, the subject of its token, or its source IP.
+func client(request events.APIGatewayProxyRequest) string {
+	if claims, ok := auth.FromRequest(request); ok {
+		if claims.ApiKeyId != "" {
+			return "apikey:" + claims.ApiKeyId
+		}
+		return "sub:" + claims.Subject
+	}
+	return "ip:" + request.RequestContext.Identity.SourceIP
+}
+
+func withRateLimit(resp *events.APIGatewayProxyResponse, result ratelimit.Result) *events.APIGatewayProxyResponse {
+	return withHeaders(resp, map[string]string{
+		"RateLimit-Limit":     strconv.Itoa(int(result.Limit)),
+		"RateLimit-Remaining": strconv.Itoa(int(result.Remaining)),
+		"RateLimit-Reset":     strconv.FormatInt(ceilSeconds(result.Reset), 10),
+	})
+}
+
+// ceilSeconds rounds the duration up to whole seconds, so that a client that waits for them is not rejected again.
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,218 @@
+package middleware
+
+import (
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lfroomin/restaurant-serverless/internal/auth"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/memory"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var testRateLimits = RateLimits{
+	Default:  ratelimit.Limit{Burst: 3, Per: time.Minute},
+	Geocode:  ratelimit.Limit{Burst: 2, Per: time.Hour},
+	SourceIP: ratelimit.Limit{Burst: 2, Per: time.Hour},
+}
+
+func Test_RateLimit(t *testing.T) {
+	t.Parallel()
+
+	user1 := auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "user1"}}
+	apiKey1 := auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "apikey:key1"}, ApiKeyId: "key1"}
+
+	type call struct {
+		method   string
+		resource string
+		claims   *auth.Claims
+		sourceIP string
+	}
+	get := call{method: http.MethodGet, resource: "/", sourceIP: "192.0.2.1"}
+	create := call{method: http.MethodPost, resource: "/", sourceIP: "192.0.2.1"}
+	patch := call{method: http.MethodPatch, resource: "/{restaurantId}", sourceIP: "192.0.2.1"}
+
+	testCases := []struct {
+		name         string
+		before       []call
+		call         call
+		responseCode int
+		limit        string
+		remaining    string
+	}{
+		{
+			name:         "first request",
+			call:         get,
+			responseCode: http.StatusOK,
+			limit:        "3",
+			remaining:    "2",
+		},
+		{
+			name:         "last request",
+			before:       []call{get, get},
+			call:         get,
+			responseCode: http.StatusOK,
+			limit:        "3",
+			remaining:    "0",
+		},
+		{
+			name:         "too many requests",
+			before:       []call{get, get, get},
+			call:         get,
+			responseCode: http.StatusTooManyRequests,
+			limit:        "3",
+			remaining:    "0",
+		},
+		{
+			name:         "endpoints have separate buckets",
+			before:       []call{get, get, get},
+			call:         call{method: http.MethodGet, resource: "/nearby", sourceIP: "192.0.2.1"},
+			responseCode: http.StatusOK,
+			limit:        "3",
+			remaining:    "2",
+		},
+		{
+			name:         "geocoding endpoints have a stricter bucket",
+			call:         create,
+			responseCode: http.StatusOK,
+			limit:        "2",
+			remaining:    "1",
+		},
+		{
+			name:         "geocoding endpoints share their bucket",
+			before:       []call{create, {method: http.MethodPost, resource: "/geocode/preview", sourceIP: "192.0.2.1"}},
+			call:         patch,
+			responseCode: http.StatusTooManyRequests,
+			limit:        "2",
+			remaining:    "0",
+		},
+		{
+			name:         "source IPs have separate buckets",
+			before:       []call{get, get, get},
+			call:         call{method: http.MethodGet, resource: "/", sourceIP: "192.0.2.2"},
+			responseCode: http.StatusOK,
+			limit:        "3",
+			remaining:    "2",
+		},
+		{
+			name:         "the subject of the token is the client",
+			before:       []call{{method: http.MethodGet, resource: "/", claims: &user1, sourceIP: "192.0.2.1"}, get, get},
+			call:         call{method: http.MethodGet, resource: "/", claims: &user1, sourceIP: "192.0.2.2"},
+			responseCode: http.StatusOK,
+			limit:        "3",
+			remaining:    "1",
+		},
+		{
+			name:         "the API key is the client",
+			before:       []call{{method: http.MethodPost, resource: "/", claims: &apiKey1, sourceIP: "192.0.2.1"}, create},
+			call:         call{method: http.MethodPost, resource: "/", claims: &apiKey1, sourceIP: "192.0.2.2"},
+			responseCode: http.StatusOK,
+			limit:        "2",
+			remaining:    "0",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			next := func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+				return httpResponse.New(http.StatusOK, nil), nil
+			}
+			h := RateLimit(memory.New(), testRateLimits, next)
+
+			newRequest := func(c call) events.APIGatewayProxyRequest {
+				request := events.APIGatewayProxyRequest{HTTPMethod: c.method, Resource: c.resource}
+				request.RequestContext.Identity.SourceIP = c.sourceIP
+				if c.claims != nil {
+					request = auth.WithClaims(request, *c.claims)
+				}
+				return request
+			}
+			for _, c := range tc.before {
+				resp, err := h(newRequest(c))
+				require.NoError(t, err)
+				require.Equal(t, http.StatusOK, resp.StatusCode)
+			}
+
+			resp, err := h(newRequest(tc.call))
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			assert.Equal(t, tc.limit, resp.Headers["RateLimit-Limit"])
+			assert.Equal(t, tc.remaining, resp.Headers["RateLimit-Remaining"])
+			reset, err := strconv.Atoi(resp.Headers["RateLimit-Reset"])
+			require.NoError(t, err)
+			assert.Positive(t, reset)
+			if tc.responseCode == http.StatusTooManyRequests {
+				retryAfter, err := strconv.Atoi(resp.Headers["Retry-After"])
+				require.NoError(t, err)
+				assert.Positive(t, retryAfter)
+				problem := model.Problem{}
+				require.NoError(t, json.Unmarshal([]byte(resp.Body), &problem))
+				assert.Equal(t, string(httpResponse.CodeRateLimited), problem.Code)
+			}
+			// The shared CORS headers are not changed
+			assert.NotContains(t, httpResponse.CORSHeaders, "RateLimit-Limit")
+		})
+	}
+}
+
+// Test_RateLimitSourceIP checks that a source IP cannot try API keys faster than its rate.
+func Test_RateLimitSourceIP(t *testing.T) {
+	t.Parallel()
+
+	store := memory.New()
+	next := func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		return httpResponse.New(http.StatusOK, nil), nil
+	}
+	h := RateLimitSourceIP(store, testRateLimits, ApiKeys(store, next))
+	newRequest := func(sourceIP string) events.APIGatewayProxyRequest {
+		request := events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, Resource: "/", Headers: map[string]string{"X-Api-Key": "key1.0123"}}
+		request.RequestContext.Identity.SourceIP = sourceIP
+		return request
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := h(newRequest("192.0.2.1"))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	}
+
+	resp, err := h(newRequest("192.0.2.1"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.NotEmpty(t, resp.Headers["Retry-After"])
+	assert.Equal(t, "2", resp.Headers["RateLimit-Limit"])
+	problem := model.Problem{}
+	require.NoError(t, json.Unmarshal([]byte(resp.Body), &problem))
+	assert.Equal(t, string(httpResponse.CodeRateLimited), problem.Code)
+
+	// The other source IPs have their own bucket
+	resp, err = h(newRequest("192.0.2.2"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func Test_RateLimitDisabled(t *testing.T) {
+	t.Parallel()
+
+	next := func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		return &events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+	}
+
+	h := RateLimitSourceIP(nil, testRateLimits, RateLimit(nil, testRateLimits, next))
+	for i := 0; i < 5; i++ {
+		resp, err := h(events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost, Resource: "/"})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+}
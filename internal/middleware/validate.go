@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lfroomin/restaurant-serverless/internal/openapi"
+	"log"
+)
+
+// Validate responds 400 with the violations when the parameters or the body of the request
+// do not conform to the operation of its resource and method in the OpenAPI spec (see
+// openapi.ValidateRequest). Requests to resources that are not in the spec are passed to
+// next unchecked.
+func Validate(next Handler) Handler {
+	return func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		if resp := openapi.ValidateRequest(request); resp != nil {
+			log.Printf("invalid request %s %s: %s\n", request.HTTPMethod, request.Resource, resp.Body)
+			return resp, nil
+		}
+
+		return next(request)
+	}
+}
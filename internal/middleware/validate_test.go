@@ -0,0 +1,212 @@
+package middleware
+
+import (
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func Test_Validate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		method         string
+		resource       string
+		pathParameters map[string]string
+		query          map[string]string
+		contentType    string
+		body           string
+		responseCode   int
+		code           httpResponse.Code
+		violations     []model.Violation
+	}{
+		{
+			name:         "valid restaurant",
+			method:       http.MethodPost,
+			resource:     "/",
+			contentType:  "application/json",
+			body:         `{"name":"name","address":{"city":"Seattle"},"openingHours":{"weekly":[{"day":"friday","open":"18:00","close":"02:00"}]}}`,
+			responseCode: http.StatusOK,
+		},
+		{
+			name:         "missing name",
+			method:       http.MethodPost,
+			resource:     "/",
+			contentType:  "application/json",
+			body:         `{"description":"description"}`,
+			responseCode: http.StatusBadRequest,
+			violations:   []model.Violation{{Field: "name", Message: "is required"}},
+		},
+		{
+			name:         "empty name",
+			method:       http.MethodPost,
+			resource:     "/",
+			contentType:  "application/json",
+			body:         `{"name":""}`,
+			responseCode: http.StatusBadRequest,
+			violations:   []model.Violation{{Field: "name", Message: "minimum string length is 1"}},
+		},
+		{
+			name:         "unknown fields",
+			method:       http.MethodPost,
+			resource:     "/",
+			contentType:  "application/json",
+			body:         `{"name":"name","cuisine":"thai","address":{"city":"Seattle","planet":"Earth"}}`,
+			responseCode: http.StatusBadRequest,
+			violations:   []model.Violation{{Field: "address.planet", Message: "is not a known field"}, {Field: "cuisine", Message: "is not a known field"}},
+		},
+		{
+			name:         "nested violations",
+			method:       http.MethodPost,
+			resource:     "/",
+			contentType:  "application/json",
+			body:         `{"name":1,"openingHours":{"weekly":[{"day":"friday","open":"18:00"}]}}`,
+			responseCode: http.StatusBadRequest,
+			violations:   []model.Violation{{Field: "name", Message: "must be of type string"}, {Field: "openingHours.weekly.0.close", Message: "is required"}},
+		},
+		{
+			name:         "no content type",
+			method:       http.MethodPost,
+			resource:     "/",
+			body:         `{"name":""}`,
+			responseCode: http.StatusBadRequest,
+			violations:   []model.Violation{{Field: "name", Message: "minimum string length is 1"}},
+		},
+		{
+			name:         "empty body",
+			method:       http.MethodPost,
+			resource:     "/",
+			contentType:  "application/json",
+			responseCode: http.StatusBadRequest,
+			code:         httpResponse.CodeMalformedBody,
+		},
+		{
+			name:         "malformed body",
+			method:       http.MethodPost,
+			resource:     "/",
+			contentType:  "application/json",
+			body:         `{"name":"name"`,
+			responseCode: http.StatusBadRequest,
+			code:         httpResponse.CodeMalformedBody,
+		},
+		{
+			name:         "wrong content type",
+			method:       http.MethodPost,
+			resource:     "/",
+			contentType:  "text/plain",
+			body:         `{"name":"name"}`,
+			responseCode: http.StatusUnsupportedMediaType,
+			code:         httpResponse.CodeUnsupportedMediaType,
+		},
+		{
+			name:           "review out of range",
+			method:         http.MethodPost,
+			resource:       "/{restaurantId}/reviews",
+			pathParameters: map[string]string{"restaurantId": "restId"},
+			contentType:    "application/json",
+			body:           `{"rating":6,"author":"author"}`,
+			responseCode:   http.StatusBadRequest,
+			violations:     []model.Violation{{Field: "rating", Message: "number must be at most 5"}},
+		},
+		{
+			name:           "path parameter too long",
+			method:         http.MethodGet,
+			resource:       "/{restaurantId}",
+			pathParameters: map[string]string{"restaurantId": "0123456789012345678901234567890123456789012345678901234567890123456789"},
+			responseCode:   http.StatusBadRequest,
+			violations:     []model.Violation{{Field: "restaurantId", Message: "maximum string length is 64"}},
+		},
+		{
+			name:         "query parameters",
+			method:       http.MethodGet,
+			resource:     "/nearby",
+			query:        map[string]string{"lat": "91", "lon": "east"},
+			responseCode: http.StatusBadRequest,
+			violations:   []model.Violation{{Field: "lat", Message: "number must be at most 90"}, {Field: "lon", Message: "value east: an invalid number: invalid syntax"}, {Field: "radiusKm", Message: "is required"}},
+		},
+		{
+			name:           "merge patch",
+			method:         http.MethodPatch,
+			resource:       "/{restaurantId}",
+			pathParameters: map[string]string{"restaurantId": "restId"},
+			contentType:    "application/merge-patch+json",
+			body:           `{"name":"name","description":null}`,
+			responseCode:   http.StatusOK,
+		},
+		{
+			name:           "merge patch not an object",
+			method:         http.MethodPatch,
+			resource:       "/{restaurantId}",
+			pathParameters: map[string]string{"restaurantId": "restId"},
+			contentType:    "application/merge-patch+json",
+			body:           `["name"]`,
+			responseCode:   http.StatusBadRequest,
+			violations:     []model.Violation{{Field: "body", Message: "must be of type object"}},
+		},
+		{
+			name:           "json patch is left to the handler",
+			method:         http.MethodPatch,
+			resource:       "/{restaurantId}",
+			pathParameters: map[string]string{"restaurantId": "restId"},
+			contentType:    "application/json-patch+json",
+			body:           `[{"op":"replace","path":"/name","value":"name"}]`,
+			responseCode:   http.StatusOK,
+		},
+		{
+			name:         "resource not in the spec",
+			method:       http.MethodPost,
+			resource:     "/photos",
+			body:         `{"unknown":true}`,
+			responseCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			called := false
+			next := func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+				called = true
+				return &events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+			}
+
+			request := events.APIGatewayProxyRequest{
+				Resource:              tc.resource,
+				HTTPMethod:            tc.method,
+				PathParameters:        tc.pathParameters,
+				QueryStringParameters: tc.query,
+				Headers:               map[string]string{},
+				Body:                  tc.body,
+			}
+			if tc.contentType != "" {
+				request.Headers["Content-Type"] = tc.contentType
+			}
+
+			resp, err := Validate(next)(request)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			assert.Equal(t, tc.responseCode == http.StatusOK, called)
+			if tc.violations != nil {
+				problem := model.Problem{}
+				require.NoError(t, json.Unmarshal([]byte(resp.Body), &problem))
+				assert.Equal(t, string(httpResponse.CodeInvalidRequest), problem.Code)
+				require.NotNil(t, problem.Violations)
+				assert.Equal(t, tc.violations, *problem.Violations)
+			}
+			if tc.code != "" {
+				problem := model.Problem{}
+				require.NoError(t, json.Unmarshal([]byte(resp.Body), &problem))
+				assert.Equal(t, string(tc.code), problem.Code)
+			}
+		})
+	}
+}
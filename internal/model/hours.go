@@ -0,0 +1,247 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dateLayout    = "2006-01-02"
+	minutesPerDay = 24 * 60
+
+	// statusHorizonDays is how far ahead Status looks for the next opening or closing
+	statusHorizonDays = 366
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// span is an opening interval in minutes after the midnight of the day it opens.
+// The close of an overnight interval is greater than minutesPerDay.
+type span struct {
+	open, close int
+}
+
+// period is an opening interval at actual times.
+type period struct {
+	start, end time.Time
+}
+
+// Validate returns an error describing the first invalid field of the opening hours.
+func (h OpeningHours) Validate() error {
+	if h.Weekly != nil {
+		if err := validateWeekly("openingHours.weekly", *h.Weekly); err != nil {
+			return err
+		}
+	}
+
+	if h.SpecialDates != nil {
+		dates := map[string]bool{}
+		for i, special := range *h.SpecialDates {
+			field := fmt.Sprintf("openingHours.specialDates[%d]", i)
+			if _, err := time.Parse(dateLayout, special.Date); err != nil {
+				return fmt.Errorf("%s.date %q is not a date (YYYY-MM-DD)", field, special.Date)
+			}
+			if dates[special.Date] {
+				return fmt.Errorf("%s.date %s is a duplicate", field, special.Date)
+			}
+			dates[special.Date] = true
+
+			spans, err := specialSpans(field, special)
+			if err != nil {
+				return err
+			}
+			if overlapping(spans) {
+				return fmt.Errorf("%s has overlapping intervals", field)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateWeekly validates weekly intervals, field is the name of the intervals in the error.
+func validateWeekly(field string, intervals []WeeklyInterval) error {
+	byDay := map[time.Weekday][]span{}
+	for i, interval := range intervals {
+		f := fmt.Sprintf("%s[%d]", field, i)
+		day, ok := weekdays[interval.Day]
+		if !ok {
+			return fmt.Errorf("%s.day %q is not a day of the week (monday to sunday)", f, interval.Day)
+		}
+		s, err := parseSpan(f, interval.Open, interval.Close)
+		if err != nil {
+			return err
+		}
+		byDay[day] = append(byDay[day], s)
+	}
+
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if overlapping(byDay[day]) {
+			return fmt.Errorf("%s has overlapping intervals on %s", field, strings.ToLower(day.String()))
+		}
+	}
+	return nil
+}
+
+// Status evaluates the opening hours at a time, in the time zone of the restaurant.
+// The opening hours should be valid, invalid intervals are ignored.
+func (h OpeningHours) Status(at time.Time, loc *time.Location) RestaurantStatus {
+	at = at.In(loc)
+	status := RestaurantStatus{At: at, TimezoneName: loc.String()}
+
+	periods, horizon := h.periods(at, loc)
+	for i, p := range periods {
+		if !p.end.After(at) {
+			continue
+		}
+
+		if !p.start.After(at) {
+			status.Open = true
+			if p.end.Before(horizon) {
+				status.NextClose = &periods[i].end
+			}
+			if i+1 < len(periods) {
+				status.NextOpen = &periods[i+1].start
+			}
+		} else {
+			status.NextOpen = &periods[i].start
+			if p.end.Before(horizon) {
+				status.NextClose = &periods[i].end
+			}
+		}
+		break
+	}
+
+	return status
+}
+
+// periods returns the merged opening periods from the day before the time up to
+// the horizon, which is returned too.
+func (h OpeningHours) periods(at time.Time, loc *time.Location) ([]period, time.Time) {
+	weekly := map[time.Weekday][]span{}
+	if h.Weekly != nil {
+		for _, interval := range *h.Weekly {
+			day, ok := weekdays[interval.Day]
+			if s, err := parseSpan("", interval.Open, interval.Close); ok && err == nil {
+				weekly[day] = append(weekly[day], s)
+			}
+		}
+	}
+
+	special := map[string][]span{}
+	if h.SpecialDates != nil {
+		for _, date := range *h.SpecialDates {
+			// Invalid intervals are dropped, the date still replaces the weekly hours
+			spans, _ := specialSpans("", date)
+			special[date.Date] = spans
+		}
+	}
+
+	// Each day is built from its own date so that the days with a DST
+	// transition are not 24 hours long
+	var periods []period
+	for d := -1; d <= statusHorizonDays; d++ {
+		day := time.Date(at.Year(), at.Month(), at.Day()+d, 0, 0, 0, 0, loc)
+		spans, ok := special[day.Format(dateLayout)]
+		if !ok {
+			spans = weekly[day.Weekday()]
+		}
+
+		for _, s := range spans {
+			start := time.Date(day.Year(), day.Month(), day.Day(), 0, s.open, 0, 0, loc)
+			end := time.Date(day.Year(), day.Month(), day.Day(), 0, s.close, 0, 0, loc)
+			// An interval can vanish in the hour skipped by a DST transition
+			if end.After(start) {
+				periods = append(periods, period{start: start, end: end})
+			}
+		}
+	}
+	horizon := time.Date(at.Year(), at.Month(), at.Day()+statusHorizonDays+1, 0, 0, 0, 0, loc)
+
+	sort.Slice(periods, func(i, j int) bool { return periods[i].start.Before(periods[j].start) })
+
+	// Merge the periods that overlap or touch, such as 18:00-24:00 and 00:00-02:00
+	var merged []period
+	for _, p := range periods {
+		if n := len(merged); n > 0 && !p.start.After(merged[n-1].end) {
+			if p.end.After(merged[n-1].end) {
+				merged[n-1].end = p.end
+			}
+			continue
+		}
+		merged = append(merged, p)
+	}
+
+	return merged, horizon
+}
+
+func specialSpans(field string, date SpecialDate) ([]span, error) {
+	var spans []span
+	if date.Intervals != nil {
+		for i, interval := range *date.Intervals {
+			s, err := parseSpan(fmt.Sprintf("%s.intervals[%d]", field, i), interval.Open, interval.Close)
+			if err != nil {
+				return nil, err
+			}
+			spans = append(spans, s)
+		}
+	}
+	return spans, nil
+}
+
+// parseSpan parses the opening and closing times of an interval. A closing time
+// before the opening time is on the next day.
+func parseSpan(field, opens, closes string) (span, error) {
+	o, ok := parseClock(opens)
+	if !ok || o == minutesPerDay {
+		return span{}, fmt.Errorf("%s.open %q is not a time (HH:MM)", field, opens)
+	}
+	c, ok := parseClock(closes)
+	if !ok {
+		return span{}, fmt.Errorf("%s.close %q is not a time (HH:MM, or 24:00)", field, closes)
+	}
+	if c == o {
+		return span{}, fmt.Errorf("%s.close %q is the same as the opening time", field, closes)
+	}
+
+	if c < o {
+		c += minutesPerDay
+	}
+	return span{open: o, close: c}, nil
+}
+
+// parseClock parses a time of day with the format HH:MM into minutes after
+// midnight. 24:00 is accepted as the midnight at the end of the day.
+func parseClock(clock string) (int, bool) {
+	if len(clock) != 5 || clock[2] != ':' || strings.ContainsAny(clock, "+-") {
+		return 0, false
+	}
+	hours, errHours := strconv.Atoi(clock[:2])
+	minutes, errMinutes := strconv.Atoi(clock[3:])
+	if errHours != nil || errMinutes != nil || minutes > 59 || hours > 24 || (hours == 24 && minutes != 0) {
+		return 0, false
+	}
+	return hours*60 + minutes, true
+}
+
+func overlapping(spans []span) bool {
+	sorted := append([]span(nil), spans...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].open < sorted[j].open })
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].open < sorted[i-1].close {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,284 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func weekly(intervals ...WeeklyInterval) OpeningHours {
+	return OpeningHours{Weekly: &intervals}
+}
+
+func Test_OpeningHoursValidate(t *testing.T) {
+	t.Parallel()
+	lunch := []TimeInterval{{Open: "11:00", Close: "14:00"}}
+	overlappingLunch := []TimeInterval{{Open: "11:00", Close: "14:00"}, {Open: "13:00", Close: "15:00"}}
+	badInterval := []TimeInterval{{Open: "11:00", Close: "1400"}}
+
+	testCases := []struct {
+		name   string
+		hours  OpeningHours
+		errMsg string
+	}{
+		{
+			name: "valid",
+			hours: OpeningHours{
+				Weekly: &[]WeeklyInterval{
+					{Day: "monday", Open: "11:00", Close: "14:00"},
+					{Day: "monday", Open: "17:00", Close: "22:00"},
+					{Day: "friday", Open: "18:00", Close: "02:00"},
+					{Day: "saturday", Open: "00:00", Close: "24:00"},
+				},
+				SpecialDates: &[]SpecialDate{
+					{Date: "2024-12-25"},
+					{Date: "2024-12-31", Intervals: &lunch},
+				},
+			},
+		},
+		{
+			name:  "empty",
+			hours: OpeningHours{},
+		},
+		{
+			name:   "unknown day",
+			hours:  weekly(WeeklyInterval{Day: "Funday", Open: "11:00", Close: "14:00"}),
+			errMsg: `openingHours.weekly[0].day "Funday" is not a day of the week (monday to sunday)`,
+		},
+		{
+			name:   "invalid open",
+			hours:  weekly(WeeklyInterval{Day: "monday", Open: "25:00", Close: "14:00"}),
+			errMsg: `openingHours.weekly[0].open "25:00" is not a time (HH:MM)`,
+		},
+		{
+			name:   "open at 24:00",
+			hours:  weekly(WeeklyInterval{Day: "monday", Open: "24:00", Close: "02:00"}),
+			errMsg: `openingHours.weekly[0].open "24:00" is not a time (HH:MM)`,
+		},
+		{
+			name:   "invalid close",
+			hours:  weekly(WeeklyInterval{Day: "monday", Open: "11:00", Close: "2pm"}),
+			errMsg: `openingHours.weekly[0].close "2pm" is not a time (HH:MM, or 24:00)`,
+		},
+		{
+			name:   "signed time",
+			hours:  weekly(WeeklyInterval{Day: "monday", Open: "+1:00", Close: "14:00"}),
+			errMsg: `openingHours.weekly[0].open "+1:00" is not a time (HH:MM)`,
+		},
+		{
+			name:   "empty interval",
+			hours:  weekly(WeeklyInterval{Day: "monday", Open: "11:00", Close: "11:00"}),
+			errMsg: `openingHours.weekly[0].close "11:00" is the same as the opening time`,
+		},
+		{
+			name: "overlapping",
+			hours: weekly(
+				WeeklyInterval{Day: "monday", Open: "11:00", Close: "14:00"},
+				WeeklyInterval{Day: "tuesday", Open: "11:00", Close: "14:00"},
+				WeeklyInterval{Day: "tuesday", Open: "13:30", Close: "22:00"},
+			),
+			errMsg: "openingHours.weekly has overlapping intervals on tuesday",
+		},
+		{
+			name: "overlapping overnight",
+			hours: weekly(
+				WeeklyInterval{Day: "friday", Open: "18:00", Close: "02:00"},
+				WeeklyInterval{Day: "friday", Open: "23:00", Close: "24:00"},
+			),
+			errMsg: "openingHours.weekly has overlapping intervals on friday",
+		},
+		{
+			name:   "invalid date",
+			hours:  OpeningHours{SpecialDates: &[]SpecialDate{{Date: "2024-02-30"}}},
+			errMsg: `openingHours.specialDates[0].date "2024-02-30" is not a date (YYYY-MM-DD)`,
+		},
+		{
+			name:   "duplicate date",
+			hours:  OpeningHours{SpecialDates: &[]SpecialDate{{Date: "2024-12-25"}, {Date: "2024-12-25", Intervals: &lunch}}},
+			errMsg: "openingHours.specialDates[1].date 2024-12-25 is a duplicate",
+		},
+		{
+			name:   "invalid special interval",
+			hours:  OpeningHours{SpecialDates: &[]SpecialDate{{Date: "2024-12-25", Intervals: &badInterval}}},
+			errMsg: `openingHours.specialDates[0].intervals[0].close "1400" is not a time (HH:MM, or 24:00)`,
+		},
+		{
+			name:   "overlapping special intervals",
+			hours:  OpeningHours{SpecialDates: &[]SpecialDate{{Date: "2024-12-25", Intervals: &overlappingLunch}}},
+			errMsg: "openingHours.specialDates[0] has overlapping intervals",
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.hours.Validate()
+
+			if tc.errMsg != "" {
+				if assert.Error(t, err) {
+					assert.Equal(t, tc.errMsg, err.Error())
+				}
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_OpeningHoursStatus(t *testing.T) {
+	t.Parallel()
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	// 2024-03-08 is a Friday, DST starts on Sunday 2024-03-10 at 02:00 and ends on Sunday 2024-11-03 at 02:00
+	hours := weekly(
+		WeeklyInterval{Day: "monday", Open: "11:00", Close: "14:00"},
+		WeeklyInterval{Day: "monday", Open: "17:00", Close: "22:00"},
+		WeeklyInterval{Day: "friday", Open: "18:00", Close: "24:00"},
+		WeeklyInterval{Day: "saturday", Open: "00:00", Close: "02:00"},
+		WeeklyInterval{Day: "saturday", Open: "18:00", Close: "04:00"},
+	)
+	christmas := OpeningHours{
+		Weekly: &[]WeeklyInterval{
+			{Day: "tuesday", Open: "09:00", Close: "17:00"},
+			{Day: "wednesday", Open: "09:00", Close: "17:00"},
+		},
+		// 2024-12-24 is a Tuesday
+		SpecialDates: &[]SpecialDate{
+			{Date: "2024-12-24", Intervals: &[]TimeInterval{{Open: "09:00", Close: "12:00"}}},
+			{Date: "2024-12-25", Name: aString("Christmas")},
+		},
+	}
+	always := weekly(
+		WeeklyInterval{Day: "sunday", Open: "00:00", Close: "24:00"},
+		WeeklyInterval{Day: "monday", Open: "00:00", Close: "24:00"},
+		WeeklyInterval{Day: "tuesday", Open: "00:00", Close: "24:00"},
+		WeeklyInterval{Day: "wednesday", Open: "00:00", Close: "24:00"},
+		WeeklyInterval{Day: "thursday", Open: "00:00", Close: "24:00"},
+		WeeklyInterval{Day: "friday", Open: "00:00", Close: "24:00"},
+		WeeklyInterval{Day: "saturday", Open: "00:00", Close: "24:00"},
+	)
+
+	testCases := []struct {
+		name      string
+		hours     OpeningHours
+		at        string
+		open      bool
+		nextOpen  string
+		nextClose string
+	}{
+		{
+			name:      "between intervals",
+			hours:     hours,
+			at:        "2024-03-04T15:00:00-05:00",
+			nextOpen:  "2024-03-04T17:00:00-05:00",
+			nextClose: "2024-03-04T22:00:00-05:00",
+		},
+		{
+			name:      "open",
+			hours:     hours,
+			at:        "2024-03-04T12:00:00-05:00",
+			open:      true,
+			nextOpen:  "2024-03-04T17:00:00-05:00",
+			nextClose: "2024-03-04T14:00:00-05:00",
+		},
+		{
+			name:      "evaluated in the restaurant time zone",
+			hours:     hours,
+			at:        "2024-03-04T17:00:00Z",
+			open:      true,
+			nextOpen:  "2024-03-04T17:00:00-05:00",
+			nextClose: "2024-03-04T14:00:00-05:00",
+		},
+		{
+			name:      "friday night continues on saturday",
+			hours:     hours,
+			at:        "2024-03-08T23:00:00-05:00",
+			open:      true,
+			nextOpen:  "2024-03-09T18:00:00-05:00",
+			nextClose: "2024-03-09T02:00:00-05:00",
+		},
+		{
+			name:      "overnight across the start of DST",
+			hours:     hours,
+			at:        "2024-03-10T01:30:00-05:00",
+			open:      true,
+			nextOpen:  "2024-03-11T11:00:00-04:00",
+			nextClose: "2024-03-10T04:00:00-04:00",
+		},
+		{
+			name:      "next week after the start of DST",
+			hours:     hours,
+			at:        "2024-03-10T12:00:00-04:00",
+			nextOpen:  "2024-03-11T11:00:00-04:00",
+			nextClose: "2024-03-11T14:00:00-04:00",
+		},
+		{
+			name:      "overnight across the end of DST",
+			hours:     hours,
+			at:        "2024-11-03T01:30:00-04:00",
+			open:      true,
+			nextOpen:  "2024-11-04T11:00:00-05:00",
+			nextClose: "2024-11-03T04:00:00-05:00",
+		},
+		{
+			name:      "special date hours",
+			hours:     christmas,
+			at:        "2024-12-24T13:00:00-05:00",
+			nextOpen:  "2024-12-31T09:00:00-05:00",
+			nextClose: "2024-12-31T17:00:00-05:00",
+		},
+		{
+			name:      "special date open",
+			hours:     christmas,
+			at:        "2024-12-24T10:00:00-05:00",
+			open:      true,
+			nextOpen:  "2024-12-31T09:00:00-05:00",
+			nextClose: "2024-12-24T12:00:00-05:00",
+		},
+		{
+			name:  "always open",
+			hours: always,
+			at:    "2024-03-10T03:00:00-04:00",
+			open:  true,
+		},
+		{
+			name: "never open",
+			at:   "2024-03-10T03:00:00-04:00",
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			at, err := time.Parse(time.RFC3339, tc.at)
+			require.NoError(t, err)
+
+			status := tc.hours.Status(at, newYork)
+
+			assert.Equal(t, tc.open, status.Open)
+			assert.Equal(t, "America/New_York", status.TimezoneName)
+			assert.True(t, at.Equal(status.At))
+			assert.Equal(t, newYork, status.At.Location())
+			assert.Equal(t, tc.nextOpen, format(status.NextOpen))
+			assert.Equal(t, tc.nextClose, format(status.NextClose))
+		})
+	}
+}
+
+func format(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func aString(s string) *string {
+	return &s
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	amountPattern   = regexp.MustCompile(`^\d+(\.\d+)?$`)
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+// Validate returns an error describing the first invalid field of the menu.
+func (m Menu) Validate() error {
+	if strings.TrimSpace(m.Name) == "" {
+		return errors.New("name is empty")
+	}
+
+	if m.Availability != nil {
+		if err := validateWeekly("availability", *m.Availability); err != nil {
+			return err
+		}
+	}
+
+	if m.Sections == nil {
+		return nil
+	}
+	for i, section := range *m.Sections {
+		field := fmt.Sprintf("sections[%d]", i)
+		if strings.TrimSpace(section.Name) == "" {
+			return fmt.Errorf("%s.name is empty", field)
+		}
+		if section.Items == nil {
+			continue
+		}
+		for j, item := range *section.Items {
+			if err := item.validate(fmt.Sprintf("%s.items[%d]", field, j)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (item MenuItem) validate(field string) error {
+	if strings.TrimSpace(item.Name) == "" {
+		return fmt.Errorf("%s.name is empty", field)
+	}
+	if !amountPattern.MatchString(item.Price.Amount) {
+		return fmt.Errorf("%s.price.amount %q is not a decimal amount, such as 12.50", field, item.Price.Amount)
+	}
+	if !currencyPattern.MatchString(item.Price.Currency) {
+		return fmt.Errorf("%s.price.currency %q is not an ISO 4217 currency code, such as USD", field, item.Price.Currency)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_MenuValidate(t *testing.T) {
+	t.Parallel()
+	items := func(items ...MenuItem) *[]MenuSection {
+		return &[]MenuSection{{Name: "Starters", Items: &items}}
+	}
+	soup := MenuItem{Name: "Soup", Price: Price{Amount: "6.50", Currency: "USD"}}
+
+	testCases := []struct {
+		name   string
+		menu   Menu
+		errMsg string
+	}{
+		{
+			name: "valid",
+			menu: Menu{
+				Name:         "Lunch",
+				Availability: &[]WeeklyInterval{{Day: "monday", Open: "11:00", Close: "15:00"}},
+				Sections:     items(soup, MenuItem{Name: "Bread", Price: Price{Amount: "3", Currency: "EUR"}}),
+			},
+		},
+		{
+			name:   "empty name",
+			menu:   Menu{Name: " "},
+			errMsg: "name is empty",
+		},
+		{
+			name:   "invalid availability",
+			menu:   Menu{Name: "Lunch", Availability: &[]WeeklyInterval{{Day: "monday", Open: "11:00", Close: "3pm"}}},
+			errMsg: `availability[0].close "3pm" is not a time (HH:MM, or 24:00)`,
+		},
+		{
+			name: "overlapping availability",
+			menu: Menu{Name: "Lunch", Availability: &[]WeeklyInterval{
+				{Day: "monday", Open: "11:00", Close: "15:00"},
+				{Day: "monday", Open: "14:00", Close: "16:00"},
+			}},
+			errMsg: "availability has overlapping intervals on monday",
+		},
+		{
+			name:   "empty section name",
+			menu:   Menu{Name: "Lunch", Sections: &[]MenuSection{{Name: ""}}},
+			errMsg: "sections[0].name is empty",
+		},
+		{
+			name:   "empty item name",
+			menu:   Menu{Name: "Lunch", Sections: items(soup, MenuItem{Price: soup.Price})},
+			errMsg: "sections[0].items[1].name is empty",
+		},
+		{
+			name:   "invalid amount",
+			menu:   Menu{Name: "Lunch", Sections: items(MenuItem{Name: "Soup", Price: Price{Amount: "$6.50", Currency: "USD"}})},
+			errMsg: `sections[0].items[0].price.amount "$6.50" is not a decimal amount, such as 12.50`,
+		},
+		{
+			name:   "invalid currency",
+			menu:   Menu{Name: "Lunch", Sections: items(MenuItem{Name: "Soup", Price: Price{Amount: "6.50", Currency: "usd"}})},
+			errMsg: `sections[0].items[0].price.currency "usd" is not an ISO 4217 currency code, such as USD`,
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.menu.Validate()
+
+			if tc.errMsg != "" {
+				if assert.Error(t, err) {
+					assert.Equal(t, tc.errMsg, err.Error())
+				}
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
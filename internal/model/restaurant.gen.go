@@ -3,6 +3,43 @@
 // Code generated by github.com/deepmap/oapi-codegen version v1.12.4 DO NOT EDIT.
 package model
 
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+const (
+	ApiKeyAuthScopes = "apiKeyAuth.Scopes"
+	BearerAuthScopes = "bearerAuth.Scopes"
+)
+
+// Defines values for ApiKeyScope.
+const (
+	Read  ApiKeyScope = "read"
+	Write ApiKeyScope = "write"
+)
+
+// Defines values for ApiKeyQuotaPeriod.
+const (
+	Day   ApiKeyQuotaPeriod = "day"
+	Month ApiKeyQuotaPeriod = "month"
+)
+
+// Defines values for WebhookDeliveryStatus.
+const (
+	DeadLettered WebhookDeliveryStatus = "deadLettered"
+	Delivered    WebhookDeliveryStatus = "delivered"
+	Pending      WebhookDeliveryStatus = "pending"
+)
+
 // Address defines model for Address.
 type Address struct {
 	City    *string `json:"city,omitempty"`
@@ -14,23 +51,218 @@ type Address struct {
 	Location *Location `json:"location,omitempty"`
 	State    *string   `json:"state,omitempty"`
 
-	// TimezoneName Name of the timezone following the IANA standard (https://www.iana.org/time-zones)
+	// TimezoneName Name of the timezone following the IANA standard (https://www.iana.org/time-zones). Set from the location when it is omitted, and rejected when it is not a valid name.
 	TimezoneName *string `json:"timezoneName,omitempty"`
 	ZipCode      *string `json:"zipCode,omitempty"`
 }
 
+// ApiKey A key that authenticates the server to server requests of a partner, in the X-Api-Key header
+type ApiKey struct {
+	// Created When the key was issued, set by the service
+	Created *time.Time `json:"created,omitempty"`
+
+	// Id ID of the API key, set by the service
+	Id *string `json:"id,omitempty"`
+
+	// Key The key, only returned when it is issued or rotated
+	Key *string `json:"key,omitempty"`
+
+	// Name Who or what the key is for
+	Name string `json:"name"`
+
+	// Quota The number of requests the key can make in each period
+	Quota ApiKeyQuota `json:"quota"`
+
+	// Revoked When the key was revoked. A revoked key is rejected
+	Revoked *time.Time `json:"revoked,omitempty"`
+
+	// Rotated When the key was last rotated
+	Rotated *time.Time `json:"rotated,omitempty"`
+
+	// Scope What the key can do: read only makes GET requests, and write also reviews the restaurants of the tenant and changes the ones it owns, as apikey:<apiKeyId>
+	Scope ApiKeyScope `json:"scope"`
+
+	// TenantId The tenant the requests of the key are for, set by the service
+	TenantId *string `json:"tenantId,omitempty"`
+}
+
+// ApiKeyScope What the key can do: read only makes GET requests, and write also reviews the restaurants of the tenant and changes the ones it owns, as apikey:<apiKeyId>
+type ApiKeyScope string
+
+// ApiKeyList defines model for ApiKeyList.
+type ApiKeyList struct {
+	Items []ApiKey `json:"items"`
+}
+
+// ApiKeyQuota The number of requests the key can make in each period
+type ApiKeyQuota struct {
+	Limit int32 `json:"limit"`
+
+	// Period The quota is reset at the start of each UTC day or month
+	Period ApiKeyQuotaPeriod `json:"period"`
+}
+
+// ApiKeyQuotaPeriod The quota is reset at the start of each UTC day or month
+type ApiKeyQuotaPeriod string
+
+// DietaryFlags defines model for DietaryFlags.
+type DietaryFlags struct {
+	DairyFree  *bool `json:"dairyFree,omitempty"`
+	GlutenFree *bool `json:"glutenFree,omitempty"`
+	Halal      *bool `json:"halal,omitempty"`
+	Kosher     *bool `json:"kosher,omitempty"`
+	NutFree    *bool `json:"nutFree,omitempty"`
+	Spicy      *bool `json:"spicy,omitempty"`
+	Vegan      *bool `json:"vegan,omitempty"`
+	Vegetarian *bool `json:"vegetarian,omitempty"`
+}
+
+// GeoJsonPoint GeoJSON Point (RFC 7946) of the location
+type GeoJsonPoint struct {
+	// Coordinates Longitude and latitude, in that order
+	Coordinates []float64 `json:"coordinates"`
+
+	// Type Always Point
+	Type string `json:"type"`
+}
+
+// GeocodeCandidate defines model for GeocodeCandidate.
+type GeocodeCandidate struct {
+	// Location Data returned from the Location service
+	Location     Location `json:"location"`
+	TimezoneName *string  `json:"timezoneName,omitempty"`
+}
+
+// GeocodeCandidateList defines model for GeocodeCandidateList.
+type GeocodeCandidateList struct {
+	Items []GeocodeCandidate `json:"items"`
+}
+
 // Location Data returned from the Location service
 type Location struct {
 	AddressNumber *string `json:"addressNumber,omitempty"`
 	Country       *string `json:"country,omitempty"`
 
-	// Geocode Geocode of address (format lat,lon)
-	Geocode      *string `json:"geocode,omitempty"`
-	Municipality *string `json:"municipality,omitempty"`
-	PostalCode   *string `json:"postalCode,omitempty"`
-	Region       *string `json:"region,omitempty"`
-	Street       *string `json:"street,omitempty"`
-	SubRegion    *string `json:"subRegion,omitempty"`
+	// Geocode Geocode of address (format lat,lon). Use latitude and longitude instead.
+	Geocode      *string  `json:"geocode,omitempty"`
+	Latitude     *float64 `json:"latitude,omitempty"`
+	Longitude    *float64 `json:"longitude,omitempty"`
+	Municipality *string  `json:"municipality,omitempty"`
+
+	// Point GeoJSON Point (RFC 7946) of the location
+	Point      *GeoJsonPoint `json:"point,omitempty"`
+	PostalCode *string       `json:"postalCode,omitempty"`
+	Region     *string       `json:"region,omitempty"`
+
+	// Relevance Confidence of the geocoding match, closer to 1 when more fields of the address match
+	Relevance *float64 `json:"relevance,omitempty"`
+	Street    *string  `json:"street,omitempty"`
+	SubRegion *string  `json:"subRegion,omitempty"`
+}
+
+// Menu defines model for Menu.
+type Menu struct {
+	// Availability When the menu is served, in the local time of the restaurant. Always when empty.
+	Availability *[]WeeklyInterval `json:"availability,omitempty"`
+	Description  *string           `json:"description,omitempty"`
+
+	// Id ID of the menu
+	Id *string `json:"id,omitempty"`
+
+	// Name Name of the menu
+	Name     string         `json:"name"`
+	Sections *[]MenuSection `json:"sections,omitempty"`
+}
+
+// MenuItem defines model for MenuItem.
+type MenuItem struct {
+	// Available False when the item is sold out
+	Available   *bool         `json:"available,omitempty"`
+	Description *string       `json:"description,omitempty"`
+	Dietary     *DietaryFlags `json:"dietary,omitempty"`
+	Name        string        `json:"name"`
+	Price       Price         `json:"price"`
+}
+
+// MenuList defines model for MenuList.
+type MenuList struct {
+	Items []Menu `json:"items"`
+}
+
+// MenuSection defines model for MenuSection.
+type MenuSection struct {
+	Description *string     `json:"description,omitempty"`
+	Items       *[]MenuItem `json:"items,omitempty"`
+	Name        string      `json:"name"`
+}
+
+// NearbyRestaurant defines model for NearbyRestaurant.
+type NearbyRestaurant struct {
+	// DistanceKm Distance in kilometers from the search center to the restaurant
+	DistanceKm float64    `json:"distanceKm"`
+	Restaurant Restaurant `json:"restaurant"`
+}
+
+// NearbyRestaurantList Restaurants sorted by distance, nearest first
+type NearbyRestaurantList struct {
+	Items []NearbyRestaurant `json:"items"`
+}
+
+// OpeningHours Opening hours in the local time of the restaurant. The special dates replace the weekly hours of their date.
+type OpeningHours struct {
+	SpecialDates *[]SpecialDate    `json:"specialDates,omitempty"`
+	Weekly       *[]WeeklyInterval `json:"weekly,omitempty"`
+}
+
+// Price defines model for Price.
+type Price struct {
+	// Amount Decimal amount, a string so that it is not rounded
+	Amount string `json:"amount"`
+
+	// Currency ISO 4217 currency code
+	Currency string `json:"currency"`
+}
+
+// Problem An error, as RFC 7807 problem details
+type Problem struct {
+	// Code Machine-readable code of the error: INVALID_REQUEST, MALFORMED_BODY, TYPE_MISMATCH, UNKNOWN_FIELD, BODY_TOO_LARGE, UNAUTHORIZED, FORBIDDEN, NOT_FOUND, METHOD_NOT_ALLOWED, CONFLICT, PRECONDITION_FAILED, UNSUPPORTED_MEDIA_TYPE, PATCH_FAILED, ADDRESS_NOT_FOUND, ADDRESS_NOT_RELEVANT, NO_OPENING_HOURS, NO_TIME_ZONE, QUOTA_EXCEEDED, RATE_LIMITED or INTERNAL_ERROR
+	Code string `json:"code"`
+
+	// CorrelationId Identifies the server logs of an internal error
+	CorrelationId *string `json:"correlationId,omitempty"`
+
+	// Detail What went wrong with this request
+	Detail *string `json:"detail,omitempty"`
+
+	// Instance The API Gateway request ID of the request
+	Instance *string `json:"instance,omitempty"`
+
+	// Reason Why the request is FORBIDDEN: AUTHENTICATION_REQUIRED, ADMIN_REQUIRED, NOT_OWNER, OWNERS_CHANGE_FORBIDDEN, TENANT_MISMATCH, READ_ONLY_API_KEY or BEARER_TOKEN_REQUIRED
+	Reason *string `json:"reason,omitempty"`
+
+	// Status The HTTP status code
+	Status int `json:"status"`
+
+	// Title The HTTP status text
+	Title string `json:"title"`
+
+	// Type URI of the problem type, urn:restaurant-serverless:problem:<code>
+	Type string `json:"type"`
+
+	// Violations The fields of the request that do not conform to this specification
+	Violations *[]Violation `json:"violations,omitempty"`
+}
+
+// RatingSummary The ratings of the reviews of a restaurant. It is maintained by the service, and absent when the restaurant has no reviews.
+type RatingSummary struct {
+	// Average Average rating, rounded to 2 decimals
+	Average float64 `json:"average"`
+
+	// Count Number of reviews
+	Count int `json:"count"`
+
+	// Histogram Number of reviews of each rating, keyed by the rating from "1" to "5"
+	Histogram map[string]int `json:"histogram"`
 }
 
 // Restaurant defines model for Restaurant.
@@ -44,20 +276,594 @@ type Restaurant struct {
 	Id *string `json:"id,omitempty"`
 
 	// Name Name of the restaurant
-	Name        string  `json:"name"`
-	PhoneNumber *string `json:"phoneNumber,omitempty"`
+	Name string `json:"name"`
+
+	// OpeningHours Opening hours in the local time of the restaurant. The special dates replace the weekly hours of their date.
+	OpeningHours *OpeningHours `json:"openingHours,omitempty"`
+
+	// OwnerIds The subjects (sub claims) of the owners of the restaurant, who may update it. Only admins can set them; an update without ownerIds keeps the stored owners.
+	OwnerIds    *[]string `json:"ownerIds,omitempty"`
+	PhoneNumber *string   `json:"phoneNumber,omitempty"`
+
+	// Rating The ratings of the reviews of a restaurant. It is maintained by the service, and absent when the restaurant has no reviews.
+	Rating *RatingSummary `json:"rating,omitempty"`
+}
+
+// RestaurantList A page of restaurants
+type RestaurantList struct {
+	Items []Restaurant `json:"items"`
+
+	// NextToken Opaque token used to retrieve the next page, absent on the last page
+	NextToken *string `json:"nextToken,omitempty"`
+}
+
+// RestaurantStatus defines model for RestaurantStatus.
+type RestaurantStatus struct {
+	// At The evaluated time, in the time zone of the restaurant
+	At time.Time `json:"at"`
+
+	// NextClose When the restaurant next closes, absent if it never does within a year
+	NextClose *time.Time `json:"nextClose,omitempty"`
+
+	// NextOpen When the restaurant next opens, absent if it never does within a year
+	NextOpen *time.Time `json:"nextOpen,omitempty"`
+
+	// Open Whether the restaurant is open at the evaluated time
+	Open         bool   `json:"open"`
+	TimezoneName string `json:"timezoneName"`
+}
+
+// Review defines model for Review.
+type Review struct {
+	// Author Name of the reviewer
+	Author string `json:"author"`
+
+	// Created When the review was created, set by the service
+	Created *time.Time `json:"created,omitempty"`
+
+	// Id ID of the review
+	Id *string `json:"id,omitempty"`
+
+	// Rating Rating from 1 to 5
+	Rating int     `json:"rating"`
+	Text   *string `json:"text,omitempty"`
+}
+
+// ReviewList A page of reviews
+type ReviewList struct {
+	Items []Review `json:"items"`
+
+	// NextToken Opaque token used to retrieve the next page, absent on the last page
+	NextToken *string `json:"nextToken,omitempty"`
+}
+
+// SpecialDate defines model for SpecialDate.
+type SpecialDate struct {
+	// Date The date (YYYY-MM-DD)
+	Date string `json:"date"`
+
+	// Intervals Opening hours of the date, closed all day when empty
+	Intervals *[]TimeInterval `json:"intervals,omitempty"`
+
+	// Name Name of the special date, such as a holiday
+	Name *string `json:"name,omitempty"`
+}
+
+// TimeInterval defines model for TimeInterval.
+type TimeInterval struct {
+	// Close Closing time (HH:MM, or 24:00 for midnight), on the next day when earlier than the opening time
+	Close string `json:"close"`
+
+	// Open Opening time (HH:MM)
+	Open string `json:"open"`
+}
+
+// Violation defines model for Violation.
+type Violation struct {
+	// Field The name of the parameter, the dotted path of the body property, such as openingHours.weekly.0.close, or body for the whole body
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Webhook A subscription to restaurant change events
+type Webhook struct {
+	// Created When the webhook was registered, set by the service
+	Created *time.Time `json:"created,omitempty"`
+
+	// EventTypes The types of the events to deliver
+	EventTypes []string `json:"eventTypes"`
+
+	// Id ID of the webhook
+	Id *string `json:"id,omitempty"`
+
+	// Secret Key of the HMAC-SHA256 signature of the deliveries, at least 16 characters. It is only returned when the webhook is registered.
+	Secret *string `json:"secret,omitempty"`
+
+	// Url The https URL the events are POSTed to. It must resolve to a public address, and redirects are not followed.
+	Url string `json:"url"`
 }
 
+// WebhookDelivery The delivery of an event to a webhook. A delivery that fails is retried with an exponential backoff, and dead lettered after the last attempt.
+type WebhookDelivery struct {
+	// Attempts Number of attempts to deliver the event
+	Attempts int `json:"attempts"`
+
+	// Created When the event happened
+	Created time.Time `json:"created"`
+
+	// Delivered When the event was delivered
+	Delivered *time.Time `json:"delivered,omitempty"`
+	EventType string     `json:"eventType"`
+
+	// Id ID of the event, sent in the X-Webhook-Id header
+	Id string `json:"id"`
+
+	// LastError Why the last attempt failed
+	LastError *string `json:"lastError,omitempty"`
+
+	// LastStatusCode HTTP status code of the response to the last attempt
+	LastStatusCode *int `json:"lastStatusCode,omitempty"`
+
+	// NextAttempt When the event is next sent, while the delivery is pending
+	NextAttempt  *time.Time            `json:"nextAttempt,omitempty"`
+	RestaurantId string                `json:"restaurantId"`
+	Status       WebhookDeliveryStatus `json:"status"`
+	WebhookId    string                `json:"webhookId"`
+}
+
+// WebhookDeliveryStatus defines model for WebhookDelivery.Status.
+type WebhookDeliveryStatus string
+
+// WebhookDeliveryList A page of deliveries
+type WebhookDeliveryList struct {
+	Items []WebhookDelivery `json:"items"`
+
+	// NextToken Opaque token used to retrieve the next page, absent on the last page
+	NextToken *string `json:"nextToken,omitempty"`
+}
+
+// WebhookList defines model for WebhookList.
+type WebhookList struct {
+	Items []Webhook `json:"items"`
+}
+
+// WeeklyInterval defines model for WeeklyInterval.
+type WeeklyInterval struct {
+	// Close Closing time (HH:MM, or 24:00 for midnight). A closing time earlier than the opening time is on the next day.
+	Close string `json:"close"`
+
+	// Day Day of the week the interval opens, monday to sunday
+	Day string `json:"day"`
+
+	// Open Opening time (HH:MM)
+	Open string `json:"open"`
+}
+
+// ApiKeyId defines model for ApiKeyId.
+type ApiKeyId = string
+
+// IfMatch defines model for IfMatch.
+type IfMatch = string
+
+// IfNoneMatch defines model for IfNoneMatch.
+type IfNoneMatch = string
+
+// Limit defines model for Limit.
+type Limit = int32
+
+// MenuId defines model for MenuId.
+type MenuId = string
+
+// NextToken defines model for NextToken.
+type NextToken = string
+
 // RestaurantId defines model for RestaurantId.
 type RestaurantId = string
 
-// N404Error defines model for 404Error.
-type N404Error struct {
-	Message *string `json:"message,omitempty"`
+// ReviewId defines model for ReviewId.
+type ReviewId = string
+
+// TenantId defines model for TenantId.
+type TenantId = string
+
+// WebhookId defines model for WebhookId.
+type WebhookId = string
+
+// GetParams defines parameters for Get.
+type GetParams struct {
+	// Limit The maximum number of restaurants to return (1-100, default 20)
+	Limit *Limit `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// NextToken The token returned by a previous request to retrieve the next page
+	NextToken *NextToken `form:"nextToken,omitempty" json:"nextToken,omitempty"`
+
+	// XTenantId The tenant (brand) of the restaurants, the default tenant when it is omitted. An authenticated request is for the tenant of its token, which this header must match
+	XTenantId *TenantId `json:"X-Tenant-Id,omitempty"`
+}
+
+// PostParams defines parameters for Post.
+type PostParams struct {
+	// XTenantId The tenant (brand) of the restaurants, the default tenant when it is omitted. An authenticated request is for the tenant of its token, which this header must match
+	XTenantId *TenantId `json:"X-Tenant-Id,omitempty"`
+}
+
+// GetApikeysParams defines parameters for GetApikeys.
+type GetApikeysParams struct {
+	// XTenantId The tenant (brand) of the restaurants, the default tenant when it is omitted. An authenticated request is for the tenant of its token, which this header must match
+	XTenantId *TenantId `json:"X-Tenant-Id,omitempty"`
+}
+
+// PostApikeysParams defines parameters for PostApikeys.
+type PostApikeysParams struct {
+	// XTenantId The tenant (brand) of the restaurants, the default tenant when it is omitted. An authenticated request is for the tenant of its token, which this header must match
+	XTenantId *TenantId `json:"X-Tenant-Id,omitempty"`
+}
+
+// DeleteApikeysApiKeyIdParams defines parameters for DeleteApikeysApiKeyId.
+type DeleteApikeysApiKeyIdParams struct {
+	// XTenantId The tenant (brand) of the restaurants, the default tenant when it is omitted. An authenticated request is for the tenant of its token, which this header must match
+	XTenantId *TenantId `json:"X-Tenant-Id,omitempty"`
+}
+
+// PostApikeysApiKeyIdRotateParams defines parameters for PostApikeysApiKeyIdRotate.
+type PostApikeysApiKeyIdRotateParams struct {
+	// XTenantId The tenant (brand) of the restaurants, the default tenant when it is omitted. An authenticated request is for the tenant of its token, which this header must match
+	XTenantId *TenantId `json:"X-Tenant-Id,omitempty"`
+}
+
+// GetNearbyParams defines parameters for GetNearby.
+type GetNearbyParams struct {
+	// Lat Latitude of the search center
+	Lat float64 `form:"lat" json:"lat"`
+
+	// Lon Longitude of the search center
+	Lon float64 `form:"lon" json:"lon"`
+
+	// RadiusKm Search radius in kilometers (maximum 50)
+	RadiusKm float64 `form:"radiusKm" json:"radiusKm"`
+
+	// XTenantId The tenant (brand) of the restaurants, the default tenant when it is omitted. An authenticated request is for the tenant of its token, which this header must match
+	XTenantId *TenantId `json:"X-Tenant-Id,omitempty"`
+}
+
+// GetWebhooksWebhookIdDeliveriesParams defines parameters for GetWebhooksWebhookIdDeliveries.
+type GetWebhooksWebhookIdDeliveriesParams struct {
+	// Limit The maximum number of restaurants to return (1-100, default 20)
+	Limit *Limit `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// NextToken The token returned by a previous request to retrieve the next page
+	NextToken *NextToken `form:"nextToken,omitempty" json:"nextToken,omitempty"`
+}
+
+// DeleteRestaurantIdParams defines parameters for DeleteRestaurantId.
+type DeleteRestaurantIdParams struct {
+	// IfMatch Only modify the restaurant if the version in its current ETag matches, whatever its rating summary
+	IfMatch *IfMatch `json:"If-Match,omitempty"`
+
+	// XTenantId The tenant (brand) of the restaurants, the default tenant when it is omitted. An authenticated request is for the tenant of its token, which this header must match
+	XTenantId *TenantId `json:"X-Tenant-Id,omitempty"`
+}
+
+// GetRestaurantIdParams defines parameters for GetRestaurantId.
+type GetRestaurantIdParams struct {
+	// IfNoneMatch Respond with 304 Not Modified if the current ETag of the restaurant matches
+	IfNoneMatch *IfNoneMatch `json:"If-None-Match,omitempty"`
+
+	// XTenantId The tenant (brand) of the restaurants, the default tenant when it is omitted. An authenticated request is for the tenant of its token, which this header must match
+	XTenantId *TenantId `json:"X-Tenant-Id,omitempty"`
+}
+
+// PatchRestaurantIdJSONBody defines parameters for PatchRestaurantId.
+type PatchRestaurantIdJSONBody = map[string]interface{}
+
+// PatchRestaurantIdParams defines parameters for PatchRestaurantId.
+type PatchRestaurantIdParams struct {
+	// IfMatch Only modify the restaurant if the version in its current ETag matches, whatever its rating summary
+	IfMatch *IfMatch `json:"If-Match,omitempty"`
+
+	// XTenantId The tenant (brand) of the restaurants, the default tenant when it is omitted. An authenticated request is for the tenant of its token, which this header must match
+	XTenantId *TenantId `json:"X-Tenant-Id,omitempty"`
+}
+
+// PostRestaurantIdParams defines parameters for PostRestaurantId.
+type PostRestaurantIdParams struct {
+	// IfMatch Only modify the restaurant if the version in its current ETag matches, whatever its rating summary
+	IfMatch *IfMatch `json:"If-Match,omitempty"`
+
+	// XTenantId The tenant (brand) of the restaurants, the default tenant when it is omitted. An authenticated request is for the tenant of its token, which this header must match
+	XTenantId *TenantId `json:"X-Tenant-Id,omitempty"`
+}
+
+// GetRestaurantIdMenusParams defines parameters for GetRestaurantIdMenus.
+type GetRestaurantIdMenusParams struct {
+	// XTenantId The tenant (brand) of the restaurants, the default tenant when it is omitted. An authenticated request is for the tenant of its token, which this header must match
+	XTenantId *TenantId `json:"X-Tenant-Id,omitempty"`
+}
+
+// PostRestaurantIdMenusParams defines parameters for PostRestaurantIdMenus.
+type PostRestaurantIdMenusParams struct {
+	// XTenantId The tenant (brand) of the restaurants, the default tenant when it is omitted. An authenticated request is for the tenant of its token, which this header must match
+	XTenantId *TenantId `json:"X-Tenant-Id,omitempty"`
+}
+
+// DeleteRestaurantIdMenusMenuIdParams defines parameters for DeleteRestaurantIdMenusMenuId.
+type DeleteRestaurantIdMenusMenuIdParams struct {
+	// XTenantId The tenant (brand) of the restaurants, the default tenant when it is omitted. An authenticated request is for the tenant of its token, which this header must match
+	XTenantId *TenantId `json:"X-Tenant-Id,omitempty"`
+}
+
+// GetRestaurantIdMenusMenuIdParams defines parameters for GetRestaurantIdMenusMenuId.
+type GetRestaurantIdMenusMenuIdParams struct {
+	// XTenantId The tenant (brand) of the restaurants, the default tenant when it is omitted. An authenticated request is for the tenant of its token, which this header must match
+	XTenantId *TenantId `json:"X-Tenant-Id,omitempty"`
+}
+
+// PostRestaurantIdMenusMenuIdParams defines parameters for PostRestaurantIdMenusMenuId.
+type PostRestaurantIdMenusMenuIdParams struct {
+	// XTenantId The tenant (brand) of the restaurants, the default tenant when it is omitted. An authenticated request is for the tenant of its token, which this header must match
+	XTenantId *TenantId `json:"X-Tenant-Id,omitempty"`
+}
+
+// GetRestaurantIdReviewsParams defines parameters for GetRestaurantIdReviews.
+type GetRestaurantIdReviewsParams struct {
+	// Sort The order of the reviews: newest (the default) or highest, the highest rating first and the newest first within a rating
+	Sort *string `form:"sort,omitempty" json:"sort,omitempty"`
+
+	// Limit The maximum number of restaurants to return (1-100, default 20)
+	Limit *Limit `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// NextToken The token returned by a previous request to retrieve the next page
+	NextToken *NextToken `form:"nextToken,omitempty" json:"nextToken,omitempty"`
+
+	// XTenantId The tenant (brand) of the restaurants, the default tenant when it is omitted. An authenticated request is for the tenant of its token, which this header must match
+	XTenantId *TenantId `json:"X-Tenant-Id,omitempty"`
+}
+
+// PostRestaurantIdReviewsParams defines parameters for PostRestaurantIdReviews.
+type PostRestaurantIdReviewsParams struct {
+	// XTenantId The tenant (brand) of the restaurants, the default tenant when it is omitted. An authenticated request is for the tenant of its token, which this header must match
+	XTenantId *TenantId `json:"X-Tenant-Id,omitempty"`
+}
+
+// DeleteRestaurantIdReviewsReviewIdParams defines parameters for DeleteRestaurantIdReviewsReviewId.
+type DeleteRestaurantIdReviewsReviewIdParams struct {
+	// XTenantId The tenant (brand) of the restaurants, the default tenant when it is omitted. An authenticated request is for the tenant of its token, which this header must match
+	XTenantId *TenantId `json:"X-Tenant-Id,omitempty"`
+}
+
+// GetRestaurantIdReviewsReviewIdParams defines parameters for GetRestaurantIdReviewsReviewId.
+type GetRestaurantIdReviewsReviewIdParams struct {
+	// XTenantId The tenant (brand) of the restaurants, the default tenant when it is omitted. An authenticated request is for the tenant of its token, which this header must match
+	XTenantId *TenantId `json:"X-Tenant-Id,omitempty"`
+}
+
+// GetRestaurantIdStatusParams defines parameters for GetRestaurantIdStatus.
+type GetRestaurantIdStatusParams struct {
+	// At The time to evaluate (RFC 3339), now when omitted
+	At *time.Time `form:"at,omitempty" json:"at,omitempty"`
+
+	// XTenantId The tenant (brand) of the restaurants, the default tenant when it is omitted. An authenticated request is for the tenant of its token, which this header must match
+	XTenantId *TenantId `json:"X-Tenant-Id,omitempty"`
 }
 
 // PostJSONRequestBody defines body for Post for application/json ContentType.
 type PostJSONRequestBody = Restaurant
 
+// PostApikeysJSONRequestBody defines body for PostApikeys for application/json ContentType.
+type PostApikeysJSONRequestBody = ApiKey
+
+// PostGeocodePreviewJSONRequestBody defines body for PostGeocodePreview for application/json ContentType.
+type PostGeocodePreviewJSONRequestBody = Address
+
+// PostWebhooksJSONRequestBody defines body for PostWebhooks for application/json ContentType.
+type PostWebhooksJSONRequestBody = Webhook
+
+// PatchRestaurantIdJSONRequestBody defines body for PatchRestaurantId for application/merge-patch+json ContentType.
+type PatchRestaurantIdJSONRequestBody = PatchRestaurantIdJSONBody
+
 // PostRestaurantIdJSONRequestBody defines body for PostRestaurantId for application/json ContentType.
 type PostRestaurantIdJSONRequestBody = Restaurant
+
+// PostRestaurantIdMenusJSONRequestBody defines body for PostRestaurantIdMenus for application/json ContentType.
+type PostRestaurantIdMenusJSONRequestBody = Menu
+
+// PostRestaurantIdMenusMenuIdJSONRequestBody defines body for PostRestaurantIdMenusMenuId for application/json ContentType.
+type PostRestaurantIdMenusMenuIdJSONRequestBody = Menu
+
+// PostRestaurantIdReviewsJSONRequestBody defines body for PostRestaurantIdReviews for application/json ContentType.
+type PostRestaurantIdReviewsJSONRequestBody = Review
+
+// Base64 encoded, gzipped, json marshaled Swagger object
+var swaggerSpec = []string{
+	"H4sIAEOC1GoC/+09aXPbxpJ/BcV9H+xaUqIuJ9bb2ipGpG3GEqlQVJzDXtWIGJF4AgEGh2TG5f++3T0z",
+	"wAAYEKBuO8qHWATm7Ol7uhtfGhN/vvA97kVhY/9LY8aZzQP6szdmU/zX5uEkcBaR43uN/cav8Bb+svwL",
+	"K5pxK+BhxOKAeVHTcrxJwOcwEret86XFr3iwtCYz5k25FfmF5he+6/rXoi2zZiyc4aBOFFoBixxvaoXx",
+	"fM5giOsZ9+A5NoEBrhx+HTaajXAy43OGC+Sf2Xzhcljcx8bOxwa8i5YL/BlGAYzT+Pr1a7OxYAGb80ju",
+	"rbNw3vNl3y7ubwyr7Bz3rUu+tPpdGMvBpwsWzeBvD4aAX0z1bjYC/lfsBBwGioKY66uas8+H3JtCv/1X",
+	"u8UlNRv9iyMWTWbFJQw9d2nNfdu5WOaAZjkC6lfyEByP4DWJgwDAbuGJWXMclIdNABuL8AwMIFXbEqed",
+	"bqx/0RJr0jdiWvkAMKZk9SMeAj7Z1rUTzayd9q418CPrCHfjwFnLDWRWXEAltYcVy8QF1FrroTN3IvMx",
+	"wwk583huefH8HMAEy0iXECLGBjyKA896sdXaarebls0vWOxG1nb7pVrZXzEnaMqFuTSZvqALP4DNwCvH",
+	"i3a24ZWctLGPQzbmjid/JRgCDfkUdouLP+JeXIakQGhxKYbORcdb4eeAf47G/iX3zPNH+EqCSBHxAsnT",
+	"j5FMATJhJIEYOICHdMYejGkt2JSXANBL5lx9qqPkoMrAo2FTGZACfZBbgWpEXKl8Kfh2xTJk51stYcy9",
+	"FdCI6K314hx2a78sUhywC3ygMFw2V4zXCS0fEBv4+obV8SwWQ1svciYMOb06amgEyE7DyO6SmxOiIDty",
+	"JjN4De0EPVvzOJSk/tErI/XfWmJjLYJPCg8AIfBy7PF/f3Zaf7DW3+3W67PWpy9bzVe7X//VMIHoAz+f",
+	"+f5lGYyuxevSc7pOut/ioL5iX2SQISdBtNtu94LAD/DviQ+k7xGzYouFi/CF1W0uAv/c5fP//k/oEymm",
+	"c/0r4Bcw+H9tpjJ8U7wNN49FLzGlCSfFqdk+Dy0PODRMjrxKCGo4o3DBJ8CyxRqaFpwsYgGcbnDthBwk",
+	"zxVzHcAHHAxo3qV2oeU6SPXwDLi9a8PhgxCCSfQpAIesc99eineOmJ5Gs34+GQ7wyVHn8M1wdNTrnv00",
+	"7P7eBM4C72Ou8PY68EGWIWix8fj3497ZUf/kqDM+eGcBfkNzmj0zAYhK7CrAgw9PB+8Hww+Dszf93mF3",
+	"AzAQ4LTb3nqU0zjnLACCECwV1jZ3whDwRYCdtiDhvQ/04iIQud20+OcF4iC1wi2GzlRyYtwpDAG8VdIk",
+	"jABKjTitDx8+tDoaCStyRPVKcAUGe9uwhoKYlTIk4QgbE3C9huY0h62WiU8CPyKuAE+Ar8F+7AS0Ow8O",
+	"2o618GH0JfA1z+FidxPmulzszF/wgGbeB6zxveUcJZd4j/+CjouKGIADd3jtZZ7GCxtBB8M4Ab7M8lJE",
+	"QmrGbJDwohsQAfOWcBSAutwN+b8tyS01FqeOQsI6y0sjJXSRgHBVYgp1PrkF4xK04wstwDBAHg9kr9TN",
+	"WAbtBG6Is1ezyQOxQgb9Bf8OYpdbdGg8TE5298FPNpX/BKgLP/ZsWsvW9qMQsFKc1QEmbJXEWw2VVyx+",
+	"51FlATFlQD2XBVMiEcCo7b1X1nvnJ7G8vUdZ3oGYqzVGfp8ALrvklDNpZG2xyYQvwK7FxW8/DmIw24ZD",
+	"Dok3JpgqGWYIBKg0IOK+DPbl8ivmTVAkgHFM+5E2woZ1CnL3eHgytjan3J/4Nt9cSNUShPYEdJNQAMD1",
+	"xY4UfW6/fpStK8aEO4tD4Drxgjb9Vwwywnrxy+lw3Dnr/XbQ63V73ZdNxQ4nwFqATELu2YkdESr/ATxc",
+	"+GAhWRcsjBSOSvsWNo7ml/Vi1Bn3zg77R/0xjCv42giMkGWrc4F9cuIutf5CDuCBSWMQjG4GzZC3nnNc",
+	"U2SxKXOAW/bIu6F0uWS0EayDTM4W/b+pPRgB0OAogf0jb9afhzySqwoTBE/3I59IuPheBhBKr0aNDXTM",
+	"SZSIfI0QpBomsQamV2gpMWTvEZTQDnovUIVnrsVx8g2rD+do84g5rpBWJM1cfzpFFYNEFsHBBz4q1E0Q",
+	"mFlJRfv5qtRx4ekRO6X92LaD3ZgL6wLYRA7q4BcMhHGzsdAeARScaGmwPJsAHsCOwPzOdTy+Vfpm2/xG",
+	"0moVJA9VO9wdqljG0SJnzv+GngOyWPJGDj5NFAnZUnrhECvxcb8z6AAiAYKywLZezKJoEe5vbl5fX284",
+	"oDts+MF0E7u2sG8IxHUCqHsR+PMM4zEYjkIhCfh/+ATVQ60BckUm9X+0tDbIGEx9ep05B7xmAIHwrONN",
+	"gUGGReuu2fjbWRwAcpvdBfKJf47TY2vh/6tEirw6ieyMSEk3gQXhhzy4Il1K/ZWwLoA4sxYsiECDbCo7",
+	"5LcWLKH1HtmjsnhzGAiqWMQNpuoHBB0OgYvRlXDkIlL3xyU4E3SyJP4nVFdbeHQm4DmGefpdhSuSkZfM",
+	"UBjsUgC2KBBoDKmiSq+RhgdiG2Q4CBvCNLZnROwPMx/7ocMzgYxwRwiHmzLIyeVWGJPkURX9CYT5hZqS",
+	"DU+2TY3TUVYQ6ez0p1qeIobap6TgUj2pC9JRA2O94cOJvzACV4OqMGT2NfNjzi6BAt72xgnCC1K/DhyQ",
+	"YEBGvvLZ511OCSsSFg52EpcFoiUyGEQNMK5wSBAJCweWsP8/yvv+v4JReOg+/bOBK4KfNG3jk2F7UR0H",
+	"mSb0k/XhvlEcARTrkcBX3T/0p8BZBV6Fbp9KmdKhE5L0zbID2NU8+0c1tjZSzseCgC0LKxNjlS/lF0UZ",
+	"azDJcUalSvU3DYEQZZAPcgaKC4zm+HaB/bnKbV90oK9wmcMoYjjjGQvFkygPj1GiNeBjQEoWLed0fGDZ",
+	"bInsZA6a0EzDMHiM09PTT1WHrm4C5HpMMO46oOsEyzcum66rntjMgY4B18Xdue+7nJGCMHVjwOfy9zPm",
+	"Mtf86tIPZzwwv/PiqHzMcOFMluZXV3zKvNJXCATH/N4kud9y/2fQQY9R+V0TNbErOhipL5gJbw6sH17v",
+	"vkrc4YkuVpDFvh/YjoeyvohZh743daKY9GrbQsUUf0hBDzgGXUm8J4SbcmM/BqU15R+CbHCTILP6ovk2",
+	"IXz6I0vP6nd+TR33Gl02AkhVqEpvm5lNfjIDHq2HA9ilY0sFNEezN9Bl8xprBVWpnnUWeBeMtLDpm7PU",
+	"Qw06ayBtl0Us1ZcSVVuNpkmh7D6lhTcQOLWuKSNNRYFZi4BPhOIhLjsKVEVWJaq50tnxQqA4EkPT9b2X",
+	"wnWhSEPQSUI1jgeWPLM3TCqJ6mImm+Qq9bV+k9p63TZQVDJfxVBbP2bGop9F8ow9Z+IswGQpsRIXijtV",
+	"4FbKyagTiCK3xIhBLJtK7DG8kl6jIic48L0Lx+boUZJcThwtGnzkeGpaE9cPheWyJfTxuR8k9zeykzrZ",
+	"ubxxXwVBDX4m4MGyOY+M+wjj81HZLk2yAK/H15Sc7Io5Ljt31NGVKNJ0uY4+FbTl7MRqQ/7jku1c9OCC",
+	"hi+4LsGQzxfRckNn+6tQ4QPnl+6yj/4QMISLTCZHc1/WteBwO/XNKd1PIHum5vhh7BEGwBknZpXJmIAj",
+	"Qs9TbU6LZ3kiOlUyWVr2pxKEQFl5M6RwDbB4g+3FkdKlGgxOeOG7YP/EmnDVlJqqs7KF4lcFkYx+qB1W",
+	"BeQXgSMYwWrHHDYqsVTECGXwvQuxSoR7c1Gq48qainMVGa29DUI3A8Gq00op5wQNDYw/qyCeusg+4Cw4",
+	"X6Y3YcVDsR105U34+3kRsbvyHbK2S8f1RWxcqmCEMDrYQxMM5wuK4XtGGVDg9UFmcatAqW0jD4DMpNqW",
+	"6sBEIWvZ7SFSciDjFdXQTcvDe9EwAhkYhFFBs1oPRwqndHO0Hy44Xh+88+MgXFOLlF2tGfatJ83QYqYg",
+	"EGhjk5sz4AuXTcQd0zVJLDme6O0E1E74b7Mgk+N0lQVVC3InaScTgYkV1B6tSsSa9ItjxUrXkSVzVKsN",
+	"9AabmQMoxXsMaRH0Dggo41QSb3iAd4TkuEuZx9b2xl7bJMLFtfLEoMz0T4bW7vbWD5ZqYpFKr496etKt",
+	"NA/lhrSZPhlBJW56iqaoJ653yIVHJveP7R+SyAJ53WOwuG2DMD5ik5nj8Rb6+lBcW8rwoBsxnGXf6g9+",
+	"7Rz2u2ej3i+nvZNxsxBLlAkXambDgJoWtjkbD4dnh53R2x6+7pyO3w1H/T968BYG+qnf7fYGTWswHJ+9",
+	"GZ4O4OlRD1p0z/BJ5/Bw+AFbHgwHbw77BzD/8agHP7r9cX8Is3T6h/j6dHByenw8HI1hWbC0fucMlwWN",
+	"cVFJq063O+qdnJxpc+mPRr3D3q+dwRgXczY87g36g7dn74anoxN6Mu4f9c7+GA5g2Oxda9PSb0jR1dUf",
+	"jHujQefwrDcaDUe5C5hkdiP66bdxBhy08Zbkwslekrj+VFyL5O8ATTMIFCnxS1/jlagIB5MXhE5yaWy8",
+	"6PAEjy+P+34L7AbU+OTqN1WiVwwrwmZMa1xm7pFhcQkK7VuIWb3BuH/QIdxAjO2PxLkf9fXfeAKAo71R",
+	"06J/Ts4O3nUGb3tnGj6OewNABQ2xR71O92w4OPz9DLZ19r73Ox70T73OqDcCBH/fSycwnDdNY7wkiFgU",
+	"h2bwvRuPjy3RoMBqdtu7JnctmPcurx4t4p+j7BqBTb6hoB+Tp9/oEzsd9fNxTdiwacWBt58KvpbAURes",
+	"3X3Zbv9j3G7vTHBL9FeWh1Z3X0lAaeykGQxZOzyJqy6GVZojN+taoL+qZVTqJ9JdKE4uQYimYNkm0TCi",
+	"1IMTmXlgjpCmJtouxYUR3ZzqOkmfSAjDKIAnaLGO0gkmbp7YOcVpJDabFgk+oygcNb5JU2FweBieXpRj",
+	"4oVcalMJaYT6NogxEu5hPa14YtYQBtqtiUpyKVIMnHDkTwO2wsb9YuhWMVVy/6G2d8mXKXhl8gjZBh8b",
+	"Wx8buOuPjT0910addw5bJlJ5UHDVN2BElozFsI7elcZ5rLwZk82KZnpeXUt+GYL11r5CX9232g2T6V/h",
+	"APBzZsIqcGRMCuyLYa59u4QXhTGdU2i9gL+sicuceZjcnsgIWUNe2PXMB5pNYmYdIORhLjgW7+Og2/zf",
+	"qBPIdijQ/Tiy1JoAJ/lC6hGRH2CsAE0pyDjhchXQyZsRixnePpR7yQXuV5qvGR5X24SvMlQ7lCuTy0y6",
+	"pUG6yhRtagk4xbS0BQPhI4PUKaCvNLunqZiwjFajeASZ9rPa2ii3ftN1nyQ6SI4FlOR5cUweoKh0tHUT",
+	"Zy4ZvhQDZSKyejETuOMD9J+v8CVr8ocARP72MAGRg1ky8OZKhQ0j3sMSmbXkLFhrJUjNaywEGcWdr8Mv",
+	"WwOmjhTSGUNahLqKzx6U0be63n0hLaaJiJHraUYwFIXrip0YmFRQxbxxYLoErmBO1UFfMuAXg3xk43sM",
+	"/BKTGQ2ehCvmPGuaprCF/GFPvxraq4reID2/8lTl5E0F/PLDrOaqStG6HUclMH1T3FR3rq0bexKVWGwk",
+	"tV/8Dv+1jo5a3e7LjJ203d7ebW1tt7b3zIa5cMyFJkjpvkuJmjiXvLoEjd91KWgnvXmra/aMgS5WXbtV",
+	"a2a6ixRIMQYdmiL5Z77riIih1UdE4DSdUGZpa0YvmyUSCioK9EXB9+Ldu/2jI4q6397db7cpanzu2J4z",
+	"nUUYje+lSJjClgWuo8LuZYi5p4asLw2GWi+5kJeVgJK8XOzNBLDUhC1oBmREm5HW084yqREgs2F9jFy2",
+	"MAtUtaB8Ezn2Mj1uXeXeEI7pjfYGLZUgTN1UWD6ow64YiRTXAszmYJ9IE3Q1RMSu0g4moMic17UjnUHB",
+	"Tw0g4kyJ0JYVHYBNmZTRahGm8mxFdOzUwUSOW4oxWgvmB5UYLtghYR1i4bgpm7vOVTY4q+yi6EAJ2/TR",
+	"KZkpFLGcPuxyl5fELud5y2rZK6HUMN+tB9wg1zCaXPZ+d9Q5aJ2862D6FqaFgsIcJGgut+2QEhpZoFqB",
+	"PNl6hQcbMMwgCZWnxRCsrZ+gox/ghgGbZTwuWnsieAhWHweu+ZAo2cA6HR3qp4SBt5jzRPKRlkVZ44CO",
+	"vntFZUWYtYjPXWeiYlRUpoENZDKRI4jMK1FuxLTOPGXhGjNYtYK0ugKaJZ4tCeuldHfTkGLVEogYGJ40",
+	"IrfeBSXAEGxRG1DZmh7m+5L4QnFzziaX/sWF2KyN0diAd3QOFruIpKZNigImyoNINDq7xKtwlS9KtdHo",
+	"JT0fo4eqmgMIKMzYAtjmGgHqcvoaQyNrSVuvzUVuEGFDfZsiQyxJ8ZAYkib2mmPcwijJvjJfH+jnSOhh",
+	"ZjDYTNjGB8brs7x/XrN7RRKbvOXXpzOeMCoFHfm+6ijwThN1iJDLZDWX60yIMiEADWzBLmomQuSqf6y4",
+	"pVDB2+kUOl4g4RxKujEmDVzrJSMqdG1KP9BqRKTo1MyXGkmc5gkFpmRTg9VUmTUpg7+lZZNncd+UiSMX",
+	"fxfxSkqLunnsRi784KH1eZQyE735Sk1eiP6M/p/PymtvwxxGJs0MkrDLlqlewy9FKJ2EhXJDzX0P7QxM",
+	"oIs9YTml010EZmNqTQtDi6f40bj+gnGGc1ZYHkIji0HRWZ4gvkjZShk0WGfDGBORVtWgrDfQe0Vmt0hy",
+	"ClU1BlkpBmSlpJjfWpSUo7KL1c9sbnH6NJdZnKRfb6yquCMzE1PosCSdSFSMULsSv94onv3zh7Gq0UNe",
+	"O3qbjoL6ncgEdrwLnxiquP3VqzkAXBqYFkL1zfCcwI5qq3OGdcCjHXiEiUBolxGoN/F/U5NGjLSfc50n",
+	"SdHI0xtvOcV3abXh/jRzgrTJpqgo9rVZ2TAtoFWjcVK/6eunXImgbUDU8sTs9RKycxcOhrzsk3gyAS36",
+	"InaF6k/M2qYM1uJNBFX/aJdNmuxiM6lyJGrs1OmwpXXYqdNhJ+2w/bpGB1UVQeS/V3dIkuRFOUHfJIKF",
+	"lZi5tC6g3LEfro9zOeygIICffHt5D4ihilTpBa6+FlBy695mXoGOUkUylU55gljYfm3S0TRHSlLSIEQf",
+	"lIN+TIxsW4KhB8QZypIwNWbd0mbd2qvTYU8jlu06xLL9gNQFfZQcXM3ZM/WNMtnETXV/QQnXIpXYm7ix",
+	"zWXJMuqCN84hd6946iVQzo7iFbWonZSZs2kspESitSBmOnJDt6T8e5ILWupxfZmgg+JZFORdA6jYoddG",
+	"aXrZ4ogJFhbdbOQDTcMYZTp9WihB5vWb8Axlyx0h2t2LGJWU/rDiRZ91BVZLPVxD6aeK0Q8gER6QwW9+",
+	"UeUcvgoaQve5yRGPnFwjJ1EtKFdJI8lewUp4gsK06oWXfBFpdYSEoRtlinQYSEo49CVRddLCz+sRV9Lx",
+	"yVgCNelCidBMHZjkJswJM3L2iVNOe7dOh91HJ4RNweHJaeWb85fSRJxLru4WFKpLncTj16j4aAXCUt1m",
+	"TEHQslZzNRHFobT9Ir2KBtJThRBSaD8SG/rnUI2sgvoNiJO1iUKYNY9RSNBJGPXDUWeu0mI5Tb4tFNdL",
+	"AlhDdiU8c6L6KyaKu7IWrSwrkZQeEYaIItSmKBBJaYhNkSgm699NRLlLWZdwMvNDjKIDPVG/gFLryJZl",
+	"K4bgwfrK1Um5r+MkFuxeVEMVlV1HN7w7ajZWDKmibYkRtg7iZ13xXonQoxzaUl/AG8ezC8XFkujVgNlO",
+	"LHNJ0ho3xSTfgsUuMneLQivniFBVTVQ8mJ43XfaZCApILa8hf5tSJygqywoUrbNESh260RJrlFApLvJE",
+	"rEkeVjYn/YX6UMde6Yc3RL/385Vr5p8nbhw6V/xILU60WLGXvfbKaiZPRi8x5r2v58kRRPbs3pc8R97k",
+	"1/BAqpakKzuBJcKzDC5FE5P5oKa5R+TQL8LXw4kECA+DCY/mrBvJKDY9LCv3EZlM1KO6S5XhXBRdmIlU",
+	"o7eno8Om+igDGWX4UGBHmbaVQYe717OSOIaH9cFlpl2JfCqYMBMC+TDI953oSopkN78ksUgrPWvCv5Ui",
+	"PlkRThSmAVquPxXeNhlDpYUXJTwOzY6NEt+ZwukPWmzUen6AtOe9CtG6aCpAmcHRok9MNXpYJH56Lq6m",
+	"WXqOMGRV47b9hDUmVVDqiM3vBqeMcvcfizRljGxTi2ys1Ms0NkXGX4ZUPX6Nxp8Q4KUmYAHNupnIypsi",
+	"XPNeYoweAI0zAag3CR7Szu+fjNtf9HjgeuJ5RUSRaDPKhhivh5yZzjXQTn279AkGtq0lvvUyBWUS/BuI",
+	"NFoXybfqxPxsbT8ZNWEF8gOXfmDMT799+y1if1bRyADW8O1n06Sy2Sa1oel2BAKu/Air/KSv+MqM/v3e",
+	"NDXmxvM/XzbXdH6Yv9h8zALMKUu/asgKUYpMfJrziAcgx4/p2ET9/J3Xryi5QBTWT1+8et3ebn6ENYhb",
+	"aip0TW8Fyr5EJksfBxHfaFPZhaJykwrRyHz5LU8dLdoN3TRufPT0b62piKrkpoY+2ZWEUAlPjvnCC4f8",
+	"RgRpHe/QHM9LA1SWo+TzGB707m0NlkXrv1OG9R1dxK9kuhLVZdqdDHbClNwIv8jMkmszUVRuBrLW8/Pf",
+	"sryZwvBQccvF7WucaOLHrk3OhHP10VI7+c6gwirFNTKNE9ZBvIh7fjydpR9nfFxn9WmRSRt9yd8RH7vf",
+	"XIZ2EcYZ/hPLRP9n/vPMf5563kTWs7CJn2+o4SqjZvlym1Wm1hGNfd+M5WEMqOTDBuuZTwK8zxbIXaXN",
+	"0WdXqtAwL9seBQ/vXmqJb2I87MVsOmfNrLu5/HDH9+EF+z5ufE0sf/ML/lPXtyw/8lPlVSZCo2+e3L8+",
+	"Kad5UgJiLc8ywrTcp/xd0dEjO4eN2GvSVZ5Rdx295hk978xQN2KoUY156ij6mGrPA1OEbvU/qz1PXu1R",
+	"pXwrbd2Sj0tUSZBRWir4dqRZdJjQF4Jz377YVxEqL4TacMFiN3qJvsuZM51RWji+kD+SLzRgIEviV5Ej",
+	"iIdaPgQ2Tev/5CLp8XtojWbxliCtkPSdF8hJ6kbfrDiOwJJn2XnriGyqc84Kn6STmB6KTy0US/erOh+h",
+	"Rk0blcL3zqj78X3fohb5Q9fwSWetXb9HFU1/FqpPXajiY/yjfqwaUV09evV8+hg4SMCEcp3SWHIDzY7k",
+	"0u6ddpOJnpiwWjPgDbusCnb7rsjy0ePWZNJ0HdXyGZHXjV17RtVb8ve0TrIRi8fcdTFsij7dw0o+3MPk",
+	"V5X0KlXp54XosfjikZAHfuZrHpg/lH71p/rDTCpao6TImk4JJ6rG8p3ba7S+yE8WLiLednZ2Xr9sgji7",
+	"FmDw53jNbZeYWSxrZNWpe/0Eo00ljKvoVvuuUx4Dvh/y3a6MhpBfn8xSAFj08CxB+QdiBVrNYiQKRC29",
+	"tO+fn/CJXsIYnnz6+v/AxLq0LaYAAA==",
+}
+
+// GetSwagger returns the content of the embedded swagger specification file
+// or error if failed to decode
+func decodeSpec() ([]byte, error) {
+	zipped, err := base64.StdEncoding.DecodeString(strings.Join(swaggerSpec, ""))
+	if err != nil {
+		return nil, fmt.Errorf("error base64 decoding spec: %s", err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(zipped))
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing spec: %s", err)
+	}
+	var buf bytes.Buffer
+	_, err = buf.ReadFrom(zr)
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing spec: %s", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+var rawSpec = decodeSpecCached()
+
+// a naive cached of a decoded swagger spec
+func decodeSpecCached() func() ([]byte, error) {
+	data, err := decodeSpec()
+	return func() ([]byte, error) {
+		return data, err
+	}
+}
+
+// Constructs a synthetic filesystem for resolving external references when loading openapi specifications.
+func PathToRawSpec(pathToFile string) map[string]func() ([]byte, error) {
+	var res = make(map[string]func() ([]byte, error))
+	if len(pathToFile) > 0 {
+		res[pathToFile] = rawSpec
+	}
+
+	return res
+}
+
+// GetSwagger returns the Swagger specification corresponding to the generated code
+// in this file. The external references of Swagger specification are resolved.
+// The logic of resolving external references is tightly connected to "import-mapping" feature.
+// Externally referenced files must be embedded in the corresponding golang packages.
+// Urls can be supported but this task was out of the scope.
+func GetSwagger() (swagger *openapi3.T, err error) {
+	var resolvePath = PathToRawSpec("")
+
+	loader := openapi3.NewLoader()
+	loader.IsExternalRefsAllowed = true
+	loader.ReadFromURIFunc = func(loader *openapi3.Loader, url *url.URL) ([]byte, error) {
+		var pathToFile = url.String()
+		pathToFile = path.Clean(pathToFile)
+		getSpec, ok := resolvePath[pathToFile]
+		if !ok {
+			err1 := fmt.Errorf("path not found: %s", pathToFile)
+			return nil, err1
+		}
+		return getSpec()
+	}
+	var specData []byte
+	specData, err = rawSpec()
+	if err != nil {
+		return
+	}
+	swagger, err = loader.LoadFromData(specData)
+	if err != nil {
+		return
+	}
+	return
+}
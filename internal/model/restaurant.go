@@ -0,0 +1,88 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const geoJsonPointType = "Point"
+
+// Coordinates returns the latitude and longitude of the restaurant's geocoded address.
+func (r Restaurant) Coordinates() (float64, float64, bool) {
+	if r.Address == nil || r.Address.Location == nil {
+		return 0, 0, false
+	}
+
+	l := r.Address.Location
+	if l.Latitude != nil && l.Longitude != nil {
+		return *l.Latitude, *l.Longitude, true
+	}
+	return parseGeocode(l.Geocode)
+}
+
+// NewLocation returns a location with the coordinates set in all of its formats.
+func NewLocation(lat, lon float64) Location {
+	l := Location{}
+	l.setCoordinates(lat, lon)
+	return l
+}
+
+// BackfillCoordinates sets the numeric coordinates of a restaurant stored before
+// they existed, from its geocode string. It returns true if the restaurant changed.
+func (r *Restaurant) BackfillCoordinates() bool {
+	if r.Address == nil {
+		return false
+	}
+	return r.Address.Location.BackfillCoordinates()
+}
+
+// BackfillCoordinates sets the numeric coordinates of a location stored before
+// they existed, from its geocode string. It returns true if the location changed.
+func (l *Location) BackfillCoordinates() bool {
+	if l == nil || (l.Latitude != nil && l.Longitude != nil) {
+		return false
+	}
+
+	lat, lon, ok := parseGeocode(l.Geocode)
+	if !ok {
+		return false
+	}
+
+	// The geocode string is kept as it was stored
+	geocode := l.Geocode
+	l.setCoordinates(lat, lon)
+	l.Geocode = geocode
+	return true
+}
+
+func (l *Location) setCoordinates(lat, lon float64) {
+	// Geocode is deprecated, it is still set for clients that have not moved to latitude and longitude
+	geocode := fmt.Sprintf("%f,%f", lat, lon)
+	l.Geocode = &geocode
+	l.Latitude = &lat
+	l.Longitude = &lon
+	l.Point = &GeoJsonPoint{Type: geoJsonPointType, Coordinates: []float64{lon, lat}}
+}
+
+// parseGeocode parses a geocode string with the format lat,lon.
+func parseGeocode(geocode *string) (float64, float64, bool) {
+	if geocode == nil {
+		return 0, 0, false
+	}
+
+	latStr, lonStr, found := strings.Cut(*geocode, ",")
+	if !found {
+		return 0, 0, false
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(latStr), 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(lonStr), 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return lat, lon, true
+}
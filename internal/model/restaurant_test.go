@@ -0,0 +1,90 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_Coordinates(t *testing.T) {
+	t.Parallel()
+	geocode := func(s string) *string { return &s }
+	location := NewLocation(47.6062, -122.3321)
+
+	testCases := []struct {
+		name     string
+		location *Location
+		lat      float64
+		lon      float64
+		ok       bool
+	}{
+		{
+			name:     "latitude and longitude",
+			location: &location,
+			lat:      47.6062,
+			lon:      -122.3321,
+			ok:       true,
+		},
+		{
+			name:     "geocode only",
+			location: &Location{Geocode: geocode("47.606200, -122.332100")},
+			lat:      47.6062,
+			lon:      -122.3321,
+			ok:       true,
+		},
+		{
+			name: "no location",
+		},
+		{
+			name:     "malformed geocode",
+			location: &Location{Geocode: geocode("47.6062")},
+		},
+		{
+			name:     "geocode not a number",
+			location: &Location{Geocode: geocode("north,west")},
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			lat, lon, ok := Restaurant{Address: &Address{Location: tc.location}}.Coordinates()
+
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.lat, lat)
+			assert.Equal(t, tc.lon, lon)
+		})
+	}
+}
+
+func Test_NewLocation(t *testing.T) {
+	t.Parallel()
+
+	l := NewLocation(47.6062, -122.3321)
+
+	assert.Equal(t, 47.6062, *l.Latitude)
+	assert.Equal(t, -122.3321, *l.Longitude)
+	assert.Equal(t, &GeoJsonPoint{Type: "Point", Coordinates: []float64{-122.3321, 47.6062}}, l.Point)
+	assert.Equal(t, "47.606200,-122.332100", *l.Geocode)
+}
+
+func Test_BackfillCoordinates(t *testing.T) {
+	t.Parallel()
+	geocode := "47.6062,-122.3321"
+
+	r := Restaurant{Address: &Address{Location: &Location{Geocode: &geocode}}}
+	assert.True(t, r.BackfillCoordinates())
+	assert.Equal(t, 47.6062, *r.Address.Location.Latitude)
+	assert.Equal(t, -122.3321, *r.Address.Location.Longitude)
+	assert.Equal(t, []float64{-122.3321, 47.6062}, r.Address.Location.Point.Coordinates)
+	assert.Equal(t, &geocode, r.Address.Location.Geocode)
+
+	// Already migrated
+	assert.False(t, r.BackfillCoordinates())
+
+	assert.False(t, (&Restaurant{}).BackfillCoordinates())
+	assert.False(t, (&Restaurant{Address: &Address{}}).BackfillCoordinates())
+	assert.False(t, (&Restaurant{Address: &Address{Location: &Location{}}}).BackfillCoordinates())
+}
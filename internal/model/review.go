@@ -0,0 +1,45 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	MinRating = 1
+	MaxRating = 5
+)
+
+// Validate returns an error describing the first invalid field of the review.
+func (r Review) Validate() error {
+	if r.Rating < MinRating || r.Rating > MaxRating {
+		return fmt.Errorf("rating %d is not between %d and %d", r.Rating, MinRating, MaxRating)
+	}
+	if strings.TrimSpace(r.Author) == "" {
+		return errors.New("author is empty")
+	}
+	return nil
+}
+
+// NewRatingSummary returns the summary of the ratings given the number of reviews
+// of each rating, histogram[0] being the number of reviews rated 1. It returns nil
+// when there are no reviews.
+func NewRatingSummary(histogram [MaxRating]int) *RatingSummary {
+	summary := RatingSummary{Histogram: map[string]int{}}
+	total := 0
+	for i, n := range histogram {
+		rating := i + MinRating
+		summary.Histogram[strconv.Itoa(rating)] = n
+		summary.Count += n
+		total += n * rating
+	}
+
+	if summary.Count == 0 {
+		return nil
+	}
+	summary.Average = math.Round(float64(total)/float64(summary.Count)*100) / 100
+	return &summary
+}
@@ -0,0 +1,67 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_ReviewValidate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		review Review
+		errMsg string
+	}{
+		{
+			name:   "valid",
+			review: Review{Rating: 5, Author: "Ana"},
+		},
+		{
+			name:   "rating too low",
+			review: Review{Rating: 0, Author: "Ana"},
+			errMsg: "rating 0 is not between 1 and 5",
+		},
+		{
+			name:   "rating too high",
+			review: Review{Rating: 6, Author: "Ana"},
+			errMsg: "rating 6 is not between 1 and 5",
+		},
+		{
+			name:   "empty author",
+			review: Review{Rating: 3, Author: " "},
+			errMsg: "author is empty",
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.review.Validate()
+
+			if tc.errMsg != "" {
+				if assert.Error(t, err) {
+					assert.Equal(t, tc.errMsg, err.Error())
+				}
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_NewRatingSummary(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, NewRatingSummary([MaxRating]int{}))
+
+	summary := NewRatingSummary([MaxRating]int{1, 0, 0, 1, 1})
+	if assert.NotNil(t, summary) {
+		assert.Equal(t, 3, summary.Count)
+		assert.Equal(t, 3.33, summary.Average)
+		assert.Equal(t, map[string]int{"1": 1, "2": 0, "3": 0, "4": 1, "5": 1}, summary.Histogram)
+	}
+}
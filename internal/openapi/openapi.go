@@ -0,0 +1,238 @@
+// Package openapi validates the requests and the documents of the API against its embedded
+// OpenAPI spec. It has no other state than the spec, so that both the middleware and the
+// controllers can use it.
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/lfroomin/restaurant-serverless/internal/httpRequest"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// spec is the embedded OpenAPI spec of the API, which the requests are validated against.
+var spec = loadSpec()
+
+func init() {
+	// A JSON Merge Patch is decoded like any JSON document
+	openapi3filter.RegisterBodyDecoder("application/merge-patch+json", openapi3filter.RegisteredBodyDecoder(httpRequest.JSONContentType))
+}
+
+func loadSpec() *openapi3.T {
+	s, err := model.GetSwagger()
+	if err != nil {
+		log.Fatalf("error loading the embedded OpenAPI spec: %s", err.Error())
+	}
+	return s
+}
+
+// ValidateRequest responds 400 with the violations when the parameters or the body of the
+// request do not conform to the operation of its resource and method in the spec. A body
+// that cannot be decoded is rejected with the same responses as httpRequest.DecodeJSON.
+// Requests to resources that are not in the spec are not checked, and nil is returned.
+func ValidateRequest(request events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	pathItem := spec.Paths[request.Resource]
+	if pathItem == nil {
+		return nil
+	}
+	operation := pathItem.GetOperation(request.HTTPMethod)
+	if operation == nil {
+		return nil
+	}
+
+	// The bearer token is verified by the middleware
+	options := &openapi3filter.Options{MultiError: true, SkipSettingDefaults: true, AuthenticationFunc: openapi3filter.NoopAuthenticationFunc}
+	contentType, body := httpRequest.MediaType(request), []byte(nil)
+	if requestBody := operation.RequestBody; requestBody != nil {
+		content := requestBody.Value.Content
+		if content.Get(contentType) == nil {
+			// The handlers decode a body without a Content-Type as JSON. The bodies the
+			// operation does not declare, such as the JSON Patch of PATCH /{restaurantId},
+			// are left to the handler.
+			switch {
+			case content.Get(httpRequest.JSONContentType) == nil:
+				options.ExcludeRequestBody = true
+			case contentType == "":
+				contentType = httpRequest.JSONContentType
+			default:
+				return httpResponse.NewProblem(http.StatusUnsupportedMediaType, httpResponse.CodeUnsupportedMediaType,
+					fmt.Sprintf("Content-Type must be %s", httpRequest.JSONContentType))
+			}
+		}
+
+		if !options.ExcludeRequestBody && (request.Body != "" || requestBody.Value.Required) {
+			var resp *events.APIGatewayProxyResponse
+			if body, resp = httpRequest.Body(request); resp != nil {
+				return resp
+			}
+			if resp = httpRequest.Unmarshal(body, new(any)); resp != nil {
+				return resp
+			}
+		}
+	}
+
+	err := openapi3filter.ValidateRequest(context.Background(), &openapi3filter.RequestValidationInput{
+		Request:    newHTTPRequest(request, contentType, body),
+		PathParams: request.PathParameters,
+		Route: &routers.Route{
+			Spec:      spec,
+			Path:      request.Resource,
+			PathItem:  pathItem,
+			Method:    request.HTTPMethod,
+			Operation: operation,
+		},
+		Options: options,
+	})
+
+	return newViolations(violations(err))
+}
+
+// ValidateSchema responds 400 with the violations when the JSON document does not conform to
+// the schema of the spec named name. It validates the documents that are not request bodies,
+// such as a restaurant once a patch is applied to it.
+func ValidateSchema(name string, doc []byte) *events.APIGatewayProxyResponse {
+	schema := spec.Components.Schemas[name]
+	if schema == nil {
+		return httpResponse.NewServerError(fmt.Errorf("the OpenAPI spec has no schema %q", name))
+	}
+
+	var value any
+	if err := json.Unmarshal(doc, &value); err != nil {
+		return httpResponse.NewServerError(fmt.Errorf("error unmarshalling the document of schema %q: %w", name, err))
+	}
+
+	err := schema.Value.VisitJSON(value, openapi3.MultiErrors())
+	if err == nil {
+		return nil
+	}
+	return newViolations(requestViolations("body", &openapi3filter.RequestError{Err: err}))
+}
+
+// newViolations responds 400 with the violations sorted by field, or returns nil when there are none.
+func newViolations(vs []model.Violation) *events.APIGatewayProxyResponse {
+	if len(vs) == 0 {
+		return nil
+	}
+	sort.Slice(vs, func(i, j int) bool {
+		if vs[i].Field != vs[j].Field {
+			return vs[i].Field < vs[j].Field
+		}
+		return vs[i].Message < vs[j].Message
+	})
+	return httpResponse.NewViolations(vs)
+}
+
+// newHTTPRequest converts the API Gateway proxy request back into the HTTP request it was
+// made from, with the decoded body and its media type.
+func newHTTPRequest(request events.APIGatewayProxyRequest, contentType string, body []byte) *http.Request {
+	header := http.Header{}
+	for k, v := range request.Headers {
+		header.Set(k, v)
+	}
+	for k, vs := range request.MultiValueHeaders {
+		header.Del(k)
+		for _, v := range vs {
+			header.Add(k, v)
+		}
+	}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+
+	query := url.Values{}
+	for k, v := range request.QueryStringParameters {
+		query.Set(k, v)
+	}
+	for k, vs := range request.MultiValueQueryStringParameters {
+		query[k] = vs
+	}
+
+	return &http.Request{
+		Method:        request.HTTPMethod,
+		URL:           &url.URL{Path: request.Path, RawQuery: query.Encode()},
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+}
+
+// violations converts the error of openapi3filter.ValidateRequest into violations.
+func violations(err error) []model.Violation {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case openapi3.MultiError:
+		var vs []model.Violation
+		for _, err := range e {
+			vs = append(vs, violations(err)...)
+		}
+		return vs
+	case *openapi3filter.RequestError:
+		field := "body"
+		if e.Parameter != nil {
+			field = e.Parameter.Name
+		}
+		return requestViolations(field, e)
+	}
+
+	return []model.Violation{{Field: "request", Message: err.Error()}}
+}
+
+// requestViolations returns the violations of the parameter or the body named field.
+func requestViolations(field string, err *openapi3filter.RequestError) []model.Violation {
+	switch e := err.Err.(type) {
+	case nil:
+		return []model.Violation{{Field: field, Message: err.Reason}}
+	case openapi3.MultiError:
+		var vs []model.Violation
+		for _, schemaErr := range e {
+			vs = append(vs, requestViolations(field, &openapi3filter.RequestError{Reason: err.Reason, Err: schemaErr})...)
+		}
+		return vs
+	case *openapi3.SchemaError:
+		return []model.Violation{schemaViolation(field, e)}
+	}
+
+	if errors.Is(err.Err, openapi3filter.ErrInvalidRequired) {
+		return []model.Violation{{Field: field, Message: "is required"}}
+	}
+	return []model.Violation{{Field: field, Message: err.Err.Error()}}
+}
+
+// schemaViolation returns the violation of a property of the parameter or the body named
+// field, or of the whole value.
+func schemaViolation(field string, err *openapi3.SchemaError) model.Violation {
+	path, message := err.JSONPointer(), err.Reason
+
+	var property string
+	if err.SchemaField == "required" {
+		// The path of a missing property ends with its name
+		message = "is required"
+	} else if err.SchemaField == "type" {
+		message = fmt.Sprintf("must be of type %s", err.Schema.Type)
+	} else if _, scanErr := fmt.Sscanf(err.Reason, "property %q is unsupported", &property); scanErr == nil {
+		path, message = append(path, property), "is not a known field"
+	}
+
+	if len(path) == 0 {
+		return model.Violation{Field: field, Message: message}
+	}
+	if field != "body" {
+		path = append([]string{field}, path...)
+	}
+	return model.Violation{Field: strings.Join(path, "."), Message: message}
+}
@@ -0,0 +1,65 @@
+package openapi
+
+import (
+	"encoding/json"
+	"github.com/lfroomin/restaurant-serverless/internal/httpResponse"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func Test_ValidateSchema(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		schema       string
+		doc          string
+		responseCode int
+		violations   []model.Violation
+	}{
+		{
+			name:   "valid restaurant",
+			schema: "Restaurant",
+			doc:    `{"id":"restId","name":"Rest 1"}`,
+		},
+		{
+			name:         "invalid restaurant",
+			schema:       "Restaurant",
+			doc:          `{"id":"restId","name":"","cuisine":"thai"}`,
+			responseCode: http.StatusBadRequest,
+			violations:   []model.Violation{{Field: "cuisine", Message: "is not a known field"}, {Field: "name", Message: "minimum string length is 1"}},
+		},
+		{
+			name:         "unknown schema",
+			schema:       "Photo",
+			doc:          `{}`,
+			responseCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			resp := ValidateSchema(tc.schema, []byte(tc.doc))
+
+			if tc.responseCode == 0 {
+				assert.Nil(t, resp)
+				return
+			}
+			require.NotNil(t, resp)
+			assert.Equal(t, tc.responseCode, resp.StatusCode)
+			if tc.violations != nil {
+				problem := model.Problem{}
+				require.NoError(t, json.Unmarshal([]byte(resp.Body), &problem))
+				assert.Equal(t, string(httpResponse.CodeInvalidRequest), problem.Code)
+				require.NotNil(t, problem.Violations)
+				assert.Equal(t, tc.violations, *problem.Violations)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+// Package outbox relays the events that the restaurant storage records in its outbox, in
+// the same write as the change of the restaurant, to an event publisher. An event is
+// published at least once: it stays in the outbox until it is marked delivered.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"github.com/lfroomin/restaurant-serverless/internal/event"
+	"log"
+	"time"
+)
+
+const (
+	defaultBatchSize   = 25
+	defaultLease       = 5 * time.Minute
+	defaultBackoffBase = 30 * time.Second
+	defaultBackoffMax  = time.Hour
+)
+
+// Record is an undelivered event in the outbox.
+type Record struct {
+	Event event.Event
+	// Attempts is the number of failed attempts to publish the event
+	Attempts int
+	// NextAttempt is when the event is due to be published, with millisecond precision
+	NextAttempt time.Time
+	LastError   string
+}
+
+type Store interface {
+	// Due returns up to limit undelivered records with a next attempt at or before now, the earliest first.
+	Due(now time.Time, limit int32) ([]Record, error)
+	// Claim moves the next attempt of the record to until, so other relays skip it while it
+	// is published. It returns false when the record changed since it was returned by Due.
+	Claim(record Record, until time.Time) (bool, error)
+	// Retry records a failed attempt to publish the record, due again at nextAttempt.
+	Retry(record Record, nextAttempt time.Time, lastError string) error
+	MarkDelivered(record Record) error
+}
+
+type Publisher interface {
+	Publish(e event.Event) error
+}
+
+// Publishers publishes each event with all the publishers. The event is retried with all of
+// them when one fails, so the publishers must accept an event more than once.
+type Publishers []Publisher
+
+func (ps Publishers) Publish(e event.Event) error {
+	var errs []error
+	for _, p := range ps {
+		if err := p.Publish(e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type Relay struct {
+	Store     Store
+	Publisher Publisher
+	BatchSize int32
+	// Lease is how long a claimed record is skipped by other relays. A record whose
+	// relay stopped before marking it is published again when its lease expires.
+	Lease time.Duration
+	// The delay before the next attempt doubles after each failed attempt, from BackoffBase up to BackoffMax.
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+}
+
+func New(store Store, publisher Publisher) Relay {
+	return Relay{
+		Store:       store,
+		Publisher:   publisher,
+		BatchSize:   defaultBatchSize,
+		Lease:       defaultLease,
+		BackoffBase: defaultBackoffBase,
+		BackoffMax:  defaultBackoffMax,
+	}
+}
+
+// Drain publishes the records that are due, a batch at a time, until none are left. It
+// returns the number of events delivered. The events that fail are retried by a later Drain.
+func (r Relay) Drain() (int, error) {
+	delivered := 0
+	for {
+		now := time.Now()
+		records, err := r.Store.Due(now, r.BatchSize)
+		if err != nil {
+			return delivered, err
+		}
+
+		for _, record := range records {
+			ok, err := r.relay(record, now)
+			if err != nil {
+				return delivered, err
+			}
+			if ok {
+				delivered++
+			}
+		}
+
+		// A record that failed is not due again before its backoff, so this ends
+		if len(records) < int(r.BatchSize) {
+			return delivered, nil
+		}
+	}
+}
+
+// Run drains the outbox every interval until the context is done, for the local server.
+func (r Relay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.Drain(); err != nil {
+			log.Printf("error draining the outbox: %s\n", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// relay publishes the record and reports whether it was delivered.
+func (r Relay) relay(record Record, now time.Time) (bool, error) {
+	claimed, err := r.Store.Claim(record, now.Add(r.Lease))
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	if err = r.Publisher.Publish(record.Event); err != nil {
+		next := now.Add(r.backoff(record.Attempts + 1))
+		log.Printf("error publishing event %s of restaurant %s (attempt %d), next attempt at %s: %s\n",
+			record.Event.Id, record.Event.RestaurantId, record.Attempts+1, next.Format(time.RFC3339), err.Error())
+		return false, r.Store.Retry(record, next, err.Error())
+	}
+
+	return true, r.Store.MarkDelivered(record)
+}
+
+// backoff returns the delay before the next attempt after the failed attempts.
+func (r Relay) backoff(attempts int) time.Duration {
+	delay := r.BackoffBase
+	for i := 1; i < attempts && delay < r.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > r.BackoffMax {
+		return r.BackoffMax
+	}
+	return delay
+}
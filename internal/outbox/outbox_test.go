@@ -0,0 +1,192 @@
+package outbox
+
+import (
+	"errors"
+	"github.com/lfroomin/restaurant-serverless/internal/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_Drain(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		records      int
+		claimLost    bool
+		publishError string
+		storeError   string
+		delivered    int
+		retried      int
+		errMsg       string
+	}{
+		{
+			name:      "happy path",
+			records:   2,
+			delivered: 2,
+		},
+		{
+			name:      "more records than the batch size",
+			records:   5,
+			delivered: 5,
+		},
+		{
+			name: "empty outbox",
+		},
+		{
+			name:      "claimed by another relay",
+			records:   2,
+			claimLost: true,
+		},
+		{
+			name:         "publish error",
+			records:      2,
+			publishError: "an error occurred",
+			retried:      2,
+		},
+		{
+			name:       "store error",
+			records:    2,
+			storeError: "an error occurred",
+			errMsg:     "an error occurred",
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			store := &storeStub{claimLost: tc.claimLost, error: tc.storeError}
+			for i := 0; i < tc.records; i++ {
+				store.records = append(store.records, Record{Event: event.Event{Id: string(rune('a' + i))}})
+			}
+			publisher := &publisherStub{error: tc.publishError}
+
+			relay := New(store, publisher)
+			relay.BatchSize = 2
+			start := time.Now()
+
+			delivered, err := relay.Drain()
+
+			if tc.errMsg != "" {
+				assert.EqualError(t, err, tc.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.delivered, delivered)
+			assert.Len(t, store.delivered, tc.delivered)
+			assert.Len(t, store.retried, tc.retried)
+			if tc.publishError == "" {
+				assert.Len(t, publisher.published, tc.delivered)
+			}
+			for _, r := range store.retried {
+				assert.Equal(t, 1, r.Attempts)
+				assert.Equal(t, tc.publishError, r.LastError)
+				assert.WithinDuration(t, start.Add(defaultBackoffBase), r.NextAttempt, time.Second)
+			}
+		})
+	}
+}
+
+func Test_Backoff(t *testing.T) {
+	t.Parallel()
+	relay := Relay{BackoffBase: time.Second, BackoffMax: 10 * time.Second}
+
+	testCases := []struct {
+		attempts int
+		delay    time.Duration
+	}{
+		{attempts: 1, delay: time.Second},
+		{attempts: 2, delay: 2 * time.Second},
+		{attempts: 4, delay: 8 * time.Second},
+		{attempts: 5, delay: 10 * time.Second},
+		{attempts: 100, delay: 10 * time.Second},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.delay, relay.backoff(tc.attempts), "attempts %d", tc.attempts)
+	}
+}
+
+func Test_Publishers(t *testing.T) {
+	t.Parallel()
+	ok, failing, other := &publisherStub{}, &publisherStub{error: "an error occurred"}, &publisherStub{}
+
+	err := Publishers{failing, ok, other}.Publish(event.Event{Id: "a"})
+
+	// The other publishers publish the event even when one fails
+	assert.EqualError(t, err, "an error occurred")
+	assert.Len(t, ok.published, 1)
+	assert.Len(t, other.published, 1)
+
+	assert.NoError(t, Publishers{ok}.Publish(event.Event{Id: "b"}))
+	assert.Len(t, ok.published, 2)
+}
+
+// storeStub is an outbox whose records are all due, until they are delivered or retried.
+type storeStub struct {
+	records   []Record
+	delivered []Record
+	retried   []Record
+	claimLost bool
+	error     string
+}
+
+func (s *storeStub) Due(_ time.Time, limit int32) ([]Record, error) {
+	if s.error != "" {
+		return nil, errors.New(s.error)
+	}
+	if s.claimLost {
+		// Another relay takes the records
+		records := s.records
+		s.records = nil
+		return records, nil
+	}
+	if len(s.records) > int(limit) {
+		return s.records[:limit], nil
+	}
+	return s.records, nil
+}
+
+func (s *storeStub) Claim(_ Record, _ time.Time) (bool, error) {
+	return !s.claimLost, nil
+}
+
+func (s *storeStub) Retry(record Record, nextAttempt time.Time, lastError string) error {
+	record.Attempts++
+	record.NextAttempt, record.LastError = nextAttempt, lastError
+	s.retried = append(s.retried, record)
+	s.remove(record)
+	return nil
+}
+
+func (s *storeStub) MarkDelivered(record Record) error {
+	s.delivered = append(s.delivered, record)
+	s.remove(record)
+	return nil
+}
+
+func (s *storeStub) remove(record Record) {
+	for i, r := range s.records {
+		if r.Event.Id == record.Event.Id {
+			s.records = append(s.records[:i], s.records[i+1:]...)
+			return
+		}
+	}
+}
+
+type publisherStub struct {
+	published []event.Event
+	error     string
+}
+
+func (p *publisherStub) Publish(e event.Event) error {
+	if p.error != "" {
+		return errors.New(p.error)
+	}
+	p.published = append(p.published, e)
+	return nil
+}
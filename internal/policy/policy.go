@@ -0,0 +1,201 @@
+// Package policy decides whether a caller may perform an action on a restaurant, its menus
+// and reviews, or manage the webhooks and API keys. An Engine evaluates its policies in order, and the first one that applies
+// to the request decides.
+package policy
+
+import (
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+)
+
+type Action string
+
+const (
+	// ActionRead is reading, listing and searching restaurants, and their status
+	ActionRead Action = "restaurant:read"
+	// ActionGeocode is previewing the geocoding of an address, which changes nothing
+	ActionGeocode Action = "restaurant:geocode"
+	ActionCreate  Action = "restaurant:create"
+	// ActionUpdate is replacing or patching a restaurant
+	ActionUpdate Action = "restaurant:update"
+	ActionDelete Action = "restaurant:delete"
+	// ActionManageMenus is creating, updating and deleting the menus of a restaurant
+	ActionManageMenus Action = "menu:manage"
+	// ActionCreateReview is reviewing a restaurant
+	ActionCreateReview Action = "review:create"
+	// ActionDeleteReview is removing a review of a restaurant, which is moderation
+	ActionDeleteReview Action = "review:delete"
+	// ActionManageWebhooks is registering, reading and deleting the webhooks of the tenant, and reading their deliveries
+	ActionManageWebhooks Action = "webhook:manage"
+	// ActionManageApiKeys is issuing, listing, rotating and revoking the API keys of the tenant
+	ActionManageApiKeys Action = "apikey:manage"
+)
+
+const RoleAdmin = "admin"
+
+// Reason is the machine-readable reason of a denial, returned in the 403 problem.
+type Reason string
+
+const (
+	ReasonAuthenticationRequired Reason = "AUTHENTICATION_REQUIRED"
+	ReasonAdminRequired          Reason = "ADMIN_REQUIRED"
+	ReasonNotOwner               Reason = "NOT_OWNER"
+	ReasonOwnersChangeForbidden  Reason = "OWNERS_CHANGE_FORBIDDEN"
+	// ReasonTenantMismatch denies the requests for another tenant than the one of the token
+	ReasonTenantMismatch Reason = "TENANT_MISMATCH"
+	// ReasonReadOnlyApiKey denies the requests of a read only API key that are not reads
+	ReasonReadOnlyApiKey Reason = "READ_ONLY_API_KEY"
+	// ReasonBearerTokenRequired denies the API keys the management of the API keys
+	ReasonBearerTokenRequired Reason = "BEARER_TOKEN_REQUIRED"
+)
+
+// Principal is the caller. The principal of an anonymous request has no subject.
+type Principal struct {
+	Subject string
+	Roles   []string
+	// ApiKey is true when the caller is authenticated with an API key rather than a bearer token
+	ApiKey bool
+}
+
+func (p Principal) Anonymous() bool {
+	return p.Subject == ""
+}
+
+func (p Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Request is an action of a principal on a restaurant.
+type Request struct {
+	Principal Principal
+	Action    Action
+	// Restaurant is the stored restaurant, nil for the actions on no restaurant in particular
+	Restaurant *model.Restaurant
+	// Changed is the restaurant as an update would leave it
+	Changed *model.Restaurant
+}
+
+type Decision struct {
+	Allow bool
+	// Policy is the name of the policy that decided
+	Policy string
+	Reason Reason
+}
+
+// Policy returns its decision on the request, and false when it does not apply to it.
+type Policy struct {
+	Name     string
+	Evaluate func(r Request) (Decision, bool)
+}
+
+type Engine struct {
+	Policies []Policy
+}
+
+// Default is the policy of the API: admins can do anything, anyone can read, authenticated
+// callers can review restaurants, and owners can update their own restaurants and their
+// menus, without changing who owns them. The API keys are authorized like users, by their
+// subject, and cannot manage the API keys.
+var Default = Engine{Policies: []Policy{
+	{Name: "bearer token", Evaluate: bearerToken},
+	{Name: "admin", Evaluate: admin},
+	{Name: "read", Evaluate: read},
+	{Name: "authenticated", Evaluate: authenticated},
+	{Name: "geocode", Evaluate: geocode},
+	{Name: "review", Evaluate: review},
+	{Name: "owner", Evaluate: owner},
+}}
+
+// Evaluate returns the decision of the first policy that applies to the request. A request
+// that no policy applies to is only allowed to admins, so it is denied.
+func (e Engine) Evaluate(r Request) Decision {
+	for _, p := range e.Policies {
+		if d, ok := p.Evaluate(r); ok {
+			d.Policy = p.Name
+			return d
+		}
+	}
+	return Decision{Reason: ReasonAdminRequired}
+}
+
+func bearerToken(r Request) (Decision, bool) {
+	return Decision{Reason: ReasonBearerTokenRequired}, r.Principal.ApiKey && r.Action == ActionManageApiKeys
+}
+
+func admin(r Request) (Decision, bool) {
+	return Decision{Allow: true}, r.Principal.HasRole(RoleAdmin)
+}
+
+func read(r Request) (Decision, bool) {
+	return Decision{Allow: true}, r.Action == ActionRead
+}
+
+// authenticated denies anonymous callers everything the previous policies do not allow.
+func authenticated(r Request) (Decision, bool) {
+	return Decision{Reason: ReasonAuthenticationRequired}, r.Principal.Anonymous()
+}
+
+func geocode(r Request) (Decision, bool) {
+	return Decision{Allow: true}, r.Action == ActionGeocode
+}
+
+func review(r Request) (Decision, bool) {
+	return Decision{Allow: true}, r.Action == ActionCreateReview
+}
+
+// owner decides the updates of a restaurant, and the changes of its menus, which are
+// requested with the restaurant they belong to.
+func owner(r Request) (Decision, bool) {
+	if (r.Action != ActionUpdate && r.Action != ActionManageMenus) || r.Restaurant == nil {
+		return Decision{}, false
+	}
+
+	if !IsOwner(r.Principal, *r.Restaurant) {
+		return Decision{Reason: ReasonNotOwner}, true
+	}
+	if r.Changed != nil && !sameOwners(*r.Restaurant, *r.Changed) {
+		return Decision{Reason: ReasonOwnersChangeForbidden}, true
+	}
+	return Decision{Allow: true}, true
+}
+
+// IsOwner reports whether the principal is one of the owners of the restaurant.
+func IsOwner(p Principal, restaurant model.Restaurant) bool {
+	if p.Anonymous() {
+		return false
+	}
+	for _, id := range owners(restaurant) {
+		if id == p.Subject {
+			return true
+		}
+	}
+	return false
+}
+
+func owners(restaurant model.Restaurant) []string {
+	if restaurant.OwnerIds == nil {
+		return nil
+	}
+	return *restaurant.OwnerIds
+}
+
+// sameOwners reports whether the restaurants have the same owners, in any order.
+func sameOwners(a, b model.Restaurant) bool {
+	ids := map[string]int{}
+	for _, id := range owners(a) {
+		ids[id]++
+	}
+	for _, id := range owners(b) {
+		ids[id]--
+	}
+	for _, n := range ids {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,225 @@
+package policy
+
+import (
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_Default(t *testing.T) {
+	t.Parallel()
+
+	anonymous := Principal{}
+	admin := Principal{Subject: "admin1", Roles: []string{"reader", RoleAdmin}}
+	owner := Principal{Subject: "owner1"}
+	user := Principal{Subject: "user1"}
+	apiKey := Principal{Subject: "apikey:key1", ApiKey: true}
+
+	restaurant := func(ownerIds ...string) *model.Restaurant {
+		return &model.Restaurant{Name: "name", OwnerIds: &ownerIds}
+	}
+
+	testCases := []struct {
+		name     string
+		request  Request
+		decision Decision
+	}{
+		{
+			name:     "anonymous read",
+			request:  Request{Principal: anonymous, Action: ActionRead},
+			decision: Decision{Allow: true, Policy: "read"},
+		},
+		{
+			name:     "anonymous create",
+			request:  Request{Principal: anonymous, Action: ActionCreate},
+			decision: Decision{Policy: "authenticated", Reason: ReasonAuthenticationRequired},
+		},
+		{
+			name:     "anonymous geocode",
+			request:  Request{Principal: anonymous, Action: ActionGeocode},
+			decision: Decision{Policy: "authenticated", Reason: ReasonAuthenticationRequired},
+		},
+		{
+			name:     "user geocode",
+			request:  Request{Principal: user, Action: ActionGeocode},
+			decision: Decision{Allow: true, Policy: "geocode"},
+		},
+		{
+			name:     "admin create",
+			request:  Request{Principal: admin, Action: ActionCreate},
+			decision: Decision{Allow: true, Policy: "admin"},
+		},
+		{
+			name:     "admin delete",
+			request:  Request{Principal: admin, Action: ActionDelete, Restaurant: restaurant("owner1")},
+			decision: Decision{Allow: true, Policy: "admin"},
+		},
+		{
+			name:     "admin changes the owners",
+			request:  Request{Principal: admin, Action: ActionUpdate, Restaurant: restaurant("owner1"), Changed: restaurant("owner2")},
+			decision: Decision{Allow: true, Policy: "admin"},
+		},
+		{
+			name:     "user create",
+			request:  Request{Principal: user, Action: ActionCreate},
+			decision: Decision{Reason: ReasonAdminRequired},
+		},
+		{
+			name:     "owner update",
+			request:  Request{Principal: owner, Action: ActionUpdate, Restaurant: restaurant("owner2", "owner1"), Changed: restaurant("owner1", "owner2")},
+			decision: Decision{Allow: true, Policy: "owner"},
+		},
+		{
+			name:     "owner update before the change is known",
+			request:  Request{Principal: owner, Action: ActionUpdate, Restaurant: restaurant("owner1")},
+			decision: Decision{Allow: true, Policy: "owner"},
+		},
+		{
+			name:     "owner adds an owner",
+			request:  Request{Principal: owner, Action: ActionUpdate, Restaurant: restaurant("owner1"), Changed: restaurant("owner1", "user1")},
+			decision: Decision{Policy: "owner", Reason: ReasonOwnersChangeForbidden},
+		},
+		{
+			name:     "owner removes the owners",
+			request:  Request{Principal: owner, Action: ActionUpdate, Restaurant: restaurant("owner1"), Changed: &model.Restaurant{Name: "name"}},
+			decision: Decision{Policy: "owner", Reason: ReasonOwnersChangeForbidden},
+		},
+		{
+			name:     "user update",
+			request:  Request{Principal: user, Action: ActionUpdate, Restaurant: restaurant("owner1"), Changed: restaurant("owner1")},
+			decision: Decision{Policy: "owner", Reason: ReasonNotOwner},
+		},
+		{
+			name:     "update of a restaurant without owners",
+			request:  Request{Principal: owner, Action: ActionUpdate, Restaurant: &model.Restaurant{Name: "name"}},
+			decision: Decision{Policy: "owner", Reason: ReasonNotOwner},
+		},
+		{
+			name:     "owner delete",
+			request:  Request{Principal: owner, Action: ActionDelete, Restaurant: restaurant("owner1")},
+			decision: Decision{Reason: ReasonAdminRequired},
+		},
+		{
+			name:     "owner manages the menus",
+			request:  Request{Principal: owner, Action: ActionManageMenus, Restaurant: restaurant("owner1")},
+			decision: Decision{Allow: true, Policy: "owner"},
+		},
+		{
+			name:     "user manages the menus",
+			request:  Request{Principal: user, Action: ActionManageMenus, Restaurant: restaurant("owner1")},
+			decision: Decision{Policy: "owner", Reason: ReasonNotOwner},
+		},
+		{
+			name:     "anonymous manages the menus",
+			request:  Request{Principal: anonymous, Action: ActionManageMenus, Restaurant: restaurant("owner1")},
+			decision: Decision{Policy: "authenticated", Reason: ReasonAuthenticationRequired},
+		},
+		{
+			name:     "user review",
+			request:  Request{Principal: user, Action: ActionCreateReview, Restaurant: restaurant("owner1")},
+			decision: Decision{Allow: true, Policy: "review"},
+		},
+		{
+			name:     "anonymous review",
+			request:  Request{Principal: anonymous, Action: ActionCreateReview, Restaurant: restaurant("owner1")},
+			decision: Decision{Policy: "authenticated", Reason: ReasonAuthenticationRequired},
+		},
+		{
+			name:     "owner deletes a review",
+			request:  Request{Principal: owner, Action: ActionDeleteReview, Restaurant: restaurant("owner1")},
+			decision: Decision{Reason: ReasonAdminRequired},
+		},
+		{
+			name:     "admin deletes a review",
+			request:  Request{Principal: admin, Action: ActionDeleteReview, Restaurant: restaurant("owner1")},
+			decision: Decision{Allow: true, Policy: "admin"},
+		},
+		{
+			name:     "admin manages the webhooks",
+			request:  Request{Principal: admin, Action: ActionManageWebhooks},
+			decision: Decision{Allow: true, Policy: "admin"},
+		},
+		{
+			name:     "user manages the webhooks",
+			request:  Request{Principal: user, Action: ActionManageWebhooks},
+			decision: Decision{Reason: ReasonAdminRequired},
+		},
+		{
+			name:     "anonymous manages the webhooks",
+			request:  Request{Principal: anonymous, Action: ActionManageWebhooks},
+			decision: Decision{Policy: "authenticated", Reason: ReasonAuthenticationRequired},
+		},
+		{
+			name:     "admin manages the API keys",
+			request:  Request{Principal: admin, Action: ActionManageApiKeys},
+			decision: Decision{Allow: true, Policy: "admin"},
+		},
+		{
+			name:     "user manages the API keys",
+			request:  Request{Principal: user, Action: ActionManageApiKeys},
+			decision: Decision{Reason: ReasonAdminRequired},
+		},
+		{
+			name:     "anonymous manages the API keys",
+			request:  Request{Principal: anonymous, Action: ActionManageApiKeys},
+			decision: Decision{Policy: "authenticated", Reason: ReasonAuthenticationRequired},
+		},
+		{
+			name:     "API key create",
+			request:  Request{Principal: apiKey, Action: ActionCreate},
+			decision: Decision{Reason: ReasonAdminRequired},
+		},
+		{
+			name:     "API key delete",
+			request:  Request{Principal: apiKey, Action: ActionDelete},
+			decision: Decision{Reason: ReasonAdminRequired},
+		},
+		{
+			name:     "API key review",
+			request:  Request{Principal: apiKey, Action: ActionCreateReview},
+			decision: Decision{Allow: true, Policy: "review"},
+		},
+		{
+			name:     "owner API key update",
+			request:  Request{Principal: apiKey, Action: ActionUpdate, Restaurant: restaurant("apikey:key1"), Changed: restaurant("apikey:key1")},
+			decision: Decision{Allow: true, Policy: "owner"},
+		},
+		{
+			name:     "owner API key changes the owners",
+			request:  Request{Principal: apiKey, Action: ActionUpdate, Restaurant: restaurant("apikey:key1"), Changed: restaurant("apikey:key1", "user1")},
+			decision: Decision{Policy: "owner", Reason: ReasonOwnersChangeForbidden},
+		},
+		{
+			name:     "API key update",
+			request:  Request{Principal: apiKey, Action: ActionUpdate, Restaurant: restaurant("owner1"), Changed: restaurant("owner1")},
+			decision: Decision{Policy: "owner", Reason: ReasonNotOwner},
+		},
+		{
+			name:     "API key manages the API keys",
+			request:  Request{Principal: apiKey, Action: ActionManageApiKeys},
+			decision: Decision{Policy: "bearer token", Reason: ReasonBearerTokenRequired},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.decision, Default.Evaluate(tc.request))
+		})
+	}
+}
+
+func Test_Engine(t *testing.T) {
+	t.Parallel()
+
+	deny := Policy{Name: "deny", Evaluate: func(r Request) (Decision, bool) {
+		return Decision{Reason: "CUSTOM"}, r.Action == ActionDelete
+	}}
+	e := Engine{Policies: []Policy{deny, {Name: "allow", Evaluate: func(Request) (Decision, bool) { return Decision{Allow: true}, true }}}}
+
+	assert.Equal(t, Decision{Policy: "deny", Reason: "CUSTOM"}, e.Evaluate(Request{Action: ActionDelete}))
+	assert.Equal(t, Decision{Allow: true, Policy: "allow"}, e.Evaluate(Request{Action: ActionRead}))
+	assert.Equal(t, Decision{Reason: ReasonAdminRequired}, Engine{}.Evaluate(Request{Action: ActionRead}))
+}
@@ -1,11 +1,108 @@
 package print
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
 	"log"
+	"strings"
+)
+
+// Redacted replaces the credentials and the secrets in the logs.
+const Redacted = "REDACTED"
+
+var (
+	// redactedHeaders are the headers with credentials, by lower case name: a logged bearer
+	// token could be replayed until it expires
+	redactedHeaders = map[string]bool{"authorization": true, "x-api-key": true}
+	// redactedFields are the fields of the JSON bodies with secrets: the key of an API key and
+	// the secret of a webhook
+	redactedFields = map[string]bool{"key": true, "secret": true}
 )
 
 func Json(label string, data any) {
 	str, _ := json.Marshal(data)
 	log.Printf("%s: %s\n", label, str)
 }
+
+// Request logs the request without its credentials and secrets.
+func Request(request events.APIGatewayProxyRequest) {
+	request.Headers = redactHeaders(request.Headers)
+	request.MultiValueHeaders = redactMultiValueHeaders(request.MultiValueHeaders)
+	request.Body = redactBody(request.Body, request.IsBase64Encoded)
+	Json("Request", request)
+}
+
+// Response logs the response without its secrets, such as the key of an API key.
+func Response(response *events.APIGatewayProxyResponse) {
+	if response == nil {
+		Json("Response", response)
+		return
+	}
+	redacted := *response
+	redacted.Body = redactBody(response.Body, response.IsBase64Encoded)
+	Json("Response", redacted)
+}
+
+func redactHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(headers))
+	for name, value := range headers {
+		if redactedHeaders[strings.ToLower(name)] {
+			value = Redacted
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
+
+func redactMultiValueHeaders(headers map[string][]string) map[string][]string {
+	if headers == nil {
+		return nil
+	}
+	redacted := make(map[string][]string, len(headers))
+	for name, values := range headers {
+		if redactedHeaders[strings.ToLower(name)] {
+			values = []string{Redacted}
+		}
+		redacted[name] = values
+	}
+	return redacted
+}
+
+// redactBody returns the body with the secret fields of its JSON object redacted. A body that
+// is not a JSON object is returned as it is.
+func redactBody(body string, base64Encoded bool) string {
+	data := []byte(body)
+	if base64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return body
+		}
+		data = decoded
+	}
+
+	object := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return body
+	}
+
+	redacted := false
+	for name := range object {
+		if redactedFields[name] {
+			object[name] = json.RawMessage(`"` + Redacted + `"`)
+			redacted = true
+		}
+	}
+	if !redacted {
+		return body
+	}
+
+	data, _ = json.Marshal(object)
+	if base64Encoded {
+		return base64.StdEncoding.EncodeToString(data)
+	}
+	return string(data)
+}
@@ -0,0 +1,98 @@
+package print
+
+import (
+	"bytes"
+	"encoding/base64"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"testing"
+)
+
+func Test_Request(t *testing.T) {
+	buf := captureLog(t)
+
+	Request(events.APIGatewayProxyRequest{
+		HTTPMethod:        "GET",
+		Path:              "/restaurants",
+		Headers:           map[string]string{"x-api-key": "rk_key1_secret", "Authorization": "Bearer eyJ.token", "Accept": "application/json"},
+		MultiValueHeaders: map[string][]string{"X-Api-Key": {"rk_key1_secret"}, "authorization": {"Bearer eyJ.token"}},
+	})
+
+	assert.NotContains(t, buf.String(), "rk_key1_secret")
+	assert.NotContains(t, buf.String(), "eyJ.token")
+	assert.Contains(t, buf.String(), `"Authorization":"REDACTED"`)
+	assert.Contains(t, buf.String(), `"x-api-key":"REDACTED"`)
+	assert.Contains(t, buf.String(), `"Accept":"application/json"`)
+	assert.Contains(t, buf.String(), `"path":"/restaurants"`)
+}
+
+func Test_Response(t *testing.T) {
+	buf := captureLog(t)
+
+	Response(&events.APIGatewayProxyResponse{StatusCode: 201, Body: `{"id":"key1","key":"rk_key1_secret"}`})
+
+	assert.NotContains(t, buf.String(), "rk_key1_secret")
+	assert.Contains(t, buf.String(), `\"id\":\"key1\",\"key\":\"REDACTED\"`)
+}
+
+func Test_redactBody(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		body          string
+		base64Encoded bool
+		expected      string
+	}{
+		{
+			name:     "key",
+			body:     `{"id":"key1","key":"rk_key1_secret"}`,
+			expected: `{"id":"key1","key":"REDACTED"}`,
+		},
+		{
+			name:     "webhook secret",
+			body:     `{"url":"https://example.com/hook","secret":"whsec_secret"}`,
+			expected: `{"secret":"REDACTED","url":"https://example.com/hook"}`,
+		},
+		{
+			name:          "base64 encoded key",
+			body:          base64.StdEncoding.EncodeToString([]byte(`{"key":"rk_key1_secret"}`)),
+			base64Encoded: true,
+			expected:      base64.StdEncoding.EncodeToString([]byte(`{"key":"REDACTED"}`)),
+		},
+		{
+			name:     "no secret",
+			body:     `{"name": "Restaurant 1"}`,
+			expected: `{"name": "Restaurant 1"}`,
+		},
+		{
+			name:     "not an object",
+			body:     `[{"op":"replace","path":"/key","value":"x"}]`,
+			expected: `[{"op":"replace","path":"/key","value":"x"}]`,
+		},
+		{
+			name:     "not JSON",
+			body:     `key`,
+			expected: `key`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, redactBody(tc.body, tc.base64Encoded))
+		})
+	}
+}
+
+// captureLog returns the buffer the log writes to until the end of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	writer := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(writer) })
+	return &buf
+}
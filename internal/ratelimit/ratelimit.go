@@ -0,0 +1,117 @@
+// Package ratelimit limits the rate of the requests of each client with token buckets. A
+// bucket holds up to Burst tokens and refills at Burst tokens per Per, and every request
+// takes a token from it. A request that finds the bucket empty is rejected.
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"math"
+	"time"
+)
+
+// maxAttempts is the number of times Take reads and saves the bucket before it gives up,
+// when the concurrent requests of the client keep changing it
+const maxAttempts = 5
+
+// ErrContended is returned when the bucket kept changing while a token was taken.
+var ErrContended = errors.New("the rate limit bucket is contended")
+
+// Limit is the rate of a bucket: Burst requests at once, and Burst requests per Per after that.
+type Limit struct {
+	Burst int32
+	Per   time.Duration
+}
+
+// rate returns the tokens added to the bucket per second.
+func (l Limit) rate() float64 {
+	return float64(l.Burst) / l.Per.Seconds()
+}
+
+// Bucket is a token bucket as it is stored.
+type Bucket struct {
+	Tokens float64
+	// Updated is when Tokens was computed
+	Updated time.Time
+	// Version is incremented by every save, so that the concurrent saves are detected
+	Version int64
+}
+
+type Store interface {
+	GetBucket(key string) (Bucket, bool, error)
+	// SaveBucket stores the bucket if the stored one has the previous version, or if there is
+	// none and the bucket has version 1. Otherwise it returns storage.ErrPreconditionFailed.
+	// The bucket can be deleted after expires, when it is full again.
+	SaveBucket(key string, b Bucket, expires time.Time) error
+}
+
+// Result is the state of the bucket after a request.
+type Result struct {
+	Allowed bool
+	Limit   int32
+	// Remaining is the number of requests that can be made right away
+	Remaining int32
+	// Reset is how long the bucket takes to be full again
+	Reset time.Duration
+	// RetryAfter is how long a rejected request must wait for a token
+	RetryAfter time.Duration
+}
+
+// Take takes a token from the bucket of key at now. The bucket is read and saved back
+// conditionally, again when a concurrent request saved it in between, so that two requests
+// never take the same token. A bucket that does not exist is full.
+func Take(store Store, key string, limit Limit, now time.Time) (Result, error) {
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		stored, exists, err := store.GetBucket(key)
+		if err != nil {
+			return Result{}, err
+		}
+		if !exists {
+			stored = Bucket{Tokens: float64(limit.Burst), Updated: now}
+		}
+
+		b, result := take(stored, limit, now)
+		if !result.Allowed {
+			// Nothing changed, so there is nothing to save
+			return result, nil
+		}
+
+		err = store.SaveBucket(key, b, now.Add(result.Reset))
+		if errors.Is(err, storage.ErrPreconditionFailed) {
+			continue
+		}
+		if err != nil {
+			return Result{}, err
+		}
+		return result, nil
+	}
+	return Result{}, fmt.Errorf("error taking a token of %q: %w", key, ErrContended)
+}
+
+// take refills the bucket for the time since it was updated, and takes a token if it has one.
+func take(b Bucket, limit Limit, now time.Time) (Bucket, Result) {
+	elapsed := now.Sub(b.Updated).Seconds()
+	if elapsed < 0 {
+		// The clocks of the Lambdas are not exactly in sync
+		elapsed = 0
+	}
+	tokens := math.Min(float64(limit.Burst), b.Tokens+elapsed*limit.rate())
+
+	result := Result{Limit: limit.Burst}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+		b = Bucket{Tokens: tokens, Updated: now, Version: b.Version + 1}
+	} else {
+		result.RetryAfter = seconds((1 - tokens) / limit.rate())
+	}
+
+	result.Remaining = int32(tokens)
+	result.Reset = seconds((float64(limit.Burst) - tokens) / limit.rate())
+	return b, result
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"errors"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_Take(t *testing.T) {
+	t.Parallel()
+
+	limit := Limit{Burst: 10, Per: time.Minute}
+	now := time.Date(2024, 3, 9, 18, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name   string
+		stored *Bucket
+		result Result
+		saved  *Bucket
+	}{
+		{
+			name:   "new bucket",
+			result: Result{Allowed: true, Limit: 10, Remaining: 9, Reset: 6 * time.Second},
+			saved:  &Bucket{Tokens: 9, Updated: now, Version: 1},
+		},
+		{
+			name:   "last token",
+			stored: &Bucket{Tokens: 1, Updated: now, Version: 3},
+			result: Result{Allowed: true, Limit: 10, Remaining: 0, Reset: time.Minute},
+			saved:  &Bucket{Tokens: 0, Updated: now, Version: 4},
+		},
+		{
+			name:   "empty bucket",
+			stored: &Bucket{Tokens: 0.5, Updated: now.Add(-time.Second), Version: 3},
+			result: Result{Limit: 10, Remaining: 0, Reset: 56 * time.Second, RetryAfter: 2 * time.Second},
+		},
+		{
+			name:   "refilled bucket",
+			stored: &Bucket{Tokens: 0, Updated: now.Add(-12 * time.Second), Version: 3},
+			result: Result{Allowed: true, Limit: 10, Remaining: 1, Reset: 54 * time.Second},
+			saved:  &Bucket{Tokens: 1, Updated: now, Version: 4},
+		},
+		{
+			name:   "full bucket",
+			stored: &Bucket{Tokens: 2, Updated: now.Add(-time.Hour), Version: 3},
+			result: Result{Allowed: true, Limit: 10, Remaining: 9, Reset: 6 * time.Second},
+			saved:  &Bucket{Tokens: 9, Updated: now, Version: 4},
+		},
+		{
+			name:   "bucket updated later",
+			stored: &Bucket{Tokens: 2, Updated: now.Add(time.Second), Version: 3},
+			result: Result{Allowed: true, Limit: 10, Remaining: 1, Reset: 54 * time.Second},
+			saved:  &Bucket{Tokens: 1, Updated: now, Version: 4},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := &storeStub{bucket: tc.stored}
+
+			result, err := Take(store, "client1", limit, now)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.result, result)
+			assert.Equal(t, tc.saved, store.saved)
+			if tc.saved != nil {
+				assert.Equal(t, now.Add(tc.result.Reset), store.expires)
+			}
+		})
+	}
+}
+
+func Test_TakeConcurrentSave(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 3, 9, 18, 0, 0, 0, time.UTC)
+
+	// Another request saves the bucket between the read and the save of the first attempt
+	store := &storeStub{bucket: &Bucket{Tokens: 5, Updated: now, Version: 3}, conflicts: 1}
+	result, err := Take(store, "client1", Limit{Burst: 10, Per: time.Minute}, now)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 2, store.gets)
+
+	store = &storeStub{bucket: &Bucket{Tokens: 5, Updated: now, Version: 3}, conflicts: maxAttempts}
+	_, err = Take(store, "client1", Limit{Burst: 10, Per: time.Minute}, now)
+	assert.ErrorIs(t, err, ErrContended)
+
+	store = &storeStub{error: "storage error"}
+	_, err = Take(store, "client1", Limit{Burst: 10, Per: time.Minute}, now)
+	assert.EqualError(t, err, "storage error")
+}
+
+// storeStub stores one bucket. Its saves fail with storage.ErrPreconditionFailed conflicts times.
+type storeStub struct {
+	bucket    *Bucket
+	saved     *Bucket
+	expires   time.Time
+	conflicts int
+	gets      int
+	error     string
+}
+
+func (s *storeStub) GetBucket(string) (Bucket, bool, error) {
+	s.gets++
+	if s.error != "" {
+		return Bucket{}, false, errors.New(s.error)
+	}
+	if s.bucket == nil {
+		return Bucket{}, false, nil
+	}
+	return *s.bucket, true, nil
+}
+
+func (s *storeStub) SaveBucket(_ string, b Bucket, expires time.Time) error {
+	if s.conflicts > 0 {
+		s.conflicts--
+		return storage.ErrPreconditionFailed
+	}
+	s.saved, s.expires = &b, expires
+	return nil
+}
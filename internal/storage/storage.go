@@ -0,0 +1,37 @@
+// Package storage holds the errors and types shared by the restaurant storage implementations.
+package storage
+
+import "errors"
+
+var (
+	// ErrInvalidNextToken is returned by List when the continuation token cannot be decoded.
+	ErrInvalidNextToken = errors.New("invalid next token")
+
+	// ErrPreconditionFailed is returned when a write is conditional on a
+	// version of the restaurant that is not the stored version.
+	ErrPreconditionFailed = errors.New("precondition failed")
+
+	// ErrNotFound is returned when the restaurant to update or delete does not exist.
+	ErrNotFound = errors.New("restaurant not found")
+
+	// ErrConflict is returned when saving a restaurant whose id already exists.
+	ErrConflict = errors.New("restaurant already exists")
+
+	// ErrRadiusTooLarge is returned by Nearby when the search radius is larger
+	// than the storage can search.
+	ErrRadiusTooLarge = errors.New("radius is too large")
+
+	// ErrLimitExceeded is returned when a counter, such as the requests of an API key
+	// in its quota period, has reached its limit.
+	ErrLimitExceeded = errors.New("limit exceeded")
+)
+
+// ReviewOrder is the order in which ListReviews returns the reviews.
+type ReviewOrder string
+
+const (
+	// ReviewsNewest lists the newest reviews first.
+	ReviewsNewest ReviewOrder = "newest"
+	// ReviewsHighest lists the highest rated reviews first, the newest first within a rating.
+	ReviewsHighest ReviewOrder = "highest"
+)
@@ -0,0 +1,179 @@
+package storagetest
+
+import (
+	"github.com/lfroomin/restaurant-serverless/internal/apikey"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ApiKeyStorer has the methods of controllers.ApiKeyStorer and apikey.Store.
+type ApiKeyStorer interface {
+	apikey.Store
+	SaveApiKey(k apikey.Key) error
+	ListApiKeys(tenantId string) ([]apikey.Key, error)
+	RotateApiKey(apiKeyId, hash string, rotated time.Time) (apikey.Key, error)
+	RevokeApiKey(apiKeyId string, revoked time.Time) (apikey.Key, error)
+}
+
+// RunApiKeys runs the API key conformance tests. newStorer must return an empty storage every time it is called.
+func RunApiKeys(t *testing.T, newStorer func() ApiKeyStorer) {
+	t.Run("save, get and list API keys", func(t *testing.T) { testApiKeys(t, newStorer()) })
+	t.Run("rotate and revoke API keys", func(t *testing.T) { testRotateRevokeApiKeys(t, newStorer()) })
+	t.Run("API key usage", func(t *testing.T) { testApiKeyUsage(t, newStorer()) })
+	t.Run("concurrent API key usage", func(t *testing.T) { testConcurrentApiKeyUsage(t, newStorer()) })
+}
+
+func anApiKey(id, tenant string) apikey.Key {
+	created := time.Date(2024, 3, 8, 18, 0, 0, 0, time.UTC)
+	return apikey.Key{
+		ApiKey: model.ApiKey{
+			Id:       &id,
+			Name:     "partner " + id,
+			Scope:    model.Read,
+			Quota:    model.ApiKeyQuota{Limit: 100, Period: model.Day},
+			TenantId: &tenant,
+			Created:  &created,
+		},
+		Hash: "hash of " + id,
+	}
+}
+
+func apiKeyIds(keys []apikey.Key) []string {
+	ids := []string{}
+	for _, k := range keys {
+		ids = append(ids, *k.Id)
+	}
+	return ids
+}
+
+func testApiKeys(t *testing.T, s ApiKeyStorer) {
+	require.NoError(t, s.SaveApiKey(anApiKey("b", tenantId)))
+	require.NoError(t, s.SaveApiKey(anApiKey("c", otherTenantId)))
+
+	// The key itself is never stored
+	a := anApiKey("a", tenantId)
+	key := "a.secret"
+	a.Key = &key
+	require.NoError(t, s.SaveApiKey(a))
+	assert.ErrorIs(t, s.SaveApiKey(anApiKey("a", tenantId)), storage.ErrConflict)
+
+	got, exists, err := s.GetApiKey("a")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	a.Key = nil
+	assert.Equal(t, a, got)
+
+	_, exists, err = s.GetApiKey("d")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	keys, err := s.ListApiKeys(tenantId)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, apiKeyIds(keys))
+	assert.Equal(t, "hash of a", keys[0].Hash)
+
+	keys, err = s.ListApiKeys("tenant3")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func testRotateRevokeApiKeys(t *testing.T, s ApiKeyStorer) {
+	require.NoError(t, s.SaveApiKey(anApiKey("a", tenantId)))
+	rotated := time.Date(2024, 3, 9, 18, 0, 0, 0, time.UTC)
+
+	k, err := s.RotateApiKey("a", "new hash", rotated)
+	require.NoError(t, err)
+	assert.Equal(t, "new hash", k.Hash)
+	assert.Equal(t, &rotated, k.Rotated)
+	assert.Equal(t, "partner a", k.Name)
+
+	got, _, err := s.GetApiKey("a")
+	require.NoError(t, err)
+	assert.Equal(t, k, got)
+
+	_, err = s.RotateApiKey("b", "new hash", rotated)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	revoked := time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC)
+	k, err = s.RevokeApiKey("a", revoked)
+	require.NoError(t, err)
+	assert.Empty(t, k.Hash)
+	assert.Equal(t, &revoked, k.Revoked)
+	assert.Equal(t, &rotated, k.Rotated)
+
+	got, _, err = s.GetApiKey("a")
+	require.NoError(t, err)
+	assert.Equal(t, k, got)
+
+	// A revoked API key stays revoked
+	_, err = s.RotateApiKey("a", "newer hash", revoked)
+	assert.ErrorIs(t, err, storage.ErrConflict)
+
+	got, _, err = s.GetApiKey("a")
+	require.NoError(t, err)
+	assert.Empty(t, got.Hash)
+
+	_, err = s.RevokeApiKey("b", revoked)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testApiKeyUsage(t *testing.T, s ApiKeyStorer) {
+	expires := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	for i := int32(1); i <= 3; i++ {
+		count, err := s.UseApiKey("a", "2024-03-09", 3, expires)
+		require.NoError(t, err)
+		assert.Equal(t, i, count)
+	}
+
+	_, err := s.UseApiKey("a", "2024-03-09", 3, expires)
+	assert.ErrorIs(t, err, storage.ErrLimitExceeded)
+
+	// A higher limit allows more requests
+	count, err := s.UseApiKey("a", "2024-03-09", 4, expires)
+	require.NoError(t, err)
+	assert.Equal(t, int32(4), count)
+
+	// The periods and the API keys are counted separately
+	count, err = s.UseApiKey("a", "2024-03-10", 3, expires)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), count)
+
+	count, err = s.UseApiKey("b", "2024-03-09", 3, expires)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), count)
+}
+
+// testConcurrentApiKeyUsage checks that concurrent requests never exceed the limit.
+func testConcurrentApiKeyUsage(t *testing.T, s ApiKeyStorer) {
+	const requests, limit = 20, 10
+	expires := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	var wg sync.WaitGroup
+	counts := make(chan int32, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if count, err := s.UseApiKey("a", "2024-03-09", limit, expires); err == nil {
+				counts <- count
+			} else {
+				assert.ErrorIs(t, err, storage.ErrLimitExceeded)
+			}
+		}()
+	}
+	wg.Wait()
+	close(counts)
+
+	seen := map[int32]bool{}
+	for count := range counts {
+		assert.False(t, seen[count], "count %d returned twice", count)
+		seen[count] = true
+	}
+	assert.Len(t, seen, limit)
+}
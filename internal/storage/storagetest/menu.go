@@ -0,0 +1,133 @@
+package storagetest
+
+import (
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// MenuStorer has the same methods as controllers.MenuStorer.
+type MenuStorer interface {
+	SaveMenu(tenantId, restaurantId string, menu model.Menu) error
+	GetMenu(tenantId, restaurantId, menuId string) (model.Menu, bool, error)
+	UpdateMenu(tenantId, restaurantId string, menu model.Menu) error
+	DeleteMenu(tenantId, restaurantId, menuId string) (model.Menu, error)
+	ListMenus(tenantId, restaurantId string) ([]model.Menu, error)
+}
+
+// RestaurantMenuStorer stores the restaurants and their menus.
+type RestaurantMenuStorer interface {
+	RestaurantStorer
+	MenuStorer
+}
+
+// RunMenus runs the menu conformance tests. newStorer must return an empty storage every time it is called.
+func RunMenus(t *testing.T, newStorer func() RestaurantMenuStorer) {
+	t.Run("save and get menu", func(t *testing.T) { testSaveGetMenu(t, newStorer()) })
+	t.Run("update menu", func(t *testing.T) { testUpdateMenu(t, newStorer()) })
+	t.Run("delete menu", func(t *testing.T) { testDeleteMenu(t, newStorer()) })
+	t.Run("list menus", func(t *testing.T) { testListMenus(t, newStorer()) })
+	t.Run("delete restaurant deletes menus", func(t *testing.T) { testDeleteRestaurantMenus(t, newStorer()) })
+	t.Run("menus of other tenants", func(t *testing.T) { testMenusOfOtherTenants(t, newStorer()) })
+}
+
+func menu(id, name string) model.Menu {
+	return model.Menu{Id: &id, Name: name}
+}
+
+func testSaveGetMenu(t *testing.T, s RestaurantMenuStorer) {
+	require.NoError(t, s.Save(tenantId, restaurant("restId", "name")))
+
+	items := []model.MenuItem{{Name: "Soup", Price: model.Price{Amount: "6.50", Currency: "USD"}}}
+	m := menu("menuId", "Lunch")
+	m.Sections = &[]model.MenuSection{{Name: "Starters", Items: &items}}
+	require.NoError(t, s.SaveMenu(tenantId, "restId", m))
+
+	err := s.SaveMenu(tenantId, "restId", menu("menuId", "Dinner"))
+	assert.ErrorIs(t, err, storage.ErrConflict)
+
+	got, exists, err := s.GetMenu(tenantId, "restId", "menuId")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, m, got)
+
+	// The menu is stored with the restaurant, not instead of it
+	r, _, exists, err := s.Get(tenantId, "restId")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, restaurant("restId", "name"), r)
+
+	_, exists, err = s.GetMenu(tenantId, "otherRestId", "menuId")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func testUpdateMenu(t *testing.T, s RestaurantMenuStorer) {
+	require.NoError(t, s.Save(tenantId, restaurant("restId", "name")))
+
+	err := s.UpdateMenu(tenantId, "restId", menu("menuId", "Lunch"))
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	require.NoError(t, s.SaveMenu(tenantId, "restId", menu("menuId", "Lunch")))
+	require.NoError(t, s.UpdateMenu(tenantId, "restId", menu("menuId", "Brunch")))
+
+	got, _, err := s.GetMenu(tenantId, "restId", "menuId")
+	require.NoError(t, err)
+	assert.Equal(t, menu("menuId", "Brunch"), got)
+}
+
+func testDeleteMenu(t *testing.T, s RestaurantMenuStorer) {
+	require.NoError(t, s.Save(tenantId, restaurant("restId", "name")))
+	require.NoError(t, s.SaveMenu(tenantId, "restId", menu("menuId", "Lunch")))
+
+	deleted, err := s.DeleteMenu(tenantId, "restId", "menuId")
+	require.NoError(t, err)
+	assert.Equal(t, menu("menuId", "Lunch"), deleted)
+
+	_, exists, err := s.GetMenu(tenantId, "restId", "menuId")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	_, err = s.DeleteMenu(tenantId, "restId", "menuId")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	// Deleting a menu does not delete the restaurant
+	_, _, exists, err = s.Get(tenantId, "restId")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func testListMenus(t *testing.T, s RestaurantMenuStorer) {
+	require.NoError(t, s.Save(tenantId, restaurant("restId", "name")))
+	require.NoError(t, s.Save(tenantId, restaurant("otherRestId", "other")))
+	require.NoError(t, s.SaveMenu(tenantId, "restId", menu("b", "Dinner")))
+	require.NoError(t, s.SaveMenu(tenantId, "restId", menu("a", "Lunch")))
+	require.NoError(t, s.SaveMenu(tenantId, "otherRestId", menu("c", "Other")))
+
+	menus, err := s.ListMenus(tenantId, "restId")
+	require.NoError(t, err)
+	assert.Equal(t, []model.Menu{menu("a", "Lunch"), menu("b", "Dinner")}, menus)
+
+	menus, err = s.ListMenus(tenantId, "noMenus")
+	require.NoError(t, err)
+	assert.Empty(t, menus)
+
+	// The menus are not listed as restaurants
+	restaurants, _, err := s.List(tenantId, 10, "")
+	require.NoError(t, err)
+	assert.Len(t, restaurants, 2)
+}
+
+func testDeleteRestaurantMenus(t *testing.T, s RestaurantMenuStorer) {
+	require.NoError(t, s.Save(tenantId, restaurant("restId", "name")))
+	require.NoError(t, s.SaveMenu(tenantId, "restId", menu("menuId", "Lunch")))
+
+	_, err := s.Delete(tenantId, "restId", nil)
+	require.NoError(t, err)
+
+	menus, err := s.ListMenus(tenantId, "restId")
+	require.NoError(t, err)
+	assert.Empty(t, menus)
+}
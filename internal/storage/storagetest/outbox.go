@@ -0,0 +1,164 @@
+package storagetest
+
+import (
+	"github.com/lfroomin/restaurant-serverless/internal/event"
+	"github.com/lfroomin/restaurant-serverless/internal/outbox"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// RestaurantOutboxStorer stores the restaurants and the events of their changes.
+type RestaurantOutboxStorer interface {
+	RestaurantStorer
+	outbox.Store
+}
+
+// RunOutbox runs the outbox conformance tests. newStorer must return an empty storage every time it is called.
+func RunOutbox(t *testing.T, newStorer func() RestaurantOutboxStorer) {
+	t.Run("changes record events", func(t *testing.T) { testOutboxEvents(t, newStorer()) })
+	t.Run("failed changes record no events", func(t *testing.T) { testOutboxFailedChanges(t, newStorer()) })
+	t.Run("claim, retry and mark delivered", func(t *testing.T) { testOutboxDelivery(t, newStorer()) })
+	t.Run("due order and limit", func(t *testing.T) { testOutboxDue(t, newStorer()) })
+}
+
+// due returns the records due at now, keyed by the version of their event.
+func due(t *testing.T, s RestaurantOutboxStorer, now time.Time) map[int64]outbox.Record {
+	records, err := s.Due(now, 100)
+	require.NoError(t, err)
+
+	byVersion := map[int64]outbox.Record{}
+	for _, r := range records {
+		byVersion[r.Event.Version] = r
+	}
+	require.Len(t, byVersion, len(records))
+	return byVersion
+}
+
+func testOutboxEvents(t *testing.T, s RestaurantOutboxStorer) {
+	require.NoError(t, s.Save(tenantId, restaurant("restId", "name")))
+
+	description := "tacos"
+	updated := restaurant("restId", "name")
+	updated.Description = &description
+	_, err := s.Update(tenantId, updated, nil)
+	require.NoError(t, err)
+
+	patched := updated
+	patched.Name = "patched"
+	_, err = s.Patch(tenantId, updated, patched, version(2))
+	require.NoError(t, err)
+
+	_, err = s.Delete(tenantId, "restId", nil)
+	require.NoError(t, err)
+
+	// The events of a deleted restaurant are delivered too
+	records := due(t, s, time.Now())
+	require.Len(t, records, 4)
+
+	created := records[1].Event
+	assert.Equal(t, event.RestaurantCreated, created.Type)
+	assert.Equal(t, "restId", created.RestaurantId)
+	assert.Nil(t, created.Before)
+	assert.Equal(t, "name", created.After.Name)
+	assert.Equal(t, []string{"id", "name"}, created.ChangedFields)
+
+	update := records[2].Event
+	assert.Equal(t, event.RestaurantUpdated, update.Type)
+	assert.Equal(t, "name", update.Before.Name)
+	assert.Equal(t, &description, update.After.Description)
+	assert.Equal(t, []string{"description"}, update.ChangedFields)
+
+	patch := records[3].Event
+	assert.Equal(t, event.RestaurantUpdated, patch.Type)
+	assert.Equal(t, &description, patch.Before.Description)
+	assert.Equal(t, "patched", patch.After.Name)
+	assert.Equal(t, []string{"name"}, patch.ChangedFields)
+
+	deleted := records[0].Event
+	assert.Equal(t, event.RestaurantDeleted, deleted.Type)
+	assert.Equal(t, "patched", deleted.Before.Name)
+	assert.Nil(t, deleted.After)
+
+	for _, r := range records {
+		assert.Zero(t, r.Attempts)
+		assert.NotEmpty(t, r.Event.Id)
+		assert.Equal(t, tenantId, r.Event.TenantId)
+	}
+}
+
+func testOutboxFailedChanges(t *testing.T, s RestaurantOutboxStorer) {
+	require.NoError(t, s.Save(tenantId, restaurant("restId", "name")))
+
+	err := s.Save(tenantId, restaurant("restId", "other"))
+	assert.ErrorIs(t, err, storage.ErrConflict)
+	_, err = s.Update(tenantId, restaurant("restId", "other"), version(5))
+	assert.ErrorIs(t, err, storage.ErrPreconditionFailed)
+	_, err = s.Update(tenantId, restaurant("otherRestId", "other"), nil)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = s.Delete(tenantId, "otherRestId", nil)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	records := due(t, s, time.Now())
+	require.Len(t, records, 1)
+	assert.Equal(t, event.RestaurantCreated, records[1].Event.Type)
+}
+
+func testOutboxDelivery(t *testing.T, s RestaurantOutboxStorer) {
+	require.NoError(t, s.Save(tenantId, restaurant("restId", "name")))
+	now := time.Now()
+
+	assert.Empty(t, due(t, s, now.Add(-time.Hour)))
+	record := due(t, s, now)[1]
+
+	// Only one relay can claim the record, which is not due while it is claimed
+	claimed, err := s.Claim(record, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = s.Claim(record, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.False(t, claimed)
+	assert.Empty(t, due(t, s, now))
+
+	// A claim that expires makes the record due again
+	record = due(t, s, now.Add(2*time.Minute))[1]
+	assert.Equal(t, now.Add(time.Minute).UnixMilli(), record.NextAttempt.UnixMilli())
+
+	require.NoError(t, s.Retry(record, now.Add(5*time.Minute), "an error occurred"))
+	assert.Empty(t, due(t, s, now.Add(2*time.Minute)))
+	record = due(t, s, now.Add(6*time.Minute))[1]
+	assert.Equal(t, 1, record.Attempts)
+	assert.Equal(t, "an error occurred", record.LastError)
+
+	require.NoError(t, s.MarkDelivered(record))
+	assert.Empty(t, due(t, s, now.Add(24*time.Hour)))
+}
+
+func testOutboxDue(t *testing.T, s RestaurantOutboxStorer) {
+	require.NoError(t, s.Save(tenantId, restaurant("a", "name")))
+	require.NoError(t, s.Save(tenantId, restaurant("b", "name")))
+	require.NoError(t, s.Save(tenantId, restaurant("c", "name")))
+	now := time.Now()
+
+	// The record of a is retried after the others
+	records, err := s.Due(now, 10)
+	require.NoError(t, err)
+	for _, r := range records {
+		if r.Event.RestaurantId == "a" {
+			require.NoError(t, s.Retry(r, now.Add(time.Minute), "an error occurred"))
+		}
+	}
+
+	records, err = s.Due(now.Add(time.Hour), 2)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.NotEqual(t, "a", records[0].Event.RestaurantId)
+	assert.NotEqual(t, "a", records[1].Event.RestaurantId)
+
+	records, err = s.Due(now.Add(time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, "a", records[2].Event.RestaurantId)
+}
@@ -0,0 +1,90 @@
+package storagetest
+
+import (
+	"github.com/lfroomin/restaurant-serverless/internal/ratelimit"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+// RunRateLimits runs the rate limit bucket conformance tests. newStore must return an empty storage every time it is called.
+func RunRateLimits(t *testing.T, newStore func() ratelimit.Store) {
+	t.Run("save and get buckets", func(t *testing.T) { testBuckets(t, newStore()) })
+	t.Run("concurrent token takes", func(t *testing.T) { testConcurrentTakes(t, newStore()) })
+}
+
+func testBuckets(t *testing.T, s ratelimit.Store) {
+	updated := time.Date(2024, 3, 9, 18, 0, 0, 123456789, time.UTC)
+	expires := updated.Add(time.Minute)
+
+	_, exists, err := s.GetBucket("client1#POST /")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	first := ratelimit.Bucket{Tokens: 9.5, Updated: updated, Version: 1}
+	require.NoError(t, s.SaveBucket("client1#POST /", first, expires))
+	assert.ErrorIs(t, s.SaveBucket("client1#POST /", first, expires), storage.ErrPreconditionFailed)
+
+	got, exists, err := s.GetBucket("client1#POST /")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, first, got)
+
+	// A bucket is saved over the version it was read at only
+	third := ratelimit.Bucket{Tokens: 7.25, Updated: updated.Add(time.Second), Version: 3}
+	assert.ErrorIs(t, s.SaveBucket("client1#POST /", third, expires), storage.ErrPreconditionFailed)
+	second := ratelimit.Bucket{Tokens: 8.5, Updated: updated.Add(time.Second), Version: 2}
+	require.NoError(t, s.SaveBucket("client1#POST /", second, expires))
+
+	got, _, err = s.GetBucket("client1#POST /")
+	require.NoError(t, err)
+	assert.Equal(t, second, got)
+
+	// The buckets of the other keys are separate
+	_, exists, err = s.GetBucket("client2#POST /")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.ErrorIs(t, s.SaveBucket("client2#POST /", second, expires), storage.ErrPreconditionFailed)
+}
+
+// testConcurrentTakes checks that concurrent requests never take more tokens than the bucket has.
+func testConcurrentTakes(t *testing.T, s ratelimit.Store) {
+	const requests, burst = 20, 10
+	limit := ratelimit.Limit{Burst: burst, Per: time.Hour}
+	now := time.Date(2024, 3, 9, 18, 0, 0, 0, time.UTC)
+
+	var wg sync.WaitGroup
+	allowed := make(chan bool, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := ratelimit.Take(s, "client1#POST /", limit, now)
+			if err != nil {
+				// The requests that kept losing the race are rejected too
+				assert.ErrorIs(t, err, ratelimit.ErrContended)
+				return
+			}
+			allowed <- result.Allowed
+		}()
+	}
+	wg.Wait()
+	close(allowed)
+
+	taken := 0
+	for a := range allowed {
+		if a {
+			taken++
+		}
+	}
+	assert.LessOrEqual(t, taken, burst)
+	assert.Positive(t, taken)
+
+	b, _, err := s.GetBucket("client1#POST /")
+	require.NoError(t, err)
+	assert.Equal(t, int64(taken), b.Version)
+	assert.InDelta(t, float64(burst-taken), b.Tokens, 1e-9)
+}
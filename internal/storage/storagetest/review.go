@@ -0,0 +1,215 @@
+package storagetest
+
+import (
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// ReviewStorer has the same methods as controllers.ReviewStorer.
+type ReviewStorer interface {
+	SaveReview(tenantId, restaurantId string, review model.Review) error
+	GetReview(tenantId, restaurantId, reviewId string) (model.Review, bool, error)
+	DeleteReview(tenantId, restaurantId, reviewId string) (model.Review, error)
+	ListReviews(tenantId, restaurantId string, order storage.ReviewOrder, limit int32, nextToken string) ([]model.Review, string, error)
+}
+
+// RestaurantReviewStorer stores the restaurants and their reviews.
+type RestaurantReviewStorer interface {
+	RestaurantStorer
+	ReviewStorer
+}
+
+// RunReviews runs the review conformance tests. newStorer must return an empty storage every time it is called.
+func RunReviews(t *testing.T, newStorer func() RestaurantReviewStorer) {
+	t.Run("save and get review", func(t *testing.T) { testSaveGetReview(t, newStorer()) })
+	t.Run("rating summary", func(t *testing.T) { testRatingSummary(t, newStorer()) })
+	t.Run("update keeps rating summary", func(t *testing.T) { testUpdateKeepsRating(t, newStorer()) })
+	t.Run("list reviews", func(t *testing.T) { testListReviews(t, newStorer()) })
+	t.Run("list reviews invalid next token", func(t *testing.T) { testListReviewsInvalidNextToken(t, newStorer()) })
+	t.Run("delete restaurant deletes reviews", func(t *testing.T) { testDeleteRestaurantReviews(t, newStorer()) })
+	t.Run("reviews of other tenants", func(t *testing.T) { testReviewsOfOtherTenants(t, newStorer()) })
+}
+
+// review returns a review created minute minutes after a fixed time.
+func review(id string, rating, minute int) model.Review {
+	created := time.Date(2024, 3, 8, 18, minute, 0, 0, time.UTC)
+	text := "text of " + id
+	return model.Review{Id: &id, Rating: rating, Author: "author", Text: &text, Created: &created}
+}
+
+func reviewIds(reviews []model.Review) []string {
+	ids := []string{}
+	for _, r := range reviews {
+		ids = append(ids, *r.Id)
+	}
+	return ids
+}
+
+func testSaveGetReview(t *testing.T, s RestaurantReviewStorer) {
+	err := s.SaveReview(tenantId, "restId", review("a", 5, 0))
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	require.NoError(t, s.Save(tenantId, restaurant("restId", "name")))
+	require.NoError(t, s.SaveReview(tenantId, "restId", review("a", 5, 0)))
+
+	// The review is stored with the restaurant, and its rating does not change the version of the restaurant
+	r, v, exists, err := s.Get(tenantId, "restId")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, int64(1), v)
+	assert.Equal(t, "name", r.Name)
+
+	err = s.SaveReview(tenantId, "restId", review("a", 1, 1))
+	assert.ErrorIs(t, err, storage.ErrConflict)
+
+	got, exists, err := s.GetReview(tenantId, "restId", "a")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, review("a", 5, 0), got)
+
+	_, exists, err = s.GetReview(tenantId, "otherRestId", "a")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	_, err = s.DeleteReview(tenantId, "restId", "a")
+	require.NoError(t, err)
+	_, deletedVersion, _, err := s.Get(tenantId, "restId")
+	require.NoError(t, err)
+	assert.Equal(t, v, deletedVersion)
+}
+
+func testRatingSummary(t *testing.T, s RestaurantReviewStorer) {
+	require.NoError(t, s.Save(tenantId, restaurant("restId", "name")))
+
+	r, _, _, err := s.Get(tenantId, "restId")
+	require.NoError(t, err)
+	assert.Nil(t, r.Rating)
+
+	require.NoError(t, s.SaveReview(tenantId, "restId", review("a", 5, 0)))
+	require.NoError(t, s.SaveReview(tenantId, "restId", review("b", 4, 1)))
+	require.NoError(t, s.SaveReview(tenantId, "restId", review("c", 1, 2)))
+
+	r, _, _, err = s.Get(tenantId, "restId")
+	require.NoError(t, err)
+	assert.Equal(t, &model.RatingSummary{
+		Count:     3,
+		Average:   3.33,
+		Histogram: map[string]int{"1": 1, "2": 0, "3": 0, "4": 1, "5": 1},
+	}, r.Rating)
+
+	deleted, err := s.DeleteReview(tenantId, "restId", "c")
+	require.NoError(t, err)
+	assert.Equal(t, review("c", 1, 2), deleted)
+
+	_, err = s.DeleteReview(tenantId, "restId", "c")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	restaurants, _, err := s.List(tenantId, 10, "")
+	require.NoError(t, err)
+	require.Len(t, restaurants, 1)
+	assert.Equal(t, &model.RatingSummary{
+		Count:     2,
+		Average:   4.5,
+		Histogram: map[string]int{"1": 0, "2": 0, "3": 0, "4": 1, "5": 1},
+	}, restaurants[0].Rating)
+
+	_, err = s.DeleteReview(tenantId, "restId", "a")
+	require.NoError(t, err)
+	_, err = s.DeleteReview(tenantId, "restId", "b")
+	require.NoError(t, err)
+
+	r, _, _, err = s.Get(tenantId, "restId")
+	require.NoError(t, err)
+	assert.Nil(t, r.Rating)
+}
+
+func testUpdateKeepsRating(t *testing.T, s RestaurantReviewStorer) {
+	require.NoError(t, s.Save(tenantId, restaurant("restId", "name")))
+	require.NoError(t, s.SaveReview(tenantId, "restId", review("a", 4, 0)))
+
+	original, v, _, err := s.Get(tenantId, "restId")
+	require.NoError(t, err)
+
+	// A review does not fail an update of the version read before it
+	require.NoError(t, s.SaveReview(tenantId, "restId", review("b", 2, 1)))
+	_, err = s.DeleteReview(tenantId, "restId", "b")
+	require.NoError(t, err)
+
+	// The rating summary sent with the restaurant is ignored
+	updated := restaurant("restId", "updated")
+	updated.Rating = &model.RatingSummary{Count: 100, Average: 1}
+	v, err = s.Update(tenantId, updated, &v)
+	require.NoError(t, err)
+
+	patched := original
+	patched.Name = "patched"
+	patched.Rating = &model.RatingSummary{Count: 100, Average: 1}
+	_, err = s.Patch(tenantId, original, patched, &v)
+	require.NoError(t, err)
+
+	r, _, _, err := s.Get(tenantId, "restId")
+	require.NoError(t, err)
+	assert.Equal(t, "patched", r.Name)
+	assert.Equal(t, original.Rating, r.Rating)
+}
+
+func testListReviews(t *testing.T, s RestaurantReviewStorer) {
+	require.NoError(t, s.Save(tenantId, restaurant("restId", "name")))
+	require.NoError(t, s.Save(tenantId, restaurant("otherRestId", "other")))
+	require.NoError(t, s.SaveReview(tenantId, "restId", review("a", 3, 0)))
+	require.NoError(t, s.SaveReview(tenantId, "restId", review("b", 5, 1)))
+	require.NoError(t, s.SaveReview(tenantId, "restId", review("c", 3, 2)))
+	require.NoError(t, s.SaveReview(tenantId, "restId", review("d", 1, 3)))
+	require.NoError(t, s.SaveReview(tenantId, "restId", review("e", 5, 4)))
+	require.NoError(t, s.SaveReview(tenantId, "otherRestId", review("f", 5, 5)))
+
+	list := func(order storage.ReviewOrder) []string {
+		var ids []string
+		token := ""
+		for {
+			reviews, next, err := s.ListReviews(tenantId, "restId", order, 2, token)
+			require.NoError(t, err)
+			assert.LessOrEqual(t, len(reviews), 2)
+			ids = append(ids, reviewIds(reviews)...)
+			if next == "" {
+				return ids
+			}
+			token = next
+		}
+	}
+
+	assert.Equal(t, []string{"e", "d", "c", "b", "a"}, list(storage.ReviewsNewest))
+	assert.Equal(t, []string{"e", "b", "c", "a", "d"}, list(storage.ReviewsHighest))
+
+	reviews, token, err := s.ListReviews(tenantId, "noReviews", storage.ReviewsNewest, 10, "")
+	require.NoError(t, err)
+	assert.Empty(t, reviews)
+	assert.Empty(t, token)
+}
+
+func testListReviewsInvalidNextToken(t *testing.T, s RestaurantReviewStorer) {
+	_, _, err := s.ListReviews(tenantId, "restId", storage.ReviewsNewest, 10, "not a token!")
+	assert.ErrorIs(t, err, storage.ErrInvalidNextToken)
+}
+
+func testDeleteRestaurantReviews(t *testing.T, s RestaurantReviewStorer) {
+	require.NoError(t, s.Save(tenantId, restaurant("restId", "name")))
+	require.NoError(t, s.SaveReview(tenantId, "restId", review("a", 5, 0)))
+
+	_, err := s.Delete(tenantId, "restId", nil)
+	require.NoError(t, err)
+
+	_, exists, err := s.GetReview(tenantId, "restId", "a")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// A restaurant saved again with the same id starts without reviews
+	require.NoError(t, s.Save(tenantId, restaurant("restId", "name")))
+	r, _, _, err := s.Get(tenantId, "restId")
+	require.NoError(t, err)
+	assert.Nil(t, r.Rating)
+}
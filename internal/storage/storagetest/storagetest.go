@@ -0,0 +1,284 @@
+// Package storagetest is a conformance test suite that every restaurant storage implementation must pass.
+package storagetest
+
+import (
+	"fmt"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// RestaurantStorer has the same methods as controllers.RestaurantStorer.
+type RestaurantStorer interface {
+	Save(tenantId string, restaurant model.Restaurant) error
+	Get(tenantId, restaurantId string) (model.Restaurant, int64, bool, error)
+	Update(tenantId string, restaurant model.Restaurant, ifVersion *int64) (int64, error)
+	Patch(tenantId string, original, patched model.Restaurant, ifVersion *int64) (int64, error)
+	Delete(tenantId, restaurantId string, ifVersion *int64) (model.Restaurant, error)
+	List(tenantId string, limit int32, nextToken string) ([]model.Restaurant, string, error)
+	Nearby(tenantId string, lat, lon, radiusKm float64) ([]model.NearbyRestaurant, error)
+}
+
+// The tenants of the restaurants of the tests. The tests write the restaurants of tenantId,
+// and the tenant tests check that otherTenantId can neither see nor change them.
+const (
+	tenantId      = "tenant1"
+	otherTenantId = "tenant2"
+)
+
+// Run runs the conformance tests. newStorer must return an empty storage every time it is called.
+func Run(t *testing.T, newStorer func() RestaurantStorer) {
+	t.Run("save and get", func(t *testing.T) { testSaveGet(t, newStorer()) })
+	t.Run("save conflict", func(t *testing.T) { testSaveConflict(t, newStorer()) })
+	t.Run("get not found", func(t *testing.T) { testGetNotFound(t, newStorer()) })
+	t.Run("update", func(t *testing.T) { testUpdate(t, newStorer()) })
+	t.Run("patch", func(t *testing.T) { testPatch(t, newStorer()) })
+	t.Run("concurrent patches", func(t *testing.T) { testConcurrentPatches(t, newStorer()) })
+	t.Run("owners", func(t *testing.T) { testOwners(t, newStorer()) })
+	t.Run("delete", func(t *testing.T) { testDelete(t, newStorer()) })
+	t.Run("not found", func(t *testing.T) { testNotFound(t, newStorer()) })
+	t.Run("list", func(t *testing.T) { testList(t, newStorer()) })
+	t.Run("list invalid next token", func(t *testing.T) { testListInvalidNextToken(t, newStorer()) })
+	t.Run("nearby", func(t *testing.T) { testNearby(t, newStorer()) })
+	t.Run("other tenants", func(t *testing.T) { testOtherTenants(t, newStorer()) })
+	t.Run("same id in two tenants", func(t *testing.T) { testSameIdInTwoTenants(t, newStorer()) })
+}
+
+func restaurant(id, name string) model.Restaurant {
+	return model.Restaurant{Id: &id, Name: name}
+}
+
+func located(id, name string, lat, lon float64) model.Restaurant {
+	r := restaurant(id, name)
+	loc := model.NewLocation(lat, lon)
+	r.Address = &model.Address{Location: &loc}
+	return r
+}
+
+func version(v int64) *int64 {
+	return &v
+}
+
+func testSaveGet(t *testing.T, s RestaurantStorer) {
+	description := "tacos"
+	r := located("restId", "Taqueria", 47.6062, -122.3321)
+	r.Description = &description
+	require.NoError(t, s.Save(tenantId, r))
+
+	got, v, exists, err := s.Get(tenantId, "restId")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, int64(1), v)
+	assert.Equal(t, r, got)
+}
+
+func testSaveConflict(t *testing.T, s RestaurantStorer) {
+	require.NoError(t, s.Save(tenantId, restaurant("restId", "first")))
+
+	err := s.Save(tenantId, restaurant("restId", "second"))
+	assert.ErrorIs(t, err, storage.ErrConflict)
+
+	got, _, _, err := s.Get(tenantId, "restId")
+	require.NoError(t, err)
+	assert.Equal(t, "first", got.Name)
+}
+
+func testGetNotFound(t *testing.T, s RestaurantStorer) {
+	got, v, exists, err := s.Get(tenantId, "restId")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.Zero(t, v)
+	assert.Equal(t, model.Restaurant{}, got)
+}
+
+func testUpdate(t *testing.T, s RestaurantStorer) {
+	require.NoError(t, s.Save(tenantId, restaurant("restId", "before")))
+
+	v, err := s.Update(tenantId, restaurant("restId", "unconditional"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), v)
+
+	v, err = s.Update(tenantId, restaurant("restId", "after"), version(2))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), v)
+
+	_, err = s.Update(tenantId, restaurant("restId", "stale"), version(2))
+	assert.ErrorIs(t, err, storage.ErrPreconditionFailed)
+
+	got, v, _, err := s.Get(tenantId, "restId")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), v)
+	assert.Equal(t, restaurant("restId", "after"), got)
+}
+
+func testPatch(t *testing.T, s RestaurantStorer) {
+	original := restaurant("restId", "before")
+	require.NoError(t, s.Save(tenantId, original))
+
+	phone := "555-1234"
+	patched := restaurant("restId", "after")
+	patched.PhoneNumber = &phone
+
+	v, err := s.Patch(tenantId, original, patched, version(1))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), v)
+
+	_, err = s.Patch(tenantId, original, restaurant("restId", "stale"), version(1))
+	assert.ErrorIs(t, err, storage.ErrPreconditionFailed)
+
+	// Removing a field
+	v, err = s.Patch(tenantId, patched, restaurant("restId", "after"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), v)
+
+	got, v, _, err := s.Get(tenantId, "restId")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), v)
+	assert.Equal(t, restaurant("restId", "after"), got)
+}
+
+func testOwners(t *testing.T, s RestaurantStorer) {
+	owned := restaurant("restId", "name")
+	owned.OwnerIds = &[]string{"owner1", "owner2"}
+	require.NoError(t, s.Save(tenantId, owned))
+
+	got, _, _, err := s.Get(tenantId, "restId")
+	require.NoError(t, err)
+	assert.Equal(t, owned, got)
+
+	updated := restaurant("restId", "name")
+	updated.OwnerIds = &[]string{"owner3"}
+	_, err = s.Update(tenantId, updated, nil)
+	require.NoError(t, err)
+
+	patched := restaurant("restId", "name")
+	patched.OwnerIds = &[]string{"owner3", "owner4"}
+	_, err = s.Patch(tenantId, updated, patched, nil)
+	require.NoError(t, err)
+
+	got, _, _, err = s.Get(tenantId, "restId")
+	require.NoError(t, err)
+	assert.Equal(t, patched, got)
+}
+
+func testConcurrentPatches(t *testing.T, s RestaurantStorer) {
+	original := restaurant("restId", "before")
+	require.NoError(t, s.Save(tenantId, original))
+
+	// Both patches are based on the same original and change different fields
+	renamed := restaurant("restId", "after")
+	description := "tacos"
+	described := restaurant("restId", "before")
+	described.Description = &description
+
+	_, err := s.Patch(tenantId, original, renamed, nil)
+	require.NoError(t, err)
+	v, err := s.Patch(tenantId, original, described, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), v)
+
+	got, _, _, err := s.Get(tenantId, "restId")
+	require.NoError(t, err)
+	assert.Equal(t, "after", got.Name)
+	assert.Equal(t, &description, got.Description)
+}
+
+func testDelete(t *testing.T, s RestaurantStorer) {
+	r := restaurant("restId", "name")
+	require.NoError(t, s.Save(tenantId, r))
+
+	_, err := s.Delete(tenantId, "restId", version(2))
+	assert.ErrorIs(t, err, storage.ErrPreconditionFailed)
+
+	deleted, err := s.Delete(tenantId, "restId", version(1))
+	require.NoError(t, err)
+	assert.Equal(t, r, deleted)
+
+	_, _, exists, err := s.Get(tenantId, "restId")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// The id can be used again after the delete
+	require.NoError(t, s.Save(tenantId, r))
+}
+
+func testNotFound(t *testing.T, s RestaurantStorer) {
+	r := restaurant("restId", "name")
+
+	_, err := s.Update(tenantId, r, nil)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = s.Update(tenantId, r, version(1))
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	_, err = s.Patch(tenantId, r, restaurant("restId", "patched"), nil)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = s.Patch(tenantId, r, restaurant("restId", "patched"), version(1))
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	_, err = s.Delete(tenantId, "restId", nil)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = s.Delete(tenantId, "restId", version(1))
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	// A failed write must not create the restaurant
+	_, _, exists, err := s.Get(tenantId, "restId")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func testList(t *testing.T, s RestaurantStorer) {
+	want := map[string]bool{}
+	for i := 0; i < 7; i++ {
+		id := fmt.Sprintf("restId%d", i)
+		require.NoError(t, s.Save(tenantId, restaurant(id, "name")))
+		want[id] = true
+	}
+
+	// A storage may return a last page that is empty, so page until there is no token
+	got := map[string]bool{}
+	token := ""
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 10, "too many pages")
+
+		restaurants, next, err := s.List(tenantId, 3, token)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(restaurants), 3)
+		for _, r := range restaurants {
+			assert.False(t, got[*r.Id], "restaurant %s listed twice", *r.Id)
+			got[*r.Id] = true
+		}
+
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	assert.Equal(t, want, got)
+}
+
+func testListInvalidNextToken(t *testing.T, s RestaurantStorer) {
+	_, _, err := s.List(tenantId, 3, "not a token")
+	assert.ErrorIs(t, err, storage.ErrInvalidNextToken)
+}
+
+func testNearby(t *testing.T, s RestaurantStorer) {
+	// Pike Place Market is about 0.4km and the Space Needle about 1.7km from the center
+	require.NoError(t, s.Save(tenantId, located("needle", "Space Needle", 47.6205, -122.3493)))
+	require.NoError(t, s.Save(tenantId, located("market", "Pike Place Market", 47.6097, -122.3422)))
+	require.NoError(t, s.Save(tenantId, located("tacoma", "Tacoma", 47.2529, -122.4443)))
+	require.NoError(t, s.Save(tenantId, restaurant("nowhere", "No address")))
+
+	nearby, err := s.Nearby(tenantId, 47.6062, -122.3378, 2)
+	require.NoError(t, err)
+	require.Len(t, nearby, 2)
+	assert.Equal(t, "market", *nearby[0].Restaurant.Id)
+	assert.Equal(t, "needle", *nearby[1].Restaurant.Id)
+	assert.InDelta(t, 0.5, nearby[0].DistanceKm, 0.2)
+	assert.InDelta(t, 1.7, nearby[1].DistanceKm, 0.3)
+
+	nearby, err = s.Nearby(tenantId, 0, 0, 2)
+	require.NoError(t, err)
+	assert.Empty(t, nearby)
+}
@@ -0,0 +1,195 @@
+package storagetest
+
+import (
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// guessedIds are restaurant ids another tenant could try to reach a restaurant of tenantId
+// with: its id, and ids that embed the tenant as a storage key might.
+var guessedIds = []string{"restId", tenantId + "#restId", "TENANT#" + tenantId + "#restId", "../" + tenantId + "/restId"}
+
+func testOtherTenants(t *testing.T, s RestaurantStorer) {
+	r := located("restId", "name", 47.6062, -122.3321)
+	require.NoError(t, s.Save(tenantId, r))
+
+	for _, id := range guessedIds {
+		guessed := located(id, "changed", 47.6062, -122.3321)
+
+		_, _, exists, err := s.Get(otherTenantId, id)
+		require.NoError(t, err)
+		assert.False(t, exists, id)
+
+		_, err = s.Update(otherTenantId, guessed, nil)
+		assert.ErrorIs(t, err, storage.ErrNotFound, id)
+		_, err = s.Update(otherTenantId, guessed, version(1))
+		assert.ErrorIs(t, err, storage.ErrNotFound, id)
+
+		_, err = s.Patch(otherTenantId, r, guessed, nil)
+		assert.ErrorIs(t, err, storage.ErrNotFound, id)
+		_, err = s.Patch(otherTenantId, r, guessed, version(1))
+		assert.ErrorIs(t, err, storage.ErrNotFound, id)
+
+		_, err = s.Delete(otherTenantId, id, nil)
+		assert.ErrorIs(t, err, storage.ErrNotFound, id)
+		_, err = s.Delete(otherTenantId, id, version(1))
+		assert.ErrorIs(t, err, storage.ErrNotFound, id)
+	}
+
+	restaurants, _, err := s.List(otherTenantId, 10, "")
+	require.NoError(t, err)
+	assert.Empty(t, restaurants)
+
+	nearby, err := s.Nearby(otherTenantId, 47.6062, -122.3321, 1)
+	require.NoError(t, err)
+	assert.Empty(t, nearby)
+
+	// The restaurant is unchanged, and the failed writes created nothing in the other tenant
+	got, v, exists, err := s.Get(tenantId, "restId")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, int64(1), v)
+	assert.Equal(t, r, got)
+
+	restaurants, _, err = s.List(otherTenantId, 10, "")
+	require.NoError(t, err)
+	assert.Empty(t, restaurants)
+}
+
+func testSameIdInTwoTenants(t *testing.T, s RestaurantStorer) {
+	require.NoError(t, s.Save(tenantId, restaurant("restId", "first")))
+	require.NoError(t, s.Save(otherTenantId, restaurant("restId", "second")))
+
+	v, err := s.Update(otherTenantId, restaurant("restId", "updated"), version(1))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), v)
+
+	got, v, _, err := s.Get(tenantId, "restId")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), v)
+	assert.Equal(t, "first", got.Name)
+
+	_, err = s.Delete(tenantId, "restId", nil)
+	require.NoError(t, err)
+
+	got, v, exists, err := s.Get(otherTenantId, "restId")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, int64(2), v)
+	assert.Equal(t, "updated", got.Name)
+
+	restaurants, _, err := s.List(otherTenantId, 10, "")
+	require.NoError(t, err)
+	assert.Equal(t, []model.Restaurant{restaurant("restId", "updated")}, restaurants)
+}
+
+func testMenusOfOtherTenants(t *testing.T, s RestaurantMenuStorer) {
+	require.NoError(t, s.Save(tenantId, restaurant("restId", "name")))
+	require.NoError(t, s.SaveMenu(tenantId, "restId", menu("menuId", "Lunch")))
+
+	for _, id := range guessedIds {
+		_, exists, err := s.GetMenu(otherTenantId, id, "menuId")
+		require.NoError(t, err)
+		assert.False(t, exists, id)
+
+		err = s.UpdateMenu(otherTenantId, id, menu("menuId", "changed"))
+		assert.ErrorIs(t, err, storage.ErrNotFound, id)
+
+		_, err = s.DeleteMenu(otherTenantId, id, "menuId")
+		assert.ErrorIs(t, err, storage.ErrNotFound, id)
+
+		menus, err := s.ListMenus(otherTenantId, id)
+		require.NoError(t, err)
+		assert.Empty(t, menus, id)
+	}
+
+	got, exists, err := s.GetMenu(tenantId, "restId", "menuId")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, menu("menuId", "Lunch"), got)
+}
+
+func testReviewsOfOtherTenants(t *testing.T, s RestaurantReviewStorer) {
+	require.NoError(t, s.Save(tenantId, restaurant("restId", "name")))
+	require.NoError(t, s.SaveReview(tenantId, "restId", review("a", 5, 0)))
+
+	for _, id := range guessedIds {
+		err := s.SaveReview(otherTenantId, id, review("b", 1, 1))
+		assert.ErrorIs(t, err, storage.ErrNotFound, id)
+
+		_, exists, err := s.GetReview(otherTenantId, id, "a")
+		require.NoError(t, err)
+		assert.False(t, exists, id)
+
+		_, err = s.DeleteReview(otherTenantId, id, "a")
+		assert.ErrorIs(t, err, storage.ErrNotFound, id)
+
+		reviews, _, err := s.ListReviews(otherTenantId, id, storage.ReviewsNewest, 10, "")
+		require.NoError(t, err)
+		assert.Empty(t, reviews, id)
+	}
+
+	// The review and the rating summary of the restaurant are unchanged
+	reviews, _, err := s.ListReviews(tenantId, "restId", storage.ReviewsNewest, 10, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, reviewIds(reviews))
+
+	r, v, _, err := s.Get(tenantId, "restId")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), v)
+	require.NotNil(t, r.Rating)
+	assert.Equal(t, 1, r.Rating.Count)
+}
+
+func testWebhooksOfOtherTenants(t *testing.T, s WebhookStorer) {
+	require.NoError(t, s.SaveWebhook(tenantId, aWebhook("a")))
+	require.NoError(t, s.SaveDelivery(delivery("a", "e1", 0, time.Now())))
+
+	// Another tenant can register a webhook with the same id
+	require.NoError(t, s.SaveWebhook(otherTenantId, aWebhook("a")))
+	_, err := s.DeleteWebhook(otherTenantId, "a")
+	require.NoError(t, err)
+
+	for _, id := range []string{"a", tenantId + "#a", "TENANT#" + tenantId + "#WEBHOOKS"} {
+		_, exists, err := s.GetWebhook(otherTenantId, id)
+		require.NoError(t, err)
+		assert.False(t, exists, id)
+
+		_, err = s.DeleteWebhook(otherTenantId, id)
+		assert.ErrorIs(t, err, storage.ErrNotFound, id)
+
+		deliveries, _, err := s.ListDeliveries(otherTenantId, id, 10, "")
+		require.NoError(t, err)
+		assert.Empty(t, deliveries, id)
+	}
+
+	webhooks, err := s.ListWebhooks(otherTenantId)
+	require.NoError(t, err)
+	assert.Empty(t, webhooks)
+
+	// The webhook of the tenant and its deliveries are unchanged
+	got, exists, err := s.GetWebhook(tenantId, "a")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "secret of a", *got.Secret)
+
+	deliveries, _, err := s.ListDeliveries(tenantId, "a", 10, "")
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+
+	// A token of the deliveries of the tenant is not a token of the other tenant
+	require.NoError(t, s.SaveDelivery(delivery("a", "e2", 1, time.Now())))
+	_, token, err := s.ListDeliveries(tenantId, "a", 1, "")
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+	deliveries, _, err = s.ListDeliveries(otherTenantId, "a", 1, token)
+	if err == nil {
+		assert.Empty(t, deliveries)
+	} else {
+		assert.ErrorIs(t, err, storage.ErrInvalidNextToken)
+	}
+}
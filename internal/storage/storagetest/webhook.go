@@ -0,0 +1,227 @@
+package storagetest
+
+import (
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"github.com/lfroomin/restaurant-serverless/internal/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// WebhookStorer has the methods of controllers.WebhookStorer and webhook.Store.
+type WebhookStorer interface {
+	webhook.Store
+	SaveWebhook(tenantId string, w model.Webhook) error
+	DeleteWebhook(tenantId, webhookId string) (model.Webhook, error)
+	ListDeliveries(tenantId, webhookId string, limit int32, nextToken string) ([]model.WebhookDelivery, string, error)
+}
+
+// RunWebhooks runs the webhook conformance tests. newStorer must return an empty storage every time it is called.
+func RunWebhooks(t *testing.T, newStorer func() WebhookStorer) {
+	t.Run("save, get, list and delete webhooks", func(t *testing.T) { testWebhooks(t, newStorer()) })
+	t.Run("delivery log", func(t *testing.T) { testDeliveryLog(t, newStorer()) })
+	t.Run("claim and update deliveries", func(t *testing.T) { testDeliveryAttempts(t, newStorer()) })
+	t.Run("due deliveries order and limit", func(t *testing.T) { testDueDeliveries(t, newStorer()) })
+	t.Run("webhooks of other tenants", func(t *testing.T) { testWebhooksOfOtherTenants(t, newStorer()) })
+}
+
+func aWebhook(id string) model.Webhook {
+	secret := "secret of " + id
+	created := time.Date(2024, 3, 8, 18, 0, 0, 0, time.UTC)
+	return model.Webhook{Id: &id, Url: "https://example.com/" + id, EventTypes: []string{"RestaurantCreated"}, Secret: &secret, Created: &created}
+}
+
+// delivery returns a pending delivery to the webhook of an event that happened minute
+// minutes after a fixed time, due at next.
+func delivery(webhookId, eventId string, minute int, next time.Time) webhook.Delivery {
+	next = time.UnixMilli(next.UnixMilli())
+	return webhook.Delivery{
+		WebhookDelivery: model.WebhookDelivery{
+			Id:           eventId,
+			WebhookId:    webhookId,
+			EventType:    "RestaurantCreated",
+			RestaurantId: "restId",
+			Status:       model.Pending,
+			NextAttempt:  &next,
+			Created:      time.Date(2024, 3, 8, 18, minute, 0, 0, time.UTC),
+		},
+		TenantId: tenantId,
+		Payload:  `{"id":"` + eventId + `"}`,
+	}
+}
+
+func deliveryIds(deliveries []webhook.Delivery) []string {
+	ids := []string{}
+	for _, d := range deliveries {
+		ids = append(ids, d.WebhookId+"/"+d.Id)
+	}
+	return ids
+}
+
+func testWebhooks(t *testing.T, s WebhookStorer) {
+	require.NoError(t, s.SaveWebhook(tenantId, aWebhook("b")))
+	require.NoError(t, s.SaveWebhook(tenantId, aWebhook("a")))
+	assert.ErrorIs(t, s.SaveWebhook(tenantId, aWebhook("a")), storage.ErrConflict)
+
+	got, exists, err := s.GetWebhook(tenantId, "a")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "secret of a", *got.Secret)
+	assert.Equal(t, "https://example.com/a", got.Url)
+	assert.Equal(t, []string{"RestaurantCreated"}, got.EventTypes)
+
+	_, exists, err = s.GetWebhook(tenantId, "c")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	webhooks, err := s.ListWebhooks(tenantId)
+	require.NoError(t, err)
+	require.Len(t, webhooks, 2)
+	assert.Equal(t, "a", *webhooks[0].Id)
+	assert.Equal(t, "b", *webhooks[1].Id)
+
+	require.NoError(t, s.SaveDelivery(delivery("a", "e1", 0, time.Now())))
+
+	deleted, err := s.DeleteWebhook(tenantId, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "a", *deleted.Id)
+
+	_, err = s.DeleteWebhook(tenantId, "a")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	// The deliveries are deleted with the webhook
+	deliveries, _, err := s.ListDeliveries(tenantId, "a", 10, "")
+	require.NoError(t, err)
+	assert.Empty(t, deliveries)
+	due, err := s.DueDeliveries(time.Now(), 10)
+	require.NoError(t, err)
+	assert.Empty(t, due)
+
+	webhooks, err = s.ListWebhooks(tenantId)
+	require.NoError(t, err)
+	require.Len(t, webhooks, 1)
+	assert.Equal(t, "b", *webhooks[0].Id)
+}
+
+func testDeliveryLog(t *testing.T, s WebhookStorer) {
+	require.NoError(t, s.SaveWebhook(tenantId, aWebhook("a")))
+	require.NoError(t, s.SaveWebhook(tenantId, aWebhook("b")))
+	now := time.Now()
+	for i, id := range []string{"e1", "e2", "e3", "e4", "e5"} {
+		require.NoError(t, s.SaveDelivery(delivery("a", id, i, now)))
+	}
+	require.NoError(t, s.SaveDelivery(delivery("b", "e6", 6, now)))
+
+	// An event is delivered once to each webhook
+	assert.ErrorIs(t, s.SaveDelivery(delivery("a", "e1", 0, now)), storage.ErrConflict)
+
+	var ids []string
+	token := ""
+	for {
+		deliveries, next, err := s.ListDeliveries(tenantId, "a", 2, token)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(deliveries), 2)
+		for _, d := range deliveries {
+			ids = append(ids, d.Id)
+			assert.Equal(t, model.Pending, d.Status)
+			require.NotNil(t, d.NextAttempt)
+			assert.Equal(t, now.UnixMilli(), d.NextAttempt.UnixMilli())
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	assert.Equal(t, []string{"e5", "e4", "e3", "e2", "e1"}, ids)
+
+	_, _, err := s.ListDeliveries(tenantId, "a", 10, "not a token!")
+	assert.ErrorIs(t, err, storage.ErrInvalidNextToken)
+
+	deliveries, token, err := s.ListDeliveries(tenantId, "c", 10, "")
+	require.NoError(t, err)
+	assert.Empty(t, deliveries)
+	assert.Empty(t, token)
+}
+
+func testDeliveryAttempts(t *testing.T, s WebhookStorer) {
+	require.NoError(t, s.SaveWebhook(tenantId, aWebhook("a")))
+	now := time.Now()
+	require.NoError(t, s.SaveDelivery(delivery("a", "e1", 0, now.Add(-time.Minute))))
+
+	due, err := s.DueDeliveries(now, 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	d := due[0]
+	assert.Equal(t, `{"id":"e1"}`, d.Payload)
+
+	// A delivery is claimed once
+	claimed, err := s.ClaimDelivery(d, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = s.ClaimDelivery(d, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	// It is not due while it is claimed
+	due, err = s.DueDeliveries(now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, due)
+
+	// A failed attempt
+	next := now.Add(-time.Second)
+	msg, code := "unexpected response status 500 Internal Server Error", 500
+	d.Attempts, d.NextAttempt, d.LastError, d.LastStatusCode = 1, &next, &msg, &code
+	require.NoError(t, s.UpdateDelivery(d))
+
+	due, err = s.DueDeliveries(now, 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, 1, due[0].Attempts)
+	assert.Equal(t, msg, *due[0].LastError)
+	assert.Equal(t, code, *due[0].LastStatusCode)
+	assert.Equal(t, next.UnixMilli(), due[0].NextAttempt.UnixMilli())
+
+	// A successful attempt
+	d = due[0]
+	delivered, code := now.UTC(), 204
+	d.Attempts, d.Status, d.NextAttempt, d.LastError, d.LastStatusCode, d.Delivered = 2, model.Delivered, nil, nil, &code, &delivered
+	require.NoError(t, s.UpdateDelivery(d))
+
+	due, err = s.DueDeliveries(now.Add(time.Hour), 10)
+	require.NoError(t, err)
+	assert.Empty(t, due)
+
+	deliveries, _, err := s.ListDeliveries(tenantId, "a", 10, "")
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, model.Delivered, deliveries[0].Status)
+	assert.Equal(t, 2, deliveries[0].Attempts)
+	assert.Nil(t, deliveries[0].NextAttempt)
+	assert.Nil(t, deliveries[0].LastError)
+	assert.True(t, delivered.Equal(*deliveries[0].Delivered))
+
+	// The delivery of a deleted webhook
+	_, err = s.DeleteWebhook(tenantId, "a")
+	require.NoError(t, err)
+	assert.ErrorIs(t, s.UpdateDelivery(d), storage.ErrNotFound)
+}
+
+func testDueDeliveries(t *testing.T, s WebhookStorer) {
+	require.NoError(t, s.SaveWebhook(tenantId, aWebhook("a")))
+	require.NoError(t, s.SaveWebhook(tenantId, aWebhook("b")))
+	now := time.Now()
+	require.NoError(t, s.SaveDelivery(delivery("a", "e1", 0, now.Add(-time.Second))))
+	require.NoError(t, s.SaveDelivery(delivery("b", "e1", 0, now.Add(-3*time.Second))))
+	require.NoError(t, s.SaveDelivery(delivery("a", "e2", 1, now.Add(-2*time.Second))))
+	require.NoError(t, s.SaveDelivery(delivery("b", "e2", 1, now.Add(time.Second))))
+
+	due, err := s.DueDeliveries(now, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b/e1", "a/e2", "a/e1"}, deliveryIds(due))
+
+	due, err = s.DueDeliveries(now, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b/e1", "a/e2"}, deliveryIds(due))
+}
@@ -0,0 +1,62 @@
+// Package tenant tells which tenant (brand) a request is for. The storage keys the
+// restaurants by tenant, so the restaurants of a tenant are isolated from the others.
+package tenant
+
+import (
+	"errors"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lfroomin/restaurant-serverless/internal/auth"
+	"github.com/lfroomin/restaurant-serverless/internal/httpRequest"
+	"regexp"
+)
+
+const (
+	Header = "X-Tenant-Id"
+	// Default is the tenant of the requests and tokens that name none
+	Default = "default"
+)
+
+var (
+	// ErrInvalid is returned when the tenant id of the header or the token is not a valid id.
+	ErrInvalid = errors.New("invalid tenant id")
+
+	// ErrMismatch is returned when the header names another tenant than the token.
+	ErrMismatch = errors.New("the tenant id does not match the tenant of the token")
+)
+
+// A tenant id cannot contain the # separating it from the restaurant id in the storage keys.
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+func Valid(tenantId string) bool {
+	return idPattern.MatchString(tenantId)
+}
+
+// FromRequest returns the tenant of the request. An authenticated request is for the tenant
+// of its token, and its X-Tenant-Id header, if any, must name the same tenant. An anonymous
+// request is for the tenant of its header. Both default to the Default tenant.
+func FromRequest(request events.APIGatewayProxyRequest) (string, error) {
+	header := httpRequest.Header(request, Header)
+	if header != "" && !Valid(header) {
+		return "", ErrInvalid
+	}
+
+	claims, ok := auth.FromRequest(request)
+	if !ok {
+		if header == "" {
+			return Default, nil
+		}
+		return header, nil
+	}
+
+	tenantId := claims.TenantId
+	if tenantId == "" {
+		tenantId = Default
+	}
+	if !Valid(tenantId) {
+		return "", ErrInvalid
+	}
+	if header != "" && header != tenantId {
+		return "", ErrMismatch
+	}
+	return tenantId, nil
+}
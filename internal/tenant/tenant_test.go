@@ -0,0 +1,100 @@
+package tenant
+
+import (
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lfroomin/restaurant-serverless/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_FromRequest(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		header   string
+		claims   *auth.Claims
+		tenantId string
+		err      error
+	}{
+		{
+			name:     "anonymous without header",
+			tenantId: Default,
+		},
+		{
+			name:     "anonymous with header",
+			header:   "brand-a",
+			tenantId: "brand-a",
+		},
+		{
+			name:   "invalid header",
+			header: "brand#a",
+			err:    ErrInvalid,
+		},
+		{
+			name:     "token with a tenant",
+			claims:   &auth.Claims{TenantId: "brand-a"},
+			tenantId: "brand-a",
+		},
+		{
+			name:     "token and header of the same tenant",
+			header:   "brand-a",
+			claims:   &auth.Claims{TenantId: "brand-a"},
+			tenantId: "brand-a",
+		},
+		{
+			name:   "token and header of different tenants",
+			header: "brand-b",
+			claims: &auth.Claims{TenantId: "brand-a"},
+			err:    ErrMismatch,
+		},
+		{
+			name:     "token without a tenant",
+			claims:   &auth.Claims{},
+			tenantId: Default,
+		},
+		{
+			name:   "token without a tenant and a header",
+			header: "brand-b",
+			claims: &auth.Claims{},
+			err:    ErrMismatch,
+		},
+		{
+			name:   "token with an invalid tenant",
+			claims: &auth.Claims{TenantId: "TENANT#brand-a"},
+			err:    ErrInvalid,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // scoped variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			request := events.APIGatewayProxyRequest{Headers: map[string]string{}}
+			if tc.header != "" {
+				request.Headers["x-tenant-id"] = tc.header
+			}
+			if tc.claims != nil {
+				tc.claims.RegisteredClaims = jwt.RegisteredClaims{Subject: "user1"}
+				request = auth.WithClaims(request, *tc.claims)
+			}
+
+			tenantId, err := FromRequest(request)
+
+			assert.ErrorIs(t, err, tc.err)
+			assert.Equal(t, tc.tenantId, tenantId)
+		})
+	}
+}
+
+func Test_Valid(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, Valid("brand_A-1"))
+	assert.False(t, Valid(""))
+	assert.False(t, Valid("brand#a"))
+	assert.False(t, Valid("brand a"))
+	assert.False(t, Valid(string(make([]byte, 65))))
+}
@@ -0,0 +1,8 @@
+module github.com/lfroomin/restaurant-serverless/internal/timezone/gen
+
+go 1.24
+
+require (
+	github.com/ringsaturn/tzf-rel-lite v0.0.2025-b2
+	google.golang.org/protobuf v1.36.12
+)
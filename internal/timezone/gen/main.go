@@ -0,0 +1,303 @@
+// The gen command writes zones.bin.gz, the time zone boundaries of the timezone package, from
+// the boundaries of timezone-boundary-builder (with the oceans) as reduced by tzf-rel-lite:
+//
+//	go generate ./internal/timezone
+//
+// It is a module of its own, so that the service does not depend on the source data.
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	tzfrellite "github.com/ringsaturn/tzf-rel-lite"
+	"google.golang.org/protobuf/encoding/protowire"
+	"log"
+	"math"
+	"os"
+)
+
+// scale is the number of units per degree of the coordinates written, about 11m.
+const scale = 1e4
+
+type point struct {
+	lon, lat int32
+}
+
+type polygon struct {
+	exterior []point
+	holes    [][]point
+}
+
+type zone struct {
+	name     string
+	polygons []polygon
+}
+
+func main() {
+	out := flag.String("o", "zones.bin.gz", "file written")
+	tolerance := flag.Float64("tolerance", 0.001, "the boundaries are simplified up to this many degrees")
+	flag.Parse()
+
+	zones, err := parseTimezones(tzfrellite.LiteData)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		log.Fatal(err)
+	}
+	points := write(zw, zones, *tolerance*scale)
+	if err = zw.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if err = os.WriteFile(*out, buf.Bytes(), 0o644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Wrote %s  zones: %d  points: %d  bytes: %d\n", *out, len(zones), points, buf.Len())
+}
+
+// write writes the zones in the format read by the timezone package, all numbers as varints:
+// the number of zones, then for each its name (length and bytes) and its polygons (count),
+// each a number of rings, the exterior and then the holes, each a number of points followed
+// by the longitude and latitude of each point as the difference with the previous point.
+// It returns the number of points written.
+func write(w *gzip.Writer, zones []zone, tolerance float64) int {
+	var buf []byte
+	uvarint := func(v int) { buf = binary.AppendUvarint(buf, uint64(v)) }
+	ring := func(r []point) {
+		uvarint(len(r))
+		prev := point{}
+		for _, p := range r {
+			buf = binary.AppendVarint(buf, int64(p.lon-prev.lon))
+			buf = binary.AppendVarint(buf, int64(p.lat-prev.lat))
+			prev = p
+		}
+	}
+
+	points := 0
+	uvarint(len(zones))
+	for _, z := range zones {
+		uvarint(len(z.name))
+		buf = append(buf, z.name...)
+
+		var rings [][][]point
+		for _, p := range z.polygons {
+			exterior := simplify(p.exterior, tolerance)
+			if len(exterior) < 3 {
+				continue
+			}
+			polygonRings := [][]point{exterior}
+			for _, h := range p.holes {
+				if hole := simplify(h, tolerance); len(hole) >= 3 {
+					polygonRings = append(polygonRings, hole)
+				}
+			}
+			rings = append(rings, polygonRings)
+		}
+
+		uvarint(len(rings))
+		for _, polygonRings := range rings {
+			uvarint(len(polygonRings))
+			for _, r := range polygonRings {
+				ring(r)
+				points += len(r)
+			}
+		}
+	}
+
+	if _, err := w.Write(buf); err != nil {
+		log.Fatal(err)
+	}
+	return points
+}
+
+// simplify removes the points of the ring that are within tolerance of the line between their
+// neighbors (Douglas-Peucker). The ring is split at the point farthest from its first point,
+// so that both halves are open lines.
+func simplify(r []point, tolerance float64) []point {
+	if len(r) < 8 {
+		return r
+	}
+	far, farthest := 0, -1.0
+	for i, p := range r {
+		if d := distance(p, r[0], r[0]); d > farthest {
+			far, farthest = i, d
+		}
+	}
+
+	first := simplifyLine(r[:far+1], tolerance)
+	second := simplifyLine(append(append([]point{}, r[far:]...), r[0]), tolerance)
+	simplified := append(first, second[1:len(second)-1]...)
+	if len(simplified) < 3 {
+		return r
+	}
+	return simplified
+}
+
+func simplifyLine(line []point, tolerance float64) []point {
+	keep := make([]bool, len(line))
+	keep[0], keep[len(line)-1] = true, true
+
+	type span struct{ from, to int }
+	spans := []span{{0, len(line) - 1}}
+	for len(spans) > 0 {
+		s := spans[len(spans)-1]
+		spans = spans[:len(spans)-1]
+
+		farthest, far := 0.0, -1
+		for i := s.from + 1; i < s.to; i++ {
+			if d := distance(line[i], line[s.from], line[s.to]); d > farthest {
+				farthest, far = d, i
+			}
+		}
+		if far >= 0 && farthest > tolerance {
+			keep[far] = true
+			spans = append(spans, span{s.from, far}, span{far, s.to})
+		}
+	}
+
+	simplified := []point{}
+	for i, k := range keep {
+		if k {
+			simplified = append(simplified, line[i])
+		}
+	}
+	return simplified
+}
+
+// distance returns the distance in units from p to the segment from a to b.
+func distance(p, a, b point) float64 {
+	dx, dy := float64(b.lon-a.lon), float64(b.lat-a.lat)
+	px, py := float64(p.lon-a.lon), float64(p.lat-a.lat)
+	t := 0.0
+	if l := dx*dx + dy*dy; l > 0 {
+		t = math.Max(0, math.Min(1, (px*dx+py*dy)/l))
+	}
+	return math.Hypot(px-t*dx, py-t*dy)
+}
+
+// parseTimezones parses the Timezones message of tzf (pb/tzf/v1/tzinfo.proto).
+func parseTimezones(b []byte) ([]zone, error) {
+	var zones []zone
+	err := parseMessage(b, func(num protowire.Number, v []byte) error {
+		if num != 1 {
+			return nil
+		}
+		z, err := parseTimezone(v)
+		zones = append(zones, z)
+		return err
+	})
+	return zones, err
+}
+
+func parseTimezone(b []byte) (zone, error) {
+	z := zone{}
+	err := parseMessage(b, func(num protowire.Number, v []byte) error {
+		switch num {
+		case 1:
+			p, err := parsePolygon(v)
+			z.polygons = append(z.polygons, p)
+			return err
+		case 2:
+			z.name = string(v)
+		}
+		return nil
+	})
+	return z, err
+}
+
+func parsePolygon(b []byte) (polygon, error) {
+	p := polygon{}
+	err := parseMessage(b, func(num protowire.Number, v []byte) error {
+		switch num {
+		case 1:
+			pt, err := parsePoint(v)
+			p.exterior = appendPoint(p.exterior, pt)
+			return err
+		case 2:
+			hole, err := parsePolygon(v)
+			p.holes = append(p.holes, hole.exterior)
+			return err
+		}
+		return nil
+	})
+	p.exterior = open(p.exterior)
+	return p, err
+}
+
+// appendPoint appends the point unless it is the same as the last one once rounded.
+func appendPoint(r []point, p point) []point {
+	if len(r) > 0 && r[len(r)-1] == p {
+		return r
+	}
+	return append(r, p)
+}
+
+// open removes the last point of the ring when it repeats the first.
+func open(r []point) []point {
+	if len(r) > 1 && r[0] == r[len(r)-1] {
+		return r[:len(r)-1]
+	}
+	return r
+}
+
+func parsePoint(b []byte) (point, error) {
+	var lon, lat float32
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return point{}, protowire.ParseError(n)
+		}
+		b = b[n:]
+		if typ != protowire.Fixed32Type {
+			return point{}, fmt.Errorf("unexpected wire type %d of point field %d", typ, num)
+		}
+		v, n := protowire.ConsumeFixed32(b)
+		if n < 0 {
+			return point{}, protowire.ParseError(n)
+		}
+		b = b[n:]
+		switch num {
+		case 1:
+			lon = math.Float32frombits(v)
+		case 2:
+			lat = math.Float32frombits(v)
+		}
+	}
+	return point{lon: int32(math.Round(float64(lon) * scale)), lat: int32(math.Round(float64(lat) * scale))}, nil
+}
+
+// parseMessage calls field with the number and the value of each length-delimited field of the
+// message, and skips the other fields.
+func parseMessage(b []byte, field func(protowire.Number, []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if typ != protowire.BytesType {
+			if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if err := field(num, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+// Package timezone resolves the IANA time zone of a coordinate offline.
+//
+// The boundaries of the time zones are the polygons of zones.bin.gz, written by gen from the
+// boundaries of timezone-boundary-builder (ODbL, see zones.LICENSE), simplified to about 100m.
+// A coordinate gets the time zone of the polygon that contains it, so the answer is only
+// approximate within about 100m of a border. The oceans are covered by the nautical time zones
+// of their longitude (Etc/GMT+N), which are also the answer for a coordinate on no polygon.
+package timezone
+
+//go:generate go run -C gen . -o ../zones.bin.gz
+
+import (
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	// The Lambda runtime has no zoneinfo files, so embed them for time.LoadLocation
+	_ "time/tzdata"
+)
+
+const (
+	// scale is the number of units per degree of the coordinates of zones.bin.gz.
+	scale = 1e4
+	// maxGap is how far from a polygon, in units, a coordinate on no polygon can be and still
+	// get its time zone. The simplified boundaries of neighboring zones leave gaps between them.
+	maxGap = 0.01 * scale
+)
+
+//go:embed zones.bin.gz
+var zonesData []byte
+
+type point struct {
+	lon, lat float64
+}
+
+// polygon is an exterior ring and its holes, in units.
+type polygon struct {
+	zone  string
+	rings [][]point
+	// The bounding box of the exterior ring
+	min, max point
+}
+
+var (
+	polygons     []polygon
+	loadPolygons sync.Once
+)
+
+// Lookup returns the IANA time zone name of the coordinate.
+func Lookup(lat, lon float64) string {
+	loadPolygons.Do(func() {
+		polygons = mustParse(zonesData)
+	})
+
+	p := point{lon: lon * scale, lat: lat * scale}
+	nearest, distance := "", math.Inf(1)
+	for i := range polygons {
+		poly := &polygons[i]
+		if p.lon < poly.min.lon-maxGap || p.lon > poly.max.lon+maxGap || p.lat < poly.min.lat-maxGap || p.lat > poly.max.lat+maxGap {
+			continue
+		}
+		if poly.contains(p) {
+			return poly.zone
+		}
+		if d := poly.distance(p); d < distance {
+			nearest, distance = poly.zone, d
+		}
+	}
+
+	if distance > maxGap {
+		return nautical(lon)
+	}
+	return nearest
+}
+
+// Valid reports whether the name is an IANA time zone that time.LoadLocation can load.
+func Valid(name string) bool {
+	// LoadLocation also accepts "" and "Local", which are not IANA names
+	if name == "" || name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// Resolve returns the name when it is valid, otherwise the time zone of the coordinate.
+func Resolve(name *string, lat, lon float64) string {
+	if name != nil && Valid(*name) {
+		return *name
+	}
+	return Lookup(lat, lon)
+}
+
+// nautical returns the time zone of the 15 degree band of longitude. The sign
+// of the Etc zones is inverted: Etc/GMT+8 is 8 hours behind UTC.
+func nautical(lon float64) string {
+	offset := int(math.Round(math.Remainder(lon, 360) / 15))
+	switch {
+	case offset > 0:
+		return fmt.Sprintf("Etc/GMT-%d", offset)
+	case offset < 0:
+		return fmt.Sprintf("Etc/GMT+%d", -offset)
+	default:
+		return "Etc/GMT"
+	}
+}
+
+// contains reports whether the point is inside the exterior ring and outside the holes, by
+// counting the edges of the rings crossed by a ray from the point.
+func (poly *polygon) contains(p point) bool {
+	if p.lon < poly.min.lon || p.lon > poly.max.lon || p.lat < poly.min.lat || p.lat > poly.max.lat {
+		return false
+	}
+
+	inside := false
+	for _, ring := range poly.rings {
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			a, b := ring[i], ring[j]
+			if (a.lat > p.lat) != (b.lat > p.lat) && p.lon < (b.lon-a.lon)*(p.lat-a.lat)/(b.lat-a.lat)+a.lon {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+// distance returns the distance in units from the point to the nearest edge of the polygon.
+func (poly *polygon) distance(p point) float64 {
+	distance := math.Inf(1)
+	for _, ring := range poly.rings {
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			distance = math.Min(distance, segmentDistance(p, ring[i], ring[j]))
+		}
+	}
+	return distance
+}
+
+func segmentDistance(p, a, b point) float64 {
+	dx, dy := b.lon-a.lon, b.lat-a.lat
+	t := 0.0
+	if l := dx*dx + dy*dy; l > 0 {
+		t = math.Max(0, math.Min(1, ((p.lon-a.lon)*dx+(p.lat-a.lat)*dy)/l))
+	}
+	return math.Hypot(p.lon-a.lon-t*dx, p.lat-a.lat-t*dy)
+}
+
+// mustParse reads the polygons of the zones in the format written by gen.
+func mustParse(data []byte) []polygon {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		panic(fmt.Sprintf("error reading zones.bin.gz: %v", err))
+	}
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		panic(fmt.Sprintf("error reading zones.bin.gz: %v", err))
+	}
+
+	r := &reader{data: raw}
+	var polys []polygon
+	zones := r.uvarint()
+	for z := 0; z < zones; z++ {
+		name := r.string()
+		for n := r.uvarint(); n > 0; n-- {
+			poly := polygon{zone: name, min: point{lon: math.Inf(1), lat: math.Inf(1)}, max: point{lon: math.Inf(-1), lat: math.Inf(-1)}}
+			for rings := r.uvarint(); rings > 0; rings-- {
+				ring := make([]point, r.uvarint())
+				prev := point{}
+				for i := range ring {
+					ring[i] = point{lon: prev.lon + float64(r.varint()), lat: prev.lat + float64(r.varint())}
+					prev = ring[i]
+				}
+				if len(poly.rings) == 0 {
+					for _, p := range ring {
+						poly.min = point{lon: math.Min(poly.min.lon, p.lon), lat: math.Min(poly.min.lat, p.lat)}
+						poly.max = point{lon: math.Max(poly.max.lon, p.lon), lat: math.Max(poly.max.lat, p.lat)}
+					}
+				}
+				poly.rings = append(poly.rings, ring)
+			}
+			polys = append(polys, poly)
+		}
+	}
+	if r.err != nil {
+		panic(fmt.Sprintf("error parsing zones.bin.gz: %v", r.err))
+	}
+	if len(r.data) != 0 {
+		panic(fmt.Sprintf("error parsing zones.bin.gz: %d bytes after the zones", len(r.data)))
+	}
+	return polys
+}
+
+// reader reads the varints of zones.bin.gz and remembers the first error.
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) uvarint() int {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.data = r.data[n:]
+	return int(v)
+}
+
+func (r *reader) varint() int64 {
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *reader) string() string {
+	n := r.uvarint()
+	if n > len(r.data) {
+		r.fail()
+		return ""
+	}
+	s := string(r.data[:n])
+	r.data = r.data[n:]
+	return s
+}
+
+func (r *reader) fail() {
+	if r.err == nil {
+		r.err = errors.New("unexpected end of data")
+	}
+	r.data = nil
+}
@@ -0,0 +1,86 @@
+package timezone
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_Lookup(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		lat      float64
+		lon      float64
+		timezone string
+	}{
+		{name: "seattle", lat: 47.6062, lon: -122.3321, timezone: "America/Los_Angeles"},
+		{name: "phoenix suburb", lat: 33.4152, lon: -111.8315, timezone: "America/Phoenix"},
+		{name: "denver", lat: 39.7392, lon: -104.9903, timezone: "America/Denver"},
+		{name: "chicago", lat: 41.8781, lon: -87.6298, timezone: "America/Chicago"},
+		{name: "brooklyn", lat: 40.6782, lon: -73.9442, timezone: "America/New_York"},
+		{name: "indianapolis", lat: 39.7684, lon: -86.1581, timezone: "America/Indiana/Indianapolis"},
+		{name: "honolulu", lat: 21.3069, lon: -157.8583, timezone: "Pacific/Honolulu"},
+		{name: "london", lat: 51.5074, lon: -0.1278, timezone: "Europe/London"},
+		{name: "tokyo", lat: 35.6762, lon: 139.6503, timezone: "Asia/Tokyo"},
+		{name: "sydney", lat: -33.8688, lon: 151.2093, timezone: "Australia/Sydney"},
+		{name: "detroit", lat: 42.3314, lon: -83.0458, timezone: "America/Detroit"},
+		{name: "windsor across the river", lat: 42.3149, lon: -83.0364, timezone: "America/Toronto"},
+		{name: "tuba city in the navajo nation", lat: 36.1350, lon: -111.2399, timezone: "America/Denver"},
+		{name: "kykotsmovi in the hopi reservation", lat: 35.8750, lon: -110.6230, timezone: "America/Phoenix"},
+		{name: "el paso", lat: 31.7619, lon: -106.4850, timezone: "America/Denver"},
+		{name: "ciudad juarez", lat: 31.6904, lon: -106.4245, timezone: "America/Ciudad_Juarez"},
+		{name: "central siberia far from cities", lat: 64, lon: 100, timezone: "Asia/Krasnoyarsk"},
+		{name: "mid atlantic", lat: 30, lon: -45, timezone: "Etc/GMT+3"},
+		{name: "south pacific", lat: -45, lon: -120, timezone: "Etc/GMT+8"},
+		{name: "south atlantic", lat: -40, lon: 0, timezone: "Etc/GMT"},
+		{name: "indian ocean", lat: -35, lon: 80, timezone: "Etc/GMT-5"},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.timezone, Lookup(tc.lat, tc.lon))
+		})
+	}
+}
+
+func Test_Polygons(t *testing.T) {
+	t.Parallel()
+
+	Lookup(0, 0)
+	assert.NotEmpty(t, polygons)
+	for _, poly := range polygons {
+		assert.True(t, Valid(poly.zone), "invalid time zone %q", poly.zone)
+		assert.True(t, poly.min.lat >= -90*scale && poly.max.lat <= 90*scale, "invalid latitude of %s", poly.zone)
+		assert.True(t, poly.min.lon >= -180*scale && poly.max.lon <= 180*scale, "invalid longitude of %s", poly.zone)
+	}
+
+	for lon := -180.0; lon <= 180; lon += 7.5 {
+		assert.True(t, Valid(nautical(lon)), "invalid nautical time zone %q", nautical(lon))
+	}
+}
+
+func Test_Valid(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, Valid("America/Los_Angeles"))
+	assert.True(t, Valid("UTC"))
+	assert.False(t, Valid(""))
+	assert.False(t, Valid("Local"))
+	assert.False(t, Valid("America/Nowhere"))
+	assert.False(t, Valid("PST"))
+}
+
+func Test_Resolve(t *testing.T) {
+	t.Parallel()
+
+	name := "America/Chicago"
+	invalid := "Mars/Olympus_Mons"
+	assert.Equal(t, "America/Chicago", Resolve(&name, 47.6062, -122.3321))
+	assert.Equal(t, "America/Los_Angeles", Resolve(&invalid, 47.6062, -122.3321))
+	assert.Equal(t, "America/Los_Angeles", Resolve(nil, 47.6062, -122.3321))
+}
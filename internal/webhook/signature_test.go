@@ -0,0 +1,111 @@
+package webhook
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func Test_Sign(t *testing.T) {
+	t.Parallel()
+
+	// echo -n "1709920800.{}" | openssl dgst -sha256 -hmac secret
+	assert.Equal(t, "sha256=f1528f027ae236000373f9428863226cb285a2ab32720e48d40308df52b2d737", Sign("secret", 1709920800, []byte("{}")))
+}
+
+func Test_Verify(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	body := []byte(`{"id":"eventId"}`)
+	timestamp := now.Add(-time.Minute).Unix()
+
+	testCases := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		body      []byte
+		err       error
+	}{
+		{
+			name:      "valid",
+			secret:    "secret",
+			timestamp: strconv.FormatInt(timestamp, 10),
+			signature: Sign("secret", timestamp, body),
+			body:      body,
+		},
+		{
+			name:      "wrong secret",
+			secret:    "other",
+			timestamp: strconv.FormatInt(timestamp, 10),
+			signature: Sign("secret", timestamp, body),
+			body:      body,
+			err:       ErrInvalidSignature,
+		},
+		{
+			name:      "body changed",
+			secret:    "secret",
+			timestamp: strconv.FormatInt(timestamp, 10),
+			signature: Sign("secret", timestamp, body),
+			body:      []byte(`{"id":"otherId"}`),
+			err:       ErrInvalidSignature,
+		},
+		{
+			name:      "timestamp changed",
+			secret:    "secret",
+			timestamp: strconv.FormatInt(timestamp+1, 10),
+			signature: Sign("secret", timestamp, body),
+			body:      body,
+			err:       ErrInvalidSignature,
+		},
+		{
+			name:      "signature without prefix",
+			secret:    "secret",
+			timestamp: strconv.FormatInt(timestamp, 10),
+			signature: Sign("secret", timestamp, body)[len("sha256="):],
+			body:      body,
+			err:       ErrInvalidSignature,
+		},
+		{
+			name:      "replayed",
+			secret:    "secret",
+			timestamp: strconv.FormatInt(now.Add(-DefaultTolerance-time.Second).Unix(), 10),
+			signature: Sign("secret", now.Add(-DefaultTolerance-time.Second).Unix(), body),
+			body:      body,
+			err:       ErrInvalidTimestamp,
+		},
+		{
+			name:      "timestamp in the future",
+			secret:    "secret",
+			timestamp: strconv.FormatInt(now.Add(DefaultTolerance+time.Second).Unix(), 10),
+			signature: Sign("secret", now.Add(DefaultTolerance+time.Second).Unix(), body),
+			body:      body,
+			err:       ErrInvalidTimestamp,
+		},
+		{
+			name:      "invalid timestamp",
+			secret:    "secret",
+			timestamp: "yesterday",
+			signature: Sign("secret", timestamp, body),
+			body:      body,
+			err:       ErrInvalidTimestamp,
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := Verify(tc.secret, tc.timestamp, tc.signature, tc.body, now, DefaultTolerance)
+
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+// Package webhook delivers the restaurant change events to the webhooks subscribed to
+// them. The Dispatcher records a pending delivery for each subscribed webhook when an
+// event is relayed from the outbox, and the Worker POSTs the pending deliveries, signed
+// with the secret of their webhook, retrying them until they succeed or are dead lettered.
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lfroomin/restaurant-serverless/internal/event"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"log"
+	"time"
+)
+
+// Delivery is the delivery of an event to a webhook, with the event as it is POSTed.
+type Delivery struct {
+	model.WebhookDelivery
+	// TenantId is the tenant of the webhook and of the restaurant of the event
+	TenantId string
+	Payload  string
+}
+
+type Store interface {
+	ListWebhooks(tenantId string) ([]model.Webhook, error)
+	GetWebhook(tenantId, webhookId string) (model.Webhook, bool, error)
+	// SaveDelivery stores a new delivery. It returns storage.ErrConflict when the
+	// webhook already has a delivery of the event.
+	SaveDelivery(delivery Delivery) error
+	// DueDeliveries returns up to limit pending deliveries with a next attempt at or before now, the earliest first.
+	DueDeliveries(now time.Time, limit int32) ([]Delivery, error)
+	// ClaimDelivery moves the next attempt of the delivery to until, so other workers skip it while
+	// it is sent. It returns false when the delivery changed since it was returned by DueDeliveries.
+	ClaimDelivery(delivery Delivery, until time.Time) (bool, error)
+	// UpdateDelivery stores the outcome of an attempt. It returns storage.ErrNotFound when
+	// the delivery was deleted with its webhook.
+	UpdateDelivery(delivery Delivery) error
+}
+
+// Dispatcher is an outbox publisher that records a pending delivery of the event for each
+// webhook of its tenant subscribed to its type. Publishing an event again records no new deliveries.
+type Dispatcher struct {
+	Store Store
+}
+
+func (d Dispatcher) Publish(e event.Event) error {
+	webhooks, err := d.Store.ListWebhooks(e.TenantId)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("error marshalling event %q: %w", e.Id, err)
+	}
+
+	now := time.Now()
+	for _, w := range webhooks {
+		if !subscribed(w, e.Type) {
+			continue
+		}
+
+		delivery := Delivery{
+			WebhookDelivery: model.WebhookDelivery{
+				Id:           e.Id,
+				WebhookId:    *w.Id,
+				EventType:    string(e.Type),
+				RestaurantId: e.RestaurantId,
+				Status:       model.Pending,
+				NextAttempt:  &now,
+				Created:      e.Time,
+			},
+			TenantId: e.TenantId,
+			Payload:  string(payload),
+		}
+		if err = d.Store.SaveDelivery(delivery); err != nil && !errors.Is(err, storage.ErrConflict) {
+			return err
+		}
+	}
+	return nil
+}
+
+func subscribed(w model.Webhook, t event.Type) bool {
+	for _, et := range w.EventTypes {
+		if et == string(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// logDelivery logs the outcome of an attempt.
+func logDelivery(d Delivery) {
+	switch d.Status {
+	case model.Delivered:
+		log.Printf("delivered event %s to webhook %s (attempt %d)\n", d.Id, d.WebhookId, d.Attempts)
+	case model.DeadLettered:
+		log.Printf("dead lettered event %s to webhook %s after %d attempts: %s\n", d.Id, d.WebhookId, d.Attempts, *d.LastError)
+	default:
+		log.Printf("error delivering event %s to webhook %s (attempt %d), next attempt at %s: %s\n",
+			d.Id, d.WebhookId, d.Attempts, d.NextAttempt.Format(time.RFC3339), *d.LastError)
+	}
+}
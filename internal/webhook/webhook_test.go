@@ -0,0 +1,190 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/lfroomin/restaurant-serverless/internal/event"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sort"
+	"testing"
+	"time"
+)
+
+func Test_Publish(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		eventType  event.Type
+		tenantId   string
+		storeError string
+		deliveries []string
+		errMsg     string
+	}{
+		{
+			name:       "webhooks subscribed to the event",
+			eventType:  event.RestaurantCreated,
+			deliveries: []string{"all/eventId", "created/eventId"},
+		},
+		{
+			name:       "webhook subscribed to another event",
+			eventType:  event.RestaurantDeleted,
+			deliveries: []string{"all/eventId"},
+		},
+		{
+			name:       "webhooks of another tenant",
+			eventType:  event.RestaurantCreated,
+			tenantId:   "tenant2",
+			deliveries: []string{},
+		},
+		{
+			name:       "store error",
+			eventType:  event.RestaurantCreated,
+			storeError: "an error occurred",
+			errMsg:     "an error occurred",
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			store := newStoreStub(
+				aWebhook("all", "", string(event.RestaurantCreated), string(event.RestaurantUpdated), string(event.RestaurantDeleted)),
+				aWebhook("created", "", string(event.RestaurantCreated)),
+			)
+			store.error = tc.storeError
+			e := anEvent(tc.eventType)
+			if tc.tenantId != "" {
+				e.TenantId = tc.tenantId
+			}
+
+			err := Dispatcher{Store: store}.Publish(e)
+
+			if tc.errMsg != "" {
+				assert.EqualError(t, err, tc.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.deliveries, store.deliveryIds())
+
+			// The deliveries are recorded once when the event is published again
+			require.NoError(t, Dispatcher{Store: store}.Publish(e))
+			assert.Equal(t, tc.deliveries, store.deliveryIds())
+
+			for _, d := range store.deliveries {
+				assert.Equal(t, model.Pending, d.Status)
+				assert.Equal(t, "tenant1", d.TenantId)
+				assert.Equal(t, string(tc.eventType), d.EventType)
+				assert.Equal(t, "restId", d.RestaurantId)
+				assert.Equal(t, e.Time, d.Created)
+				assert.Equal(t, 0, d.Attempts)
+				require.NotNil(t, d.NextAttempt)
+				assert.WithinDuration(t, time.Now(), *d.NextAttempt, time.Second)
+
+				published := event.Event{}
+				require.NoError(t, json.Unmarshal([]byte(d.Payload), &published))
+				assert.Equal(t, e, published)
+			}
+		})
+	}
+}
+
+func anEvent(t event.Type) event.Event {
+	restId := "restId"
+	r := model.Restaurant{Id: &restId, Name: "name"}
+	e := event.New(stubTenantId, t, nil, &r, 1)
+	e.Id, e.Time = "eventId", time.Date(2024, 3, 8, 18, 30, 0, 0, time.UTC)
+	return e
+}
+
+func aWebhook(id, url string, eventTypes ...string) model.Webhook {
+	secret := "secret of " + id
+	return model.Webhook{Id: &id, Url: url, EventTypes: eventTypes, Secret: &secret}
+}
+
+// stubTenantId is the tenant of the webhooks of storeStub.
+const stubTenantId = "tenant1"
+
+// storeStub keeps the webhooks of stubTenantId and the deliveries, keyed by webhook id and event id.
+type storeStub struct {
+	webhooks   []model.Webhook
+	deliveries map[string]Delivery
+	claimLost  bool
+	error      string
+}
+
+func newStoreStub(webhooks ...model.Webhook) *storeStub {
+	return &storeStub{webhooks: webhooks, deliveries: map[string]Delivery{}}
+}
+
+func (s *storeStub) deliveryIds() []string {
+	ids := []string{}
+	for id := range s.deliveries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (s *storeStub) ListWebhooks(tenantId string) ([]model.Webhook, error) {
+	if s.error != "" {
+		return nil, errors.New(s.error)
+	}
+	if tenantId != stubTenantId {
+		return nil, nil
+	}
+	return s.webhooks, nil
+}
+
+func (s *storeStub) GetWebhook(tenantId, webhookId string) (model.Webhook, bool, error) {
+	if tenantId != stubTenantId {
+		return model.Webhook{}, false, nil
+	}
+	for _, w := range s.webhooks {
+		if *w.Id == webhookId {
+			return w, true, nil
+		}
+	}
+	return model.Webhook{}, false, nil
+}
+
+func (s *storeStub) SaveDelivery(d Delivery) error {
+	if _, exists := s.deliveries[d.WebhookId+"/"+d.Id]; exists {
+		return storage.ErrConflict
+	}
+	s.deliveries[d.WebhookId+"/"+d.Id] = d
+	return nil
+}
+
+func (s *storeStub) DueDeliveries(now time.Time, limit int32) ([]Delivery, error) {
+	if s.error != "" {
+		return nil, errors.New(s.error)
+	}
+	var due []Delivery
+	for _, id := range s.deliveryIds() {
+		d := s.deliveries[id]
+		if d.Status == model.Pending && !d.NextAttempt.After(now) && len(due) < int(limit) {
+			due = append(due, d)
+		}
+	}
+	return due, nil
+}
+
+func (s *storeStub) ClaimDelivery(d Delivery, until time.Time) (bool, error) {
+	if s.claimLost {
+		return false, nil
+	}
+	d.NextAttempt = &until
+	s.deliveries[d.WebhookId+"/"+d.Id] = d
+	return true, nil
+}
+
+func (s *storeStub) UpdateDelivery(d Delivery) error {
+	s.deliveries[d.WebhookId+"/"+d.Id] = d
+	return nil
+}
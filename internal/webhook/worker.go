@@ -0,0 +1,275 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/lfroomin/restaurant-serverless/internal/storage"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultBatchSize   = 25
+	defaultLease       = 5 * time.Minute
+	defaultTimeout     = 10 * time.Second
+	defaultMaxAttempts = 10
+	defaultBackoffBase = 30 * time.Second
+	defaultBackoffMax  = time.Hour
+)
+
+type Worker struct {
+	Store Store
+	// Client sends the deliveries. The one of NewWorker only connects to public addresses.
+	Client    *http.Client
+	BatchSize int32
+	// Lease is how long a claimed delivery is skipped by other workers
+	Lease time.Duration
+	// MaxAttempts is the number of attempts after which a delivery is dead lettered
+	MaxAttempts int
+	// The delay before the next attempt doubles after each failed attempt, from BackoffBase up to BackoffMax.
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+}
+
+// ErrForbiddenAddress is returned when a webhook URL resolves to an address of the internal network.
+var ErrForbiddenAddress = errors.New("the address is not a public address")
+
+// NewWorker returns a worker that only connects to public addresses and does not follow
+// redirects, so that a webhook URL cannot reach the internal network of the service.
+func NewWorker(store Store) Worker {
+	return Worker{
+		Store:       store,
+		Client:      newClient(),
+		BatchSize:   defaultBatchSize,
+		Lease:       defaultLease,
+		MaxAttempts: defaultMaxAttempts,
+		BackoffBase: defaultBackoffBase,
+		BackoffMax:  defaultBackoffMax,
+	}
+}
+
+// newClient returns the client of the deliveries. The addresses are checked once resolved,
+// when connecting, so that a host name cannot resolve to an internal address after the
+// check. There is no proxy, which would be connected to instead.
+func newClient() *http.Client {
+	dialer := &net.Dialer{Timeout: defaultTimeout, Control: publicAddress}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   defaultTimeout,
+		Transport: transport,
+		// A redirect could send the delivery to an address that was not registered
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// forbiddenPrefixes are the special-purpose address ranges of the IANA registries, which are
+// not reachable on the internet or reach the network of the service, such as the carrier-grade
+// NAT or the benchmarking ranges. The IPv6 ranges that embed an IPv4 address, such as NAT64
+// and 6to4, are all forbidden, as they could be translated to an internal IPv4 address.
+var forbiddenPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // this network
+	netip.MustParsePrefix("10.0.0.0/8"),      // private
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("127.0.0.0/8"),     // loopback
+	netip.MustParsePrefix("169.254.0.0/16"),  // link-local, such as the instance metadata service
+	netip.MustParsePrefix("172.16.0.0/12"),   // private
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("192.31.196.0/24"), // AS112
+	netip.MustParsePrefix("192.52.193.0/24"), // AMT
+	netip.MustParsePrefix("192.88.99.0/24"),  // 6to4 relay anycast
+	netip.MustParsePrefix("192.168.0.0/16"),  // private
+	netip.MustParsePrefix("192.175.48.0/24"), // direct delegation AS112
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("224.0.0.0/4"),     // multicast
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved and broadcast
+	netip.MustParsePrefix("::/96"),           // unspecified, loopback and IPv4-compatible
+	netip.MustParsePrefix("::ffff:0:0/96"),   // IPv4-mapped
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local NAT64
+	netip.MustParsePrefix("100::/64"),        // discard
+	netip.MustParsePrefix("2001::/23"),       // IETF protocol assignments, such as Teredo
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4
+	netip.MustParsePrefix("3fff::/20"),       // documentation
+	netip.MustParsePrefix("5f00::/16"),       // segment routing
+	netip.MustParsePrefix("fc00::/7"),        // unique local
+	netip.MustParsePrefix("fe80::/10"),       // link-local
+	netip.MustParsePrefix("fec0::/10"),       // site-local
+	netip.MustParsePrefix("ff00::/8"),        // multicast
+}
+
+// publicAddress refuses to connect to the addresses of the special-purpose ranges. An
+// IPv4-mapped IPv6 address is checked as the IPv4 address it maps.
+func publicAddress(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil || forbidden(ip.WithZone("").Unmap()) {
+		return fmt.Errorf("error connecting to %s: %w", host, ErrForbiddenAddress)
+	}
+	return nil
+}
+
+func forbidden(ip netip.Addr) bool {
+	for _, prefix := range forbiddenPrefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Drain sends the deliveries that are due, a batch at a time, until none are left. It
+// returns the number of events delivered. The deliveries that fail are retried by a later Drain.
+func (w Worker) Drain() (int, error) {
+	delivered := 0
+	for {
+		deliveries, err := w.Store.DueDeliveries(time.Now(), w.BatchSize)
+		if err != nil {
+			return delivered, err
+		}
+
+		for _, d := range deliveries {
+			ok, err := w.deliver(d)
+			if err != nil {
+				return delivered, err
+			}
+			if ok {
+				delivered++
+			}
+		}
+
+		// A delivery that failed is not due again before its backoff, so this ends
+		if len(deliveries) < int(w.BatchSize) {
+			return delivered, nil
+		}
+	}
+}
+
+// Run drains the deliveries every interval until the context is done, for the local server.
+func (w Worker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.Drain(); err != nil {
+			log.Printf("error draining the webhook deliveries: %s\n", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliver sends the delivery, stores the outcome and reports whether it was delivered.
+func (w Worker) deliver(d Delivery) (bool, error) {
+	now := time.Now()
+	claimed, err := w.Store.ClaimDelivery(d, now.Add(w.Lease))
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	hook, exists, err := w.Store.GetWebhook(d.TenantId, d.WebhookId)
+	if err != nil {
+		return false, err
+	}
+
+	d.Attempts++
+	d.LastStatusCode = nil
+	if exists {
+		var statusCode int
+		statusCode, err = w.send(hook, d, now)
+		if statusCode != 0 {
+			d.LastStatusCode = &statusCode
+		}
+	} else {
+		// A delivery saved while its webhook was deleted
+		err = errors.New("the webhook was deleted")
+	}
+
+	switch {
+	case err == nil:
+		d.Status, d.Delivered, d.NextAttempt, d.LastError = model.Delivered, &now, nil, nil
+	case d.Attempts >= w.MaxAttempts || !exists:
+		msg := err.Error()
+		d.Status, d.NextAttempt, d.LastError = model.DeadLettered, nil, &msg
+	default:
+		msg := err.Error()
+		next := now.Add(w.backoff(d.Attempts))
+		d.NextAttempt, d.LastError = &next, &msg
+	}
+	logDelivery(d)
+
+	if err = w.Store.UpdateDelivery(d); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return false, err
+	}
+	return d.Status == model.Delivered, nil
+}
+
+// send POSTs the payload of the delivery to the webhook, signed at now. It returns the
+// status code of the response, and an error unless it is a 2xx.
+func (w Worker) send(hook model.Webhook, d Delivery, now time.Time) (int, error) {
+	body := []byte(d.Payload)
+	req, err := http.NewRequest(http.MethodPost, hook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	secret := ""
+	if hook.Secret != nil {
+		secret = *hook.Secret
+	}
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IdHeader, d.Id)
+	req.Header.Set(EventHeader, d.EventType)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(secret, timestamp, body))
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// The body is read so that the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay before the next attempt after the failed attempts.
+func (w Worker) backoff(attempts int) time.Duration {
+	delay := w.BackoffBase
+	for i := 1; i < attempts && delay < w.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > w.BackoffMax {
+		return w.BackoffMax
+	}
+	return delay
+}
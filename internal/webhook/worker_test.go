@@ -0,0 +1,301 @@
+package webhook
+
+import (
+	"github.com/lfroomin/restaurant-serverless/internal/event"
+	"github.com/lfroomin/restaurant-serverless/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_Drain(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		responseCode   int
+		unreachable    bool
+		deleted        bool
+		claimLost      bool
+		drains         int
+		requests       int
+		status         model.WebhookDeliveryStatus
+		attempts       int
+		lastStatusCode int
+		lastError      string
+	}{
+		{
+			name:           "delivered",
+			responseCode:   http.StatusNoContent,
+			drains:         1,
+			requests:       1,
+			status:         model.Delivered,
+			attempts:       1,
+			lastStatusCode: http.StatusNoContent,
+		},
+		{
+			name:           "error response is retried",
+			responseCode:   http.StatusInternalServerError,
+			drains:         1,
+			requests:       1,
+			status:         model.Pending,
+			attempts:       1,
+			lastStatusCode: http.StatusInternalServerError,
+			lastError:      "unexpected response status 500 Internal Server Error",
+		},
+		{
+			name:           "dead lettered after the last attempt",
+			responseCode:   http.StatusServiceUnavailable,
+			drains:         5,
+			requests:       3,
+			status:         model.DeadLettered,
+			attempts:       3,
+			lastStatusCode: http.StatusServiceUnavailable,
+			lastError:      "unexpected response status 503 Service Unavailable",
+		},
+		{
+			name:           "redirect is not followed",
+			responseCode:   http.StatusFound,
+			drains:         1,
+			requests:       1,
+			status:         model.Pending,
+			attempts:       1,
+			lastStatusCode: http.StatusFound,
+			lastError:      "unexpected response status 302 Found",
+		},
+		{
+			name:        "unreachable",
+			unreachable: true,
+			drains:      1,
+			status:      model.Pending,
+			attempts:    1,
+		},
+		{
+			name:      "webhook deleted",
+			deleted:   true,
+			drains:    1,
+			status:    model.DeadLettered,
+			attempts:  1,
+			lastError: "the webhook was deleted",
+		},
+		{
+			name:      "claimed by another worker",
+			claimLost: true,
+			drains:    1,
+			status:    model.Pending,
+		},
+	}
+
+	for _, tc := range testCases {
+		// scoped variable
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var mu sync.Mutex
+			var requests []*http.Request
+			var bodies [][]byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				requests, bodies = append(requests, r), append(bodies, body)
+				mu.Unlock()
+				if tc.responseCode == http.StatusFound {
+					w.Header().Set("Location", "/elsewhere")
+				}
+				w.WriteHeader(tc.responseCode)
+			}))
+			defer server.Close()
+			if tc.unreachable {
+				server.Close()
+			}
+
+			store := newStoreStub()
+			if !tc.deleted {
+				store.webhooks = []model.Webhook{aWebhook("webhookId", server.URL, string(event.RestaurantCreated))}
+			}
+			store.claimLost = tc.claimLost
+			next := time.Now().Add(-time.Second)
+			d := Delivery{
+				WebhookDelivery: model.WebhookDelivery{Id: "eventId", WebhookId: "webhookId", EventType: "RestaurantCreated", Status: model.Pending, NextAttempt: &next},
+				TenantId:        stubTenantId,
+				Payload:         `{"id":"eventId"}`,
+			}
+			require.NoError(t, store.SaveDelivery(d))
+
+			worker := NewWorker(store)
+			allowLoopback(&worker)
+			worker.MaxAttempts = 3
+			// The deliveries that fail are due again immediately
+			worker.BackoffBase, worker.BackoffMax = 0, 0
+			start := time.Now()
+
+			delivered := 0
+			for i := 0; i < tc.drains; i++ {
+				n, err := worker.Drain()
+				require.NoError(t, err)
+				delivered += n
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			require.Len(t, requests, tc.requests)
+			for i, r := range requests {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				assert.Equal(t, "eventId", r.Header.Get(IdHeader))
+				assert.Equal(t, "RestaurantCreated", r.Header.Get(EventHeader))
+				assert.Equal(t, `{"id":"eventId"}`, string(bodies[i]))
+				assert.NoError(t, Verify("secret of webhookId", r.Header.Get(TimestampHeader), r.Header.Get(SignatureHeader), bodies[i], time.Now(), DefaultTolerance))
+			}
+
+			got := store.deliveries["webhookId/eventId"]
+			assert.Equal(t, tc.status, got.Status)
+			assert.Equal(t, tc.attempts, got.Attempts)
+			if tc.status == model.Delivered {
+				assert.Equal(t, 1, delivered)
+				require.NotNil(t, got.Delivered)
+				assert.WithinDuration(t, start, *got.Delivered, time.Second)
+			} else {
+				assert.Equal(t, 0, delivered)
+				assert.Nil(t, got.Delivered)
+			}
+			if tc.status == model.Pending {
+				assert.NotNil(t, got.NextAttempt)
+			} else {
+				assert.Nil(t, got.NextAttempt)
+			}
+			if tc.lastStatusCode != 0 {
+				require.NotNil(t, got.LastStatusCode)
+				assert.Equal(t, tc.lastStatusCode, *got.LastStatusCode)
+			} else {
+				assert.Nil(t, got.LastStatusCode)
+			}
+			switch {
+			case tc.lastError != "":
+				require.NotNil(t, got.LastError)
+				assert.Equal(t, tc.lastError, *got.LastError)
+			case tc.unreachable:
+				assert.NotNil(t, got.LastError)
+			default:
+				assert.Nil(t, got.LastError)
+			}
+		})
+	}
+}
+
+func Test_DrainRetryBackoff(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	store := newStoreStub(aWebhook("webhookId", server.URL, string(event.RestaurantCreated)))
+	next := time.Now().Add(-time.Second)
+	require.NoError(t, store.SaveDelivery(Delivery{
+		WebhookDelivery: model.WebhookDelivery{Id: "eventId", WebhookId: "webhookId", Status: model.Pending, Attempts: 2, NextAttempt: &next},
+		TenantId:        stubTenantId,
+	}))
+
+	worker := NewWorker(store)
+	allowLoopback(&worker)
+	start := time.Now()
+	_, err := worker.Drain()
+	require.NoError(t, err)
+
+	// The third attempt failed, so the next one is after 4 times the base
+	got := store.deliveries["webhookId/eventId"]
+	assert.Equal(t, 3, got.Attempts)
+	assert.Equal(t, model.Pending, got.Status)
+	assert.WithinDuration(t, start.Add(4*defaultBackoffBase), *got.NextAttempt, time.Second)
+}
+
+func Test_NewWorkerConnectsToPublicAddresses(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	// The test server listens on the loopback address
+	_, err := NewWorker(newStoreStub()).Client.Post(server.URL, "application/json", nil)
+	assert.ErrorIs(t, err, ErrForbiddenAddress)
+
+	testCases := []struct {
+		address string
+		public  bool
+	}{
+		{address: "93.184.216.34:443", public: true},
+		{address: "[2606:2800:220:1:248:1893:25c8:1946]:443", public: true},
+		{address: "127.0.0.1:443"},
+		{address: "[::1]:443"},
+		{address: "10.0.0.1:443"},
+		{address: "172.16.0.1:443"},
+		{address: "192.168.1.1:443"},
+		{address: "[fd00::1]:443"},
+		{address: "169.254.169.254:80"},
+		{address: "[fe80::1]:443"},
+		{address: "0.0.0.0:443"},
+		{address: "224.0.0.1:443"},
+		{address: "100.64.0.1:443"},
+		{address: "0.1.2.3:443"},
+		{address: "198.18.0.1:443"},
+		{address: "192.0.0.170:443"},
+		{address: "255.255.255.255:443"},
+		{address: "[::]:443"},
+		{address: "[::ffff:127.0.0.1]:443"},
+		{address: "[::ffff:169.254.169.254]:80"},
+		{address: "[::ffff:93.184.216.34]:443", public: true},
+		{address: "[::127.0.0.1]:443"},
+		{address: "[64:ff9b::a9fe:a9fe]:80"},
+		{address: "[64:ff9b:1::a00:1]:443"},
+		{address: "[2002:a9fe:a9fe::1]:80"},
+		{address: "[2001::1]:443"},
+		{address: "[2001:db8::1]:443"},
+		{address: "[fe80::1%eth0]:443"},
+		{address: "[ff02::1]:443"},
+		{address: "localhost:443"},
+	}
+
+	for _, tc := range testCases {
+		err := publicAddress("tcp", tc.address, nil)
+		if tc.public {
+			assert.NoError(t, err, tc.address)
+		} else {
+			assert.ErrorIs(t, err, ErrForbiddenAddress, tc.address)
+		}
+	}
+}
+
+func Test_Backoff(t *testing.T) {
+	t.Parallel()
+	worker := Worker{BackoffBase: time.Second, BackoffMax: 10 * time.Second}
+
+	testCases := []struct {
+		attempts int
+		delay    time.Duration
+	}{
+		{attempts: 1, delay: time.Second},
+		{attempts: 2, delay: 2 * time.Second},
+		{attempts: 4, delay: 8 * time.Second},
+		{attempts: 5, delay: 10 * time.Second},
+		{attempts: 100, delay: 10 * time.Second},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.delay, worker.backoff(tc.attempts), "attempts %d", tc.attempts)
+	}
+}
+
+// allowLoopback lets the worker connect to the test servers, which listen on the loopback address.
+func allowLoopback(w *Worker) {
+	w.Client.Transport = http.DefaultTransport
+}